@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/data"
+	"whatsapp-analytics-mvp/internal/leaderboard"
+)
+
+// lapimport — загрузка файлов результатов симуляторов в лидерборд:
+//
+//	go run ./cmd/lapimport -game "Automobilista2" results/*.json
+func main() {
+	dbPath := flag.String("db", "whatsapp_analytics.db", "файл базы SQLite")
+	game := flag.String("game", "", "игра для файлов без поля game (по умолчанию Automobilista2)")
+	date := flag.String("date", "", "дата сессии YYYY-MM-DD, если её нет ни в файле, ни в имени файла")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: lapimport [flags] results.json...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	sessionDate := availability.Now()
+	if *date != "" {
+		t, err := time.Parse("2006-01-02", *date)
+		if err != nil {
+			log.Fatalf("-date: %v", err)
+		}
+		sessionDate = t
+	}
+
+	repo, err := data.NewSQLiteContextRepo(*dbPath)
+	if err != nil {
+		log.Fatalf("DB init failed: %v", err)
+	}
+
+	ctx := context.Background()
+	failed := false
+	for _, path := range flag.Args() {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("❌ %s: %v", path, err)
+			failed = true
+			continue
+		}
+
+		laps, err := leaderboard.Parse(body, leaderboard.Options{Game: *game, Source: path, Date: sessionDate})
+		if err != nil {
+			log.Printf("❌ %s: %v", path, err)
+			failed = true
+			continue
+		}
+		res, err := repo.ImportLaps(ctx, laps)
+		if err != nil {
+			log.Printf("❌ %s: %v", path, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: кругов %d, новых %d (клиентов %d, без привязки %d)\n",
+			path, res.Parsed, res.Inserted, res.Linked, res.Unassigned)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
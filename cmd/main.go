@@ -4,20 +4,28 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"time"
 
 	"whatsapp-analytics-mvp/internal/api"
+	"whatsapp-analytics-mvp/internal/availability"
 	"whatsapp-analytics-mvp/internal/config"
 	"whatsapp-analytics-mvp/internal/core"
 	"whatsapp-analytics-mvp/internal/data"
+	"whatsapp-analytics-mvp/internal/events"
 	"whatsapp-analytics-mvp/internal/infrastructure"
 	"whatsapp-analytics-mvp/internal/llm"
+	"whatsapp-analytics-mvp/internal/models"
+	"whatsapp-analytics-mvp/internal/pricing"
 	"whatsapp-analytics-mvp/internal/weather"
 
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
 )
 
-const geminiModelName = "gemini-1.5-flash" // стабильная модель для tools
+const (
+	openAIModelName = "gpt-4o-mini"      // основной движок
+	geminiModelName = "gemini-1.5-flash" // стабильная модель для tools (fallback)
+)
 
 // ------------------------------
 // MAIN
@@ -31,6 +39,7 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	availability.SetLocation(cfg.ClubLocation)
 
 	// 2) Init SQLite
 	contextManager, err := data.NewSQLiteContextRepo("whatsapp_analytics.db")
@@ -40,7 +49,7 @@ func main() {
 	analyticsRepo := contextManager
 	settingsRepo := contextManager
 
-	// 3) Init Gemini client (используем только для инструментов)
+	// 3) Init Gemini client (fallback движка)
 	geminiClient, err := genai.NewClient(ctx, option.WithAPIKey(cfg.API.GeminiAPIKey))
 	if err != nil {
 		log.Fatalf("Gemini client init failed: %v", err)
@@ -48,8 +57,10 @@ func main() {
 
 	// 4) Init Hybrid LLM Engine (OpenAI → fallback Gemini)
 	llmEngine := llm.NewLLMEngine(
+		cfg.API.OpenAIKey,
+		openAIModelName,
 		geminiClient,
-		cfg.API.OpenAIKey, // добавь в config.yaml поле openai_key
+		geminiModelName,
 	)
 
 	// 5) Init Weather
@@ -59,20 +70,105 @@ func main() {
 		cfg.Location.AstanaLon,
 	)
 
-	// 6) Init Tools Provider
-	toolsProvider := infrastructure.NewToolsService(contextManager.DB, weatherClient)
+	// 6) Init Tools Provider (тариф: БД → конфиг → по умолчанию)
+	tariff := pricing.Default()
+	if cfg.Pricing != nil {
+		tariff = *cfg.Pricing
+	}
+	if saved, err := contextManager.GetTariff(ctx); err != nil {
+		log.Printf("[Pricing] ⚠️ тариф из БД не прочитан: %v", err)
+	} else if saved != nil {
+		tariff = *saved
+	}
+	toolsProvider := infrastructure.NewToolsService(contextManager, tariff)
+	if cfg.Booking.FreeCancelHours > 0 {
+		toolsProvider.FreeCancelHours = cfg.Booking.FreeCancelHours
+	}
 
 	// 7) Init Senders, Notifiers, Events, Tasks
 	telegramSender := infrastructure.NewTelegramSender(cfg.API.TelegramToken)
+	if cfg.API.TelegramBaseURL != "" {
+		telegramSender.BaseURL = cfg.API.TelegramBaseURL
+	}
+	telegramSender.ParseMode = cfg.API.TelegramParseMode
 	wazzupSender := infrastructure.NewWazzupSender(cfg.API.WazzupAPIKey)
-	notifier := infrastructure.NewDefaultNotifier()
-	eventBus := &infrastructure.MockEventBus{}
-	taskManager := &infrastructure.MockTaskManager{}
+	if cfg.API.WazzupBaseURL != "" {
+		wazzupSender.BaseURL = cfg.API.WazzupBaseURL
+	}
+	wazzupSender.Store = contextManager
+	// Уведомления администратору — в его Telegram (оттуда же /reply);
+	// без токена — только в лог
+	adminChatID := api.ADMIN_TELEGRAM_ID
+	if cfg.Telegram.AdminChatID != 0 {
+		adminChatID = cfg.Telegram.AdminChatID
+	}
+	var notifier core.NotificationProvider = infrastructure.NewDefaultNotifier()
+	if cfg.API.TelegramToken != "" {
+		notifier = infrastructure.NewTelegramNotifier(telegramSender, adminChatID)
+	}
+	eventBus := infrastructure.NewMemoryEventBus()
+	taskManager := infrastructure.NewDBTaskManager(contextManager)
+	if cfg.Tasks.MaxAttempts > 0 {
+		taskManager.MaxAttempts = cfg.Tasks.MaxAttempts
+	}
+	messenger := infrastructure.NewChannelRouter(telegramSender, wazzupSender, cfg.API.WazzupChannelID)
+
+	// 7.0) Лист ожидания: освободившиеся места → предложение следующему
+	toolsProvider.Messenger = messenger
+	if cfg.Booking.WaitlistOfferTTL > 0 {
+		toolsProvider.WaitlistOfferTTL = cfg.Booking.WaitlistOfferTTL
+	}
+	eventBus.SubscribeAsync(events.NameBookingCancelled, toolsProvider.OnSeatsFreed)
+	eventBus.SubscribeAsync(events.NameBookingRescheduled, toolsProvider.OnSeatsFreed)
+	go toolsProvider.RunWaitlist(ctx, time.Minute)
+
+	// 7.1) Payments: счета, уведомления провайдера, снятие неоплаченных броней
+	var payments *core.PaymentService
+	if cfg.Payments.Provider == "fake" {
+		payments = core.NewPaymentService(
+			contextManager,
+			messenger,
+			notifier,
+			cfg.Payments.HoldTimeout,
+			infrastructure.NewFakePaymentProvider(cfg.Payments.Secret, cfg.Payments.BaseURL),
+		)
+		toolsProvider.Payments = payments
+	}
+
+	// 7.2) Задания по броням: напоминание, отзыв, снятие неоплаченных холдов
+	reminders := core.NewBookingReminders(taskManager, contextManager, messenger, payments)
+	if cfg.Booking.ReminderBefore > 0 {
+		reminders.RemindBefore = cfg.Booking.ReminderBefore
+	}
+	if cfg.Booking.FollowUpAfter > 0 {
+		reminders.FollowUpAfter = cfg.Booking.FollowUpAfter
+	}
+	reminders.Subscribe(eventBus)
+	if n, err := reminders.Backfill(ctx, contextManager, 60*24*time.Hour); err != nil {
+		log.Printf("[Reminders] ⚠️ backfill: %v", err)
+	} else if n > 0 {
+		log.Printf("[Reminders] synced %d upcoming bookings", n)
+	}
+
+	// 7.3) События: аналитика диалогов, уведомления админу
+	(&core.DialogAnalytics{Repo: analyticsRepo}).Subscribe(eventBus)
+	(&core.AdminAlerts{Notifier: notifier, History: contextManager}).Subscribe(eventBus)
+
+	// 7.4) Исходящие вебхуки (бухгалтерия, CRM)
+	webhooks := infrastructure.NewWebhookDispatcher(contextManager, cfg.Webhooks.Subscribers)
+	if cfg.Webhooks.MaxAttempts > 0 {
+		webhooks.MaxAttempts = cfg.Webhooks.MaxAttempts
+	}
+	webhooks.Subscribe(eventBus)
+	webhookEvery := cfg.Webhooks.PollInterval
+	if webhookEvery <= 0 {
+		webhookEvery = 5 * time.Second
+	}
+	go webhooks.Run(ctx, webhookEvery)
 
 	// 8) Init AIService (теперь с гибридным движком)
 	aiService := core.NewAIService(
 		llmEngine,
-		geminiModelName,
 		analyticsRepo,
 		settingsRepo,
 		nil, // transcriber добавите позже
@@ -82,8 +178,36 @@ func main() {
 		taskManager,
 		toolsProvider,
 		weatherClient,
-		geminiClient,
 	)
+	aiService.Payments = payments
+	aiService.Messenger = messenger
+	if cfg.Handoff.IdleTimeout > 0 {
+		aiService.HandoffIdle = cfg.Handoff.IdleTimeout
+	}
+	if cfg.Handoff.Keywords != nil {
+		aiService.HandoffKeywords = cfg.Handoff.Keywords
+	}
+	if h := cfg.LLM.History; h.MaxMessages != 0 || h.MaxTokens != 0 || h.MaxAge != 0 {
+		aiService.HistoryWindow = llm.HistoryWindow{
+			MaxMessages: h.MaxMessages,
+			MaxTokens:   h.MaxTokens,
+			MaxAge:      h.MaxAge,
+		}
+	}
+
+	// 8.1) Фоновые циклы — после того, как все обработчики заданий
+	// (включая AIService) и подписчики шины зарегистрированы: иначе
+	// созревшее задание или событие outbox уйдёт в пустоту.
+	pollEvery := cfg.Tasks.PollInterval
+	if pollEvery <= 0 {
+		pollEvery = 15 * time.Second
+	}
+	go taskManager.Run(ctx, pollEvery)
+	relayEvery := cfg.Events.PollInterval
+	if relayEvery <= 0 {
+		relayEvery = time.Second
+	}
+	go infrastructure.NewOutboxRelay(contextManager, eventBus).Run(ctx, relayEvery)
 
 	// 9) API router
 	apiHandler := api.NewAPIHandler(
@@ -92,9 +216,50 @@ func main() {
 		telegramSender,
 		nil, // transcriber (если пока нет)
 	)
+	apiHandler.Payments = payments
+	apiHandler.Messenger = messenger
+	apiHandler.AdminToken = cfg.App.AdminToken
+	apiHandler.Laps = contextManager
+	apiHandler.Webhooks = contextManager
+	apiHandler.Outbound = contextManager
+
+	apiHandler.TelegramSecret = cfg.Telegram.WebhookSecret
+	apiHandler.AdminChatID = adminChatID
+
+	// 9.0) Входящие: вебхуки и polling сохраняют события в очередь, пул
+	// воркеров отвечает (события одного клиента — по очереди)
+	inbound := infrastructure.NewInboundQueue(contextManager)
+	if cfg.Inbound.Workers > 0 {
+		inbound.Workers = cfg.Inbound.Workers
+	}
+	if cfg.Inbound.MaxAttempts > 0 {
+		inbound.MaxAttempts = cfg.Inbound.MaxAttempts
+	}
+	for source, d := range cfg.Inbound.Debounce {
+		inbound.Debounce[source] = d
+	}
+	inbound.Handle(models.InboundTelegram, apiHandler.ProcessTelegramUpdates)
+	inbound.Handle(models.InboundWazzup, apiHandler.ProcessWazzupMessages)
+	apiHandler.Inbound = inbound
+	go inbound.Run(ctx)
 
 	router := api.SetupRouter(apiHandler)
 
+	// 9.1) Telegram: регистрация вебхука или long polling
+	if cfg.API.TelegramToken != "" {
+		if err := infrastructure.SetupTelegramDelivery(ctx, telegramSender, cfg.Telegram.Mode,
+			cfg.Telegram.WebhookURL, cfg.Telegram.WebhookSecret, cfg.Telegram.DropPending); err != nil {
+			log.Printf("[Telegram] ⚠️ setup: %v", err)
+		}
+		if cfg.Telegram.Mode == infrastructure.TelegramModePolling {
+			poller := infrastructure.NewTelegramPoller(telegramSender, contextManager, apiHandler.HandleTelegramUpdate)
+			if cfg.Telegram.PollTimeout > 0 {
+				poller.Timeout = cfg.Telegram.PollTimeout
+			}
+			go poller.Run(ctx)
+		}
+	}
+
 	// 10) Start HTTP Server
 	log.Printf("Server running on %s...", cfg.App.Port)
 	if err := http.ListenAndServe(cfg.App.Port, router); err != nil {
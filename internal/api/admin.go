@@ -0,0 +1,226 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/leaderboard"
+	"whatsapp-analytics-mvp/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ==========================================================
+// ADMIN API (служебные эндпоинты под Bearer-токеном)
+// ==========================================================
+
+func (h *APIHandler) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.AdminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.AdminToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// ==========================================================
+// LAP RESULTS IMPORT
+// ==========================================================
+
+// HandleLapImport — тело запроса: файл результатов симулятора (JSON).
+// Query: source — имя файла (из него AC берёт дату сессии), game и date
+// (YYYY-MM-DD) — если их нет в файле.
+func (h *APIHandler) HandleLapImport(w http.ResponseWriter, r *http.Request) {
+	if h.Laps == nil {
+		http.Error(w, "leaderboard disabled", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 10<<20))
+	if err != nil {
+		http.Error(w, "bad body", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	opts := leaderboard.Options{
+		Game:   q.Get("game"),
+		Source: q.Get("source"),
+		Date:   availability.Now(),
+	}
+	if d := q.Get("date"); d != "" {
+		if opts.Date, err = time.Parse("2006-01-02", d); err != nil {
+			http.Error(w, "date: YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	laps, err := leaderboard.Parse(body, opts)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, leaderboard.ErrUnknownFormat) {
+			status = http.StatusUnprocessableEntity
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	res, err := h.Laps.ImportLaps(r.Context(), laps)
+	if err != nil {
+		log.Printf("❌ Lap import %s: %v", opts.Source, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("🏁 Lap import %s: parsed=%d inserted=%d unassigned=%d", opts.Source, res.Parsed, res.Inserted, res.Unassigned)
+	writeJSON(w, http.StatusOK, res)
+}
+
+// ==========================================================
+// OUTBOUND WEBHOOKS
+// ==========================================================
+
+// HandleWebhookDeliveries — журнал доставок, новые первыми. Query: status
+// (pending | delivered | dead), subscriber, event, limit.
+func (h *APIHandler) HandleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if h.Webhooks == nil {
+		http.Error(w, "webhooks disabled", http.StatusNotFound)
+		return
+	}
+
+	q := r.URL.Query()
+	f := models.WebhookFilter{
+		Subscriber: q.Get("subscriber"),
+		Event:      q.Get("event"),
+		Status:     q.Get("status"),
+	}
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit: positive number", http.StatusBadRequest)
+			return
+		}
+		f.Limit = n
+	}
+
+	list, err := h.Webhooks.ListWebhookDeliveries(r.Context(), f)
+	if err != nil {
+		log.Printf("❌ Webhook deliveries: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []models.WebhookDelivery{}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (h *APIHandler) HandleWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	if h.Webhooks == nil {
+		http.Error(w, "webhooks disabled", http.StatusNotFound)
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "bad id", http.StatusBadRequest)
+		return
+	}
+
+	d, err := h.Webhooks.GetWebhookDelivery(r.Context(), id)
+	if err != nil {
+		log.Printf("❌ Webhook delivery %d: %v", id, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if d == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+// HandleWebhookReplay — вернуть dead-доставки в очередь: одну
+// (/deliveries/{id}/replay) или все, с query subscriber — одного получателя.
+func (h *APIHandler) HandleWebhookReplay(w http.ResponseWriter, r *http.Request) {
+	if h.Webhooks == nil {
+		http.Error(w, "webhooks disabled", http.StatusNotFound)
+		return
+	}
+
+	var id int64
+	if p := chi.URLParam(r, "id"); p != "" {
+		var err error
+		if id, err = strconv.ParseInt(p, 10, 64); err != nil {
+			http.Error(w, "bad id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	n, err := h.Webhooks.ReplayWebhooks(r.Context(), id, r.URL.Query().Get("subscriber"))
+	if err != nil {
+		log.Printf("❌ Webhook replay: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if id > 0 && n == 0 {
+		http.Error(w, "delivery not found or not dead", http.StatusConflict)
+		return
+	}
+	log.Printf("🔁 Webhook replay: %d deliveries requeued", n)
+	writeJSON(w, http.StatusOK, map[string]int{"replayed": n})
+}
+
+// ==========================================================
+// WHATSAPP DELIVERY (ответы бота, не дошедшие до клиента)
+// ==========================================================
+
+// HandleUndeliveredMessages — исходящие WhatsApp за последние hours часов
+// (по умолчанию 24) с ошибкой доставки или без подтверждения дольше часа.
+func (h *APIHandler) HandleUndeliveredMessages(w http.ResponseWriter, r *http.Request) {
+	if h.Outbound == nil {
+		http.Error(w, "outbound log disabled", http.StatusNotFound)
+		return
+	}
+
+	hours := 24
+	if v := r.URL.Query().Get("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "hours: positive number", http.StatusBadRequest)
+			return
+		}
+		hours = n
+	}
+
+	now := availability.Now()
+	list, err := h.Outbound.ListUndeliveredMessages(r.Context(), now.Add(-time.Duration(hours)*time.Hour),
+		now.Add(-models.OutboundStaleAfter), 200)
+	if err != nil {
+		log.Printf("❌ Undelivered messages: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []models.OutboundMessage{}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
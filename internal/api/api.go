@@ -1,14 +1,21 @@
 package api
 
 import (
-	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"whatsapp-analytics-mvp/internal/core"
+	"whatsapp-analytics-mvp/internal/models"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -20,16 +27,48 @@ const ADMIN_TELEGRAM_ID int64 = 779270468
 // STRUCTS
 // ==========================================================
 
-// WhatsApp (Wazzup) webhook format
+// WhatsApp (Wazzup) webhook format (API v3): входящие messages и статусы
+// наших исходящих statuses приходят разными запросами. Top-level channelId,
+// direction и audioUrl — старый формат, поддерживается для совместимости.
 type WazzupWebhook struct {
-	ChannelID string `json:"channelId"`
-	Messages  []struct {
-		Text      string `json:"text"`
-		ChatID    string `json:"chatID"`
-		Direction string `json:"direction"`
-		Type      string `json:"type"`
-		AudioURL  string `json:"audioUrl,omitempty"`
-	} `json:"messages"`
+	Test      bool            `json:"test,omitempty"` // проверка адреса при подключении вебхука
+	ChannelID string          `json:"channelId"`
+	Messages  []WazzupMessage `json:"messages"`
+	Statuses  []WazzupStatus  `json:"statuses"`
+}
+
+// WazzupMessage — одно сообщение вебхука messages.
+type WazzupMessage struct {
+	MessageID  string `json:"messageId"`
+	ChannelID  string `json:"channelId"`
+	ChatType   string `json:"chatType"`
+	Text       string `json:"text"`
+	ChatID     string `json:"chatId"`
+	Type       string `json:"type"`
+	Status     string `json:"status"` // inbound — от клиента
+	IsEcho     bool   `json:"isEcho"` // отправлено не через API (с телефона, из Wazzup)
+	ContentURI string `json:"contentUri,omitempty"`
+	Direction  string `json:"direction,omitempty"`
+	AudioURL   string `json:"audioUrl,omitempty"`
+	DateTime   string `json:"dateTime,omitempty"`
+}
+
+// contentKey — ключ дедупликации для сообщений без messageId (старый
+// формат): повторная доставка того же сообщения даёт тот же ключ.
+func (m WazzupMessage) contentKey() string {
+	sum := sha256.Sum256([]byte(m.ChatID + "\x00" + m.DateTime + "\x00" + m.Text + "\x00" + m.ContentURI))
+	return "sha256-" + hex.EncodeToString(sum[:16])
+}
+
+// WazzupStatus — статус исходящего сообщения: sent, delivered, read, error.
+type WazzupStatus struct {
+	MessageID string `json:"messageId"`
+	Timestamp string `json:"timestamp"`
+	Status    string `json:"status"`
+	Error     *struct {
+		Error       string `json:"error"`
+		Description string `json:"description"`
+	} `json:"error,omitempty"`
 }
 
 // Telegram update
@@ -50,6 +89,31 @@ type TelegramUpdate struct {
 			FileID string `json:"file_id"`
 		} `json:"voice,omitempty"`
 	} `json:"message,omitempty"`
+
+	// Нажатие inline-кнопки
+	CallbackQuery *TelegramCallbackQuery `json:"callback_query,omitempty"`
+}
+
+// TelegramCallbackQuery — нажатие кнопки под сообщением бота.
+type TelegramCallbackQuery struct {
+	ID   string `json:"id"`
+	From struct {
+		ID int64 `json:"id"`
+	} `json:"from"`
+	Message *struct {
+		MessageID int64 `json:"message_id"`
+		Chat      struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+		Text        string `json:"text"`
+		ReplyMarkup *struct {
+			InlineKeyboard [][]struct {
+				Text         string `json:"text"`
+				CallbackData string `json:"callback_data"`
+			} `json:"inline_keyboard"`
+		} `json:"reply_markup,omitempty"`
+	} `json:"message,omitempty"`
+	Data string `json:"data"`
 }
 
 // ==========================================================
@@ -61,6 +125,29 @@ type APIHandler struct {
 	WazzupSender   core.WhatsAppSender
 	TelegramSender core.TelegramSender
 	Transcriber    core.TranscriptionProvider
+
+	// Payments — уведомления платёжных провайдеров; nil — маршрут отвечает 404.
+	Payments *core.PaymentService
+	// Messenger — ответы клиенту вне диалога (оплата, снятие холда).
+	Messenger core.ClientMessenger
+
+	// TelegramSecret — секрет вебхука Telegram; задан — запросы без него
+	// отклоняются.
+	TelegramSecret string
+	// AdminChatID — Telegram администратора (личный ID или чат): его
+	// сообщения идут ассистенту владельца и командам оператора.
+	AdminChatID int64
+
+	// AdminToken — Bearer-токен служебного API (/api/...); пусто — API выключен.
+	AdminToken string
+	// Laps — приём файлов результатов для лидерборда.
+	Laps core.LapRepository
+	// Webhooks — журнал исходящих вебхуков и повтор dead-доставок.
+	Webhooks core.WebhookRepository
+	// Outbound — статусы доставки сообщений WhatsApp (вебхук statuses Wazzup).
+	Outbound core.OutboundMessageRepository
+	// Inbound — очередь входящих сообщений; nil — обработка прямо в вебхуке.
+	Inbound core.InboundQueue
 }
 
 func NewAPIHandler(
@@ -74,6 +161,7 @@ func NewAPIHandler(
 		WazzupSender:   wazzupSender,
 		TelegramSender: telegramSender,
 		Transcriber:    transcriber,
+		AdminChatID:    ADMIN_TELEGRAM_ID,
 	}
 }
 
@@ -90,6 +178,17 @@ func SetupRouter(h *APIHandler) *chi.Mux {
 
 	r.Post("/webhook/wazzup", h.HandleWazzupWebhook)
 	r.Post("/webhook/telegram", h.HandleTelegramWebhook)
+	r.Post("/webhook/payments/{provider}", h.HandlePaymentWebhook)
+
+	r.Route("/api", func(r chi.Router) {
+		r.Use(h.requireAdmin)
+		r.Post("/laps/import", h.HandleLapImport)
+		r.Get("/webhooks/deliveries", h.HandleWebhookDeliveries)
+		r.Get("/webhooks/deliveries/{id}", h.HandleWebhookDelivery)
+		r.Post("/webhooks/deliveries/{id}/replay", h.HandleWebhookReplay)
+		r.Post("/webhooks/replay", h.HandleWebhookReplay)
+		r.Get("/messages/undelivered", h.HandleUndeliveredMessages)
+	})
 
 	return r
 }
@@ -98,56 +197,212 @@ func SetupRouter(h *APIHandler) *chi.Mux {
 // TELEGRAM HANDLER
 // ==========================================================
 
+// TelegramSecretHeader — секрет вебхука, заданный в setWebhook.
+const TelegramSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
 func (h *APIHandler) HandleTelegramWebhook(w http.ResponseWriter, r *http.Request) {
-	body, _ := io.ReadAll(r.Body)
-	r.Body = io.NopCloser(bytes.NewBuffer(body))
+	if h.TelegramSecret != "" &&
+		subtle.ConstantTimeCompare([]byte(r.Header.Get(TelegramSecretHeader)), []byte(h.TelegramSecret)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "bad body", http.StatusBadRequest)
+		return
+	}
+
+	// Не удалось принять — 500, Telegram повторит доставку
+	if err := h.HandleTelegramUpdate(r.Context(), body); err != nil {
+		log.Printf("❌ Telegram enqueue: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
 
-	log.Printf("📩 TG RAW: %s\n", string(body))
+// HandleTelegramUpdate — приём одного обновления Telegram; общий для
+// вебхука и long polling. С очередью обновление сохраняется (повтор того же
+// update_id отбрасывается) и обрабатывается воркером, без неё — в фоне.
+func (h *APIHandler) HandleTelegramUpdate(ctx context.Context, body []byte) error {
+	if h.Inbound == nil {
+		go func() {
+			if err := h.ProcessTelegramUpdates(context.WithoutCancel(ctx), [][]byte{body}, true); err != nil {
+				log.Printf("❌ Telegram update: %v", err)
+			}
+		}()
+		return nil
+	}
 
 	var upd TelegramUpdate
-	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+	if err := json.Unmarshal(body, &upd); err != nil {
 		log.Printf("❌ Telegram decode error: %v", err)
-		w.WriteHeader(http.StatusOK)
-		return
+		return nil // повтор не поможет
+	}
+	var chatID int64
+	switch {
+	case upd.Message != nil:
+		chatID = upd.Message.Chat.ID
+	case upd.CallbackQuery != nil && upd.CallbackQuery.Message != nil:
+		chatID = upd.CallbackQuery.Message.Chat.ID
+	default:
+		return nil
 	}
 
-	if upd.Message == nil {
-		w.WriteHeader(http.StatusOK)
-		return
+	_, err := h.Inbound.Enqueue(ctx, models.InboundEvent{
+		Source:     models.InboundTelegram,
+		ExternalID: strconv.FormatInt(upd.UpdateID, 10),
+		ClientID:   fmt.Sprintf("TG-%d", chatID),
+		Payload:    string(body),
+	})
+	return err
+}
+
+// ProcessTelegramUpdates — ответ на обновления одного чата по порядку
+// (обработчик очереди). Подряд идущие сообщения клиента — один ход
+// диалога и один ответ, нажатия кнопок — по одному. Сбой обработки
+// возвращается очереди на повтор; «Ошибка сервера» клиент получает, только
+// когда попытки кончились (final).
+func (h *APIHandler) ProcessTelegramUpdates(ctx context.Context, payloads [][]byte, final bool) error {
+	var turn []TelegramUpdate
+	flush := func() error {
+		if len(turn) == 0 {
+			return nil
+		}
+		err := h.answerTelegramMessages(ctx, turn, final)
+		turn = nil
+		return err
+	}
+
+	for _, body := range payloads {
+		var upd TelegramUpdate
+		if err := json.Unmarshal(body, &upd); err != nil {
+			log.Printf("❌ Telegram decode error: %v", err)
+			continue // повтор не поможет
+		}
+		switch {
+		case upd.CallbackQuery != nil:
+			if err := flush(); err != nil {
+				return err
+			}
+			if err := h.handleTelegramCallback(ctx, upd.CallbackQuery, final); err != nil {
+				return err
+			}
+		case upd.Message != nil:
+			turn = append(turn, upd)
+		}
 	}
+	return flush()
+}
 
-	chatID := upd.Message.Chat.ID
+// serverErrorReply — ответ клиенту, когда обработать сообщение так и не вышло.
+const serverErrorReply = "Ошибка сервера. Попробуйте позже."
+
+// answerTelegramMessages — один ответ на сообщения клиента, пришедшие подряд.
+func (h *APIHandler) answerTelegramMessages(ctx context.Context, turn []TelegramUpdate, final bool) error {
+	first := turn[0].Message
+	chatID := first.Chat.ID
 	clientID := fmt.Sprintf("TG-%d", chatID)
-	userMessage := upd.Message.Text
 
-	// Voice message
-	if upd.Message.Voice != nil && upd.Message.Voice.FileID != "" {
-		log.Println("🎤 TG voice message detected")
+	var parts []string
+	for _, upd := range turn {
+		text := upd.Message.Text
+
+		// Voice message
+		if upd.Message.Voice != nil && upd.Message.Voice.FileID != "" && h.Transcriber != nil {
+			log.Println("🎤 TG voice message detected")
 
-		fileURL, err := h.TelegramSender.GetFileDirectURL(upd.Message.Voice.FileID)
-		if err == nil {
-			text, err := h.Transcriber.Transcribe(fileURL)
+			fileURL, err := h.TelegramSender.GetFileDirectURL(upd.Message.Voice.FileID)
 			if err == nil {
-				userMessage = text
+				if t, err := h.Transcriber.Transcribe(fileURL); err == nil {
+					text = t
+				}
 			}
 		}
+		if text = strings.TrimSpace(text); text != "" {
+			parts = append(parts, text)
+		}
 	}
+	userMessage := strings.Join(parts, "\n")
 
-	isAdmin := upd.Message.From != nil && upd.Message.From.ID == ADMIN_TELEGRAM_ID
+	isAdmin := chatID == h.AdminChatID || (first.From != nil && first.From.ID == h.AdminChatID)
 
-	// Answer immediately, process async
-	w.WriteHeader(http.StatusOK)
+	_ = h.TelegramSender.SendTyping(chatID)
 
-	go func() {
-		_ = h.TelegramSender.SendTyping(chatID)
+	reply, err := h.Service.ProcessMessage(ctx, clientID, userMessage, isAdmin)
+	if err != nil {
+		if !final {
+			return err
+		}
+		if !isAdmin {
+			h.Service.EscalateFailure(ctx, clientID, err)
+		}
+		reply = serverErrorReply
+	}
+	if reply == "" {
+		return nil // диалог у оператора
+	}
 
-		reply, err := h.Service.ProcessMessage(clientID, userMessage, isAdmin)
-		if err != nil {
-			reply = "Ошибка сервера. Попробуйте позже."
+	// Варианты следующего шага брони — кнопками
+	var kb models.Keyboard
+	if !isAdmin && err == nil {
+		kb = h.Service.BookingKeyboard(ctx, clientID, userMessage, reply)
+	}
+	if sendErr := h.TelegramSender.SendKeyboard(chatID, reply, kb); sendErr != nil {
+		log.Printf("❌ Telegram send error: %v", sendErr)
+	}
+	return err
+}
+
+// handleTelegramCallback — нажатие кнопки: ответить Telegram, в исходном
+// сообщении заменить кнопки выбранным вариантом, следующий шаг — новым
+// сообщением.
+func (h *APIHandler) handleTelegramCallback(ctx context.Context, cq *TelegramCallbackQuery, final bool) error {
+	if cq.Message == nil || !core.IsBookingChoice(cq.Data) {
+		_ = h.TelegramSender.AnswerCallback(cq.ID, "Кнопка устарела")
+		return nil
+	}
+	chatID := cq.Message.Chat.ID
+	clientID := fmt.Sprintf("TG-%d", chatID)
+	log.Printf("🔘 TG callback %s: %s", clientID, cq.Data)
+
+	if err := h.TelegramSender.AnswerCallback(cq.ID, ""); err != nil {
+		log.Printf("❌ Telegram answerCallbackQuery: %v", err)
+	}
+
+	chosen := pressedButton(cq)
+	reply, err := h.Service.HandleBookingChoice(ctx, clientID, cq.Data, chosen)
+	if err != nil {
+		if !final {
+			return fmt.Errorf("booking choice: %w", err)
 		}
+		log.Printf("❌ Booking choice %s: %v", clientID, err)
+		reply = models.BotReply{Text: serverErrorReply}
+	}
 
-		_ = h.TelegramSender.Send(chatID, reply)
-	}()
+	if err := h.TelegramSender.EditMessage(chatID, cq.Message.MessageID, cq.Message.Text+"\n\n→ "+chosen, nil); err != nil {
+		log.Printf("❌ Telegram editMessageText: %v", err)
+	}
+	if reply.Text == "" {
+		return nil // диалог у оператора: нажатие переслано ему
+	}
+	_ = h.TelegramSender.SendKeyboard(chatID, reply.Text, reply.Keyboard)
+	return err
+}
+
+// pressedButton — подпись нажатой кнопки из разметки исходного сообщения.
+func pressedButton(cq *TelegramCallbackQuery) string {
+	if cq.Message.ReplyMarkup != nil {
+		for _, row := range cq.Message.ReplyMarkup.InlineKeyboard {
+			for _, b := range row {
+				if b.CallbackData == cq.Data {
+					return b.Text
+				}
+			}
+		}
+	}
+	return cq.Data
 }
 
 // ==========================================================
@@ -163,32 +418,176 @@ func (h *APIHandler) HandleWazzupWebhook(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	h.applyWazzupStatuses(r.Context(), wh.Statuses)
+
 	for _, m := range wh.Messages {
-		if m.Direction != "inbound" {
+		if m.IsEcho || (m.Status != "inbound" && m.Direction != "inbound") {
+			continue
+		}
+		if m.ChannelID == "" {
+			m.ChannelID = wh.ChannelID
+		}
+		if m.ContentURI == "" {
+			m.ContentURI = m.AudioURL
+		}
+
+		if h.Inbound == nil {
+			if err := h.answerWazzupMessages(r.Context(), []WazzupMessage{m}, true); err != nil {
+				log.Printf("❌ Wazzup message: %v", err)
+			}
 			continue
 		}
 
-		clientID := "WA-" + m.ChatID
-		userMessage := m.Text
+		// Не удалось принять — 500, Wazzup повторит; уже принятые отсеются
+		if err := h.enqueueWazzupMessage(r.Context(), m); err != nil {
+			log.Printf("❌ Wazzup enqueue: %v", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
 
-		if m.Type == "audio" && m.AudioURL != "" {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *APIHandler) enqueueWazzupMessage(ctx context.Context, m WazzupMessage) error {
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	externalID := m.MessageID
+	if externalID == "" {
+		externalID = m.contentKey()
+	}
+	_, err = h.Inbound.Enqueue(ctx, models.InboundEvent{
+		Source:     models.InboundWazzup,
+		ExternalID: externalID,
+		ClientID:   "WA-" + m.ChatID,
+		Payload:    string(payload),
+	})
+	return err
+}
+
+// ProcessWazzupMessages — один ответ на сообщения WhatsApp, пришедшие
+// подряд от одного клиента (обработчик очереди). Сбой — повтор очередью,
+// «Ошибка сервера» — только после последней попытки (final).
+func (h *APIHandler) ProcessWazzupMessages(ctx context.Context, payloads [][]byte, final bool) error {
+	var turn []WazzupMessage
+	for _, p := range payloads {
+		var m WazzupMessage
+		if err := json.Unmarshal(p, &m); err != nil {
+			log.Printf("❌ Wazzup decode error: %v", err)
+			continue // повтор не поможет
+		}
+		turn = append(turn, m)
+	}
+	if len(turn) == 0 {
+		return nil
+	}
+	return h.answerWazzupMessages(ctx, turn, final)
+}
+
+func (h *APIHandler) answerWazzupMessages(ctx context.Context, turn []WazzupMessage, final bool) error {
+	last := turn[len(turn)-1]
+	clientID := "WA-" + last.ChatID
+	h.rememberWazzupChannel(last.ChatID, last.ChannelID)
+
+	var parts []string
+	for _, m := range turn {
+		text := m.Text
+		if m.Type == "audio" && m.ContentURI != "" && h.Transcriber != nil {
 			log.Println("🎤 WA audio message detected")
 
-			text, err := h.Transcriber.Transcribe(m.AudioURL)
-			if err == nil {
-				userMessage = text
+			if t, err := h.Transcriber.Transcribe(m.ContentURI); err == nil {
+				text = t
 			}
 		}
+		if text = strings.TrimSpace(text); text != "" {
+			parts = append(parts, text)
+		}
+	}
 
-		reply, err := h.Service.ProcessMessage(clientID, userMessage, false)
-		if err != nil {
-			reply = "Ошибка сервера. Попробуйте позже."
+	reply, err := h.Service.ProcessMessage(ctx, clientID, strings.Join(parts, "\n"), false)
+	if err != nil {
+		if !final {
+			return err
 		}
+		h.Service.EscalateFailure(ctx, clientID, err)
+		reply = serverErrorReply
+	}
+	if reply == "" {
+		return nil // диалог у оператора
+	}
+
+	if sendErr := h.WazzupSender.Send(last.ChannelID, last.ChatID, reply); sendErr != nil {
+		log.Printf("❌ Wazzup send error: %v", sendErr)
+	}
+	return err
+}
 
-		if err := h.WazzupSender.Send(wh.ChannelID, m.ChatID, reply); err != nil {
-			log.Printf("❌ Wazzup send error: %v", err)
+// applyWazzupStatuses — статусы доставки наших сообщений в outbound_messages.
+func (h *APIHandler) applyWazzupStatuses(ctx context.Context, statuses []WazzupStatus) {
+	if h.Outbound == nil {
+		return
+	}
+	for _, st := range statuses {
+		errText := ""
+		if st.Error != nil {
+			errText = strings.TrimSpace(st.Error.Error + ": " + st.Error.Description)
+			errText = strings.Trim(errText, ": ")
+		}
+		updated, err := h.Outbound.UpdateOutboundStatus(ctx, st.MessageID, st.Status, errText)
+		if err != nil {
+			log.Printf("❌ Wazzup status %s %s: %v", st.MessageID, st.Status, err)
+			continue
+		}
+		if !updated {
+			log.Printf("[Wazzup] status %s %s not applied (stale or message not sent yet)", st.MessageID, st.Status)
+		}
+		if st.Status == models.OutboundError {
+			log.Printf("⚠️ Wazzup message %s not delivered: %s", st.MessageID, errText)
 		}
 	}
+}
 
-	w.WriteHeader(http.StatusOK)
+// rememberWazzupChannel — чтобы сообщения вне диалога (об оплате и т.п.)
+// ушли в тот же канал Wazzup, откуда писал клиент.
+func (h *APIHandler) rememberWazzupChannel(chatID, channelID string) {
+	if r, ok := h.Messenger.(interface {
+		RememberWazzupChannel(chatID, channelID string)
+	}); ok {
+		r.RememberWazzupChannel(chatID, channelID)
+	}
+}
+
+// ==========================================================
+// PAYMENT PROVIDER CALLBACKS
+// ==========================================================
+
+func (h *APIHandler) HandlePaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	provider := chi.URLParam(r, "provider")
+	if h.Payments == nil {
+		http.Error(w, "payments disabled", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "bad body", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Payments.HandleCallback(r.Context(), provider, r.Header, body)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusOK)
+	case errors.Is(err, core.ErrUnknownProvider):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, core.ErrBadSignature):
+		log.Printf("❌ Payment callback %s: bad signature", provider)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	default:
+		// 5xx — провайдер повторит уведомление
+		log.Printf("❌ Payment callback %s: %v", provider, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
 }
@@ -0,0 +1,133 @@
+package availability
+
+import (
+	"sort"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// Виды альтернатив занятому слоту.
+const (
+	AltEarlier  = "earlier"   // тот же день, раньше
+	AltLater    = "later"     // тот же день, позже
+	AltOtherDay = "other_day" // то же время в другой день
+	AltSplit    = "split"     // часть группы сейчас, остальные часом позже/раньше
+)
+
+// AltRequest — запрошенный слот, под который ищем замену.
+type AltRequest struct {
+	Start time.Time
+	Seats int
+	Hours int
+	Game  string
+	Days  int // сколько соседних дней проверять (в обе стороны)
+}
+
+// AltPart — часть альтернативы: сколько мест с какого времени.
+type AltPart struct {
+	Start time.Time `json:"start"`
+	Seats int       `json:"seats"`
+}
+
+// Alternative — вариант вместо занятого слота. Parts — одна часть,
+// для AltSplit — две.
+type Alternative struct {
+	Kind  string    `json:"kind"`
+	Parts []AltPart `json:"parts"`
+	Score float64   `json:"-"` // меньше — ближе к запросу
+}
+
+// Alternatives — ближайшие limit вариантов для req. bookings должны покрывать
+// все проверяемые дни (req.Start ± Days). Порядок: ±1 ч, деление группы,
+// ±2 ч … внутри дня, потом другие дни.
+func Alternatives(req AltRequest, rigs []models.Rig, bookings []models.Booking, now time.Time, limit int) []Alternative {
+	var out []Alternative
+	free := func(start time.Time, bookings []models.Booking) []models.Rig {
+		return FreeRigs(rigs, bookings, start, start.Add(time.Duration(req.Hours)*time.Hour), req.Game)
+	}
+	usable := func(start time.Time) bool {
+		return start.After(now) && WithinOpeningHours(start, req.Hours)
+	}
+
+	// Тот же рабочий день, другое время
+	open := BusinessDay(req.Start)
+	for h := 0; h < 24-OpenHour+CloseHour; h++ {
+		start := open.Add(time.Duration(h) * time.Hour)
+		if start.Equal(req.Start) || !usable(start) || len(free(start, bookings)) < req.Seats {
+			continue
+		}
+		shift := start.Sub(req.Start).Hours()
+		kind := AltLater
+		if shift < 0 {
+			kind, shift = AltEarlier, -shift
+		}
+		out = append(out, Alternative{Kind: kind, Parts: []AltPart{{start, req.Seats}}, Score: shift})
+	}
+
+	// Деление группы: сколько влезает сейчас, остальные — на час позже/раньше
+	if now.Before(req.Start) {
+		if split, ok := splitGroup(req, free, usable, bookings); ok {
+			out = append(out, split)
+		}
+	}
+
+	// То же время в соседние дни (после — чуть ближе, чем до)
+	for d := 1; d <= req.Days; d++ {
+		for _, sign := range []int{1, -1} {
+			start := req.Start.AddDate(0, 0, sign*d)
+			if !usable(start) || len(free(start, bookings)) < req.Seats {
+				continue
+			}
+			score := 2 + float64(d)
+			if sign < 0 {
+				score += 0.5
+			}
+			out = append(out, Alternative{Kind: AltOtherDay, Parts: []AltPart{{start, req.Seats}}, Score: score})
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score < out[j].Score
+		}
+		return out[i].Parts[0].Start.Before(out[j].Parts[0].Start)
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// splitGroup — часть группы в запрошенное время, остальные со сдвигом
+// на час (сначала позже). Первая часть занимает станции для проверки второй.
+func splitGroup(req AltRequest, free func(time.Time, []models.Booking) []models.Rig, usable func(time.Time) bool, bookings []models.Booking) (Alternative, bool) {
+	if req.Seats < 2 {
+		return Alternative{}, false
+	}
+	now := free(req.Start, bookings)
+	if len(now) == 0 || len(now) >= req.Seats {
+		return Alternative{}, false
+	}
+
+	first, _ := PickRigs(now, len(now))
+	held := models.Booking{Start: req.Start, Hours: req.Hours, Seats: len(first)}
+	for _, r := range first {
+		held.RigIDs = append(held.RigIDs, r.ID)
+	}
+	withFirst := append(append([]models.Booking{}, bookings...), held)
+
+	rest := req.Seats - len(first)
+	for _, shift := range []time.Duration{time.Hour, -time.Hour} {
+		start := req.Start.Add(shift)
+		if !usable(start) || len(free(start, withFirst)) < rest {
+			continue
+		}
+		parts := []AltPart{{req.Start, len(first)}, {start, rest}}
+		if shift < 0 {
+			parts[0], parts[1] = parts[1], parts[0]
+		}
+		return Alternative{Kind: AltSplit, Parts: parts, Score: 1.5}, true
+	}
+	return Alternative{}, false
+}
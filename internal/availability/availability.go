@@ -0,0 +1,234 @@
+package availability
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // пояс клуба не зависит от базы зон на сервере
+
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// Часы работы клуба: 12:00–04:00 следующего дня.
+const (
+	OpenHour  = 12
+	CloseHour = 4
+
+	// MaxHours — максимальная длительность одного сеанса.
+	MaxHours = 12
+)
+
+// Slot — часовой слот рабочего дня.
+type Slot struct {
+	Start time.Time `json:"start"`
+	Used  int       `json:"used"`
+	Free  int       `json:"free"`
+}
+
+// -----------------------------------------------------------------------------
+// INTERVALS
+// -----------------------------------------------------------------------------
+
+// End — конец брони (start + hours).
+func End(b models.Booking) time.Time {
+	return b.Start.Add(time.Duration(b.Hours) * time.Hour)
+}
+
+// Overlaps — пересекается ли бронь с полуинтервалом [from, to).
+func Overlaps(b models.Booking, from, to time.Time) bool {
+	return b.Start.Before(to) && End(b).After(from)
+}
+
+// BusinessDay — рабочий день, к которому относится момент t: всё, что
+// до 04:00, принадлежит предыдущей дате. Возвращает начало дня (12:00).
+func BusinessDay(t time.Time) time.Time {
+	d := time.Date(t.Year(), t.Month(), t.Day(), OpenHour, 0, 0, 0, t.Location())
+	if t.Hour() < CloseHour {
+		d = d.AddDate(0, 0, -1)
+	}
+	return d
+}
+
+// DefaultTimezone — часовой пояс клуба, если в конфиге не задан.
+const DefaultTimezone = "Asia/Almaty"
+
+// clubLocation — пояс, в котором клуб считает время броней. Меняется только
+// при старте (SetLocation), до запуска фоновых задач.
+var clubLocation = mustLoadLocation(DefaultTimezone)
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// SetLocation — часовой пояс клуба (location.timezone в конфиге).
+func SetLocation(loc *time.Location) {
+	if loc != nil {
+		clubLocation = loc
+	}
+}
+
+// Now — текущее время в шкале броней: брони хранят местное время клуба
+// с пометкой UTC (time.Parse без зоны), поэтому сравнивать их с time.Now()
+// напрямую нельзя — сдвинется на часовой пояс сервера. Часы клуба берутся
+// в его поясе (SetLocation), а не в поясе сервера.
+func Now() time.Time {
+	return NowPrecise().Truncate(time.Second)
+}
+
+// NowPrecise — Now с долями секунды, для окон в несколько секунд.
+func NowPrecise() time.Time {
+	n := time.Now().In(clubLocation)
+	return time.Date(n.Year(), n.Month(), n.Day(), n.Hour(), n.Minute(), n.Second(), n.Nanosecond(), time.UTC)
+}
+
+// WithinOpeningHours — помещается ли сеанс [start, start+hours) в часы работы.
+func WithinOpeningHours(start time.Time, hours int) bool {
+	if hours <= 0 {
+		return false
+	}
+	if start.Hour() >= CloseHour && start.Hour() < OpenHour {
+		return false
+	}
+	open := BusinessDay(start)
+	closeAt := open.Add(time.Duration(24-OpenHour+CloseHour) * time.Hour)
+	return !start.Add(time.Duration(hours) * time.Hour).After(closeAt)
+}
+
+// -----------------------------------------------------------------------------
+// OCCUPANCY
+// -----------------------------------------------------------------------------
+
+// Occupancy — максимум занятых мест в любой момент внутри [from, to).
+// Загрузка меняется только на началах броней, поэтому достаточно проверить
+// from и все старты внутри интервала.
+func Occupancy(bookings []models.Booking, from, to time.Time) int {
+	points := []time.Time{from}
+	for _, b := range bookings {
+		if b.Start.After(from) && b.Start.Before(to) {
+			points = append(points, b.Start)
+		}
+	}
+
+	peak := 0
+	for _, p := range points {
+		used := 0
+		for _, b := range bookings {
+			if !b.Start.After(p) && End(b).After(p) {
+				used += b.Seats
+			}
+		}
+		if used > peak {
+			peak = used
+		}
+	}
+	return peak
+}
+
+// Free — сколько мест свободно на всём интервале [start, start+hours).
+func Free(bookings []models.Booking, start time.Time, hours, capacity int) int {
+	free := capacity - Occupancy(bookings, start, start.Add(time.Duration(hours)*time.Hour))
+	if free < 0 {
+		return 0
+	}
+	return free
+}
+
+// DaySlots — свободные места по часам рабочего дня day (12:00 → 04:00).
+func DaySlots(bookings []models.Booking, day time.Time, capacity int) []Slot {
+	open := time.Date(day.Year(), day.Month(), day.Day(), OpenHour, 0, 0, 0, day.Location())
+	n := 24 - OpenHour + CloseHour
+
+	slots := make([]Slot, 0, n)
+	for i := 0; i < n; i++ {
+		start := open.Add(time.Duration(i) * time.Hour)
+		used := Occupancy(bookings, start, start.Add(time.Hour))
+		free := capacity - used
+		if free < 0 {
+			free = 0
+		}
+		slots = append(slots, Slot{Start: start, Used: used, Free: free})
+	}
+	return slots
+}
+
+// -----------------------------------------------------------------------------
+// RIGS
+// -----------------------------------------------------------------------------
+
+// FreeRigs — активные станции с нужной игрой, не занятые на [from, to).
+// Брони без привязки к станциям (до учёта rigs) занимают "любые" места —
+// на их количество список свободных укорачивается.
+func FreeRigs(rigs []models.Rig, bookings []models.Booking, from, to time.Time, game string) []models.Rig {
+	busy := map[int]bool{}
+	var legacy []models.Booking
+	for _, b := range bookings {
+		if !Overlaps(b, from, to) {
+			continue
+		}
+		if len(b.RigIDs) == 0 {
+			legacy = append(legacy, b)
+			continue
+		}
+		for _, id := range b.RigIDs {
+			busy[id] = true
+		}
+	}
+
+	var free []models.Rig
+	for _, r := range rigs {
+		if r.Status == models.RigActive && !busy[r.ID] && r.HasGame(game) {
+			free = append(free, r)
+		}
+	}
+	sort.Slice(free, func(i, j int) bool { return free[i].ID < free[j].ID })
+
+	if n := len(free) - Occupancy(legacy, from, to); n < len(free) {
+		if n < 0 {
+			n = 0
+		}
+		free = free[:n]
+	}
+	return free
+}
+
+// PickRigs — выбирает n станций из free (отсортированных по ID), предпочитая
+// стоящие подряд. Если подряд не получается — берёт самое компактное окно.
+func PickRigs(free []models.Rig, n int) (picked []models.Rig, adjacent bool) {
+	if n <= 0 || len(free) < n {
+		return nil, false
+	}
+
+	best, bestSpan := 0, -1
+	for i := 0; i+n <= len(free); i++ {
+		span := free[i+n-1].ID - free[i].ID
+		if bestSpan < 0 || span < bestSpan {
+			best, bestSpan = i, span
+		}
+	}
+	return free[best : best+n], bestSpan == n-1
+}
+
+// ActiveCount — сколько станций в работе.
+func ActiveCount(rigs []models.Rig) int {
+	n := 0
+	for _, r := range rigs {
+		if r.Status == models.RigActive {
+			n++
+		}
+	}
+	return n
+}
+
+// FormatRigIDs — номера станций для ответа: "3, 4, 5".
+func FormatRigIDs(ids []int) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.Itoa(id))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,232 @@
+package availability_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// at — момент в шкале броней (местное время клуба с пометкой UTC).
+func at(day, clock string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", day+" "+clock)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func rigIDs(rigs []models.Rig) string {
+	ids := make([]int, 0, len(rigs))
+	for _, r := range rigs {
+		ids = append(ids, r.ID)
+	}
+	return availability.FormatRigIDs(ids)
+}
+
+func TestBusinessDay(t *testing.T) {
+	tests := []struct {
+		at   time.Time
+		want time.Time
+	}{
+		{at("2026-05-10", "12:00"), at("2026-05-10", "12:00")},
+		{at("2026-05-10", "23:59"), at("2026-05-10", "12:00")},
+		// После полуночи до закрытия — ещё вчерашний рабочий день.
+		{at("2026-05-11", "00:00"), at("2026-05-10", "12:00")},
+		{at("2026-05-11", "03:59"), at("2026-05-10", "12:00")},
+		{at("2026-05-11", "04:00"), at("2026-05-11", "12:00")},
+		{at("2026-05-11", "11:00"), at("2026-05-11", "12:00")},
+		// Через границу месяца и года.
+		{at("2027-01-01", "02:00"), at("2026-12-31", "12:00")},
+	}
+	for _, tt := range tests {
+		if got := availability.BusinessDay(tt.at); !got.Equal(tt.want) {
+			t.Errorf("BusinessDay(%s) = %s, want %s", tt.at.Format("01-02 15:04"), got.Format("01-02 15:04"), tt.want.Format("01-02 15:04"))
+		}
+	}
+}
+
+func TestWithinOpeningHours(t *testing.T) {
+	tests := []struct {
+		clock string
+		hours int
+		want  bool
+	}{
+		{"12:00", 1, true},
+		{"11:00", 1, false},
+		{"04:00", 1, false},
+		{"03:00", 1, true}, // ровно до закрытия
+		{"03:00", 2, false},
+		{"23:00", 5, true},
+		{"23:00", 6, false},
+		{"00:00", 4, true},
+		{"12:00", availability.MaxHours, true},
+		{"16:00", availability.MaxHours, true}, // 16:00 → 04:00
+		{"17:00", availability.MaxHours, false},
+		{"12:00", 0, false},
+	}
+	for _, tt := range tests {
+		start := at("2026-05-10", tt.clock)
+		if got := availability.WithinOpeningHours(start, tt.hours); got != tt.want {
+			t.Errorf("WithinOpeningHours(%s, %d) = %v, want %v", tt.clock, tt.hours, got, tt.want)
+		}
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	b := models.Booking{Start: at("2026-05-10", "22:00"), Hours: 3} // до 01:00
+	tests := []struct {
+		from, to time.Time
+		want     bool
+	}{
+		{at("2026-05-10", "20:00"), at("2026-05-10", "22:00"), false}, // встык до
+		{at("2026-05-10", "21:00"), at("2026-05-10", "23:00"), true},
+		{at("2026-05-10", "23:30"), at("2026-05-11", "00:30"), true},
+		{at("2026-05-11", "00:30"), at("2026-05-11", "02:00"), true},
+		{at("2026-05-11", "01:00"), at("2026-05-11", "02:00"), false}, // встык после
+	}
+	for _, tt := range tests {
+		if got := availability.Overlaps(b, tt.from, tt.to); got != tt.want {
+			t.Errorf("Overlaps [%s, %s) = %v, want %v", tt.from.Format("15:04"), tt.to.Format("15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestOccupancy(t *testing.T) {
+	bookings := []models.Booking{
+		{Start: at("2026-05-10", "18:00"), Hours: 2, Seats: 2},
+		{Start: at("2026-05-10", "19:00"), Hours: 3, Seats: 3},
+		{Start: at("2026-05-10", "23:00"), Hours: 2, Seats: 1}, // через полночь
+	}
+	tests := []struct {
+		from, to time.Time
+		want     int
+	}{
+		{at("2026-05-10", "18:00"), at("2026-05-10", "19:00"), 2},
+		{at("2026-05-10", "18:00"), at("2026-05-10", "21:00"), 5}, // пик в 19:00
+		{at("2026-05-10", "20:00"), at("2026-05-10", "22:00"), 3},
+		{at("2026-05-10", "22:00"), at("2026-05-10", "23:00"), 0},
+		{at("2026-05-10", "22:00"), at("2026-05-11", "01:00"), 1},
+		{at("2026-05-11", "00:00"), at("2026-05-11", "01:00"), 1},
+		{at("2026-05-11", "01:00"), at("2026-05-11", "04:00"), 0},
+	}
+	for _, tt := range tests {
+		if got := availability.Occupancy(bookings, tt.from, tt.to); got != tt.want {
+			t.Errorf("Occupancy [%s, %s) = %d, want %d", tt.from.Format("15:04"), tt.to.Format("15:04"), got, tt.want)
+		}
+	}
+
+	if got := availability.Free(bookings, at("2026-05-10", "18:00"), 3, 6); got != 1 {
+		t.Errorf("Free 18:00 for 3h = %d, want 1", got)
+	}
+	if got := availability.Free(bookings, at("2026-05-10", "19:00"), 1, 4); got != 0 {
+		t.Errorf("Free over capacity = %d, want 0", got)
+	}
+}
+
+func TestDaySlotsWrapMidnight(t *testing.T) {
+	bookings := []models.Booking{{Start: at("2026-05-11", "02:00"), Hours: 2, Seats: 2}}
+	slots := availability.DaySlots(bookings, at("2026-05-10", "00:00"), 6)
+
+	if len(slots) != 16 {
+		t.Fatalf("slots = %d, want 16 (12:00–04:00)", len(slots))
+	}
+	if first := slots[0].Start; !first.Equal(at("2026-05-10", "12:00")) {
+		t.Errorf("first slot = %s, want 05-10 12:00", first)
+	}
+	last := slots[len(slots)-1]
+	if !last.Start.Equal(at("2026-05-11", "03:00")) || last.Used != 2 || last.Free != 4 {
+		t.Errorf("last slot = %s used %d free %d, want 05-11 03:00 used 2 free 4", last.Start, last.Used, last.Free)
+	}
+}
+
+func TestFreeRigs(t *testing.T) {
+	acc := []string{"Assetto Corsa Competizione", "iRacing"}
+	rigs := []models.Rig{
+		{ID: 6, Status: models.RigActive, Games: []string{"iRacing"}},
+		{ID: 1, Status: models.RigActive, Games: acc},
+		{ID: 2, Status: models.RigActive, Games: acc},
+		{ID: 3, Status: models.RigActive, Games: acc},
+		{ID: 4, Status: models.RigMaintenance, Games: acc},
+		{ID: 5, Status: models.RigActive, Games: acc},
+	}
+	bookings := []models.Booking{
+		{Start: at("2026-05-10", "20:00"), Hours: 2, Seats: 2, RigIDs: []int{2, 3}},
+		// Старая бронь без станций занимает «любое» место.
+		{Start: at("2026-05-10", "19:00"), Hours: 2, Seats: 1},
+	}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		game     string
+		want     string
+	}{
+		{"rigs and legacy busy", at("2026-05-10", "20:00"), at("2026-05-10", "22:00"), "", "1, 5"},
+		{"only legacy busy", at("2026-05-10", "19:00"), at("2026-05-10", "20:00"), "", "1, 2, 3, 5"},
+		{"all free", at("2026-05-10", "22:00"), at("2026-05-11", "00:00"), "", "1, 2, 3, 5, 6"},
+		{"game filter", at("2026-05-10", "22:00"), at("2026-05-11", "00:00"), "assetto", "1, 2, 3, 5"},
+		{"after midnight", at("2026-05-11", "00:00"), at("2026-05-11", "02:00"), "iracing", "1, 2, 3, 5, 6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rigIDs(availability.FreeRigs(rigs, bookings, tt.from, tt.to, tt.game)); got != tt.want {
+				t.Errorf("FreeRigs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickRigs(t *testing.T) {
+	rigs := func(ids ...int) []models.Rig {
+		out := make([]models.Rig, 0, len(ids))
+		for _, id := range ids {
+			out = append(out, models.Rig{ID: id, Status: models.RigActive})
+		}
+		return out
+	}
+	tests := []struct {
+		free     []models.Rig
+		n        int
+		want     string
+		adjacent bool
+	}{
+		{rigs(1, 2, 5, 6, 7), 2, "1, 2", true},
+		{rigs(1, 2, 5, 6, 7), 3, "5, 6, 7", true},
+		{rigs(1, 2, 5, 6, 7), 4, "1, 2, 5, 6", false},
+		{rigs(1, 3, 4, 8), 2, "3, 4", true},
+		{rigs(1, 3, 6), 2, "1, 3", false},
+		{rigs(1, 2), 3, "", false},
+		{rigs(1, 2), 0, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", rigIDs(tt.free), tt.n), func(t *testing.T) {
+			picked, adjacent := availability.PickRigs(tt.free, tt.n)
+			if got := rigIDs(picked); got != tt.want || adjacent != tt.adjacent {
+				t.Errorf("PickRigs = %q adjacent %v, want %q adjacent %v", got, adjacent, tt.want, tt.adjacent)
+			}
+		})
+	}
+}
+
+// Now — часы клуба в его поясе, а не в поясе сервера.
+func TestNowUsesClubLocation(t *testing.T) {
+	t.Cleanup(func() {
+		loc, _ := time.LoadLocation(availability.DefaultTimezone)
+		availability.SetLocation(loc)
+	})
+
+	for _, offset := range []int{5, -3} {
+		availability.SetLocation(time.FixedZone("club", offset*3600))
+		want := time.Now().UTC().Add(time.Duration(offset) * time.Hour)
+		got := availability.Now()
+		if got.Location() != time.UTC {
+			t.Errorf("UTC%+d: location = %s, want UTC label", offset, got.Location())
+		}
+		if d := got.Sub(want); d < -2*time.Second || d > 2*time.Second {
+			t.Errorf("UTC%+d: Now = %s, want %s", offset, got.Format(time.TimeOnly), want.Format(time.TimeOnly))
+		}
+	}
+}
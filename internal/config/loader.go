@@ -5,6 +5,11 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+	"whatsapp-analytics-mvp/internal/pricing"
 
 	"gopkg.in/yaml.v3"
 )
@@ -12,19 +17,132 @@ import (
 type Config struct {
 	App struct {
 		Port string `yaml:"port"`
+		// Bearer-токен служебного API (/api/...); пусто — API выключен.
+		AdminToken string `yaml:"admin_token"`
 	} `yaml:"app"`
 
 	API struct {
 		OpenAIKey         string `yaml:"openai_api_key"`
+		GeminiAPIKey      string `yaml:"gemini_api_key"`
 		OpenWeatherMapKey string `yaml:"openweathermap_key"`
 		TelegramToken     string `yaml:"telegram_token"`
+		// Адрес Bot API; пусто — https://api.telegram.org (свой сервер
+		// telegram-bot-api или фейк в тестах).
+		TelegramBaseURL string `yaml:"telegram_base_url"`
+		// parse_mode ответов: пусто (обычный текст), MarkdownV2 или HTML.
+		TelegramParseMode string `yaml:"telegram_parse_mode"`
 		WazzupAPIKey      string `yaml:"wazzup_api_key"`
+		// Адрес Wazzup API; пусто — https://api.wazzup24.com (фейк в тестах).
+		WazzupBaseURL string `yaml:"wazzup_base_url"`
+		// Канал Wazzup для сообщений вне диалога, если клиент ещё не писал
+		// после перезапуска.
+		WazzupChannelID string `yaml:"wazzup_channel_id"`
 	} `yaml:"api"`
 
+	Telegram struct {
+		// Приём обновлений: webhook (по умолчанию) или polling (getUpdates,
+		// без публичного адреса — для разработки).
+		Mode string `yaml:"mode"`
+		// Адрес вебхука (https://host/webhook/telegram); задан — регистрируется
+		// при старте в режиме webhook.
+		WebhookURL string `yaml:"webhook_url"`
+		// Секрет вебхука (X-Telegram-Bot-Api-Secret-Token).
+		WebhookSecret string `yaml:"webhook_secret"`
+		// Ожидание getUpdates в режиме polling. 0 — 30 секунд.
+		PollTimeout time.Duration `yaml:"poll_timeout"`
+		// Отбросить накопившиеся обновления при смене режима.
+		DropPending bool `yaml:"drop_pending"`
+		// Чат администратора: команды оператора и уведомления (эскалации,
+		// сообщения клиентов на операторе). 0 — встроенный ADMIN_TELEGRAM_ID.
+		AdminChatID int64 `yaml:"admin_chat_id"`
+	} `yaml:"telegram"`
+
+	LLM struct {
+		// Окно истории диалога, которое уходит в модель.
+		// Секция не задана — используется llm.DefaultHistoryWindow().
+		History struct {
+			MaxMessages int           `yaml:"max_messages"`
+			MaxTokens   int           `yaml:"max_tokens"`
+			MaxAge      time.Duration `yaml:"max_age"`
+		} `yaml:"history"`
+	} `yaml:"llm"`
+
+	Booking struct {
+		// Клиент сам отменяет/переносит бронь не позже, чем за N часов
+		// до начала. 0 — значение по умолчанию (3 ч).
+		FreeCancelHours int `yaml:"free_cancel_hours"`
+		// Сколько держим освободившиеся места под клиента из листа
+		// ожидания. 0 — по умолчанию (15 минут).
+		WaitlistOfferTTL time.Duration `yaml:"waitlist_offer_ttl"`
+		// Напоминание за N до начала и вопрос об отзыве через N после
+		// конца. 0 — по умолчанию (2 ч и 1 ч).
+		ReminderBefore time.Duration `yaml:"reminder_before"`
+		FollowUpAfter  time.Duration `yaml:"followup_after"`
+	} `yaml:"booking"`
+
+	Tasks struct {
+		// Как часто планировщик проверяет созревшие задания. 0 — 15 секунд.
+		PollInterval time.Duration `yaml:"poll_interval"`
+		// Сколько попыток у задания до пометки failed. 0 — 5.
+		MaxAttempts int `yaml:"max_attempts"`
+	} `yaml:"tasks"`
+
+	Events struct {
+		// Как часто outbox доставляет события броней и оплат в шину. 0 — 1 секунда.
+		PollInterval time.Duration `yaml:"poll_interval"`
+	} `yaml:"events"`
+
+	Inbound struct {
+		// Сколько клиентов обрабатывается одновременно. 0 — 4.
+		Workers int `yaml:"workers"`
+		// Попыток обработки входящего сообщения до dead. 0 — 3.
+		MaxAttempts int `yaml:"max_attempts"`
+		// Склейка сообщений по каналам (wazzup, telegram); канал не задан —
+		// умолчание (3s/10s и 2s/8s), window: 0 — без склейки.
+		Debounce map[string]models.Debounce `yaml:"debounce"`
+	} `yaml:"inbound"`
+
+	Handoff struct {
+		// Оператор молчит дольше — диалог возвращается боту. 0 — 30 минут.
+		IdleTimeout time.Duration `yaml:"idle_timeout"`
+		// Фразы клиента, после которых диалог сразу уходит оператору.
+		// Не задано — встроенный список, [] — только по решению бота.
+		Keywords []string `yaml:"keywords"`
+	} `yaml:"handoff"`
+
+	Webhooks struct {
+		// Как часто отправляются исходящие вебхуки из очереди. 0 — 5 секунд.
+		PollInterval time.Duration `yaml:"poll_interval"`
+		// Попыток до dead (повтор вручную через /api/webhooks). 0 — 8.
+		MaxAttempts int                        `yaml:"max_attempts"`
+		Subscribers []models.WebhookSubscriber `yaml:"subscribers"`
+	} `yaml:"webhooks"`
+
+	// Тариф. Секция не задана — pricing.Default(); тариф, сохранённый
+	// в БД (settings), важнее конфига.
+	Pricing *pricing.Tariff `yaml:"pricing"`
+
+	Payments struct {
+		// Провайдер счетов: fake (локальный, для разработки). Пусто — онлайн-
+		// оплата выключена, бронь оплачивается на месте.
+		Provider string `yaml:"provider"`
+		// Общий секрет для подписи уведомлений провайдера.
+		Secret  string `yaml:"secret"`
+		BaseURL string `yaml:"base_url"`
+		// Сколько бронь бота держит места без оплаты; 0 — не снимать.
+		HoldTimeout time.Duration `yaml:"hold_timeout"`
+	} `yaml:"payments"`
+
 	Location struct {
 		AstanaLat float64 `yaml:"astana_lat"`
 		AstanaLon float64 `yaml:"astana_lon"`
+		// Часовой пояс клуба (IANA): в нём считаются брони и часы работы.
+		// Пусто — availability.DefaultTimezone; пояс сервера не важен.
+		Timezone string `yaml:"timezone"`
 	} `yaml:"location"`
+
+	// ClubLocation — загруженный Location.Timezone.
+	ClubLocation *time.Location `yaml:"-"`
 }
 
 func LoadConfig(configPath string) (*Config, error) {
@@ -48,11 +166,18 @@ func LoadConfig(configPath string) (*Config, error) {
 	if v := os.Getenv("OPENAI_API_KEY"); v != "" {
 		cfg.API.OpenAIKey = v
 	}
+	if v := os.Getenv("GEMINI_API_KEY"); v != "" {
+		cfg.API.GeminiAPIKey = v
+	}
 
 	if cfg.API.OpenAIKey == "" {
 		return nil, fmt.Errorf("КРИТИЧЕСКАЯ ОШИБКА: OpenAI API key не указан (api.openai_api_key или env OPENAI_API_KEY)")
 	}
 
+	if v := os.Getenv("ADMIN_API_TOKEN"); v != "" {
+		cfg.App.AdminToken = v
+	}
+
 	if cfg.App.Port == "" {
 		cfg.App.Port = ":8080"
 		log.Println("[CONFIG] ⚠️ Port не указан, использован :8080")
@@ -61,6 +186,58 @@ func LoadConfig(configPath string) (*Config, error) {
 		cfg.App.Port = ":" + cfg.App.Port
 	}
 
+	if cfg.Pricing != nil {
+		if err := cfg.Pricing.Validate(); err != nil {
+			return nil, fmt.Errorf("ошибка тарифа: %w", err)
+		}
+	}
+
+	if v := os.Getenv("PAYMENTS_SECRET"); v != "" {
+		cfg.Payments.Secret = v
+	}
+	switch cfg.Payments.Provider {
+	case "":
+	case "fake":
+		if cfg.Payments.Secret == "" {
+			return nil, fmt.Errorf("payments.secret не указан (или env PAYMENTS_SECRET)")
+		}
+	default:
+		return nil, fmt.Errorf("неизвестный платёжный провайдер: %s", cfg.Payments.Provider)
+	}
+	switch cfg.Telegram.Mode {
+	case "", "webhook", "polling":
+	default:
+		return nil, fmt.Errorf("telegram.mode: %q (нужно webhook или polling)", cfg.Telegram.Mode)
+	}
+	if cfg.Handoff.IdleTimeout < 0 {
+		return nil, fmt.Errorf("handoff.idle_timeout: %s (нужно >= 0)", cfg.Handoff.IdleTimeout)
+	}
+	if cfg.Location.Timezone == "" {
+		cfg.Location.Timezone = availability.DefaultTimezone
+	}
+	if cfg.ClubLocation, err = time.LoadLocation(cfg.Location.Timezone); err != nil {
+		return nil, fmt.Errorf("location.timezone: %w", err)
+	}
+	if v := os.Getenv("TELEGRAM_WEBHOOK_SECRET"); v != "" {
+		cfg.Telegram.WebhookSecret = v
+	}
+	switch cfg.API.TelegramParseMode {
+	case "", "MarkdownV2", "HTML":
+	default:
+		return nil, fmt.Errorf("api.telegram_parse_mode: %q (нужно пусто, MarkdownV2 или HTML)", cfg.API.TelegramParseMode)
+	}
+	for source, d := range cfg.Inbound.Debounce {
+		if source != models.InboundWazzup && source != models.InboundTelegram {
+			return nil, fmt.Errorf("inbound.debounce: неизвестный канал %q (нужно wazzup или telegram)", source)
+		}
+		if d.Window < 0 || d.MaxWait < 0 {
+			return nil, fmt.Errorf("inbound.debounce.%s: отрицательное время", source)
+		}
+	}
+
+	if cfg.API.GeminiAPIKey == "" {
+		log.Println("[CONFIG] ⚠️ Gemini API key отсутствует. Fallback на Gemini работать не будет.")
+	}
 	if cfg.API.OpenWeatherMapKey == "" {
 		log.Println("[CONFIG] ⚠️ OpenWeatherMap API key отсутствует. Модуль погоды работать не будет.")
 	}
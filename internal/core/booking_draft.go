@@ -0,0 +1,359 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+//  SLOT PARSING (места / дата / время / часы из свободного текста)
+// -----------------------------------------------------------------------------
+
+// slotUpdate — то, что удалось распознать в одном сообщении.
+type slotUpdate struct {
+	Seats     int
+	Date      string
+	StartTime string
+	Hours     int
+}
+
+var (
+	reClock      = regexp.MustCompile(`(?:^|[^\d])([01]?\d|2[0-3]):([0-5]\d)`)
+	reHourPhrase = regexp.MustCompile(`(?:^|[^\p{L}])(?:(?:в|к|at)\s+(\d{1,2})(?:\s*час(?:а|ов)?)?(?:\s*(вечера|ночи|дня|утра|pm|am))?|(\d{1,2})\s*(?:час(?:а|ов)?\s*)?(вечера|ночи|дня|утра|pm|am))`)
+	reHalfHour   = regexp.MustCompile(`через полчаса|жарты сағат|in half an hour`)
+	reSeats      = regexp.MustCompile(`(\d{1,2})\s*(?:мест[аоы]?|орын|seats?|чел(?:овек[а]?)?|адам|people|persons?)`)
+	reHours      = regexp.MustCompile(`(\d{1,2})\s*(?:час(?:а|ов)?|сағат\p{L}*|hours?|h)(?:[^\p{L}]|$)`)
+	reISODate    = regexp.MustCompile(`(\d{4}-\d{2}-\d{2})`)
+	reDotDate    = regexp.MustCompile(`(?:^|[^\d])(\d{1,2})\.(\d{1,2})(?:[^\d]|$)`)
+	reBookingID  = regexp.MustCompile(`bk_\d+`)
+	reQuoteTotal = regexp.MustCompile(`Итого:\s*(\d+(?:\.\d+)?)`)
+)
+
+// parseSlots — грубый, но детерминированный разбор реплики клиента.
+// LLM всё равно видит исходный текст; парсер лишь фиксирует уже названное,
+// чтобы бот не переспрашивал.
+func parseSlots(text string, now time.Time) slotUpdate {
+	var u slotUpdate
+	t := strings.ToLower(text)
+
+	// 1) Время — первым, и вырезаем его, чтобы "в 8 часов" не стало часами
+	if m := reClock.FindStringSubmatchIndex(t); m != nil {
+		h, _ := strconv.Atoi(t[m[2]:m[3]])
+		u.StartTime = fmt.Sprintf("%02d:%s", h, t[m[4]:m[5]])
+		t = t[:m[0]] + " " + t[m[1]:]
+	} else if m := reHourPhrase.FindStringSubmatchIndex(t); m != nil {
+		hourIdx, suffixIdx := 2, 4
+		if m[2] < 0 {
+			hourIdx, suffixIdx = 6, 8
+		}
+		h, _ := strconv.Atoi(t[m[hourIdx]:m[hourIdx+1]])
+		suffix := ""
+		if m[suffixIdx] >= 0 {
+			suffix = t[m[suffixIdx]:m[suffixIdx+1]]
+		}
+		if h = clubHour(h, suffix); h >= 0 {
+			u.StartTime = fmt.Sprintf("%02d:00", h)
+		}
+		t = t[:m[0]] + " " + t[m[1]:]
+	} else if reHalfHour.MatchString(t) {
+		u.StartTime = now.Add(30 * time.Minute).Format("15:04")
+	}
+
+	// 2) Места и часы
+	if m := reSeats.FindStringSubmatch(t); m != nil {
+		u.Seats, _ = strconv.Atoi(m[1])
+	}
+	if m := reHours.FindStringSubmatch(t); m != nil {
+		u.Hours, _ = strconv.Atoi(m[1])
+	}
+
+	// 3) Дата
+	relative := false
+	switch {
+	case strings.Contains(t, "послезавтра"):
+		u.Date = now.AddDate(0, 0, 2).Format("2006-01-02")
+		relative = true
+	case strings.Contains(t, "завтра") || strings.Contains(t, "ертең") || strings.Contains(t, "tomorrow"):
+		u.Date = now.AddDate(0, 0, 1).Format("2006-01-02")
+		relative = true
+	case strings.Contains(t, "сегодня") || strings.Contains(t, "бүгін") || strings.Contains(t, "today"):
+		u.Date = now.Format("2006-01-02")
+		relative = true
+	default:
+		if m := reISODate.FindStringSubmatch(t); m != nil {
+			if _, err := time.Parse("2006-01-02", m[1]); err == nil {
+				u.Date = m[1]
+			}
+		} else if m := reDotDate.FindStringSubmatch(t); m != nil {
+			day, _ := strconv.Atoi(m[1])
+			month, _ := strconv.Atoi(m[2])
+			if month >= 1 && month <= 12 && day >= 1 && day <= 31 {
+				d := time.Date(now.Year(), time.Month(month), day, 0, 0, 0, 0, now.Location())
+				if d.Before(now.AddDate(0, 0, -1)) {
+					d = d.AddDate(1, 0, 0)
+				}
+				u.Date = d.Format("2006-01-02")
+			}
+		} else if u.StartTime != "" {
+			// Время без даты — значит сегодня
+			u.Date = now.Format("2006-01-02")
+			relative = true
+		}
+	}
+
+	// Клуб работает до 04:00: "сегодня в 2 ночи" — это уже завтрашняя дата
+	// (если сейчас не та же ночь)
+	today := now.Format("2006-01-02")
+	if relative && u.StartTime != "" && u.StartTime < "05:00" && (u.Date != today || now.Hour() >= 5) {
+		d, _ := time.Parse("2006-01-02", u.Date)
+		u.Date = d.AddDate(0, 0, 1).Format("2006-01-02")
+	}
+
+	return u
+}
+
+// clubHour — час из фразы ("в 8 вечера", "в 2 ночи") в 24-часовой формат
+// с поправкой на часы работы 12:00–04:00. -1 — час не распознан.
+func clubHour(h int, suffix string) int {
+	if h < 0 || h > 23 {
+		return -1
+	}
+	switch suffix {
+	case "вечера", "pm":
+		if h < 12 {
+			h += 12
+		}
+	case "дня":
+		if h <= 6 {
+			h += 12
+		}
+	case "ночи", "утра", "am":
+		// как есть
+	default:
+		// Без уточнения 5–11 — это вечер: утром клуб закрыт
+		if h >= 5 && h <= 11 {
+			h += 12
+		}
+	}
+	return h % 24
+}
+
+// apply — переносит распознанные слоты в черновик. Возвращает true, если
+// что-то изменилось. Клиент может передумать — новое значение побеждает.
+func (u slotUpdate) apply(d *models.BookingDraft) bool {
+	changed := false
+	if u.Seats > 0 && u.Seats != d.Seats {
+		d.Seats, changed = u.Seats, true
+	}
+	if u.Date != "" && u.Date != d.Date {
+		d.Date, changed = u.Date, true
+	}
+	if u.StartTime != "" && u.StartTime != d.StartTime {
+		d.StartTime, changed = u.StartTime, true
+	}
+	if u.Hours > 0 && u.Hours != d.Hours {
+		d.Hours, changed = u.Hours, true
+	}
+	return changed
+}
+
+// startsNew — черновик уже стал бронью, а клиент называет другой день или
+// время: это следующая бронь, а не уточнение прежней.
+func (u slotUpdate) startsNew(d *models.BookingDraft) bool {
+	if d.BookingID == "" {
+		return false
+	}
+	return (u.Date != "" && u.Date != d.Date) || (u.StartTime != "" && u.StartTime != d.StartTime)
+}
+
+// resetDraft — начать черновик заново (сессия та же).
+func resetDraft(d *models.BookingDraft) {
+	*d = models.BookingDraft{ClientID: d.ClientID, SessionID: d.SessionID}
+}
+
+// -----------------------------------------------------------------------------
+//  DRAFT UPDATES
+// -----------------------------------------------------------------------------
+
+// updateDraftFromMessage — разбирает реплику клиента и сохраняет черновик.
+// Возвращает актуальный черновик (nil, если хранилище недоступно).
+func (s *AIService) updateDraftFromMessage(ctx context.Context, clientID, userMessage string) *models.BookingDraft {
+	repo, ok := s.ContextManager.(DraftRepository)
+	if !ok {
+		return nil
+	}
+
+	draft, err := repo.GetDraft(ctx, clientID)
+	if err != nil {
+		log.Printf("[Draft] load failed for %s: %v", clientID, err)
+		return nil
+	}
+
+	u := parseSlots(userMessage, availability.Now())
+	renewed := u.startsNew(draft)
+	if renewed {
+		resetDraft(draft)
+	}
+	if u.apply(draft) || renewed {
+		if err := repo.SaveDraft(ctx, draft); err != nil {
+			log.Printf("[Draft] save failed for %s: %v", clientID, err)
+		}
+	}
+	return draft
+}
+
+// updateDraftFromTool — аргументы и результаты клиентских инструментов —
+// самый надёжный источник слотов: модель уже нормализовала дату и время.
+func (s *AIService) updateDraftFromTool(ctx context.Context, clientID, name string, args map[string]any, result string) {
+	repo, ok := s.ContextManager.(DraftRepository)
+	if !ok {
+		return
+	}
+
+	draft, err := repo.GetDraft(ctx, clientID)
+	if err != nil {
+		log.Printf("[Draft] load failed for %s: %v", clientID, err)
+		return
+	}
+
+	u := slotUpdate{}
+	u.Date, _ = strArg(args, "date")
+	u.StartTime, _ = strArg(args, "time")
+	if v, ok := floatArg(args, "seats"); ok {
+		u.Seats = int(v)
+	}
+	if v, ok := floatArg(args, "hours"); ok {
+		u.Hours = int(v)
+	}
+	if name != "RescheduleBooking" && u.startsNew(draft) {
+		resetDraft(draft)
+	}
+	u.apply(draft)
+	if game, _ := strArg(args, "game"); game != "" {
+		draft.Game = game // confirmDraft подберёт ПК под игру
+	}
+
+	switch name {
+	case "GetPrice":
+		if m := reQuoteTotal.FindStringSubmatch(result); m != nil {
+			draft.PriceQuote, _ = strconv.ParseFloat(m[1], 64)
+		}
+	case "ApplyPromoCode":
+		code, _ := strArg(args, "code")
+		draft.PromoCode = models.NormalizePromoCode(code)
+	case "CreateBooking":
+		draft.PromoCode = "" // погашен вместе с бронью
+		draft.BookingID = reBookingID.FindString(result)
+		bookingID := draft.BookingID
+		_ = s.ContextManager.CreateOrUpdateSession(ctx, clientID, &bookingID)
+	case "AnswerWaitlistOffer":
+		if accept, _ := boolArg(args, "accept"); accept {
+			draft.BookingID = reBookingID.FindString(result)
+			bookingID := draft.BookingID
+			_ = s.ContextManager.CreateOrUpdateSession(ctx, clientID, &bookingID)
+		}
+	case "CancelBooking":
+		if id, _ := strArg(args, "booking_id"); id == draft.BookingID {
+			draft.BookingID, draft.PriceQuote = "", 0
+		}
+	case "GeneratePaymentLink", "PayWithPrepaid":
+		// оплата предложена или проведена — бронь закрыта, следующий
+		// разговор о брони начинается с чистого черновика
+		if draft.BookingID != "" && !strings.HasPrefix(result, "Ошибка") {
+			resetDraft(draft)
+		}
+	}
+
+	if err := repo.SaveDraft(ctx, draft); err != nil {
+		log.Printf("[Draft] save failed for %s: %v", clientID, err)
+	}
+}
+
+// draftPromoCode — промокод, принятый в текущей сессии (пусто, если нет).
+func (s *AIService) draftPromoCode(ctx context.Context, clientID string) string {
+	repo, ok := s.ContextManager.(DraftRepository)
+	if !ok {
+		return ""
+	}
+	draft, err := repo.GetDraft(ctx, clientID)
+	if err != nil {
+		return ""
+	}
+	return draft.PromoCode
+}
+
+// -----------------------------------------------------------------------------
+//  PROMPT / ADMIN VIEW
+// -----------------------------------------------------------------------------
+
+var slotLabels = map[string]string{
+	"seats": "количество мест",
+	"date":  "дату",
+	"time":  "время начала",
+	"hours": "сколько часов",
+}
+
+// draftPrompt — структурированное состояние брони для system prompt.
+func draftPrompt(d *models.BookingDraft) string {
+	if d == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("\n***ТЕКУЩАЯ БРОНЬ КЛИЕНТА (уже известно — НЕ переспрашивай)***\n")
+	b.WriteString(formatDraft(d))
+
+	missing := d.MissingSlots()
+	switch {
+	case d.BookingID != "":
+		b.WriteString("СЛЕДУЮЩИЙ ШАГ: бронь создана — предложи оплату (GeneratePaymentLink).\n")
+	case len(missing) == 0:
+		b.WriteString("СЛЕДУЮЩИЙ ШАГ: все данные есть — проверь наличие (CheckAvailability), назови цену (GetPrice) и создай бронь (CreateBooking).\n")
+	default:
+		b.WriteString(fmt.Sprintf("СЛЕДУЮЩИЙ ШАГ: спроси ТОЛЬКО %s.\n", slotLabels[missing[0]]))
+	}
+	return b.String()
+}
+
+// formatDraft — черновик построчно (для промпта и для админа).
+func formatDraft(d *models.BookingDraft) string {
+	orNone := func(v string) string {
+		if v == "" {
+			return "не указано"
+		}
+		return v
+	}
+	intOrNone := func(v int) string {
+		if v == 0 {
+			return "не указано"
+		}
+		return strconv.Itoa(v)
+	}
+
+	var b strings.Builder
+	b.WriteString("- Места: " + intOrNone(d.Seats) + "\n")
+	b.WriteString("- Дата: " + orNone(d.Date) + "\n")
+	b.WriteString("- Время: " + orNone(d.StartTime) + "\n")
+	b.WriteString("- Часы: " + intOrNone(d.Hours) + "\n")
+	if d.Game != "" {
+		b.WriteString("- Игра: " + d.Game + "\n")
+	}
+	if d.PriceQuote > 0 {
+		b.WriteString(fmt.Sprintf("- Цена: %.0f тг\n", d.PriceQuote))
+	}
+	if d.PromoCode != "" {
+		b.WriteString("- Промокод: " + d.PromoCode + "\n")
+	}
+	if d.BookingID != "" {
+		b.WriteString("- ID брони: " + d.BookingID + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,385 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+//  BOOKING KEYBOARD (кнопки выбора слотов брони)
+// -----------------------------------------------------------------------------
+
+// Данные кнопок: «bk:<слот>:<значение>». Нажатие разбирает
+// HandleBookingChoice — прямо в черновик, без хода LLM.
+const (
+	choicePrefix  = "bk:"
+	choiceSeats   = "bk:s:"
+	choiceDate    = "bk:d:"
+	choiceStart   = "bk:t:" // bk:t:2026-10-18T19:00 — ночные часы уже со следующей датой
+	choiceHours   = "bk:h:"
+	choiceConfirm = "bk:ok"
+	choiceCancel  = "bk:no"
+
+	choiceStartLayout = "2006-01-02T15:04"
+)
+
+// Сколько вариантов показывать кнопками.
+const (
+	keyboardMaxSeats  = 6
+	keyboardMaxHours  = 4
+	keyboardMaxStarts = 8
+	keyboardDays      = 3
+)
+
+// reBookingIntent — клиент заговорил о брони, хотя слотов ещё не назвал.
+var reBookingIntent = regexp.MustCompile(`брон|запис|book|орын`)
+
+// IsBookingChoice — данные нажатой кнопки относятся к брони.
+func IsBookingChoice(data string) bool {
+	return strings.HasPrefix(data, choicePrefix)
+}
+
+// reStepQuestion — чем LLM спрашивает о шаге, к которому относятся кнопки.
+var reStepQuestion = map[string]*regexp.Regexp{
+	choiceSeats:   regexp.MustCompile(`мест|человек|сколько вас|орын|адам|seat|people`),
+	choiceDate:    regexp.MustCompile(`день|дат|числ|когда|күн|date|day|when`),
+	choiceStart:   regexp.MustCompile(`во сколько|к скольки|врем|когда|нешеде|time|when`),
+	choiceHours:   regexp.MustCompile(`час|долго|сағат|hour|how long`),
+	choiceConfirm: regexp.MustCompile(`подтвер|брониру|оформ|верно|всё так|все так|растай|confirm|book`),
+}
+
+// BookingKeyboard — кнопки к ответу бота: варианты первого незаполненного
+// слота черновика или «Подтвердить / Отмена», когда всё известно. Текст
+// ответа пишет LLM, поэтому кнопки показываются, только если reply
+// спрашивает именно этот шаг. nil — разговор не о брони, бронь уже
+// создана или LLM спросила о другом.
+func (s *AIService) BookingKeyboard(ctx context.Context, clientID, userMessage, reply string) models.Keyboard {
+	repo, ok := s.ContextManager.(DraftRepository)
+	if !ok || s.ToolsProvider == nil {
+		return nil
+	}
+	draft, err := repo.GetDraft(ctx, clientID)
+	if err != nil || draft.BookingID != "" {
+		return nil
+	}
+	if draftEmpty(draft) && !reBookingIntent.MatchString(strings.ToLower(userMessage)) {
+		return nil
+	}
+	kb := s.nextStep(ctx, clientID, draft).Keyboard
+	if !asksStep(reply, kb) {
+		return nil
+	}
+	return kb
+}
+
+// asksStep — есть ли в reply вопрос о шаге, к которому относятся кнопки kb.
+func asksStep(reply string, kb models.Keyboard) bool {
+	if len(kb) == 0 || len(kb[0]) == 0 {
+		return false
+	}
+	data := kb[0][0].Data
+	for prefix, re := range reStepQuestion {
+		if !strings.HasPrefix(data, prefix) {
+			continue
+		}
+		for _, q := range questions(strings.ToLower(reply)) {
+			if re.MatchString(q) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// questions — вопросительные предложения текста.
+func questions(text string) []string {
+	var out []string
+	for i, r := range text {
+		if r != '?' {
+			continue
+		}
+		start := strings.LastIndexAny(text[:i], ".!?\n") + 1
+		if q := strings.TrimSpace(text[start:i]); q != "" {
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+// HandleBookingChoice — нажатие кнопки брони: значение идёт в черновик,
+// ответ — следующий вопрос с кнопками. «Подтвердить» создаёт бронь.
+// Обе реплики сохраняются в историю, чтобы LLM видела выбор клиента.
+// Пустой ответ — диалог у оператора: нажатие (pressed — подпись кнопки)
+// только пересылается ему.
+func (s *AIService) HandleBookingChoice(ctx context.Context, clientID, data, pressed string) (models.BotReply, error) {
+	repo, ok := s.ContextManager.(DraftRepository)
+	if !ok || s.ToolsProvider == nil {
+		return models.BotReply{}, fmt.Errorf("черновики броней недоступны")
+	}
+	if s.activeHandoff(ctx, clientID) {
+		_ = s.ContextManager.SaveMessage(ctx, clientID, "client", pressed)
+		s.notifyAdmin(fmt.Sprintf("💬 %s нажал кнопку «%s»\n%s", clientID, pressed, operatorHint(clientID)))
+		return models.BotReply{}, nil
+	}
+	draft, err := repo.GetDraft(ctx, clientID)
+	if err != nil {
+		return models.BotReply{}, err
+	}
+
+	label, reply := s.applyChoice(ctx, clientID, draft, data)
+	if err := repo.SaveDraft(ctx, draft); err != nil {
+		log.Printf("[Draft] save failed for %s: %v", clientID, err)
+	}
+
+	_ = s.ContextManager.SaveMessage(ctx, clientID, "client", label)
+	_ = s.ContextManager.SaveMessage(ctx, clientID, "bot", reply.Text)
+	return reply, nil
+}
+
+// applyChoice — меняет draft по кнопке; возвращает подпись выбора для
+// истории и ответ клиенту.
+func (s *AIService) applyChoice(ctx context.Context, clientID string, draft *models.BookingDraft, data string) (string, models.BotReply) {
+	if draft.BookingID != "" {
+		return "[кнопка брони]", models.BotReply{Text: fmt.Sprintf(
+			"Бронь %s уже оформлена. Если нужна ещё одна — напиши, на когда и сколько мест.", draft.BookingID)}
+	}
+
+	stale := models.BotReply{Text: "Эта кнопка уже неактуальна."}
+	switch {
+	case data == choiceCancel:
+		draft.Seats, draft.Date, draft.StartTime, draft.Hours, draft.PriceQuote, draft.Game = 0, "", "", 0, 0, ""
+		return "Отмена", models.BotReply{Text: "Хорошо, ничего не бронирую. Когда захочешь — просто напиши 🙂"}
+
+	case data == choiceConfirm:
+		if len(draft.MissingSlots()) > 0 {
+			return "Подтвердить", s.nextStep(ctx, clientID, draft)
+		}
+		return "Подтвердить", s.confirmDraft(ctx, clientID, draft)
+
+	case strings.HasPrefix(data, choiceSeats):
+		n, err := strconv.Atoi(strings.TrimPrefix(data, choiceSeats))
+		if err != nil || n <= 0 {
+			return data, stale
+		}
+		draft.Seats = n
+		return fmt.Sprintf("Мест: %d", n), s.nextStep(ctx, clientID, draft)
+
+	case strings.HasPrefix(data, choiceDate):
+		d := strings.TrimPrefix(data, choiceDate)
+		day, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			return data, stale
+		}
+		if draft.Date != d {
+			draft.Date, draft.StartTime = d, "" // время выбиралось под другой день
+		}
+		return "Дата: " + day.Format("02.01"), s.nextStep(ctx, clientID, draft)
+
+	case strings.HasPrefix(data, choiceStart):
+		start, err := time.Parse(choiceStartLayout, strings.TrimPrefix(data, choiceStart))
+		if err != nil {
+			return data, stale
+		}
+		draft.Date, draft.StartTime = start.Format("2006-01-02"), start.Format("15:04")
+		return "Время: " + draft.StartTime, s.nextStep(ctx, clientID, draft)
+
+	case strings.HasPrefix(data, choiceHours):
+		n, err := strconv.Atoi(strings.TrimPrefix(data, choiceHours))
+		if err != nil || n <= 0 || n > availability.MaxHours {
+			return data, stale
+		}
+		draft.Hours = n
+		return fmt.Sprintf("Часов: %d", n), s.nextStep(ctx, clientID, draft)
+	}
+	return data, stale
+}
+
+// confirmDraft — бронь по черновику; не вышло (место заняли) — время
+// сбрасывается и предлагается заново.
+func (s *AIService) confirmDraft(ctx context.Context, clientID string, draft *models.BookingDraft) models.BotReply {
+	args := map[string]any{
+		"date": draft.Date, "time": draft.StartTime,
+		"seats": float64(draft.Seats), "hours": float64(draft.Hours), "game": draft.Game,
+	}
+	out, err := s.ToolsProvider.CreateBooking(ctx, clientID, draft.Date, draft.StartTime, draft.Seats, draft.Hours, draft.Game, draft.PromoCode, "")
+	if err != nil {
+		draft.StartTime = ""
+		next := s.nextStep(ctx, clientID, draft)
+		next.Text = fmt.Sprintf("Не получилось забронировать: %v.\n\n%s", err, next.Text)
+		return next
+	}
+
+	// Тот же путь, что у инструмента CreateBooking: ID брони → черновик и сессия
+	s.updateDraftFromTool(ctx, clientID, "CreateBooking", args, out)
+	if saved, err := s.ContextManager.(DraftRepository).GetDraft(ctx, clientID); err == nil {
+		*draft = *saved
+	}
+	return models.BotReply{Text: fmt.Sprintf(
+		"Готово! Забронировал %s в %s: мест %d, часов %d.\nБронь %s.\nОплатить можно онлайн или на месте — напиши, как удобнее.",
+		formatDraftDate(draft.Date, draft.StartTime), draft.StartTime, draft.Seats, draft.Hours, out)}
+}
+
+// nextStep — вопрос о первом незаполненном слоте с кнопками вариантов или
+// итог с ценой и «Подтвердить / Отмена».
+func (s *AIService) nextStep(ctx context.Context, clientID string, draft *models.BookingDraft) models.BotReply {
+	missing := draft.MissingSlots()
+	if len(missing) == 0 {
+		text := fmt.Sprintf("Итак: %s в %s, мест %d, часов %d.",
+			formatDraftDate(draft.Date, draft.StartTime), draft.StartTime, draft.Seats, draft.Hours)
+		if quote, err := s.ToolsProvider.GetPrice(ctx, clientID, draft.Seats, draft.Hours, draft.Date, draft.StartTime, draft.PromoCode); err == nil {
+			if m := reQuoteTotal.FindStringSubmatch(quote); m != nil {
+				draft.PriceQuote, _ = strconv.ParseFloat(m[1], 64)
+				text += fmt.Sprintf(" Стоимость: %s тг.", m[1])
+			}
+		}
+		return models.BotReply{
+			Text: text + "\nПодтверждаешь бронь?",
+			Keyboard: models.Keyboard{{
+				{Text: "✅ Подтвердить", Data: choiceConfirm},
+				{Text: "✖️ Отмена", Data: choiceCancel},
+			}},
+		}
+	}
+
+	switch missing[0] {
+	case "seats":
+		capacity, err := s.ToolsProvider.SeatCapacity(ctx)
+		if err != nil || capacity <= 0 || capacity > keyboardMaxSeats {
+			capacity = keyboardMaxSeats
+		}
+		var row []models.Button
+		for n := 1; n <= capacity; n++ {
+			row = append(row, models.Button{Text: strconv.Itoa(n), Data: choiceSeats + strconv.Itoa(n)})
+		}
+		return models.BotReply{Text: "Сколько мест бронируем?", Keyboard: models.Keyboard{row}}
+
+	case "date":
+		return models.BotReply{Text: "На какой день?", Keyboard: dateKeyboard()}
+
+	case "time":
+		starts, err := s.ToolsProvider.FreeStarts(ctx, draft.Date, draft.Seats, max(draft.Hours, 1))
+		if err != nil || len(starts) == 0 {
+			day := draft.Date
+			draft.Date = ""
+			return models.BotReply{
+				Text:     fmt.Sprintf("%s свободного времени (мест: %d) уже нет. Выбери другой день:", capitalize(formatDraftDate(day, "")), draft.Seats),
+				Keyboard: dateKeyboard(),
+			}
+		}
+		if len(starts) > keyboardMaxStarts {
+			starts = starts[:keyboardMaxStarts]
+		}
+		var buttons []models.Button
+		for _, st := range starts {
+			buttons = append(buttons, models.Button{Text: st.Format("15:04"), Data: choiceStart + st.Format(choiceStartLayout)})
+		}
+		return models.BotReply{
+			Text:     fmt.Sprintf("Во сколько начинаем? Свободное время (мест: %d):", draft.Seats),
+			Keyboard: rows(buttons, 4),
+		}
+
+	case "hours":
+		var row []models.Button
+		for h := 1; h <= keyboardMaxHours; h++ {
+			if s.startFree(ctx, draft, h) {
+				row = append(row, models.Button{Text: fmt.Sprintf("%d ч", h), Data: choiceHours + strconv.Itoa(h)})
+			}
+		}
+		if len(row) == 0 {
+			start := draft.StartTime
+			draft.StartTime = ""
+			next := s.nextStep(ctx, clientID, draft)
+			next.Text = fmt.Sprintf("С %s уже занято. %s", start, next.Text)
+			return next
+		}
+		return models.BotReply{Text: "На сколько часов?", Keyboard: models.Keyboard{row}}
+	}
+	return models.BotReply{Text: "Продолжим. На какое время, сколько мест и на сколько часов планируешь?"}
+}
+
+// startFree — свободен ли выбранный старт черновика на hours часов.
+func (s *AIService) startFree(ctx context.Context, draft *models.BookingDraft, hours int) bool {
+	start, err := time.Parse("2006-01-02 15:04", draft.Date+" "+draft.StartTime)
+	if err != nil {
+		return false
+	}
+	starts, err := s.ToolsProvider.FreeStarts(ctx, availability.BusinessDay(start).Format("2006-01-02"), draft.Seats, hours)
+	if err != nil {
+		return false
+	}
+	for _, st := range starts {
+		if st.Equal(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// dateKeyboard — сегодня, завтра и послезавтра (рабочие дни клуба).
+func dateKeyboard() models.Keyboard {
+	today := availability.BusinessDay(availability.Now())
+	var row []models.Button
+	for i := 0; i < keyboardDays; i++ {
+		d := today.AddDate(0, 0, i)
+		row = append(row, models.Button{Text: capitalize(businessDayWord(d)), Data: choiceDate + d.Format("2006-01-02")})
+	}
+	return models.Keyboard{row}
+}
+
+// rows — кнопки по perRow в ряд.
+func rows(buttons []models.Button, perRow int) models.Keyboard {
+	var kb models.Keyboard
+	for len(buttons) > perRow {
+		kb, buttons = append(kb, buttons[:perRow]), buttons[perRow:]
+	}
+	if len(buttons) > 0 {
+		kb = append(kb, buttons)
+	}
+	return kb
+}
+
+func draftEmpty(d *models.BookingDraft) bool {
+	return d.Seats == 0 && d.Date == "" && d.StartTime == "" && d.Hours == 0
+}
+
+// formatDraftDate — день сеанса: «сегодня», «завтра» или 02.01. Считается
+// по рабочим дням клуба: ночные часы — ещё тот же день, что и вечер.
+func formatDraftDate(date, clock string) string {
+	if clock == "" {
+		clock = "12:00"
+	}
+	start, err := time.Parse("2006-01-02 15:04", date+" "+clock)
+	if err != nil {
+		return date
+	}
+	return businessDayWord(availability.BusinessDay(start))
+}
+
+// businessDayWord — рабочий день относительно текущего.
+func businessDayWord(day time.Time) string {
+	today := availability.BusinessDay(availability.Now())
+	switch {
+	case sameDate(day, today):
+		return "сегодня"
+	case sameDate(day, today.AddDate(0, 0, 1)):
+		return "завтра"
+	default:
+		return day.Format("02.01")
+	}
+}
+
+func capitalize(s string) string {
+	r := []rune(s)
+	if len(r) == 0 {
+		return s
+	}
+	return strings.ToUpper(string(r[:1])) + string(r[1:])
+}
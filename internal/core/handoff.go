@@ -0,0 +1,389 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/events"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// ================================
+// Передача диалога оператору
+// ================================
+//
+// Бот (CallOperator), ключевое слово клиента или ответ оператора (/reply)
+// передают диалог человеку: бот перестаёт отвечать, сообщения клиента и
+// нажатия кнопок пересылаются администратору. Боту диалог возвращается
+// командой /release или сам, если оператор молчит дольше HandoffIdle:
+// заданием планировщика (models.JobHandoffIdle, переносится каждым ответом
+// оператора), а без планировщика — при следующем сообщении клиента.
+
+// DefaultHandoffIdle — простой оператора до автоматического возврата боту.
+const DefaultHandoffIdle = 30 * time.Minute
+
+// DefaultHandoffKeywords — фразы клиента, после которых бот сразу зовёт
+// человека: просьба об операторе или явная жалоба. Совпадение — целыми
+// словами, поэтому «администратор на месте?» диалог не передаёт.
+var DefaultHandoffKeywords = []string{
+	"оператор", "оператора", "живой человек", "живого человека",
+	"позовите человека", "позови человека", "позовите администратора", "позови администратора",
+	"жалоба", "верните деньги",
+	"адам керек", "әкімші керек",
+	"operator",
+}
+
+// handoffReply — ответ клиенту, когда диалог передан по ключевому слову.
+const handoffReply = "Передал ваш вопрос администратору — он ответит здесь в ближайшее время."
+
+// handoffIdleReply — клиенту, когда оператор не ответил и диалог снова у бота.
+const handoffIdleReply = "Администратор пока не на связи, поэтому снова отвечаю я, бот. Чем помочь?"
+
+// Команды оператора в чате администратора.
+const (
+	cmdReply    = "/reply"
+	cmdRelease  = "/release"
+	cmdHandoffs = "/handoffs"
+)
+
+func (s *AIService) handoffRepo() (HandoffRepository, bool) {
+	repo, ok := s.ContextManager.(HandoffRepository)
+	return repo, ok
+}
+
+// StartHandoff — передать диалог оператору: бот замолкает, администратору
+// уходит эскалация с перепиской (AdminAlerts). false — уже у оператора.
+func (s *AIService) StartHandoff(ctx context.Context, clientID, reason string) (bool, error) {
+	repo, ok := s.handoffRepo()
+	if !ok {
+		return false, fmt.Errorf("передача оператору не поддерживается хранилищем")
+	}
+	started, err := repo.StartHandoff(ctx, clientID, reason)
+	if err != nil || !started {
+		return false, err
+	}
+	log.Printf("[Handoff] %s → operator: %s", clientID, reason)
+	s.publish(ctx, events.ClientEscalated{ClientID: clientID, Reason: reason})
+	s.scheduleHandoffRelease(ctx, clientID)
+	return true, nil
+}
+
+func (s *AIService) handoffIdle() time.Duration {
+	if s.HandoffIdle <= 0 {
+		return DefaultHandoffIdle
+	}
+	return s.HandoffIdle
+}
+
+// activeHandoff — диалог клиента сейчас у оператора? Истёкший по простою
+// возвращается боту (если задание планировщика ещё не успело).
+func (s *AIService) activeHandoff(ctx context.Context, clientID string) bool {
+	repo, ok := s.handoffRepo()
+	if !ok {
+		return false
+	}
+	h, err := repo.GetActiveHandoff(ctx, clientID)
+	if err != nil {
+		log.Printf("[Handoff] %s: %v", clientID, err)
+		return false
+	}
+	if h == nil {
+		return false
+	}
+	if availability.Now().Sub(h.LastOperatorAt) < s.handoffIdle() {
+		return true
+	}
+
+	if _, err := s.endIdleHandoff(ctx, clientID); err != nil {
+		log.Printf("[Handoff] %s: %v", clientID, err)
+	}
+	return false
+}
+
+// endIdleHandoff — вернуть боту диалог, в котором оператор молчал дольше
+// HandoffIdle, и сообщить администратору. false — диалог уже у бота.
+func (s *AIService) endIdleHandoff(ctx context.Context, clientID string) (bool, error) {
+	repo, ok := s.handoffRepo()
+	if !ok {
+		return false, nil
+	}
+	ended, err := repo.EndHandoff(ctx, clientID, models.HandoffEndedByTimeout)
+	if err != nil || !ended {
+		return false, err
+	}
+	log.Printf("[Handoff] %s → bot (idle)", clientID)
+	s.notifyAdmin(fmt.Sprintf("⏱ %s снова у бота: оператор не отвечал %d мин.", clientID, int(s.handoffIdle().Minutes())))
+	return true, nil
+}
+
+// scheduleHandoffRelease — задание вернуть диалог боту через HandoffIdle;
+// повторный вызов (ответ оператора) переносит его.
+func (s *AIService) scheduleHandoffRelease(ctx context.Context, clientID string) {
+	if s.TaskManager == nil {
+		return
+	}
+	if _, err := s.TaskManager.Schedule(ctx, models.ScheduledJob{
+		Kind: models.JobHandoffIdle, Ref: clientID, RunAt: availability.Now().Add(s.handoffIdle()),
+	}); err != nil {
+		log.Printf("[Handoff] schedule release %s: %v", clientID, err)
+	}
+}
+
+// releaseIdleHandoff — задание JobHandoffIdle: оператор так и не ответил —
+// диалог снова у бота, клиент и администратор получают сообщение.
+func (s *AIService) releaseIdleHandoff(ctx context.Context, job models.ScheduledJob) error {
+	repo, ok := s.handoffRepo()
+	if !ok {
+		return nil
+	}
+	h, err := repo.GetActiveHandoff(ctx, job.Ref)
+	if err != nil || h == nil {
+		return err
+	}
+	if availability.Now().Sub(h.LastOperatorAt) < s.handoffIdle() {
+		return nil // оператор ответил, а задание не перенеслось — вернёт activeHandoff
+	}
+
+	ended, err := s.endIdleHandoff(ctx, job.Ref)
+	if err != nil || !ended || s.Messenger == nil {
+		return err
+	}
+	if err := s.Messenger.SendToClient(ctx, job.Ref, handoffIdleReply); err != nil {
+		log.Printf("[Handoff] notify client %s: %v", job.Ref, err)
+		return nil // диалог уже у бота; повтор задания его не найдёт
+	}
+	if err := s.ContextManager.SaveMessage(ctx, job.Ref, "bot", handoffIdleReply); err != nil {
+		log.Printf("[Handoff] save message %s: %v", job.Ref, err)
+	}
+	return nil
+}
+
+// handoffTrigger — просит ли клиент человека (ключевые слова целыми словами).
+func (s *AIService) handoffTrigger(text string) (string, bool) {
+	keywords := s.HandoffKeywords
+	if keywords == nil {
+		keywords = DefaultHandoffKeywords
+	}
+	lower := strings.ToLower(text)
+	for _, k := range keywords {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" && containsWord(lower, k) {
+			return fmt.Sprintf("клиент написал «%s»", k), true
+		}
+	}
+	return "", false
+}
+
+// containsWord — phrase в text целиком: по краям не буква и не цифра.
+func containsWord(text, phrase string) bool {
+	for from := 0; ; {
+		i := strings.Index(text[from:], phrase)
+		if i < 0 {
+			return false
+		}
+		start, end := from+i, from+i+len(phrase)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		from = start + 1
+	}
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// notifyAdmin — best-effort уведомление администратору.
+func (s *AIService) notifyAdmin(msg string) {
+	if s.Notifier == nil {
+		return
+	}
+	if err := s.Notifier.NotifyAdmin(msg); err != nil {
+		log.Printf("[Handoff] notify admin: %v", err)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Команды оператора
+// -----------------------------------------------------------------------------
+
+// handleOperatorCommands — /reply, /release, /handoffs из чата
+// администратора. Склеенные сообщения разбираются построчно: строка без
+// команды продолжает текст предыдущего /reply. false — это не команда,
+// сообщение идёт ассистенту владельца.
+func (s *AIService) handleOperatorCommands(ctx context.Context, text string) (string, bool) {
+	if !isOperatorCommand(strings.TrimSpace(text)) {
+		return "", false
+	}
+
+	var cmds []string
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if isOperatorCommand(strings.TrimSpace(line)) || len(cmds) == 0 {
+			cmds = append(cmds, strings.TrimSpace(line))
+		} else {
+			cmds[len(cmds)-1] += "\n" + line
+		}
+	}
+
+	var out []string
+	for _, c := range cmds {
+		out = append(out, s.operatorCommand(ctx, c))
+	}
+	return strings.Join(out, "\n"), true
+}
+
+func isOperatorCommand(line string) bool {
+	name, _, _ := strings.Cut(line, " ")
+	switch strings.ToLower(name) {
+	case cmdReply, cmdRelease, cmdHandoffs:
+		return true
+	}
+	return false
+}
+
+func (s *AIService) operatorCommand(ctx context.Context, line string) string {
+	name, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimSpace(rest)
+
+	switch strings.ToLower(name) {
+	case cmdReply:
+		clientID, msg, _ := strings.Cut(rest, " ")
+		if clientID, msg = strings.TrimSpace(clientID), strings.TrimSpace(msg); clientID == "" || msg == "" {
+			return "Формат: /reply WA-7701… текст"
+		}
+		if err := s.OperatorReply(ctx, clientID, msg); err != nil {
+			return fmt.Sprintf("Ошибка: %v", err)
+		}
+		return fmt.Sprintf("✓ Отправлено %s. Бот молчит до /release %s.", clientID, clientID)
+
+	case cmdRelease:
+		if rest == "" {
+			return "Формат: /release WA-7701…"
+		}
+		repo, ok := s.handoffRepo()
+		if !ok {
+			return "Ошибка: передача оператору не поддерживается хранилищем."
+		}
+		ended, err := repo.EndHandoff(ctx, rest, models.HandoffEndedByOperator)
+		if err != nil {
+			return fmt.Sprintf("Ошибка: %v", err)
+		}
+		if s.TaskManager != nil {
+			if _, err := s.TaskManager.Cancel(ctx, rest, models.JobHandoffIdle); err != nil {
+				log.Printf("[Handoff] cancel release %s: %v", rest, err)
+			}
+		}
+		if !ended {
+			return fmt.Sprintf("%s и так у бота.", rest)
+		}
+		log.Printf("[Handoff] %s → bot (released)", rest)
+		return fmt.Sprintf("✓ %s снова у бота.", rest)
+
+	case cmdHandoffs:
+		return s.listHandoffs(ctx)
+	}
+	return ""
+}
+
+// OperatorReply — сообщение оператора клиенту через бота. Диалог
+// остаётся у оператора (или переходит к нему, если ещё был у бота).
+func (s *AIService) OperatorReply(ctx context.Context, clientID, text string) error {
+	if s.Messenger == nil {
+		return fmt.Errorf("отправка клиентам не подключена")
+	}
+	repo, ok := s.handoffRepo()
+	if !ok {
+		return fmt.Errorf("передача оператору не поддерживается хранилищем")
+	}
+
+	if err := s.Messenger.SendToClient(ctx, clientID, text); err != nil {
+		return err
+	}
+	if err := s.ContextManager.SaveMessage(ctx, clientID, models.SenderOperator, text); err != nil {
+		log.Printf("[Handoff] save operator message %s: %v", clientID, err)
+	}
+
+	// Ответ без эскалации — оператор сам перехватил диалог
+	started, err := repo.StartHandoff(ctx, clientID, "оператор перехватил диалог")
+	if err != nil {
+		return err
+	}
+	if started {
+		log.Printf("[Handoff] %s → operator (takeover)", clientID)
+	} else if err := repo.TouchHandoff(ctx, clientID); err != nil {
+		return err
+	}
+	s.scheduleHandoffRelease(ctx, clientID)
+	return nil
+}
+
+func (s *AIService) listHandoffs(ctx context.Context) string {
+	repo, ok := s.handoffRepo()
+	if !ok {
+		return "Ошибка: передача оператору не поддерживается хранилищем."
+	}
+	list, err := repo.ListActiveHandoffs(ctx)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err)
+	}
+	if len(list) == 0 {
+		return "Все диалоги ведёт бот."
+	}
+
+	var b strings.Builder
+	b.WriteString("У оператора:\n")
+	for _, h := range list {
+		fmt.Fprintf(&b, "- %s с %s (%s), последний ответ оператора %s\n",
+			h.ClientID, h.StartedAt.Format("02.01 15:04"), h.Reason, h.LastOperatorAt.Format("15:04"))
+	}
+	return b.String()
+}
+
+// -----------------------------------------------------------------------------
+// Переписка для администратора
+// -----------------------------------------------------------------------------
+
+// transcriptLimit — сколько последних сообщений уходит администратору.
+const transcriptLimit = 10
+
+// formatTranscript — последние сообщения диалога для уведомления.
+func formatTranscript(hist []models.ChatMessage) string {
+	if len(hist) > transcriptLimit {
+		hist = hist[len(hist)-transcriptLimit:]
+	}
+	var b strings.Builder
+	for _, m := range hist {
+		text := strings.TrimSpace(m.Text)
+		if text == "" || m.Sender == "tool" {
+			continue
+		}
+		if r := []rune(text); len(r) > 300 {
+			text = string(r[:300]) + "…"
+		}
+		fmt.Fprintf(&b, "%s %s: %s\n", m.Timestamp.Format("15:04"), senderLabel(m.Sender), text)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func senderLabel(sender string) string {
+	switch sender {
+	case "bot":
+		return "Бот"
+	case models.SenderOperator:
+		return "Оператор"
+	default:
+		return "Клиент"
+	}
+}
+
+// operatorHint — как ответить клиенту из чата администратора.
+func operatorHint(clientID string) string {
+	return fmt.Sprintf("Ответить: %s %s текст · вернуть боту: %s %s", cmdReply, clientID, cmdRelease, clientID)
+}
@@ -2,8 +2,11 @@ package core
 
 import (
 	"context"
+	"net/http"
 	"time"
 
+	"whatsapp-analytics-mvp/internal/events"
+	"whatsapp-analytics-mvp/internal/llm"
 	"whatsapp-analytics-mvp/internal/models"
 	"whatsapp-analytics-mvp/internal/weather"
 )
@@ -15,8 +18,10 @@ import (
 //
 
 // LLMProvider — единый интерфейс для всех движков (OpenAI, Gemini, Hybrid).
+// GenerateChat принимает весь диалог, сам выполняет цикл вызовов инструментов
+// через exec и возвращает (ответ, ошибка, wasOpenAI).
 type LLMProvider interface {
-	Generate(ctx context.Context, systemPrompt, userPrompt string) (string, error)
+	GenerateChat(ctx context.Context, messages []llm.Message, tools []llm.Tool, exec llm.ToolExecutor) (string, error, bool)
 }
 
 //
@@ -27,10 +32,47 @@ type LLMProvider interface {
 
 // ToolsProvider — интерфейс доступа к бизнес-операциям (бронь, цена, слоты).
 type ToolsProvider interface {
-	CheckAvailability(ctx context.Context, date, time string, seats int) (string, error)
-	GetPrice(ctx context.Context, seats, hours int, time string) (string, error)
-	CreateBooking(ctx context.Context, clientID, date, time string, seats, hours int) (string, error)
+	CheckAvailability(ctx context.Context, clientID, date, time string, seats, hours int, game string) (string, error)
+	GetFreeSlots(ctx context.Context, date string) (string, error)
+	// FreeStarts — начала сеансов рабочего дня date (по часам, не в прошлом),
+	// с которых seats станций свободны hours часов подряд.
+	FreeStarts(ctx context.Context, date string, seats, hours int) ([]time.Time, error)
+	// SeatCapacity — сколько станций в работе (максимум мест в брони).
+	SeatCapacity(ctx context.Context) (int, error)
+	// SuggestAlternatives — ближайшие свободные варианты с ценами, если слот занят.
+	SuggestAlternatives(ctx context.Context, clientID, date, time string, seats, hours int, game, promoCode string, limit int) (string, error)
+	GetPrice(ctx context.Context, clientID string, seats, hours int, date, time, promoCode string) (string, error)
+	// CreateBooking — prepaidCode: код сертификата/пакета или "hours" (пакет
+	// часов клиента) — списывается сразу после создания брони.
+	CreateBooking(ctx context.Context, clientID, date, time string, seats, hours int, game, promoCode, prepaidCode string) (string, error)
+	ApplyPromoCode(ctx context.Context, clientID, code string) (string, error)
 	GeneratePaymentLink(ctx context.Context, amount float64, bookingID string) (string, error)
+
+	// Жизненный цикл брони (клиент)
+	GetMyBookings(ctx context.Context, clientID string) (string, error)
+	CancelBooking(ctx context.Context, clientID, bookingID string) (string, error)
+	RescheduleBooking(ctx context.Context, clientID, bookingID, date, time string, hours int) (string, error)
+
+	// Лист ожидания на занятые слоты
+	JoinWaitlist(ctx context.Context, clientID, date, time string, seats, hours int, game string, flexHours int) (string, error)
+	AnswerWaitlistOffer(ctx context.Context, clientID string, accept bool) (string, error)
+	LeaveWaitlist(ctx context.Context, clientID string, entryID int64) (string, error)
+
+	// Турниры и мероприятия клуба
+	ListEvents(ctx context.Context, clientID string) (string, error)
+	RegisterForEvent(ctx context.Context, clientID string, eventID int64, name string) (string, error)
+	CancelEventRegistration(ctx context.Context, clientID string, eventID int64) (string, error)
+
+	// Сертификаты и пакеты часов
+	GetPrepaidBalance(ctx context.Context, clientID, code string) (string, error)
+	PayWithPrepaid(ctx context.Context, clientID, bookingID, code string) (string, error)
+
+	// Лидерборд по времени круга
+	GetMyBestLaps(ctx context.Context, clientID, track string) (string, error)
+	LinkDriverName(ctx context.Context, clientID, driver string) (string, error)
+
+	// Бронь "с улицы" от администратора: сейчас, сразу подтверждена
+	CreateWalkIn(ctx context.Context, clientID string, seats, hours int) (string, error)
 }
 
 //
@@ -58,15 +100,174 @@ type AnalyticsRepo interface {
 	SaveLog(ctx context.Context, entry models.DialogLog) error
 }
 
+// SettingsRepository — фиксированные параметры бизнеса (адрес, часы работы).
+type SettingsRepository interface {
+	GetBusinessSettings(ctx context.Context) (models.BusinessSettings, error)
+}
+
 // ContextManager — управление клиентом, сессией и историей переписки.
 type ContextManager interface {
 	GetProfile(ctx context.Context, clientID string) (*models.ClientProfile, error)
-	GetChatHistory(ctx context.Context, clientID string) ([]map[string]string, error)
+	GetChatHistory(ctx context.Context, clientID string) ([]models.ChatMessage, error)
 
 	SaveMessage(ctx context.Context, clientID, sender, text string) error
 	CreateOrUpdateSession(ctx context.Context, clientID string, bookingID *string) error
 }
 
+// BookingRepository — хранилище броней и станций для ToolsService.
+// CreateBooking подбирает станции и вставляет бронь в одной транзакции
+// (models.ErrNotEnoughSeats, если свободных станций не хватило).
+type BookingRepository interface {
+	GetBookingsBetween(ctx context.Context, from, to time.Time) ([]models.Booking, error)
+	ListRigs(ctx context.Context) ([]models.Rig, error)
+	GetFreeRigs(ctx context.Context, from, to time.Time, game string) ([]models.Rig, error)
+	CreateBooking(ctx context.Context, b *models.Booking, game string) error
+}
+
+// BookingLifecycleRepository — статусы броней и перенос. Каждый переход
+// пишется в журнал booking_events с тем, кто его сделал (bot/admin/client).
+type BookingLifecycleRepository interface {
+	GetBooking(ctx context.Context, bookingID string) (*models.Booking, error)
+	ListClientBookings(ctx context.Context, clientID string, from time.Time) ([]models.Booking, error)
+	SetBookingStatus(ctx context.Context, bookingID, status, actor, note string) error
+	RescheduleBooking(ctx context.Context, b *models.Booking, game, actor string) error
+	GetBookingEvents(ctx context.Context, bookingID string) ([]models.BookingEvent, error)
+}
+
+// RigRepository — управление станциями для админ-инструментов.
+type RigRepository interface {
+	ListRigs(ctx context.Context) ([]models.Rig, error)
+	SetRigStatus(ctx context.Context, rigID int, status string) error
+	GetRigBookings(ctx context.Context, rigID int, from time.Time) ([]models.Booking, error)
+}
+
+// DraftRepository — черновик брони клиента в текущей сессии (слоты скрипта продаж).
+type DraftRepository interface {
+	GetDraft(ctx context.Context, clientID string) (*models.BookingDraft, error)
+	SaveDraft(ctx context.Context, draft *models.BookingDraft) error
+}
+
+// HandoffRepository — передача диалога живому оператору (бот молчит).
+type HandoffRepository interface {
+	StartHandoff(ctx context.Context, clientID, reason string) (bool, error)
+	GetActiveHandoff(ctx context.Context, clientID string) (*models.Handoff, error)
+	TouchHandoff(ctx context.Context, clientID string) error
+	EndHandoff(ctx context.Context, clientID, endedBy string) (bool, error)
+	ListActiveHandoffs(ctx context.Context) ([]models.Handoff, error)
+}
+
+// PromoRepository — промокоды и их погашения. Погашение пишется в
+// транзакции CreateBooking (models.ErrPromoExhausted при исчерпании лимита).
+type PromoRepository interface {
+	GetPromo(ctx context.Context, code string) (*models.PromoCode, error)
+	PromoUsage(ctx context.Context, code, clientID string) (total, byClient int, err error)
+	CreatePromo(ctx context.Context, p *models.PromoCode) error
+	ListPromoStats(ctx context.Context) ([]models.PromoStats, error)
+}
+
+// LoyaltyRepository — сумма завершённых броней клиента и его уровень.
+type LoyaltyRepository interface {
+	CompletedSpend(ctx context.Context, clientID string, now time.Time) (float64, error)
+	UpdateLoyalty(ctx context.Context, clientID string, spent float64, level string) error
+}
+
+// PaymentRepository — счета на оплату броней.
+type PaymentRepository interface {
+	SaveInvoice(ctx context.Context, inv *models.Invoice) error
+	GetInvoice(ctx context.Context, invoiceID string) (*models.Invoice, error)
+	GetPendingInvoice(ctx context.Context, bookingID string) (*models.Invoice, error)
+	// SetInvoiceStatus — from → status; false — счёт уже не в from.
+	SetInvoiceStatus(ctx context.Context, invoiceID, from, status string) (bool, error)
+	// PayInvoice — счёт → paid и запись e в журнал атомарно; false — счёт
+	// уже оплачен (повтор уведомления), журнал не тронут.
+	PayInvoice(ctx context.Context, invoiceID string, e *models.LedgerEntry) (*models.BookingBalance, bool, error)
+}
+
+// LedgerRepository — журнал оплат по броням (депозиты, оплаты, возвраты).
+type LedgerRepository interface {
+	AddLedgerEntry(ctx context.Context, e *models.LedgerEntry) (*models.BookingBalance, error)
+	GetLedger(ctx context.Context, bookingID string) ([]models.LedgerEntry, error)
+	GetBookingBalance(ctx context.Context, bookingID string) (*models.BookingBalance, error)
+}
+
+// WaitlistRepository — лист ожидания и учёт упущенного спроса.
+type WaitlistRepository interface {
+	AddWaitlist(ctx context.Context, e *models.WaitlistEntry) error
+	// ListWaiting — ждущие заявки на слоты после from, в порядке очереди.
+	ListWaiting(ctx context.Context, from time.Time) ([]models.WaitlistEntry, error)
+	ListClientWaitlist(ctx context.Context, clientID string) ([]models.WaitlistEntry, error)
+	ListExpiredOffers(ctx context.Context, now time.Time) ([]models.WaitlistEntry, error)
+	MarkWaitlistOffered(ctx context.Context, id int64, bookingID string, expiresAt time.Time) error
+	SetWaitlistStatus(ctx context.Context, id int64, status string) error
+	// ExpireWaitlist — закрывает ждущие заявки на слоты, начавшиеся до now.
+	ExpireWaitlist(ctx context.Context, now time.Time) (int, error)
+
+	RecordDemandMiss(ctx context.Context, m models.DemandMiss) error
+	TurnedAwayByHour(ctx context.Context, from, to time.Time) ([]models.TurnedAwayHour, error)
+}
+
+// PrepaidRepository — подарочные сертификаты и пакеты часов. Списание
+// пишется в журнал оплат брони способом prepaid.
+type PrepaidRepository interface {
+	IssuePrepaid(ctx context.Context, p *models.PrepaidAccount) error
+	GetPrepaid(ctx context.Context, code string) (*models.PrepaidAccount, error)
+	ListClientPrepaid(ctx context.Context, clientID string) ([]models.PrepaidAccount, error)
+	RedeemPrepaid(ctx context.Context, code, bookingID, actor string) (*models.PrepaidRedemption, *models.BookingBalance, error)
+	// RestorePrepaid — при отмене брони списанное возвращается на баланс.
+	RestorePrepaid(ctx context.Context, bookingID, actor string) (float64, error)
+	PrepaidLiability(ctx context.Context, now time.Time) ([]models.PrepaidLiability, error)
+}
+
+// LapRepository — круги из файлов результатов симуляторов и ники пилотов.
+type LapRepository interface {
+	ImportLaps(ctx context.Context, laps []models.LapRecord) (models.LapImportResult, error)
+	// LinkDriver — ник → клиент; чужой ник перепривязывается только с force.
+	LinkDriver(ctx context.Context, driver, clientID string, force bool) (int, error)
+	// ClientBestLaps — лучшие круги клиента с местом в таблице трассы.
+	ClientBestLaps(ctx context.Context, clientID, track string) ([]models.LapStanding, error)
+	// TopLaps — первые limit мест на каждой трассе за [from, to).
+	TopLaps(ctx context.Context, track string, from, to time.Time, limit int) ([]models.LapStanding, error)
+	LapActivity(ctx context.Context, from, to time.Time) (laps, drivers, clients int, err error)
+}
+
+// ClubEventRepository — турниры и регистрации участников. Станции под
+// мероприятие держит обычная бронь ev_<id>, созданная вместе с ним.
+type ClubEventRepository interface {
+	CreateEvent(ctx context.Context, e *models.ClubEvent) error
+	GetEvent(ctx context.Context, id int64) (*models.ClubEvent, error)
+	ListUpcomingEvents(ctx context.Context, from time.Time) ([]models.ClubEvent, error)
+	CancelEvent(ctx context.Context, eventID int64, actor, note string) (*models.ClubEvent, error)
+
+	RegisterForEvent(ctx context.Context, reg *models.EventRegistration) (*models.ClubEvent, error)
+	CancelEventRegistration(ctx context.Context, eventID int64, clientID string) (*models.ClubEvent, error)
+	ListEventRegistrations(ctx context.Context, eventID int64) ([]models.EventRegistration, error)
+	ListClientRegistrations(ctx context.Context, clientID string) ([]models.EventRegistration, error)
+	MarkRegistrationPaid(ctx context.Context, eventID int64, clientID string) error
+}
+
+//
+// ============================================================================
+//  PAYMENTS / CLIENT MESSAGING
+// ============================================================================
+//
+
+// PaymentProvider — платёжный провайдер (Kaspi, карта, фейк для разработки).
+// ParseCallback проверяет подпись уведомления и разбирает его; неверная
+// подпись — ErrBadSignature.
+type PaymentProvider interface {
+	Name() string
+	CreateInvoice(ctx context.Context, bookingID string, amount float64, description string) (*models.Invoice, error)
+	GetInvoiceStatus(ctx context.Context, invoiceID string) (string, error)
+	Refund(ctx context.Context, invoiceID string, amount float64) error
+	ParseCallback(header http.Header, body []byte) (*models.PaymentCallback, error)
+}
+
+// ClientMessenger — сообщение клиенту в тот канал, откуда он пришёл
+// (по префиксу clientID: WA-… или TG-…).
+type ClientMessenger interface {
+	SendToClient(ctx context.Context, clientID, text string) error
+}
+
 //
 // ============================================================================
 //  NOTIFIER / EVENTS / TASKS
@@ -78,14 +279,83 @@ type NotificationProvider interface {
 	NotifyAdmin(message string) error
 }
 
-// EventBus — шина событий (для будущих маркетинговых триггеров).
+// EventHandler — подписчик шины; получает события по значению
+// (events.BookingCreated и т.п.).
+type EventHandler func(ctx context.Context, e events.Event) error
+
+// EventBus — шина доменных событий. Синхронные подписчики выполняются в
+// Publish, их ошибки возвращаются (из outbox событие будет доставлено
+// повторно); асинхронные — в фоне, ошибки только логируются. Доставка не
+// меньше одного раза: подписчик должен переносить повтор.
 type EventBus interface {
-	Publish(eventName string, payload interface{}) error
+	Publish(ctx context.Context, e events.Event) error
+	Subscribe(name string, h EventHandler)
+	SubscribeAsync(name string, h EventHandler)
 }
 
-// TaskManager — планировщик заданий.
+// OutboxRepository — события, записанные в транзакциях изменений.
+type OutboxRepository interface {
+	ListOutbox(ctx context.Context, now time.Time, limit int) ([]models.OutboxEvent, error)
+	MarkOutboxDelivered(ctx context.Context, id int64) error
+	MarkOutboxFailed(ctx context.Context, id int64, lastError string, retryAt time.Time, dead bool) error
+}
+
+// InboundRepository — очередь входящих событий (вебхуки, long polling).
+type InboundRepository interface {
+	EnqueueInbound(ctx context.Context, e models.InboundEvent) (bool, error)
+	ListInboundHeads(ctx context.Context, now time.Time, limit int) ([]models.InboundEvent, error)
+	ListInboundPending(ctx context.Context, clientID string, limit int) ([]models.InboundEvent, error)
+	MarkInboundProcessing(ctx context.Context, id int64) error
+	MarkInboundDone(ctx context.Context, id int64) error
+	MarkInboundFailed(ctx context.Context, id int64, lastError string, retryAt time.Time, dead bool) error
+	ResetInboundProcessing(ctx context.Context) (int, error)
+}
+
+// InboundQueue — приём входящего события: сохранить и сразу ответить
+// провайдеру, обработка — позже в пуле воркеров. false — дубль.
+type InboundQueue interface {
+	Enqueue(ctx context.Context, e models.InboundEvent) (bool, error)
+}
+
+// WebhookRepository — очередь исходящих вебхуков.
+type WebhookRepository interface {
+	EnqueueWebhooks(ctx context.Context, list []models.WebhookDelivery) error
+	ListDueWebhooks(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error)
+	MarkWebhookDelivered(ctx context.Context, id int64, code int) error
+	MarkWebhookFailed(ctx context.Context, id int64, code int, lastError string, retryAt time.Time, dead bool) error
+	ListWebhookDeliveries(ctx context.Context, f models.WebhookFilter) ([]models.WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error)
+	// ReplayWebhooks — dead → pending: одну доставку (id) или все получателя.
+	ReplayWebhooks(ctx context.Context, id int64, subscriber string) (int, error)
+}
+
+// JobHandler — обработчик заданий одного вида. Ошибка → повтор с backoff.
+// Выполнение не меньше одного раза: обработчик должен быть идемпотентным.
+type JobHandler func(ctx context.Context, job models.ScheduledJob) error
+
+// TaskManager — планировщик заданий. Задания хранятся в БД и переживают
+// рестарт; замыкание не сохранишь, поэтому задание — вид + payload, а код
+// вида регистрируется через Handle при старте.
 type TaskManager interface {
-	Schedule(taskName string, when time.Time, fn func()) error
+	Handle(kind string, h JobHandler)
+	// Schedule — поставить или перенести задание (ключ — job.Key или kind:ref).
+	Schedule(ctx context.Context, job models.ScheduledJob) (*models.ScheduledJob, error)
+	// Cancel — отменить ждущие задания объекта ref (только видов kinds, если заданы).
+	Cancel(ctx context.Context, ref string, kinds ...string) (int, error)
+	CancelJob(ctx context.Context, id int64) (bool, error)
+	List(ctx context.Context, f models.JobFilter) ([]models.ScheduledJob, error)
+}
+
+// JobRepository — хранилище заданий планировщика.
+type JobRepository interface {
+	ScheduleJob(ctx context.Context, j *models.ScheduledJob) error
+	CancelJobs(ctx context.Context, ref string, kinds ...string) (int, error)
+	CancelJob(ctx context.Context, id int64) (bool, error)
+	ListJobs(ctx context.Context, f models.JobFilter) ([]models.ScheduledJob, error)
+	// ClaimDueJobs — созревшие задания в работу на lease (attempts+1).
+	ClaimDueJobs(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.ScheduledJob, error)
+	// FinishJob — done/failed или pending с повтором в retryAt.
+	FinishJob(ctx context.Context, id int64, status, lastError string, retryAt time.Time) error
 }
 
 //
@@ -108,11 +378,36 @@ type TranscriptionProvider interface {
 // WhatsAppSender — отправка сообщений WA (через Wazzup).
 type WhatsAppSender interface {
 	Send(channelID, clientPhone, messageText string) error
+	// SendMedia — файл по публичной ссылке (картинка, PDF, аудио).
+	SendMedia(channelID, clientPhone, contentURI string) error
+}
+
+// OutboundMessageRepository — исходящие сообщения WA и их статусы доставки
+// (для ответа «какие сообщения не дошли»).
+type OutboundMessageRepository interface {
+	SaveOutboundMessage(ctx context.Context, m *models.OutboundMessage) (int64, error)
+	MarkOutboundSent(ctx context.Context, id int64, providerID string, attempts int) error
+	MarkOutboundFailed(ctx context.Context, id int64, lastError string, attempts int) error
+	// UpdateOutboundStatus — false: статус устарел или сообщение ещё не
+	// отмечено отправленным (тогда статус ждёт MarkOutboundSent).
+	UpdateOutboundStatus(ctx context.Context, providerID, status, errText string) (bool, error)
+	ListUndeliveredMessages(ctx context.Context, since, staleBefore time.Time, limit int) ([]models.OutboundMessage, error)
+}
+
+// TelegramOffsetRepository — offset long polling Telegram (следующий update_id).
+type TelegramOffsetRepository interface {
+	GetTelegramOffset(ctx context.Context) (int64, error)
+	SaveTelegramOffset(ctx context.Context, offset int64) error
 }
 
 // TelegramSender — отправка сообщений телеграм-клиенту.
 type TelegramSender interface {
 	Send(chatID int64, text string) error
+	// SendKeyboard — сообщение с inline-кнопками; нажатие приходит
+	// callback_query с Button.Data.
+	SendKeyboard(chatID int64, text string, kb models.Keyboard) error
+	EditMessage(chatID, messageID int64, text string, kb models.Keyboard) error
+	AnswerCallback(callbackID, text string) error
 	SendTyping(chatID int64) error
 	GetFileDirectURL(fileID string) (string, error)
 }
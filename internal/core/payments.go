@@ -0,0 +1,376 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+var (
+	// ErrBadSignature — подпись платёжного уведомления не сошлась.
+	ErrBadSignature = errors.New("неверная подпись уведомления")
+	// ErrUnknownProvider — уведомление от незарегистрированного провайдера.
+	ErrUnknownProvider = errors.New("неизвестный платёжный провайдер")
+)
+
+// ================================
+// PaymentService
+// ================================
+
+// PaymentService — счета на оплату броней, уведомления провайдеров и
+// снятие неоплаченных холдов.
+type PaymentService struct {
+	Providers map[string]PaymentProvider
+	Default   string // провайдер для новых счетов
+
+	Bookings  BookingLifecycleRepository
+	Payments  PaymentRepository
+	Ledger    LedgerRepository
+	Messenger ClientMessenger
+	Notifier  NotificationProvider
+
+	// HoldTimeout — сколько бронь бота держит места без оплаты. 0 — не снимать.
+	HoldTimeout time.Duration
+}
+
+// NewPaymentService — конструктор; первый провайдер становится провайдером
+// по умолчанию. repo — брони, счета и журнал оплат.
+func NewPaymentService(
+	repo interface {
+		BookingLifecycleRepository
+		PaymentRepository
+		LedgerRepository
+	},
+	messenger ClientMessenger,
+	notifier NotificationProvider,
+	holdTimeout time.Duration,
+	providers ...PaymentProvider,
+) *PaymentService {
+	s := &PaymentService{
+		Providers:   map[string]PaymentProvider{},
+		Bookings:    repo,
+		Payments:    repo,
+		Ledger:      repo,
+		Messenger:   messenger,
+		Notifier:    notifier,
+		HoldTimeout: holdTimeout,
+	}
+	for _, p := range providers {
+		if s.Default == "" {
+			s.Default = p.Name()
+		}
+		s.Providers[p.Name()] = p
+	}
+	return s
+}
+
+// -----------------------------------------------------------------------------
+// INVOICE
+// -----------------------------------------------------------------------------
+
+// CreatePaymentLink — ссылка на оплату остатка по брони (за вычетом
+// депозита и прошлых оплат); выставленный на ту же сумму счёт
+// переиспользуется.
+func (s *PaymentService) CreatePaymentLink(ctx context.Context, bookingID string) (*models.Invoice, error) {
+	provider, ok := s.Providers[s.Default]
+	if !ok {
+		return nil, fmt.Errorf("оплата онлайн не настроена")
+	}
+
+	bk, err := s.Bookings.GetBooking(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if bk == nil {
+		return nil, fmt.Errorf("бронь %s не найдена", bookingID)
+	}
+	switch bk.Status {
+	case models.BookingPaid:
+		return nil, fmt.Errorf("бронь %s уже оплачена", bookingID)
+	case models.BookingCancelled, models.BookingNoShow:
+		return nil, fmt.Errorf("бронь %s %s", bookingID, models.BookingStatusLabel(bk.Status))
+	}
+
+	bal, err := s.Ledger.GetBookingBalance(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if bal.Due <= 0 {
+		return nil, fmt.Errorf("бронь %s уже оплачена", bookingID)
+	}
+
+	if inv, err := s.Payments.GetPendingInvoice(ctx, bookingID); err != nil {
+		return nil, err
+	} else if inv != nil && inv.Amount == bal.Due {
+		return inv, nil
+	}
+
+	inv, err := provider.CreateInvoice(ctx, bookingID, bal.Due,
+		fmt.Sprintf("Team Racing Club, бронь %s", bookingID))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", provider.Name(), err)
+	}
+	inv.Provider, inv.BookingID, inv.Status = provider.Name(), bookingID, models.InvoicePending
+	if err := s.Payments.SaveInvoice(ctx, inv); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// -----------------------------------------------------------------------------
+// CALLBACK (/webhook/payments/{provider})
+// -----------------------------------------------------------------------------
+
+// HandleCallback — проверяет подпись, обновляет счёт и переводит бронь
+// в paid. Повторное уведомление об уже оплаченном счёте — не ошибка:
+// статус меняется условно, и журнал пишет только тот, кто его сменил.
+func (s *PaymentService) HandleCallback(ctx context.Context, providerName string, header http.Header, body []byte) error {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return ErrUnknownProvider
+	}
+
+	cb, err := provider.ParseCallback(header, body)
+	if err != nil {
+		return err
+	}
+
+	inv, err := s.Payments.GetInvoice(ctx, cb.InvoiceID)
+	if err != nil {
+		return err
+	}
+	if inv == nil || inv.Provider != providerName {
+		return fmt.Errorf("счёт %s не найден", cb.InvoiceID)
+	}
+
+	if cb.Status == models.InvoicePaid {
+		return s.markPaid(ctx, inv)
+	}
+	changed, err := s.Payments.SetInvoiceStatus(ctx, inv.InvoiceID, models.InvoicePending, cb.Status)
+	if err != nil || !changed {
+		return err
+	}
+	log.Printf("💳 Payment callback %s: invoice %s → %s (booking %s)", providerName, inv.InvoiceID, cb.Status, inv.BookingID)
+	return nil
+}
+
+// markPaid — счёт → paid вместе с записью в журнал, бронь → paid, если
+// оплачена целиком, и сообщение клиенту. Счёт уже оплачен (повтор или
+// параллельное уведомление) — ничего не делает. Если холд уже сняли,
+// а деньги пришли — это к администратору (вернуть или восстановить бронь).
+func (s *PaymentService) markPaid(ctx context.Context, inv *models.Invoice) error {
+	bal, changed, err := s.Payments.PayInvoice(ctx, inv.InvoiceID, &models.LedgerEntry{
+		BookingID: inv.BookingID,
+		Kind:      models.LedgerCharge,
+		Method:    models.MethodOnline,
+		Amount:    inv.Amount,
+		Actor:     models.ActorBot,
+		Note:      inv.Provider,
+		InvoiceID: inv.InvoiceID,
+	})
+	if err != nil || !changed {
+		return err
+	}
+	log.Printf("💳 Invoice %s paid (%s, booking %s)", inv.InvoiceID, inv.Provider, inv.BookingID)
+
+	bk, err := s.Bookings.GetBooking(ctx, inv.BookingID)
+	if err != nil {
+		return err
+	}
+	if bk == nil {
+		return fmt.Errorf("бронь %s не найдена", inv.BookingID)
+	}
+
+	if !bk.IsActive() {
+		s.notify(fmt.Sprintf("Оплата %.0f тг по брони %s (%s), но бронь %s — нужен возврат или восстановление.",
+			inv.Amount, bk.BookingID, bk.ClientID, models.BookingStatusLabel(bk.Status)))
+		return nil
+	}
+
+	settled, err := SettleBooking(ctx, s.Bookings, bk, bal, models.ActorBot,
+		fmt.Sprintf("оплата %s, счёт %s", inv.Provider, inv.InvoiceID))
+	if err != nil {
+		return err
+	}
+
+	if !settled {
+		s.sendToClient(ctx, bk.ClientID, fmt.Sprintf(
+			"Получили %.0f тг по брони %s. Осталось оплатить %.0f тг.", inv.Amount, bk.BookingID, bal.Due))
+		return nil
+	}
+	s.sendToClient(ctx, bk.ClientID, fmt.Sprintf(
+		"Оплата получена ✅ Бронь %s на %s подтверждена: мест — %d, часов — %d. Ждём вас!",
+		bk.BookingID, bk.Start.Format("02.01 15:04"), bk.Seats, bk.Hours))
+	return nil
+}
+
+// SettleBooking — переводит бронь в paid, когда по журналу долга не осталось.
+// true — бронь оплачена (сейчас или раньше).
+func SettleBooking(ctx context.Context, repo BookingLifecycleRepository, bk *models.Booking, bal *models.BookingBalance, actor, note string) (bool, error) {
+	if bal.Due > 0 {
+		return false, nil
+	}
+	if bk.Status == models.BookingPaid || !models.CanTransition(bk.Status, models.BookingPaid) {
+		return bk.Status == models.BookingPaid, nil
+	}
+	if err := repo.SetBookingStatus(ctx, bk.BookingID, models.BookingPaid, actor, note); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// -----------------------------------------------------------------------------
+// UNPAID HOLDS
+// -----------------------------------------------------------------------------
+
+// ReleaseHold — снимает бронь бота, если она так и не оплачена. Вызывается
+// заданием models.JobHoldExpiry через HoldTimeout после создания; бронь
+// с любым поступлением (депозит на кассе) холдом уже не считается. Перед
+// отменой спрашивает провайдера: вдруг уведомление потерялось.
+func (s *PaymentService) ReleaseHold(ctx context.Context, bookingID string) (bool, error) {
+	bk, err := s.Bookings.GetBooking(ctx, bookingID)
+	if err != nil {
+		return false, err
+	}
+	if bk == nil || bk.Status != models.BookingCreated || bk.CreatedBy != models.ActorBot {
+		return false, nil
+	}
+	if ledger, err := s.Ledger.GetLedger(ctx, bookingID); err != nil {
+		return false, err
+	} else if len(ledger) > 0 {
+		return false, nil
+	}
+	if s.paidMeanwhile(ctx, bookingID) {
+		return false, nil
+	}
+
+	note := fmt.Sprintf("не оплачена за %s", s.HoldTimeout)
+	if err := s.Bookings.SetBookingStatus(ctx, bookingID, models.BookingCancelled, models.ActorBot, note); err != nil {
+		if errors.Is(err, models.ErrInvalidTransition) {
+			return false, nil // статус успел смениться
+		}
+		return false, err
+	}
+	log.Printf("[Payments] released unpaid hold %s", bookingID)
+
+	s.sendToClient(ctx, bk.ClientID, fmt.Sprintf(
+		"Бронь %s на %s снята: оплата не поступила. Если ещё актуально — напишите, подберём время заново.",
+		bk.BookingID, bk.Start.Format("02.01 15:04")))
+	return true, nil
+}
+
+// paidMeanwhile — у брони есть счёт, который провайдер считает оплаченным.
+func (s *PaymentService) paidMeanwhile(ctx context.Context, bookingID string) bool {
+	inv, err := s.Payments.GetPendingInvoice(ctx, bookingID)
+	if err != nil || inv == nil {
+		return false
+	}
+	provider, ok := s.Providers[inv.Provider]
+	if !ok {
+		return false
+	}
+
+	status, err := provider.GetInvoiceStatus(ctx, inv.InvoiceID)
+	if err != nil || status != models.InvoicePaid {
+		return false
+	}
+
+	log.Printf("[Payments] invoice %s paid without callback", inv.InvoiceID)
+	return s.markPaid(ctx, inv) == nil
+}
+
+// -----------------------------------------------------------------------------
+// REFUND
+// -----------------------------------------------------------------------------
+
+// Refund — возврат оплаченного счёта брони целиком.
+func (s *PaymentService) Refund(ctx context.Context, bookingID string) (*models.Invoice, error) {
+	bk, err := s.Bookings.GetBooking(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if bk == nil {
+		return nil, fmt.Errorf("бронь %s не найдена", bookingID)
+	}
+
+	inv, err := s.paidInvoice(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	provider, ok := s.Providers[inv.Provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	if err := provider.Refund(ctx, inv.InvoiceID, inv.Amount); err != nil {
+		return nil, fmt.Errorf("%s: %w", inv.Provider, err)
+	}
+	if changed, err := s.Payments.SetInvoiceStatus(ctx, inv.InvoiceID, models.InvoicePaid, models.InvoiceRefunded); err != nil {
+		return nil, err
+	} else if !changed {
+		return nil, fmt.Errorf("счёт %s уже возвращён", inv.InvoiceID)
+	}
+	inv.Status = models.InvoiceRefunded
+
+	if _, err := s.Ledger.AddLedgerEntry(ctx, &models.LedgerEntry{
+		BookingID: bookingID,
+		Kind:      models.LedgerRefund,
+		Method:    models.MethodOnline,
+		Amount:    inv.Amount,
+		Actor:     models.ActorAdmin,
+		Note:      inv.Provider,
+		InvoiceID: inv.InvoiceID,
+	}); err != nil {
+		return inv, err
+	}
+
+	if bk.IsActive() && bk.Start.After(availability.Now()) {
+		if err := s.Bookings.SetBookingStatus(ctx, bookingID, models.BookingCancelled, models.ActorAdmin, "возврат оплаты"); err != nil {
+			return inv, err
+		}
+	}
+	return inv, nil
+}
+
+func (s *PaymentService) paidInvoice(ctx context.Context, bookingID string) (*models.Invoice, error) {
+	type paidLookup interface {
+		GetPaidInvoice(ctx context.Context, bookingID string) (*models.Invoice, error)
+	}
+	repo, ok := s.Payments.(paidLookup)
+	if !ok {
+		return nil, fmt.Errorf("возвраты недоступны")
+	}
+	inv, err := repo.GetPaidInvoice(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if inv == nil {
+		return nil, fmt.Errorf("у брони %s нет оплаченного счёта", bookingID)
+	}
+	return inv, nil
+}
+
+// -----------------------------------------------------------------------------
+// Helpers
+// -----------------------------------------------------------------------------
+
+func (s *PaymentService) sendToClient(ctx context.Context, clientID, text string) {
+	if s.Messenger == nil {
+		return
+	}
+	if err := s.Messenger.SendToClient(ctx, clientID, text); err != nil {
+		log.Printf("[Payments] message to %s failed: %v", clientID, err)
+	}
+}
+
+func (s *PaymentService) notify(msg string) {
+	if s.Notifier != nil {
+		_ = s.Notifier.NotifyAdmin(msg)
+	}
+}
@@ -0,0 +1,321 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/core"
+	"whatsapp-analytics-mvp/internal/data"
+	"whatsapp-analytics-mvp/internal/infrastructure"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// recorder — сообщения клиентам и администратору.
+type recorder struct {
+	mu     sync.Mutex
+	client []string
+	admin  []string
+}
+
+func (r *recorder) SendToClient(ctx context.Context, clientID, text string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.client = append(r.client, text)
+	return nil
+}
+
+func (r *recorder) NotifyAdmin(msg string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.admin = append(r.admin, msg)
+	return nil
+}
+
+type paymentEnv struct {
+	repo     *data.SQLiteContextRepo
+	fake     *infrastructure.FakePaymentProvider
+	payments *core.PaymentService
+	out      *recorder
+}
+
+func newPaymentEnv(t *testing.T) *paymentEnv {
+	t.Helper()
+	repo, err := data.NewSQLiteContextRepo(filepath.Join(t.TempDir(), "payments.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.DB.Close() })
+
+	fake := infrastructure.NewFakePaymentProvider("test-secret", "")
+	out := &recorder{}
+	return &paymentEnv{
+		repo:     repo,
+		fake:     fake,
+		payments: core.NewPaymentService(repo, out, out, 15*time.Minute, fake),
+		out:      out,
+	}
+}
+
+// hold — неоплаченная бронь бота на 4000 тг послезавтра вечером.
+func (e *paymentEnv) hold(t *testing.T) *models.Booking {
+	t.Helper()
+	day := availability.BusinessDay(availability.Now()).AddDate(0, 0, 2)
+	bk := &models.Booking{
+		BookingID: fmt.Sprintf("bk_test_%d", time.Now().UnixNano()),
+		ClientID:  "TG-1",
+		Start:     day.Add(6 * time.Hour),
+		Seats:     1,
+		Hours:     2,
+		Amount:    4000,
+		CreatedBy: models.ActorBot,
+	}
+	if err := e.repo.CreateBooking(context.Background(), bk, ""); err != nil {
+		t.Fatal(err)
+	}
+	return bk
+}
+
+// invoice — счёт на amount, сохранённый так же, как CreatePaymentLink.
+func (e *paymentEnv) invoice(t *testing.T, bk *models.Booking, amount float64) *models.Invoice {
+	t.Helper()
+	ctx := context.Background()
+	if amount == 0 {
+		inv, err := e.payments.CreatePaymentLink(ctx, bk.BookingID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return inv
+	}
+	inv, err := e.fake.CreateInvoice(ctx, bk.BookingID, amount, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv.Provider, inv.Status = e.fake.Name(), models.InvoicePending
+	if err := e.repo.SaveInvoice(ctx, inv); err != nil {
+		t.Fatal(err)
+	}
+	return inv
+}
+
+// pay — клиент оплатил счёт; уведомление уходит в HandleCallback.
+func (e *paymentEnv) pay(t *testing.T, inv *models.Invoice, signature func(string) string) error {
+	t.Helper()
+	body, sig, err := e.fake.Pay(inv.InvoiceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature != nil {
+		sig = signature(sig)
+	}
+	header := http.Header{}
+	header.Set(infrastructure.FakeSignatureHeader, sig)
+	return e.payments.HandleCallback(context.Background(), e.fake.Name(), header, body)
+}
+
+func TestPaymentCallbacks(t *testing.T) {
+	tests := []struct {
+		name       string
+		run        func(t *testing.T, e *paymentEnv, bk *models.Booking) error
+		wantErr    error
+		wantStatus string
+		wantPaid   float64
+		wantAdmin  string // подстрока уведомления администратору; пусто — без уведомлений
+		wantClient string // подстрока последнего сообщения клиенту
+	}{
+		{
+			name: "bad signature",
+			run: func(t *testing.T, e *paymentEnv, bk *models.Booking) error {
+				return e.pay(t, e.invoice(t, bk, 0), func(string) string { return strings.Repeat("0", 64) })
+			},
+			wantErr:    core.ErrBadSignature,
+			wantStatus: models.BookingCreated,
+		},
+		{
+			name: "full payment",
+			run: func(t *testing.T, e *paymentEnv, bk *models.Booking) error {
+				return e.pay(t, e.invoice(t, bk, 0), nil)
+			},
+			wantStatus: models.BookingPaid,
+			wantPaid:   4000,
+			wantClient: "Оплата получена",
+		},
+		{
+			name: "repeated callback is a no-op",
+			run: func(t *testing.T, e *paymentEnv, bk *models.Booking) error {
+				inv := e.invoice(t, bk, 0)
+				if err := e.pay(t, inv, nil); err != nil {
+					return err
+				}
+				return e.pay(t, inv, nil)
+			},
+			wantStatus: models.BookingPaid,
+			wantPaid:   4000,
+			wantClient: "Оплата получена",
+		},
+		{
+			name: "partial payment keeps booking created",
+			run: func(t *testing.T, e *paymentEnv, bk *models.Booking) error {
+				return e.pay(t, e.invoice(t, bk, 1000), nil)
+			},
+			wantStatus: models.BookingCreated,
+			wantPaid:   1000,
+			wantClient: "Осталось оплатить 3000",
+		},
+		{
+			name: "hold release skips booking paid without callback",
+			run: func(t *testing.T, e *paymentEnv, bk *models.Booking) error {
+				inv := e.invoice(t, bk, 0)
+				if _, _, err := e.fake.Pay(inv.InvoiceID); err != nil { // уведомление потерялось
+					t.Fatal(err)
+				}
+				released, err := e.payments.ReleaseHold(context.Background(), bk.BookingID)
+				if released {
+					t.Error("ReleaseHold released a paid booking")
+				}
+				return err
+			},
+			wantStatus: models.BookingPaid,
+			wantPaid:   4000,
+			wantClient: "Оплата получена",
+		},
+		{
+			name: "payment after hold release alerts admin",
+			run: func(t *testing.T, e *paymentEnv, bk *models.Booking) error {
+				inv := e.invoice(t, bk, 0)
+				released, err := e.payments.ReleaseHold(context.Background(), bk.BookingID)
+				if err != nil || !released {
+					t.Fatalf("ReleaseHold = %v, %v; want released", released, err)
+				}
+				return e.pay(t, inv, nil)
+			},
+			wantStatus: models.BookingCancelled,
+			wantPaid:   4000,
+			wantAdmin:  "нужен возврат или восстановление",
+			wantClient: "снята",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newPaymentEnv(t)
+			bk := e.hold(t)
+
+			err := tt.run(t, e, bk)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			ctx := context.Background()
+			got, err := e.repo.GetBooking(ctx, bk.BookingID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", got.Status, tt.wantStatus)
+			}
+
+			ledger, err := e.repo.GetLedger(ctx, bk.BookingID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var paid float64
+			for _, le := range ledger {
+				paid += le.Amount
+			}
+			if paid != tt.wantPaid {
+				t.Errorf("ledger total = %.0f, want %.0f (%d entries)", paid, tt.wantPaid, len(ledger))
+			}
+
+			switch {
+			case tt.wantAdmin == "" && len(e.out.admin) > 0:
+				t.Errorf("unexpected admin alert: %q", e.out.admin)
+			case tt.wantAdmin != "" && (len(e.out.admin) == 0 || !strings.Contains(e.out.admin[len(e.out.admin)-1], tt.wantAdmin)):
+				t.Errorf("admin alerts = %q, want %q", e.out.admin, tt.wantAdmin)
+			}
+
+			if tt.wantClient == "" {
+				return
+			}
+			if len(e.out.client) == 0 || !strings.Contains(e.out.client[len(e.out.client)-1], tt.wantClient) {
+				t.Errorf("client messages = %q, want %q", e.out.client, tt.wantClient)
+			}
+		})
+	}
+}
+
+// Повторное уведомление не пишет второй раз ни в журнал, ни клиенту.
+func TestPaymentCallbackRepeatSendsOnce(t *testing.T) {
+	e := newPaymentEnv(t)
+	bk := e.hold(t)
+	inv := e.invoice(t, bk, 0)
+
+	for i := 0; i < 3; i++ {
+		if err := e.pay(t, inv, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ledger, err := e.repo.GetLedger(context.Background(), bk.BookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ledger) != 1 {
+		t.Errorf("ledger entries = %d, want 1", len(ledger))
+	}
+	if len(e.out.client) != 1 {
+		t.Errorf("client messages = %d, want 1", len(e.out.client))
+	}
+}
+
+// Два уведомления об одной оплате одновременно — деньги в журнал попадают
+// один раз: счёт переводит в paid только один из обработчиков.
+func TestPaymentCallbackConcurrent(t *testing.T) {
+	e := newPaymentEnv(t)
+	bk := e.hold(t)
+	inv := e.invoice(t, bk, 0)
+	body, sig, err := e.fake.Pay(inv.InvoiceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{}
+	header.Set(infrastructure.FakeSignatureHeader, sig)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- e.payments.HandleCallback(context.Background(), e.fake.Name(), header, body)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ledger, err := e.repo.GetLedger(context.Background(), bk.BookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ledger) != 1 {
+		t.Errorf("ledger entries = %d, want 1", len(ledger))
+	}
+	if len(e.out.client) != 1 {
+		t.Errorf("client messages = %d, want 1", len(e.out.client))
+	}
+}
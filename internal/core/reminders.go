@@ -0,0 +1,215 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/events"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// ================================
+// BookingReminders
+// ================================
+
+// BookingReminders — задания планировщика вокруг брони: напоминание перед
+// началом, вопрос «как прошло?» после, снятие неоплаченного холда. Sync
+// вызывается на каждое событие брони (Subscribe) и приводит задания
+// в порядок: перенос двигает их, отмена — снимает.
+type BookingReminders struct {
+	Tasks     TaskManager
+	Bookings  BookingLifecycleRepository
+	Messenger ClientMessenger
+
+	// Payments — снятие холдов; nil или HoldTimeout = 0 — не снимать.
+	Payments *PaymentService
+
+	RemindBefore  time.Duration // напоминание за столько до начала
+	FollowUpAfter time.Duration // вопрос об отзыве через столько после конца
+}
+
+// Значения по умолчанию.
+const (
+	defaultRemindBefore  = 2 * time.Hour
+	defaultFollowUpAfter = time.Hour
+)
+
+// NewBookingReminders — конструктор; регистрирует обработчики в tasks.
+func NewBookingReminders(tasks TaskManager, bookings BookingLifecycleRepository, messenger ClientMessenger, payments *PaymentService) *BookingReminders {
+	r := &BookingReminders{
+		Tasks:         tasks,
+		Bookings:      bookings,
+		Messenger:     messenger,
+		Payments:      payments,
+		RemindBefore:  defaultRemindBefore,
+		FollowUpAfter: defaultFollowUpAfter,
+	}
+	tasks.Handle(models.JobBookingReminder, r.remind)
+	tasks.Handle(models.JobBookingFollowUp, r.followUp)
+	tasks.Handle(models.JobHoldExpiry, r.releaseHold)
+	return r
+}
+
+// -----------------------------------------------------------------------------
+// SYNC
+// -----------------------------------------------------------------------------
+
+// Sync — пересчитать задания брони по её текущему состоянию.
+func (r *BookingReminders) Sync(ctx context.Context, bookingID string) error {
+	bk, err := r.Bookings.GetBooking(ctx, bookingID)
+	if err != nil {
+		return err
+	}
+	if bk == nil || !bk.IsActive() || models.ClientChannel(bk.ClientID) == "" {
+		_, err := r.Tasks.Cancel(ctx, bookingID)
+		return err
+	}
+
+	now := availability.Now()
+	plan := func(kind string, at time.Time) error {
+		if !at.After(now) {
+			_, err := r.Tasks.Cancel(ctx, bookingID, kind)
+			return err
+		}
+		_, err := r.Tasks.Schedule(ctx, models.ScheduledJob{Kind: kind, Ref: bookingID, RunAt: at})
+		return err
+	}
+
+	if err := plan(models.JobBookingReminder, bk.Start.Add(-r.RemindBefore)); err != nil {
+		return err
+	}
+	if err := plan(models.JobBookingFollowUp, availability.End(*bk).Add(r.FollowUpAfter)); err != nil {
+		return err
+	}
+	return r.syncHold(ctx, bk, now)
+}
+
+// syncHold — срок холда считается от создания брони и переносом не
+// сдвигается, поэтому задание ставится только один раз.
+func (r *BookingReminders) syncHold(ctx context.Context, bk *models.Booking, now time.Time) error {
+	if bk.Status != models.BookingCreated || bk.CreatedBy != models.ActorBot ||
+		r.Payments == nil || r.Payments.HoldTimeout <= 0 {
+		_, err := r.Tasks.Cancel(ctx, bk.BookingID, models.JobHoldExpiry)
+		return err
+	}
+
+	existing, err := r.Tasks.List(ctx, models.JobFilter{Ref: bk.BookingID, Kind: models.JobHoldExpiry})
+	if err != nil || len(existing) > 0 {
+		return err
+	}
+	_, err = r.Tasks.Schedule(ctx, models.ScheduledJob{
+		Kind: models.JobHoldExpiry, Ref: bk.BookingID, RunAt: now.Add(r.Payments.HoldTimeout),
+	})
+	return err
+}
+
+// Subscribe — синхронная подписка на события броней: не удалось обновить
+// задания — outbox доставит событие ещё раз.
+func (r *BookingReminders) Subscribe(bus EventBus) {
+	for _, name := range []string{
+		events.NameBookingCreated, events.NameBookingCancelled,
+		events.NameBookingRescheduled, events.NameBookingStatusChanged,
+	} {
+		bus.Subscribe(name, r.onBookingEvent)
+	}
+}
+
+func (r *BookingReminders) onBookingEvent(ctx context.Context, e events.Event) error {
+	id := events.BookingID(e)
+	if id == "" {
+		return nil
+	}
+	if err := r.Sync(ctx, id); err != nil {
+		return fmt.Errorf("напоминания %s: %w", id, err)
+	}
+	return nil
+}
+
+// Backfill — задания для предстоящих броней, созданных до планировщика
+// (или пока он был выключен). Sync идемпотентен, повтор безопасен.
+func (r *BookingReminders) Backfill(ctx context.Context, bookings BookingRepository, horizon time.Duration) (int, error) {
+	now := availability.Now()
+	list, err := bookings.GetBookingsBetween(ctx, now.Add(-r.FollowUpAfter), now.Add(horizon))
+	if err != nil {
+		return 0, err
+	}
+	for _, b := range list {
+		if err := r.Sync(ctx, b.BookingID); err != nil {
+			return 0, err
+		}
+	}
+	return len(list), nil
+}
+
+// -----------------------------------------------------------------------------
+// HANDLERS
+// -----------------------------------------------------------------------------
+
+func (r *BookingReminders) remind(ctx context.Context, job models.ScheduledJob) error {
+	bk, err := r.Bookings.GetBooking(ctx, job.Ref)
+	if err != nil {
+		return err
+	}
+	// Отменена или уже началась (задание пролежало, пока сервис стоял)
+	if bk == nil || !bk.IsActive() || !bk.Start.After(availability.Now()) {
+		return nil
+	}
+
+	text := fmt.Sprintf("Напоминаем: %s в %s ваша бронь %s — мест: %d, часов: %d",
+		dayWord(bk.Start), bk.Start.Format("15:04"), bk.BookingID, bk.Seats, bk.Hours)
+	if len(bk.RigIDs) > 0 {
+		text += ", станции " + availability.FormatRigIDs(bk.RigIDs)
+	}
+	text += ". Ждём вас! Если планы изменились — напишите, перенесём или отменим."
+	if bk.Status == models.BookingCreated {
+		text += " Бронь ещё не оплачена."
+	}
+	return r.send(ctx, bk.ClientID, text)
+}
+
+func (r *BookingReminders) followUp(ctx context.Context, job models.ScheduledJob) error {
+	bk, err := r.Bookings.GetBooking(ctx, job.Ref)
+	if err != nil {
+		return err
+	}
+	if bk == nil || !bk.IsActive() {
+		return nil // отменена или неявка — спрашивать не о чем
+	}
+	return r.send(ctx, bk.ClientID,
+		"Спасибо, что были у нас в Team Racing Club! Как прошёл заезд? Оцените от 1 до 5 и напишите пару слов — нам важно.")
+}
+
+func (r *BookingReminders) releaseHold(ctx context.Context, job models.ScheduledJob) error {
+	if r.Payments == nil {
+		return nil
+	}
+	_, err := r.Payments.ReleaseHold(ctx, job.Ref)
+	return err
+}
+
+// send — ошибка доставки возвращается: задание повторится.
+func (r *BookingReminders) send(ctx context.Context, clientID, text string) error {
+	if r.Messenger == nil {
+		return fmt.Errorf("отправка клиентам не настроена")
+	}
+	return r.Messenger.SendToClient(ctx, clientID, text)
+}
+
+// dayWord — «сегодня», «завтра» или дата.
+func dayWord(t time.Time) string {
+	today := availability.Now()
+	switch {
+	case sameDate(t, today):
+		return "сегодня"
+	case sameDate(t, today.AddDate(0, 0, 1)):
+		return "завтра"
+	default:
+		return t.Format("02.01")
+	}
+}
+
+func sameDate(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
@@ -7,22 +7,11 @@ import (
 	"strings"
 	"time"
 
+	"whatsapp-analytics-mvp/internal/events"
+	"whatsapp-analytics-mvp/internal/llm"
 	"whatsapp-analytics-mvp/internal/models"
-
-	"github.com/google/generative-ai-go/genai"
 )
 
-// ================================
-// LLMProvider (интерфейс движка)
-// ================================
-
-// Вариант минимального интерфейса, чтобы не создавать отдельный файл.
-// Если у тебя уже есть определение — оставь своё, это совместимо.
-type LLMProvider interface {
-	// Generate возвращает (ответ, ошибка, wasOpenAI)
-	Generate(ctx context.Context, systemPrompt, userPrompt string, tools any) (string, error, bool)
-}
-
 // ================================
 // AIService
 // ================================
@@ -31,32 +20,40 @@ type LLMProvider interface {
 type AIService struct {
 	// --- Гибридный движок (OpenAI → fallback на Gemini) ---
 	LLMEngine LLMProvider
-	ModelName string // имя модели Gemini для инструментов
 
 	// --- Хранилища/адаптеры ---
-	AnalyticsRepo AnalyticsAdapter
+	AnalyticsRepo AnalyticsRepo
 	SettingsRepo  SettingsRepository
 	Transcription TranscriptionProvider
 	Notifier      NotificationProvider
 
 	// --- NEW ARCHITECTURE DEPENDENCIES ---
 	ContextManager ContextManager  // Retrieves enriched client profile
-	EventBus       EventBus        // Publishes events
+	EventBus       EventBus        // Domain events: analytics, alerts, triggers
 	TaskManager    TaskManager     // Schedules tasks
 	ToolsProvider  ToolsProvider   // Executes business logic tools
 	WeatherClient  WeatherProvider // Provides weather data for analytics
 
-	// --- (опционально) прямой доступ к Gemini для инструментов ---
-	// Если твой LLMEngine внутри уже содержит genai.Client — можно удалить это поле.
-	// Но тут оставляем: инструменты по-прежнему вызываем через Gemini.
-	Client *genai.Client
+	// HistoryWindow — сколько переписки отдаём модели (кол-во, токены, возраст).
+	HistoryWindow llm.HistoryWindow
+
+	// Payments — счета и возвраты; nil — онлайн-оплата не подключена.
+	Payments *PaymentService
+
+	// Messenger — сообщения клиентам по инициативе клуба (рассылки участникам).
+	Messenger ClientMessenger
+
+	// HandoffIdle — простой оператора до возврата диалога боту;
+	// HandoffKeywords — фразы клиента, передающие диалог человеку
+	// (nil — DefaultHandoffKeywords, пустой список — без ключевых слов).
+	HandoffIdle     time.Duration
+	HandoffKeywords []string
 }
 
 // NewAIService — конструктор.
 func NewAIService(
 	llmEngine LLMProvider, // <— гибридный движок
-	modelName string,
-	analyticsRepo AnalyticsAdapter,
+	analyticsRepo AnalyticsRepo,
 	settingsRepo SettingsRepository,
 	transcriber TranscriptionProvider,
 	notifier NotificationProvider,
@@ -65,12 +62,9 @@ func NewAIService(
 	taskManager TaskManager,
 	toolsProvider ToolsProvider,
 	weatherClient WeatherProvider,
-	// Дополнительно: явный Gemini client для инструментов
-	geminiClient *genai.Client,
 ) *AIService {
-	return &AIService{
+	s := &AIService{
 		LLMEngine:      llmEngine,
-		ModelName:      modelName,
 		AnalyticsRepo:  analyticsRepo,
 		SettingsRepo:   settingsRepo,
 		Transcription:  transcriber,
@@ -80,24 +74,69 @@ func NewAIService(
 		TaskManager:    taskManager,
 		ToolsProvider:  toolsProvider,
 		WeatherClient:  weatherClient,
-		Client:         geminiClient,
+		HistoryWindow:  llm.DefaultHistoryWindow(),
+		HandoffIdle:    DefaultHandoffIdle,
 	}
+	if taskManager != nil {
+		taskManager.Handle(models.JobHandoffIdle, s.releaseIdleHandoff)
+	}
+	return s
 }
 
-// ProcessMessage — ядро контроллера. Возвращает ответ агента.
-func (s *AIService) ProcessMessage(clientID, userMessage string, isAdmin bool) (string, error) {
-	ctx := context.Background()
+// retryKey — метка контекста повторной обработки входящего сообщения.
+type retryKey struct{}
+
+// WithRetry — контекст повтора (очередь входящих, попытка не первая):
+// сообщение клиента уже записано в историю первой попыткой.
+func WithRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryKey{}, true)
+}
 
-	// 1) Persist incoming message (best-effort)
-	if repo, ok := s.ContextManager.(interface {
-		SaveMessage(ctx context.Context, clientID, sender, text string) error
-	}); ok {
-		_ = repo.SaveMessage(ctx, clientID, "client", userMessage)
+func isRetry(ctx context.Context) bool {
+	retry, _ := ctx.Value(retryKey{}).(bool)
+	return retry
+}
+
+// ProcessMessage — ядро контроллера. Возвращает ответ агента; пустой
+// ответ — бот молчит (диалог ведёт оператор).
+func (s *AIService) ProcessMessage(ctx context.Context, clientID, userMessage string, isAdmin bool) (string, error) {
+	// 0) Команды оператора (/reply, /release, /handoffs) — не к ассистенту
+	if isAdmin {
+		if out, ok := s.handleOperatorCommands(ctx, userMessage); ok {
+			return out, nil
+		}
+	}
+
+	// 1) Persist incoming message (best-effort); при повторе оно уже в
+	// истории — второй копии модель не увидит
+	if !isRetry(ctx) {
+		_ = s.ContextManager.SaveMessage(ctx, clientID, "client", userMessage)
+		s.publish(ctx, events.MessageReceived{
+			ClientID: clientID,
+			Channel:  models.ClientChannel(clientID),
+			Text:     userMessage,
+			IsAdmin:  isAdmin,
+			At:       time.Now(),
+		})
 	}
-	if repo, ok := s.ContextManager.(interface {
-		CreateOrUpdateSession(ctx context.Context, clientID string, bookingID *string) error
-	}); ok {
-		_ = repo.CreateOrUpdateSession(ctx, clientID, nil)
+	_ = s.ContextManager.CreateOrUpdateSession(ctx, clientID, nil)
+
+	// 1.1) Диалог у оператора — бот молчит, сообщение уходит администратору
+	if !isAdmin {
+		if s.activeHandoff(ctx, clientID) {
+			s.notifyAdmin(fmt.Sprintf("💬 %s: %s\n%s", clientID, userMessage, operatorHint(clientID)))
+			return "", nil
+		}
+		if reason, ok := s.handoffTrigger(userMessage); ok {
+			started, err := s.StartHandoff(ctx, clientID, reason)
+			if err != nil {
+				log.Printf("[Handoff] %s: %v", clientID, err)
+			}
+			if started {
+				_ = s.ContextManager.SaveMessage(ctx, clientID, "bot", handoffReply)
+				return handoffReply, nil
+			}
+		}
 	}
 
 	// 2) System prompt + tools
@@ -105,147 +144,96 @@ func (s *AIService) ProcessMessage(clientID, userMessage string, isAdmin bool) (
 	if isAdmin {
 		systemInstruction = s.getAdminSystemPrompt()
 	} else {
-		systemInstruction = s.getClientSystemPrompt()
+		// Слоты брони из реплики → черновик; состояние уходит в промпт,
+		// чтобы модель спрашивала только недостающее.
+		draft := s.updateDraftFromMessage(ctx, clientID, userMessage)
+		systemInstruction = s.getClientSystemPrompt() + draftPrompt(draft)
 	}
 
-	// 3) ЛЁГКИЙ ПУТЬ: сначала пробуем гибридный LLMEngine (OpenAI → Gemini-fallback)
-	// ВАЖНО: здесь мы не передаем инструменты, это быстрый ответ для "простых" реплик.
-	// Если нужно вызвать инструменты — перейдем к инструментальному пайплайну ниже.
-	if s.LLMEngine != nil {
-		reply, err, wasOpenAI := s.LLMEngine.Generate(ctx, systemInstruction, userMessage, nil)
-		if err == nil && strings.TrimSpace(reply) != "" {
-			// Сохраняем и выходим
-			if repo, ok := s.ContextManager.(interface {
-				SaveMessage(ctx context.Context, clientID, sender, text string) error
-			}); ok {
-				_ = repo.SaveMessage(ctx, clientID, "bot", reply)
-			}
-			go s.saveAnalyticsLog(clientID, userMessage, reply)
-			log.Printf("[AI] Quick reply via %s", map[bool]string{true: "OpenAI", false: "Gemini-fallback"}[wasOpenAI])
-			return reply, nil
-		}
-		// Если движок не дал валидный ответ — продолжаем штатный пайплайн с инструментами.
-		log.Printf("[AI] Quick reply not used (err=%v). Continue with tools...", err)
-	}
-
-	// 4) ИНСТРУМЕНТАЛЬНЫЙ ПАЙПЛАЙН (через Gemini Tools)
-	//    Он нужен для цен/броней/линков и админ-аналитики.
-	if s.Client == nil {
-		// Без прямого Gemini-клиента инструменты недоступны: мягкий ответ
+	// 3) Один проход через гибридный движок: инструменты объявлены один раз,
+	//    цикл function_call → function_response крутится внутри движка
+	//    у того провайдера, который обслужил ход (OpenAI или Gemini-fallback).
+	if s.LLMEngine == nil {
 		return "Сейчас не могу обработать запрос полностью. Напиши: на когда, сколько мест и на сколько часов?", nil
 	}
 
-	model := s.Client.GenerativeModel(s.ModelName)
-	// Tools
+	var tools []llm.Tool
 	if isAdmin {
-		model.Tools = getAdminTools()
+		tools = GetAdminTools()
 	} else {
-		model.Tools = getClientTools()
+		tools = GetClientTools()
 	}
 
-	model.SystemInstruction = &genai.Content{Parts: []genai.Part{genai.Text(systemInstruction)}}
-	chat := model.StartChat()
-
-	// 5) История (если доступна)
-	if repo, ok := s.ContextManager.(interface {
-		GetChatHistory(ctx context.Context, clientID string) ([]map[string]string, error)
-	}); ok {
-		if hist, err := repo.GetChatHistory(ctx, clientID); err == nil && len(hist) > 0 {
-			h := make([]*genai.Content, 0, len(hist))
-			for _, m := range hist {
-				role := m["role"]
-				txt := m["text"]
-				if txt == "" {
-					continue
-				}
-				h = append(h, &genai.Content{
-					Role:  role,
-					Parts: []genai.Part{genai.Text(txt)},
-				})
-			}
-			chat.History = h
-			log.Printf("📚 Injected history for %s: %d msgs", clientID, len(h))
+	exec := func(ctx context.Context, name string, args map[string]any) (string, error) {
+		if isAdmin {
+			return s.handleAdminToolCall(ctx, name, args)
 		}
+		out, err := s.dispatchClientTool(ctx, name, args, clientID)
+		if err == nil {
+			s.updateDraftFromTool(ctx, clientID, name, args, out)
+		}
+		return out, err
 	}
 
-	// 6) Первичный ответ модели (Gemini)
-	log.Printf("🔧 DEBUG: Send → Gemini (admin=%v, tools=%d)", isAdmin, len(model.Tools))
-	resp, err := chat.SendMessage(ctx, genai.Text(userMessage))
-	if err != nil {
-		// Попробуем честно отреагировать: уведомим и вернём мягкий ответ
-		s.notify(fmt.Sprintf("Gemini API error (initial) for %s: %v", clientID, err))
-		return "Извини, сейчас перегрузка. Попробуй через минуту.", fmt.Errorf("gemini send failed: %w", err)
-	}
+	messages := s.buildMessages(ctx, clientID, systemInstruction, userMessage)
 
-	// 7) Tool loop — обрабатываем FunctionCall → FunctionResponse
-	finalResp, err := s.handleToolLoop(ctx, chat, resp, isAdmin, clientID)
+	text, err, wasOpenAI := s.LLMEngine.GenerateChat(ctx, messages, tools, exec)
 	if err != nil {
-		s.notify(fmt.Sprintf("Tool loop error for %s: %v", clientID, err))
-		return "Произошёл технический сбой. Давай начнём с простого: на когда нужна бронь и на сколько мест?", nil
+		return "Извини, сейчас перегрузка. Попробуй через минуту.", fmt.Errorf("llm generate failed: %w", err)
 	}
+	log.Printf("[AI] Reply via %s (admin=%v, tools=%d, msgs=%d)", map[bool]string{true: "OpenAI", false: "Gemini-fallback"}[wasOpenAI], isAdmin, len(tools), len(messages))
 
-	// 8) Достаём финальный текст
-	text := extractFirstText(finalResp)
+	// 4) Достаём финальный текст
 	if strings.TrimSpace(text) == "" {
 		text = "Продолжим. На какое время, сколько мест и на сколько часов планируешь?"
 	}
 
-	// 9) Сохранение и лог
-	if repo, ok := s.ContextManager.(interface {
-		SaveMessage(ctx context.Context, clientID, sender, text string) error
-	}); ok {
-		_ = repo.SaveMessage(ctx, clientID, "bot", text)
-	}
-	go s.saveAnalyticsLog(clientID, userMessage, text)
+	// 5) Сохранение
+	_ = s.ContextManager.SaveMessage(ctx, clientID, "bot", text)
 
 	return text, nil
 }
 
-// handleToolLoop — цикл обработки function_call → function_response
-func (s *AIService) handleToolLoop(
-	ctx context.Context,
-	chat *genai.ChatSession,
-	firstResp *genai.GenerateContentResponse,
-	isAdmin bool,
-	clientID string,
-) (*genai.GenerateContentResponse, error) {
-
-	const maxSteps = 3
-	resp := firstResp
-
-	for step := 0; step < maxSteps; step++ {
-		call := firstFunctionCall(resp)
-		if call == nil {
-			return resp, nil
-		}
-
-		// Выполняем инструмент
-		var toolOutput string
-		if isAdmin {
-			out, _ := s.handleAdminToolCall(ctx, call.Name, call.Args) // ошибки → в текст
-			toolOutput = out
-		} else {
-			out, _ := s.dispatchClientTool(ctx, call.Name, call.Args, clientID)
-			toolOutput = out
-		}
-
-		fnResp := genai.FunctionResponse{
-			Name: call.Name,
-			Response: map[string]any{
-				"result": toolOutput,
-			},
-		}
+// buildMessages — system prompt + история из ContextManager, обрезанная окном.
+// Входящее сообщение уже сохранено, поэтому обычно оно последнее в истории;
+// если нет (история недоступна) — добавляем его явно.
+func (s *AIService) buildMessages(ctx context.Context, clientID, systemInstruction, userMessage string) []llm.Message {
+	messages := []llm.Message{{Role: llm.RoleSystem, Content: systemInstruction}}
 
-		next, err := chat.SendMessage(ctx, fnResp)
+	if s.ContextManager != nil {
+		hist, err := s.ContextManager.GetChatHistory(ctx, clientID)
 		if err != nil {
-			s.notify(fmt.Sprintf("Gemini API error (tool step) for %s: %v", clientID, err))
-			return nil, fmt.Errorf("gemini function response failed: %w", err)
+			log.Printf("[AI] History unavailable for %s: %v", clientID, err)
 		}
+		for _, m := range hist {
+			if strings.TrimSpace(m.Text) == "" {
+				continue
+			}
+			messages = append(messages, llm.Message{
+				Role:    senderRole(m.Sender),
+				Content: m.Text,
+				Time:    m.Timestamp,
+			})
+		}
+	}
 
-		resp = next
+	if last := messages[len(messages)-1]; last.Role != llm.RoleUser || last.Content != userMessage {
+		messages = append(messages, llm.Message{Role: llm.RoleUser, Content: userMessage, Time: time.Now()})
 	}
 
-	return resp, nil
+	return s.HistoryWindow.Apply(messages, time.Now())
+}
+
+// senderRole — sender из таблицы messages → роль для LLM.
+func senderRole(sender string) llm.Role {
+	switch sender {
+	case "bot", models.SenderOperator:
+		return llm.RoleAssistant
+	case "tool":
+		return llm.RoleTool
+	default:
+		return llm.RoleUser
+	}
 }
 
 // dispatchClientTool — маршрутизация клиентских инструментов к ToolsProvider.
@@ -255,84 +243,143 @@ func (s *AIService) dispatchClientTool(ctx context.Context, name string, args ma
 		date, _ := strArg(args, "date")
 		tm, _ := strArg(args, "time")
 		seats, _ := floatArg(args, "seats")
-		return s.ToolsProvider.CheckAvailability(ctx, date, tm, int(seats))
+		hours, _ := floatArg(args, "hours")
+		game, _ := strArg(args, "game")
+		return s.ToolsProvider.CheckAvailability(ctx, clientID, date, tm, int(seats), int(hours), game)
+
+	case "GetFreeSlots":
+		date, _ := strArg(args, "date")
+		return s.ToolsProvider.GetFreeSlots(ctx, date)
+
+	case "SuggestAlternatives":
+		date, _ := strArg(args, "date")
+		tm, _ := strArg(args, "time")
+		seats, _ := floatArg(args, "seats")
+		hours, _ := floatArg(args, "hours")
+		game, _ := strArg(args, "game")
+		limit, _ := floatArg(args, "limit")
+		return s.ToolsProvider.SuggestAlternatives(ctx, clientID, date, tm, int(seats), int(hours), game, s.draftPromoCode(ctx, clientID), int(limit))
 
 	case "GetPrice":
 		seats, _ := floatArg(args, "seats")
 		hours, _ := floatArg(args, "hours")
+		date, _ := strArg(args, "date")
 		tm, _ := strArg(args, "time")
-		return s.ToolsProvider.GetPrice(ctx, int(seats), int(hours), tm)
+		return s.ToolsProvider.GetPrice(ctx, clientID, int(seats), int(hours), date, tm, s.draftPromoCode(ctx, clientID))
 
 	case "CreateBooking":
 		date, _ := strArg(args, "date")
 		tm, _ := strArg(args, "time")
 		seats, _ := floatArg(args, "seats")
 		hours, _ := floatArg(args, "hours")
-		return s.ToolsProvider.CreateBooking(ctx, clientID, date, tm, int(seats), int(hours))
+		game, _ := strArg(args, "game")
+		prepaid, _ := strArg(args, "prepaid_code")
+		return s.ToolsProvider.CreateBooking(ctx, clientID, date, tm, int(seats), int(hours), game, s.draftPromoCode(ctx, clientID), prepaid)
 
 	case "GeneratePaymentLink":
 		amount, _ := floatArg(args, "amount")
 		bookingID, _ := strArg(args, "bookingID")
 		return s.ToolsProvider.GeneratePaymentLink(ctx, amount, bookingID)
 
+	case "ApplyPromoCode":
+		code, _ := strArg(args, "code")
+		return s.ToolsProvider.ApplyPromoCode(ctx, clientID, code)
+
+	case "GetMyBookings":
+		return s.ToolsProvider.GetMyBookings(ctx, clientID)
+
+	case "CancelBooking":
+		bookingID, _ := strArg(args, "booking_id")
+		return s.ToolsProvider.CancelBooking(ctx, clientID, bookingID)
+
+	case "RescheduleBooking":
+		bookingID, _ := strArg(args, "booking_id")
+		date, _ := strArg(args, "date")
+		tm, _ := strArg(args, "time")
+		hours, _ := floatArg(args, "hours")
+		return s.ToolsProvider.RescheduleBooking(ctx, clientID, bookingID, date, tm, int(hours))
+
+	case "JoinWaitlist":
+		date, _ := strArg(args, "date")
+		tm, _ := strArg(args, "time")
+		seats, _ := floatArg(args, "seats")
+		hours, _ := floatArg(args, "hours")
+		game, _ := strArg(args, "game")
+		flex, _ := floatArg(args, "flex_hours")
+		return s.ToolsProvider.JoinWaitlist(ctx, clientID, date, tm, int(seats), int(hours), game, int(flex))
+
+	case "AnswerWaitlistOffer":
+		accept, _ := boolArg(args, "accept")
+		return s.ToolsProvider.AnswerWaitlistOffer(ctx, clientID, accept)
+
+	case "LeaveWaitlist":
+		entryID, _ := floatArg(args, "entry_id")
+		return s.ToolsProvider.LeaveWaitlist(ctx, clientID, int64(entryID))
+
+	case "ListEvents":
+		return s.ToolsProvider.ListEvents(ctx, clientID)
+
+	case "RegisterForEvent":
+		eventID, _ := floatArg(args, "event_id")
+		participant, _ := strArg(args, "name")
+		return s.ToolsProvider.RegisterForEvent(ctx, clientID, int64(eventID), participant)
+
+	case "GetPrepaidBalance":
+		code, _ := strArg(args, "code")
+		return s.ToolsProvider.GetPrepaidBalance(ctx, clientID, code)
+
+	case "PayWithPrepaid":
+		bookingID, _ := strArg(args, "booking_id")
+		code, _ := strArg(args, "code")
+		return s.ToolsProvider.PayWithPrepaid(ctx, clientID, bookingID, code)
+
+	case "GetMyBestLaps":
+		track, _ := strArg(args, "track")
+		return s.ToolsProvider.GetMyBestLaps(ctx, clientID, track)
+
+	case "LinkDriverName":
+		driver, _ := strArg(args, "driver")
+		return s.ToolsProvider.LinkDriverName(ctx, clientID, driver)
+
+	case "CancelEventRegistration":
+		eventID, _ := floatArg(args, "event_id")
+		return s.ToolsProvider.CancelEventRegistration(ctx, clientID, int64(eventID))
+
+	case "CallOperator":
+		reason, _ := strArg(args, "reason")
+		if strings.TrimSpace(reason) == "" {
+			reason = "бот позвал оператора"
+		}
+		if _, err := s.StartHandoff(ctx, clientID, reason); err != nil {
+			return fmt.Sprintf("Ошибка: %v", err), nil
+		}
+		return "Диалог передан администратору. Скажи клиенту, что администратор ответит здесь в ближайшее время; дальше бот не отвечает.", nil
+
 	default:
 		return fmt.Sprintf("Ошибка: неизвестный инструмент '%s'", name), nil
 	}
 }
 
-// saveAnalyticsLog — best-effort лог.
-func (s *AIService) saveAnalyticsLog(clientID, userMessage, reply string) {
-	if s.AnalyticsRepo == nil {
-		return
-	}
-	entry := models.DialogLog{
-		ClientID:    clientID,
-		Timestamp:   time.Now(),
-		MessageText: userMessage,
-		Intent:      "unknown",
-		LeadSource:  "whatsapp",
-		Sentiment:   "neutral",
-	}
-	_ = s.AnalyticsRepo.SaveLog(context.Background(), entry)
+// EscalateFailure — бот так и не ответил клиенту: попытки очереди
+// кончились, клиент получил «Ошибка сервера». Одно событие на сообщение,
+// а не на каждую попытку.
+func (s *AIService) EscalateFailure(ctx context.Context, clientID string, err error) {
+	s.publish(ctx, events.ClientEscalated{ClientID: clientID, Reason: fmt.Sprintf("бот не смог ответить: %v", err)})
 }
 
 // -----------------------------
 // Helpers
 // -----------------------------
 
-func (s *AIService) notify(msg string) {
-	if s.Notifier != nil {
-		_ = s.Notifier.NotifyAdmin(msg)
-	}
-}
-
-type fnCall struct {
-	Name string
-	Args map[string]any
-}
-
-func firstFunctionCall(resp *genai.GenerateContentResponse) *fnCall {
-	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
-		return nil
-	}
-	for _, p := range resp.Candidates[0].Content.Parts {
-		if fc, ok := p.(genai.FunctionCall); ok {
-			return &fnCall{Name: fc.Name, Args: fc.Args}
-		}
-	}
-	return nil
-}
-
-func extractFirstText(resp *genai.GenerateContentResponse) string {
-	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
-		return ""
+// publish — событие напрямую в шину (не из outbox): теряется при падении
+// процесса, подходит для сообщений и эскалаций.
+func (s *AIService) publish(ctx context.Context, e events.Event) {
+	if s.EventBus == nil {
+		return
 	}
-	for _, p := range resp.Candidates[0].Content.Parts {
-		if t, ok := p.(genai.Text); ok {
-			return string(t)
-		}
+	if err := s.EventBus.Publish(ctx, e); err != nil {
+		log.Printf("[AI] event %s: %v", e.Name(), err)
 	}
-	return ""
 }
 
 func strArg(m map[string]any, key string) (string, bool) {
@@ -356,3 +403,15 @@ func floatArg(m map[string]any, key string) (float64, bool) {
 	}
 	return 0, false
 }
+
+func boolArg(m map[string]any, key string) (bool, bool) {
+	if v, ok := m[key]; ok {
+		switch b := v.(type) {
+		case bool:
+			return b, true
+		case string: // "true"/"false" от слабых моделей
+			return b == "true", true
+		}
+	}
+	return false, false
+}
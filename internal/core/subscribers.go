@@ -0,0 +1,80 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"whatsapp-analytics-mvp/internal/events"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// ================================
+// Подписчики шины событий
+// ================================
+
+// DialogAnalytics — лог диалогов для аналитики (dialog_logs) из входящих
+// сообщений клиентов.
+type DialogAnalytics struct {
+	Repo AnalyticsRepo
+}
+
+func (a *DialogAnalytics) Subscribe(bus EventBus) {
+	bus.SubscribeAsync(events.NameMessageReceived, a.onMessage)
+}
+
+func (a *DialogAnalytics) onMessage(ctx context.Context, e events.Event) error {
+	msg, ok := e.(events.MessageReceived)
+	if !ok || msg.IsAdmin {
+		return nil
+	}
+	return a.Repo.SaveLog(ctx, models.DialogLog{
+		ClientID:    msg.ClientID,
+		Timestamp:   msg.At,
+		MessageText: msg.Text,
+		Intent:      "unknown",
+		LeadSource:  msg.Channel,
+		Sentiment:   "neutral",
+	})
+}
+
+// AdminAlerts — уведомления администратору: клиенту нужен человек (с
+// последней перепиской, если задан History), клиент отменил бронь, пришла
+// онлайн-оплата.
+type AdminAlerts struct {
+	Notifier NotificationProvider
+	History  ContextManager
+}
+
+func (a *AdminAlerts) Subscribe(bus EventBus) {
+	bus.SubscribeAsync(events.NameClientEscalated, a.onEvent)
+	bus.SubscribeAsync(events.NameBookingCancelled, a.onEvent)
+	bus.SubscribeAsync(events.NamePaymentReceived, a.onEvent)
+}
+
+func (a *AdminAlerts) onEvent(ctx context.Context, e events.Event) error {
+	var msg string
+	switch v := e.(type) {
+	case events.ClientEscalated:
+		msg = fmt.Sprintf("🆘 Клиенту %s нужен администратор: %s", v.ClientID, v.Reason)
+		if a.History != nil {
+			if hist, err := a.History.GetChatHistory(ctx, v.ClientID); err == nil && len(hist) > 0 {
+				msg += "\n\n" + formatTranscript(hist)
+			}
+		}
+		msg += "\n" + operatorHint(v.ClientID)
+	case events.BookingCancelled:
+		if v.Actor != models.ActorClient {
+			return nil // отменил сам администратор или бот по таймауту
+		}
+		msg = fmt.Sprintf("✗ Клиент %s отменил бронь %s на %s (%d мест × %d ч)",
+			v.ClientID, v.BookingID, v.Start.Format("02.01 15:04"), v.Seats, v.Hours)
+	case events.PaymentReceived:
+		if v.Method != models.MethodOnline {
+			return nil // касса и сертификаты проходят через администратора
+		}
+		msg = fmt.Sprintf("💳 Онлайн-оплата %.0f тг по брони %s (%s)", v.Amount, v.BookingID, v.ClientID)
+	default:
+		return nil
+	}
+	return a.Notifier.NotifyAdmin(msg)
+}
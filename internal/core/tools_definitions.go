@@ -1,107 +1,431 @@
 package core
 
-import "github.com/google/generative-ai-go/genai"
+import "whatsapp-analytics-mvp/internal/llm"
 
 // -----------------------------
 // CLIENT TOOLS
 // -----------------------------
 
-func GetClientTools() []*genai.Tool {
-	return []*genai.Tool{
-		{
-			FunctionDeclarations: []*genai.FunctionDeclaration{
-
-				{
-					Name:        "CheckAvailability",
-					Description: "Проверяет доступность мест на указанную дату и время.",
-					Parameters: &genai.Schema{
-						Type: genai.TypeObject,
-						Properties: map[string]*genai.Schema{
-							"date": {
-								Type:        genai.TypeString,
-								Description: "Дата YYYY-MM-DD",
-							},
-							"time": {
-								Type:        genai.TypeString,
-								Description: "Время HH:MM",
-							},
-							"seats": {
-								Type:        genai.TypeInteger,
-								Description: "Количество мест",
-							},
-						},
-						Required: []string{"date", "time", "seats"},
-					},
-				},
-
-				{
-					Name:        "GetPrice",
-					Description: "Рассчитывает стоимость брони.",
-					Parameters: &genai.Schema{
-						Type: genai.TypeObject,
-						Properties: map[string]*genai.Schema{
-							"seats": {
-								Type:        genai.TypeInteger,
-								Description: "Количество мест",
-							},
-							"hours": {
-								Type:        genai.TypeInteger,
-								Description: "Количество часов",
-							},
-							"time": {
-								Type:        genai.TypeString,
-								Description: "Время начала (опционально)",
-							},
-						},
-						Required: []string{"seats", "hours"},
-					},
-				},
-
-				{
-					Name:        "CreateBooking",
-					Description: "Создаёт бронь.",
-					Parameters: &genai.Schema{
-						Type: genai.TypeObject,
-						Properties: map[string]*genai.Schema{
-							"date": {
-								Type:        genai.TypeString,
-								Description: "Дата YYYY-MM-DD",
-							},
-							"time": {
-								Type:        genai.TypeString,
-								Description: "Время HH:MM",
-							},
-							"seats": {
-								Type:        genai.TypeInteger,
-								Description: "Кол-во мест",
-							},
-							"hours": {
-								Type:        genai.TypeInteger,
-								Description: "Сколько часов",
-							},
-						},
-						Required: []string{"date", "time", "seats", "hours"},
-					},
-				},
-
-				{
-					Name:        "GeneratePaymentLink",
-					Description: "Генерирует ссылку на оплату.",
-					Parameters: &genai.Schema{
-						Type: genai.TypeObject,
-						Properties: map[string]*genai.Schema{
-							"amount": {
-								Type:        genai.TypeNumber,
-								Description: "Сумма к оплате",
-							},
-							"bookingID": {
-								Type:        genai.TypeString,
-								Description: "ID брони",
-							},
-						},
-						Required: []string{"amount", "bookingID"},
+// GetClientTools — инструменты клиентского диалога (одинаковы для OpenAI и Gemini).
+func GetClientTools() []llm.Tool {
+	return []llm.Tool{
+
+		{
+			Name:        "CheckAvailability",
+			Description: "Проверяет, свободно ли нужное количество мест на весь сеанс (с учётом пересекающихся броней).",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"date": {
+						Type:        llm.TypeString,
+						Description: "Дата YYYY-MM-DD (ночные часы 00:00–04:00 — это уже следующая дата)",
+					},
+					"time": {
+						Type:        llm.TypeString,
+						Description: "Время HH:MM",
+					},
+					"seats": {
+						Type:        llm.TypeInteger,
+						Description: "Количество мест",
+					},
+					"hours": {
+						Type:        llm.TypeInteger,
+						Description: "Сколько часов (по умолчанию 1)",
+					},
+					"game": {
+						Type:        llm.TypeString,
+						Description: "Игра, если клиент назвал (Assetto Corsa, Automobilista 2, ...)",
+					},
+				},
+				Required: []string{"date", "time", "seats"},
+			},
+		},
+
+		{
+			Name:        "GetFreeSlots",
+			Description: "Свободные места по часам за рабочий день (12:00–04:00).",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"date": {
+						Type:        llm.TypeString,
+						Description: "Дата YYYY-MM-DD (начало рабочего дня)",
+					},
+				},
+				Required: []string{"date"},
+			},
+		},
+
+		{
+			Name:        "SuggestAlternatives",
+			Description: "Если на запрошенное время мест нет: ближайшие свободные варианты с ценами — раньше/позже в тот же день, разделить группу на соседние часы, то же время в другие дни.",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"date": {
+						Type:        llm.TypeString,
+						Description: "Запрошенная дата (YYYY-MM-DD)",
+					},
+					"time": {
+						Type:        llm.TypeString,
+						Description: "Запрошенное время (HH:MM)",
+					},
+					"seats": {
+						Type:        llm.TypeInteger,
+						Description: "Кол-во мест",
+					},
+					"hours": {
+						Type:        llm.TypeInteger,
+						Description: "Сколько часов",
+					},
+					"game": {
+						Type:        llm.TypeString,
+						Description: "Игра, если клиент назвал",
+					},
+					"limit": {
+						Type:        llm.TypeInteger,
+						Description: "Сколько вариантов (по умолчанию 5)",
 					},
 				},
+				Required: []string{"date", "time", "seats", "hours"},
+			},
+		},
+
+		{
+			Name:        "GetPrice",
+			Description: "Рассчитывает стоимость брони по тарифу (будни/выходные/праздники, ночная надбавка, пакетные скидки). Возвращает расчёт построчно и итог.",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"seats": {
+						Type:        llm.TypeInteger,
+						Description: "Количество мест",
+					},
+					"hours": {
+						Type:        llm.TypeInteger,
+						Description: "Количество часов",
+					},
+					"date": {
+						Type:        llm.TypeString,
+						Description: "Дата YYYY-MM-DD (опционально, по умолчанию сегодня)",
+					},
+					"time": {
+						Type:        llm.TypeString,
+						Description: "Время начала HH:MM (опционально)",
+					},
+				},
+				Required: []string{"seats", "hours"},
+			},
+		},
+
+		{
+			Name:        "CreateBooking",
+			Description: "Создаёт бронь и резервирует конкретные станции (по возможности рядом). Возвращает ID брони и номера станций.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"date": {
+						Type:        llm.TypeString,
+						Description: "Дата YYYY-MM-DD",
+					},
+					"time": {
+						Type:        llm.TypeString,
+						Description: "Время HH:MM",
+					},
+					"seats": {
+						Type:        llm.TypeInteger,
+						Description: "Кол-во мест",
+					},
+					"hours": {
+						Type:        llm.TypeInteger,
+						Description: "Сколько часов",
+					},
+					"game": {
+						Type:        llm.TypeString,
+						Description: "Игра, если клиент назвал (Assetto Corsa, Automobilista 2, ...)",
+					},
+					"prepaid_code": {
+						Type:        llm.TypeString,
+						Description: "Оплатить сертификатом (его код) или пакетом часов клиента (\"hours\"); пусто — обычная оплата",
+					},
+				},
+				Required: []string{"date", "time", "seats", "hours"},
+			},
+		},
+
+		{
+			Name:        "GeneratePaymentLink",
+			Description: "Выставляет счёт на оплату брони и возвращает ссылку. Сумма берётся из брони.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"amount": {
+						Type:        llm.TypeNumber,
+						Description: "Сумма к оплате (для сверки)",
+					},
+					"bookingID": {
+						Type:        llm.TypeString,
+						Description: "ID брони",
+					},
+				},
+				Required: []string{"bookingID"},
+			},
+		},
+
+		{
+			Name:        "ApplyPromoCode",
+			Description: "Проверяет промокод клиента. Принятый код сам применяется в GetPrice и CreateBooking этой сессии.",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"code": {
+						Type:        llm.TypeString,
+						Description: "Промокод, как его написал клиент",
+					},
+				},
+				Required: []string{"code"},
+			},
+		},
+
+		{
+			Name:        "GetMyBookings",
+			Description: "Предстоящие брони клиента: ID, время, места, сумма, станции, статус.",
+			Parameters:  &llm.Schema{Type: llm.TypeObject},
+		},
+
+		{
+			Name:        "CancelBooking",
+			Description: "Отменяет бронь клиента. Бесплатно — не позже чем за несколько часов до начала, иначе только через администратора.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"booking_id": {
+						Type:        llm.TypeString,
+						Description: "ID брони (bk_...); если неизвестен — сначала GetMyBookings",
+					},
+				},
+				Required: []string{"booking_id"},
+			},
+		},
+
+		{
+			Name:        "RescheduleBooking",
+			Description: "Переносит бронь клиента на другое время (станции подбираются заново, цена пересчитывается). Те же правила дедлайна, что и у отмены.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"booking_id": {
+						Type:        llm.TypeString,
+						Description: "ID брони (bk_...)",
+					},
+					"date": {
+						Type:        llm.TypeString,
+						Description: "Новая дата YYYY-MM-DD",
+					},
+					"time": {
+						Type:        llm.TypeString,
+						Description: "Новое время HH:MM",
+					},
+					"hours": {
+						Type:        llm.TypeInteger,
+						Description: "Сколько часов (опционально, по умолчанию как было)",
+					},
+				},
+				Required: []string{"booking_id", "date", "time"},
+			},
+		},
+
+		{
+			Name:        "JoinWaitlist",
+			Description: "Ставит клиента в лист ожидания, если мест не хватило. Когда места освободятся, бот сам придержит их и напишет клиенту.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"date": {
+						Type:        llm.TypeString,
+						Description: "Дата (YYYY-MM-DD)",
+					},
+					"time": {
+						Type:        llm.TypeString,
+						Description: "Время начала (HH:MM)",
+					},
+					"seats": {
+						Type:        llm.TypeInteger,
+						Description: "Кол-во мест",
+					},
+					"hours": {
+						Type:        llm.TypeInteger,
+						Description: "Сколько часов",
+					},
+					"game": {
+						Type:        llm.TypeString,
+						Description: "Игра, если клиент назвал",
+					},
+					"flex_hours": {
+						Type:        llm.TypeInteger,
+						Description: "На сколько часов раньше/позже клиент готов сдвинуться (0–3)",
+					},
+				},
+				Required: []string{"date", "time", "seats", "hours"},
+			},
+		},
+
+		{
+			Name:        "AnswerWaitlistOffer",
+			Description: "Ответ клиента на предложение из листа ожидания: забрать придержанные места или отказаться.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"accept": {
+						Type:        llm.TypeBoolean,
+						Description: "true — забирает, false — отказывается",
+					},
+				},
+				Required: []string{"accept"},
+			},
+		},
+
+		{
+			Name:        "LeaveWaitlist",
+			Description: "Снимает заявку клиента с листа ожидания (номер заявки — из GetMyBookings).",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"entry_id": {
+						Type:        llm.TypeInteger,
+						Description: "Номер заявки",
+					},
+				},
+				Required: []string{"entry_id"},
+			},
+		},
+
+		{
+			Name:        "ListEvents",
+			Description: "Ближайшие турниры и мероприятия клуба: дата, трасса, класс машин, взнос, свободные места.",
+			Parameters:  &llm.Schema{Type: llm.TypeObject, Properties: map[string]*llm.Schema{}},
+		},
+
+		{
+			Name:        "RegisterForEvent",
+			Description: "Регистрирует клиента на турнир (номер мероприятия — из ListEvents).",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"event_id": {
+						Type:        llm.TypeInteger,
+						Description: "Номер мероприятия",
+					},
+					"name": {
+						Type:        llm.TypeString,
+						Description: "Имя или ник участника для таблицы результатов",
+					},
+				},
+				Required: []string{"event_id"},
+			},
+		},
+
+		{
+			Name:        "GetPrepaidBalance",
+			Description: "Остаток по подарочным сертификатам и пакетам часов клиента, или по конкретному коду сертификата.",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"code": {
+						Type:        llm.TypeString,
+						Description: "Код сертификата (GC-...), если клиент его назвал",
+					},
+				},
+			},
+		},
+
+		{
+			Name:        "PayWithPrepaid",
+			Description: "Оплачивает уже созданную бронь сертификатом или пакетом часов клиента (целиком или частично).",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"booking_id": {
+						Type:        llm.TypeString,
+						Description: "ID брони (bk_...)",
+					},
+					"code": {
+						Type:        llm.TypeString,
+						Description: "Код сертификата или \"hours\" — пакет часов клиента",
+					},
+				},
+				Required: []string{"booking_id", "code"},
+			},
+		},
+
+		{
+			Name:        "GetMyBestLaps",
+			Description: "Лучшие круги клиента (Assetto Corsa, Automobilista 2) по трассам и место в таблице клуба.",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"track": {
+						Type:        llm.TypeString,
+						Description: "Трасса или часть названия (Spa, Monza); пусто — все трассы",
+					},
+				},
+			},
+		},
+
+		{
+			Name:        "LinkDriverName",
+			Description: "Привязывает игровой ник клиента к его профилю, чтобы круги под этим ником засчитывались ему.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"driver": {
+						Type:        llm.TypeString,
+						Description: "Ник в игре, как в таблице результатов",
+					},
+				},
+				Required: []string{"driver"},
+			},
+		},
+
+		{
+			Name:        "CancelEventRegistration",
+			Description: "Отменяет регистрацию клиента на турнир.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"event_id": {
+						Type:        llm.TypeInteger,
+						Description: "Номер мероприятия",
+					},
+				},
+				Required: []string{"event_id"},
+			},
+		},
+
+		{
+			Name:        "CallOperator",
+			Description: "Передаёт диалог живому администратору: бот замолкает, администратор отвечает клиенту в этом же чате.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"reason": {
+						Type:        llm.TypeString,
+						Description: "Коротко, зачем нужен человек (жалоба, возврат, просьба клиента)",
+					},
+				},
+				Required: []string{"reason"},
 			},
 		},
 	}
@@ -111,71 +435,615 @@ func GetClientTools() []*genai.Tool {
 // ADMIN TOOLS
 // -----------------------------
 
-func GetAdminTools() []*genai.Tool {
-	return []*genai.Tool{
-		{
-			FunctionDeclarations: []*genai.FunctionDeclaration{
-
-				{
-					Name:        "GetSalesDetailTool",
-					Description: "Детальная аналитика продаж по фильтрам.",
-					Parameters: &genai.Schema{
-						Type: genai.TypeObject,
-						Properties: map[string]*genai.Schema{
-							"filters": {
-								Type:        genai.TypeString,
-								Description: "today | last30 | client_id:XXX | seats=4 и т.п.",
-							},
-						},
-						Required: []string{"filters"},
-					},
-				},
-
-				{
-					Name:        "GetMarketingStatsTool",
-					Description: "Возвращает статистику маркетинга.",
-					Parameters:  &genai.Schema{Type: genai.TypeObject},
-				},
-
-				{
-					Name:        "GetWeatherTool",
-					Description: "Получает прогноз или текущую погоду.",
-					Parameters: &genai.Schema{
-						Type: genai.TypeObject,
-						Properties: map[string]*genai.Schema{
-							"date": {
-								Type:        genai.TypeString,
-								Description: "Дата или today",
-							},
-						},
-						Required: []string{"date"},
-					},
-				},
-
-				{
-					Name:        "GetRevenueByDateRangeTool",
-					Description: "Аналитика выручки за выбранный период.",
-					Parameters: &genai.Schema{
-						Type: genai.TypeObject,
-						Properties: map[string]*genai.Schema{
-							"start_date": {Type: genai.TypeString},
-							"end_date":   {Type: genai.TypeString},
-						},
-						Required: []string{"start_date", "end_date"},
-					},
-				},
-
-				{
-					Name:        "GetSalesRecommendationTool",
-					Description: "Генерирует рекомендации по продажам, используя данные и погоду.",
-					Parameters: &genai.Schema{
-						Type: genai.TypeObject,
-						Properties: map[string]*genai.Schema{
-							"reason": {
-								Type:        genai.TypeString,
-								Description: "Причина вызова (optional)",
-							},
-						},
+// GetAdminTools — инструменты ассистента владельца.
+func GetAdminTools() []llm.Tool {
+	return []llm.Tool{
+
+		{
+			Name:        "GetSalesDetailTool",
+			Description: "Детальная аналитика продаж по фильтрам.",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"filters": {
+						Type:        llm.TypeString,
+						Description: "today | last30 | client_id:XXX | seats=4 и т.п.",
+					},
+				},
+				Required: []string{"filters"},
+			},
+		},
+
+		{
+			Name:        "GetMarketingStatsTool",
+			Description: "Возвращает статистику маркетинга.",
+			Parameters:  &llm.Schema{Type: llm.TypeObject},
+		},
+
+		{
+			Name:        "GetWeatherTool",
+			Description: "Получает прогноз или текущую погоду.",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"date": {
+						Type:        llm.TypeString,
+						Description: "Дата или today",
+					},
+				},
+				Required: []string{"date"},
+			},
+		},
+
+		{
+			Name:        "GetRevenueByDateRangeTool",
+			Description: "Аналитика выручки за выбранный период.",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"start_date": {Type: llm.TypeString},
+					"end_date":   {Type: llm.TypeString},
+				},
+				Required: []string{"start_date", "end_date"},
+			},
+		},
+
+		{
+			Name:        "GetSalesRecommendationTool",
+			Description: "Генерирует рекомендации по продажам, используя данные и погоду.",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"reason": {
+						Type:        llm.TypeString,
+						Description: "Причина вызова (optional)",
+					},
+				},
+			},
+		},
+
+		{
+			Name:        "ListRigsTool",
+			Description: "Список станций (симуляторов): оборудование, игры, статус.",
+			Parameters:  &llm.Schema{Type: llm.TypeObject},
+		},
+
+		{
+			Name:        "SetRigStatusTool",
+			Description: "Выводит станцию в обслуживание или возвращает в работу; показывает затронутые брони.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"rig_id": {
+						Type:        llm.TypeInteger,
+						Description: "Номер станции",
+					},
+					"status": {
+						Type:        llm.TypeString,
+						Description: "active | maintenance",
+						Enum:        []string{"active", "maintenance"},
+					},
+				},
+				Required: []string{"rig_id", "status"},
+			},
+		},
+
+		{
+			Name:        "GetClientDraftTool",
+			Description: "Показывает текущий черновик брони клиента: места, дата, время, часы, цена, ID брони.",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"client_id": {
+						Type:        llm.TypeString,
+						Description: "ID клиента: WA-<телефон> или TG-<chat id>",
+					},
+				},
+				Required: []string{"client_id"},
+			},
+		},
+
+		{
+			Name:        "CreatePromoTool",
+			Description: "Создаёт промокод: процент или фиксированная сумма, срок действия, лимиты использований.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"code": {
+						Type:        llm.TypeString,
+						Description: "Код (регистр не важен)",
+					},
+					"kind": {
+						Type:        llm.TypeString,
+						Description: "percent — скидка в %, fixed — скидка суммой",
+						Enum:        []string{"percent", "fixed"},
+					},
+					"value": {
+						Type:        llm.TypeNumber,
+						Description: "Размер скидки (% или тг)",
+					},
+					"valid_from": {
+						Type:        llm.TypeString,
+						Description: "Действует с YYYY-MM-DD (опционально)",
+					},
+					"valid_to": {
+						Type:        llm.TypeString,
+						Description: "Действует по YYYY-MM-DD включительно (опционально)",
+					},
+					"max_uses": {
+						Type:        llm.TypeInteger,
+						Description: "Всего использований, 0 — без лимита",
+					},
+					"per_client_limit": {
+						Type:        llm.TypeInteger,
+						Description: "Использований на клиента, 0 — без лимита",
+					},
+				},
+				Required: []string{"code", "kind", "value"},
+			},
+		},
+
+		{
+			Name:        "GetPromoStatsTool",
+			Description: "Список промокодов: условия, сколько раз погашен каждый и сумма скидок.",
+			Parameters:  &llm.Schema{Type: llm.TypeObject},
+		},
+
+		{
+			Name:        "SetBookingStatusTool",
+			Description: "Меняет статус брони: подтвердить (клиент пришёл), неявка или отмена администратором (без ограничений политики отмены).",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"booking_id": {
+						Type:        llm.TypeString,
+						Description: "ID брони (bk_...)",
+					},
+					"status": {
+						Type:        llm.TypeString,
+						Description: "confirmed | no_show | cancelled",
+						Enum:        []string{"confirmed", "no_show", "cancelled"},
+					},
+					"note": {
+						Type:        llm.TypeString,
+						Description: "Комментарий (опционально)",
+					},
+				},
+				Required: []string{"booking_id", "status"},
+			},
+		},
+
+		{
+			Name:        "CreateWalkInTool",
+			Description: "Бронь для гостя, пришедшего без записи: начинается сейчас и сразу подтверждена.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"seats": {
+						Type:        llm.TypeInteger,
+						Description: "Кол-во мест",
+					},
+					"hours": {
+						Type:        llm.TypeInteger,
+						Description: "Сколько часов",
+					},
+					"client_id": {
+						Type:        llm.TypeString,
+						Description: "ID клиента, если известен (WA-<телефон>), иначе пусто",
+					},
+				},
+				Required: []string{"seats", "hours"},
+			},
+		},
+
+		{
+			Name:        "GetBookingTool",
+			Description: "Бронь по ID и её журнал: кто и когда создал, подтвердил, перенёс или отменил; оплаты и запланированные задания.",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"booking_id": {
+						Type:        llm.TypeString,
+						Description: "ID брони (bk_...)",
+					},
+				},
+				Required: []string{"booking_id"},
+			},
+		},
+
+		{
+			Name:        "GetTurnedAwayDemandTool",
+			Description: "Упущенный спрос по часам: сколько запросов и место-часов не поместилось, сколько встало в лист ожидания и сколько получило бронь.",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"start_date": {
+						Type:        llm.TypeString,
+						Description: "Начало периода (YYYY-MM-DD)",
+					},
+					"end_date": {
+						Type:        llm.TypeString,
+						Description: "Конец периода (YYYY-MM-DD), включительно",
+					},
+				},
+				Required: []string{"start_date", "end_date"},
+			},
+		},
+
+		{
+			Name:        "RecordPaymentTool",
+			Description: "Записывает деньги по брони на кассе: оплату, депозит, возврат или корректировку. Показывает остаток к оплате; при полной оплате бронь становится оплаченной.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"booking_id": {
+						Type:        llm.TypeString,
+						Description: "ID брони (bk_...)",
+					},
+					"kind": {
+						Type:        llm.TypeString,
+						Description: "charge (оплата) | deposit (депозит) | refund (возврат) | adjustment (корректировка ±, нужен комментарий)",
+						Enum:        []string{"charge", "deposit", "refund", "adjustment"},
+					},
+					"method": {
+						Type:        llm.TypeString,
+						Description: "kaspi_qr | cash | card",
+						Enum:        []string{"kaspi_qr", "cash", "card"},
+					},
+					"amount": {
+						Type:        llm.TypeNumber,
+						Description: "Сумма; возврат — положительным числом",
+					},
+					"note": {
+						Type:        llm.TypeString,
+						Description: "Комментарий (опционально)",
+					},
+				},
+				Required: []string{"booking_id", "kind", "method", "amount"},
+			},
+		},
+
+		{
+			Name:        "RefundPaymentTool",
+			Description: "Возврат онлайн-оплаты брони целиком; будущая бронь при этом отменяется.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"booking_id": {
+						Type:        llm.TypeString,
+						Description: "ID брони (bk_...)",
+					},
+				},
+				Required: []string{"booking_id"},
+			},
+		},
+
+		{
+			Name:        "CreateEventTool",
+			Description: "Создаёт турнир/мероприятие и сразу блокирует под него станции на всё время. Клиенты регистрируются через бота.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"title": {
+						Type:        llm.TypeString,
+						Description: "Название",
+					},
+					"date": {
+						Type:        llm.TypeString,
+						Description: "Дата (YYYY-MM-DD)",
+					},
+					"time": {
+						Type:        llm.TypeString,
+						Description: "Время начала (HH:MM)",
+					},
+					"hours": {
+						Type:        llm.TypeInteger,
+						Description: "Длительность, часов",
+					},
+					"track": {
+						Type:        llm.TypeString,
+						Description: "Трасса",
+					},
+					"car_class": {
+						Type:        llm.TypeString,
+						Description: "Класс машин (GT3, F1...)",
+					},
+					"game": {
+						Type:        llm.TypeString,
+						Description: "Игра, если нужны станции с ней (опционально)",
+					},
+					"max_participants": {
+						Type:        llm.TypeInteger,
+						Description: "Максимум участников = сколько станций заблокировать",
+					},
+					"entry_fee": {
+						Type:        llm.TypeNumber,
+						Description: "Взнос участника, 0 — бесплатно",
+					},
+				},
+				Required: []string{"title", "date", "time", "hours", "max_participants"},
+			},
+		},
+
+		{
+			Name:        "GetEventRegistrationsTool",
+			Description: "Список участников мероприятия с оплатой взносов. Без event_id — ближайшие мероприятия с числом регистраций.",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"event_id": {
+						Type:        llm.TypeInteger,
+						Description: "Номер мероприятия (опционально)",
+					},
+				},
+			},
+		},
+
+		{
+			Name:        "BroadcastEventTool",
+			Description: "Рассылает сообщение всем зарегистрированным участникам мероприятия в их мессенджер.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"event_id": {
+						Type:        llm.TypeInteger,
+						Description: "Номер мероприятия",
+					},
+					"message": {
+						Type:        llm.TypeString,
+						Description: "Текст сообщения участникам",
+					},
+				},
+				Required: []string{"event_id", "message"},
+			},
+		},
+
+		{
+			Name:        "CancelEventTool",
+			Description: "Отменяет мероприятие: станции освобождаются, участникам уходит уведомление.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"event_id": {
+						Type:        llm.TypeInteger,
+						Description: "Номер мероприятия",
+					},
+					"reason": {
+						Type:        llm.TypeString,
+						Description: "Причина (уйдёт участникам)",
+					},
+				},
+				Required: []string{"event_id"},
+			},
+		},
+
+		{
+			Name:        "RecordEventPaymentTool",
+			Description: "Записывает взнос участника, оплаченный на кассе, и отмечает его оплаченным.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"event_id": {
+						Type:        llm.TypeInteger,
+						Description: "Номер мероприятия",
+					},
+					"client_id": {
+						Type:        llm.TypeString,
+						Description: "ID клиента из списка участников",
+					},
+					"method": {
+						Type:        llm.TypeString,
+						Description: "kaspi_qr | cash | card",
+						Enum:        []string{"kaspi_qr", "cash", "card"},
+					},
+				},
+				Required: []string{"event_id", "client_id", "method"},
+			},
+		},
+
+		{
+			Name:        "GetLapLeaderboardTool",
+			Description: "Топ пилотов по лучшему кругу на каждой трассе за последние дни (по умолчанию неделя, топ-10) и вовлечённость: кругов, пилотов, из них клиентов.",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"track": {
+						Type:        llm.TypeString,
+						Description: "Трасса или часть названия; пусто — все трассы",
+					},
+					"days": {
+						Type:        llm.TypeInteger,
+						Description: "За сколько последних дней (по умолчанию 7)",
+					},
+					"limit": {
+						Type:        llm.TypeInteger,
+						Description: "Сколько мест на трассу (по умолчанию 10)",
+					},
+				},
+			},
+		},
+
+		{
+			Name:        "LinkDriverTool",
+			Description: "Привязывает игровой ник к клиенту (перепривязывает, если ник был за другим).",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"driver": {
+						Type:        llm.TypeString,
+						Description: "Ник в игре",
+					},
+					"client_id": {
+						Type:        llm.TypeString,
+						Description: "ID клиента (TG-... / WA-...)",
+					},
+				},
+				Required: []string{"driver", "client_id"},
+			},
+		},
+
+		{
+			Name:        "ListScheduledJobsTool",
+			Description: "Запланированные задания: напоминания за 2 часа, вопросы «как прошло?» после сеанса, снятие неоплаченных броней. По брони — все её задания, без брони — ближайшие ждущие.",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"booking_id": {
+						Type:        llm.TypeString,
+						Description: "ID брони; пусто — все",
+					},
+					"status": {
+						Type:        llm.TypeString,
+						Description: "pending | done | failed | cancelled",
+						Enum:        []string{"pending", "done", "failed", "cancelled"},
+					},
+				},
+			},
+		},
+
+		{
+			Name:        "CancelScheduledJobTool",
+			Description: "Отменяет задание по номеру или все ждущие задания брони (например, клиент попросил не напоминать).",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"job_id": {
+						Type:        llm.TypeInteger,
+						Description: "Номер задания из ListScheduledJobsTool",
+					},
+					"booking_id": {
+						Type:        llm.TypeString,
+						Description: "ID брони — отменить все её задания",
+					},
+				},
+			},
+		},
+
+		{
+			Name:        "GetUndeliveredMessagesTool",
+			Description: "Ответы бота в WhatsApp, которые не дошли до клиента: ошибка Wazzup/WhatsApp или нет подтверждения доставки дольше часа.",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"hours": {
+						Type:        llm.TypeInteger,
+						Description: "За сколько последних часов (по умолчанию 24)",
+					},
+				},
+			},
+		},
+
+		{
+			Name:        "IssueCertificateTool",
+			Description: "Оформляет подарочный сертификат на сумму с уникальным кодом и сроком действия.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"amount": {
+						Type:        llm.TypeNumber,
+						Description: "Номинал, тг",
+					},
+					"price": {
+						Type:        llm.TypeNumber,
+						Description: "Сколько заплатили (по умолчанию = номиналу; 0 — бесплатно, например компенсация)",
+					},
+					"method": {
+						Type:        llm.TypeString,
+						Description: "kaspi_qr | cash | card",
+						Enum:        []string{"kaspi_qr", "cash", "card"},
+					},
+					"valid_days": {
+						Type:        llm.TypeInteger,
+						Description: "Срок действия, дней (по умолчанию 365)",
+					},
+					"client_id": {
+						Type:        llm.TypeString,
+						Description: "Кому выдан, если известно; иначе привяжется при первом списании",
+					},
+					"code": {
+						Type:        llm.TypeString,
+						Description: "Свой код (если пусто — сгенерируется)",
+					},
+				},
+				Required: []string{"amount"},
+			},
+		},
+
+		{
+			Name:        "SellHourPackageTool",
+			Description: "Продаёт клиенту пакет место-часов (например, 10 часов) со сроком действия.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"client_id": {
+						Type:        llm.TypeString,
+						Description: "ID клиента (TG-... / WA-...)",
+					},
+					"hours": {
+						Type:        llm.TypeNumber,
+						Description: "Сколько место-часов",
+					},
+					"price": {
+						Type:        llm.TypeNumber,
+						Description: "Цена пакета, тг",
+					},
+					"method": {
+						Type:        llm.TypeString,
+						Description: "kaspi_qr | cash | card",
+						Enum:        []string{"kaspi_qr", "cash", "card"},
+					},
+					"valid_days": {
+						Type:        llm.TypeInteger,
+						Description: "Срок действия, дней (по умолчанию 180)",
+					},
+				},
+				Required: []string{"client_id", "hours", "price", "method"},
+			},
+		},
+
+		{
+			Name:        "RedeemPrepaidTool",
+			Description: "Списывает сертификат или пакет часов в оплату брони (на кассе). Списывается не больше долга по брони.",
+			Mutates:     true,
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"booking_id": {
+						Type:        llm.TypeString,
+						Description: "ID брони",
+					},
+					"code": {
+						Type:        llm.TypeString,
+						Description: "Код сертификата или пакета (GC-... / HP-...)",
+					},
+				},
+				Required: []string{"booking_id", "code"},
+			},
+		},
+
+		{
+			Name:        "GetPrepaidReportTool",
+			Description: "Обязательства клуба по сертификатам и пакетам: непогашенный остаток, продано, списано, сгорело. С кодом — карточка одного сертификата/пакета.",
+			Parameters: &llm.Schema{
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"code": {
+						Type:        llm.TypeString,
+						Description: "Код для проверки; пусто — общий отчёт",
 					},
 				},
 			},
@@ -2,9 +2,16 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+	"whatsapp-analytics-mvp/internal/pricing"
 )
 
 // -----------------------------------------------------------------------------
@@ -40,6 +47,120 @@ func (s *AIService) handleAdminToolCall(
 	case "GetSalesRecommendationTool":
 		return s.GetSalesRecommendationTool(ctx)
 
+	case "ListRigsTool":
+		return s.ListRigsTool(ctx)
+
+	case "SetRigStatusTool":
+		rigID, _ := floatArg(args, "rig_id")
+		status, _ := args["status"].(string)
+		return s.SetRigStatusTool(ctx, int(rigID), status)
+
+	case "GetClientDraftTool":
+		clientID, _ := args["client_id"].(string)
+		return s.GetClientDraftTool(ctx, clientID)
+
+	case "CreatePromoTool":
+		return s.CreatePromoTool(ctx, args)
+
+	case "GetPromoStatsTool":
+		return s.GetPromoStatsTool(ctx)
+
+	case "SetBookingStatusTool":
+		bookingID, _ := strArg(args, "booking_id")
+		status, _ := strArg(args, "status")
+		note, _ := strArg(args, "note")
+		return s.SetBookingStatusTool(ctx, bookingID, status, note)
+
+	case "CreateWalkInTool":
+		seats, _ := floatArg(args, "seats")
+		hours, _ := floatArg(args, "hours")
+		clientID, _ := strArg(args, "client_id")
+		return s.CreateWalkInTool(ctx, clientID, int(seats), int(hours))
+
+	case "GetBookingTool":
+		bookingID, _ := strArg(args, "booking_id")
+		return s.GetBookingTool(ctx, bookingID)
+
+	case "GetTurnedAwayDemandTool":
+		start, _ := strArg(args, "start_date")
+		end, _ := strArg(args, "end_date")
+		return s.GetTurnedAwayDemandTool(ctx, start, end)
+
+	case "RecordPaymentTool":
+		bookingID, _ := strArg(args, "booking_id")
+		kind, _ := strArg(args, "kind")
+		method, _ := strArg(args, "method")
+		amount, _ := floatArg(args, "amount")
+		note, _ := strArg(args, "note")
+		return s.RecordPaymentTool(ctx, bookingID, kind, method, amount, note)
+
+	case "RefundPaymentTool":
+		bookingID, _ := strArg(args, "booking_id")
+		return s.RefundPaymentTool(ctx, bookingID)
+
+	case "CreateEventTool":
+		return s.CreateEventTool(ctx, args)
+
+	case "GetEventRegistrationsTool":
+		eventID, _ := floatArg(args, "event_id")
+		return s.GetEventRegistrationsTool(ctx, int64(eventID))
+
+	case "BroadcastEventTool":
+		eventID, _ := floatArg(args, "event_id")
+		message, _ := strArg(args, "message")
+		return s.BroadcastEventTool(ctx, int64(eventID), message)
+
+	case "CancelEventTool":
+		eventID, _ := floatArg(args, "event_id")
+		reason, _ := strArg(args, "reason")
+		return s.CancelEventTool(ctx, int64(eventID), reason)
+
+	case "RecordEventPaymentTool":
+		eventID, _ := floatArg(args, "event_id")
+		clientID, _ := strArg(args, "client_id")
+		method, _ := strArg(args, "method")
+		return s.RecordEventPaymentTool(ctx, int64(eventID), clientID, method)
+
+	case "IssueCertificateTool":
+		return s.IssueCertificateTool(ctx, args)
+
+	case "SellHourPackageTool":
+		return s.SellHourPackageTool(ctx, args)
+
+	case "RedeemPrepaidTool":
+		bookingID, _ := strArg(args, "booking_id")
+		code, _ := strArg(args, "code")
+		return s.RedeemPrepaidTool(ctx, bookingID, code)
+
+	case "GetPrepaidReportTool":
+		code, _ := strArg(args, "code")
+		return s.GetPrepaidReportTool(ctx, code)
+
+	case "ListScheduledJobsTool":
+		bookingID, _ := strArg(args, "booking_id")
+		status, _ := strArg(args, "status")
+		return s.ListScheduledJobsTool(ctx, bookingID, status)
+
+	case "CancelScheduledJobTool":
+		jobID, _ := floatArg(args, "job_id")
+		bookingID, _ := strArg(args, "booking_id")
+		return s.CancelScheduledJobTool(ctx, int64(jobID), bookingID)
+
+	case "GetUndeliveredMessagesTool":
+		hours, _ := floatArg(args, "hours")
+		return s.GetUndeliveredMessagesTool(ctx, int(hours))
+
+	case "GetLapLeaderboardTool":
+		track, _ := strArg(args, "track")
+		days, _ := floatArg(args, "days")
+		limit, _ := floatArg(args, "limit")
+		return s.GetLapLeaderboardTool(ctx, track, int(days), int(limit))
+
+	case "LinkDriverTool":
+		driver, _ := strArg(args, "driver")
+		clientID, _ := strArg(args, "client_id")
+		return s.LinkDriverTool(ctx, driver, clientID)
+
 	default:
 		return fmt.Sprintf("Ошибка: неизвестный инструмент администратора '%s'", name), nil
 	}
@@ -79,10 +200,12 @@ func (s *AIService) GetSalesDetailTool(ctx context.Context, filters string) (str
 
 	// Default (last 30 days)
 	return fmt.Sprintf(
-		"30 дней: %d броней, выручка: %.0f тг, средний чек: %.0f тг.",
+		"30 дней: %d броней, выручка: %.0f тг, средний чек: %.0f тг. Отмен: %d, неявок: %d.",
 		data["total_bookings"],
 		data["total_revenue"],
 		data["avg_check"],
+		data["cancelled_bookings"],
+		data["no_show_bookings"],
 	), nil
 }
 
@@ -141,22 +264,1070 @@ func (s *AIService) GetRevenueByDateRangeTool(ctx context.Context, startDate str
 		return fmt.Sprintf("Ошибка аналитики: %v", err), nil
 	}
 
-	return fmt.Sprintf(
-		"Выручка %s → %s: %.0f тг, %d бронирований, средний чек %.0f тг.",
+	out := fmt.Sprintf(
+		"Выручка %s → %s (получено по журналу оплат): %.0f тг, %d бронирований, средний чек %.0f тг. Отмен: %d, неявок: %d.",
 		startDate, endDate,
 		data["total_revenue"],
 		data["total_bookings"],
 		data["average_check"],
+		data["cancelled_bookings"],
+		data["no_show_bookings"],
+	)
+
+	if byMethod, _ := data["revenue_by_method"].(map[string]float64); len(byMethod) > 0 {
+		methods := make([]string, 0, len(byMethod))
+		for m := range byMethod {
+			methods = append(methods, m)
+		}
+		sort.Strings(methods)
+		parts := make([]string, 0, len(methods))
+		for _, m := range methods {
+			parts = append(parts, fmt.Sprintf("%s %.0f", models.PaymentMethodLabel(m), byMethod[m]))
+		}
+		out += " По способам: " + strings.Join(parts, ", ") + "."
+	}
+	if refunds, _ := data["refunds"].(float64); refunds > 0 {
+		out += fmt.Sprintf(" Возвраты: %.0f тг.", refunds)
+	}
+	if sold, _ := data["prepaid_sales"].(float64); sold > 0 {
+		out += fmt.Sprintf(" В т.ч. продажи сертификатов и пакетов: %.0f тг.", sold)
+	}
+	if redeemed, _ := data["prepaid_redeemed"].(float64); redeemed != 0 {
+		out += fmt.Sprintf(" Списано с сертификатов и пакетов (в выручку не входит): %.0f тг.", redeemed)
+	}
+	out += fmt.Sprintf(" По сметам броней: %.0f тг, не оплачено: %.0f тг.", data["booked_amount"], data["balance_due"])
+	return out, nil
+}
+
+// -----------------------------------------------------------------------------
+//  TURNED-AWAY DEMAND (упущенный спрос по часам)
+// -----------------------------------------------------------------------------
+
+func (s *AIService) GetTurnedAwayDemandTool(ctx context.Context, startDate, endDate string) (string, error) {
+	repo, ok := s.ContextManager.(WaitlistRepository)
+	if !ok {
+		return "Ошибка: учёт листа ожидания недоступен.", nil
+	}
+
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: неверная дата начала: %s", startDate), nil
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: неверная дата окончания: %s", endDate), nil
+	}
+
+	// Рабочие дни целиком: ночь после последнего дня тоже входит
+	from := start.Add(availability.OpenHour * time.Hour)
+	to := end.AddDate(0, 0, 1).Add(availability.CloseHour * time.Hour)
+	hours, err := repo.TurnedAwayByHour(ctx, from, to)
+	if err != nil {
+		return fmt.Sprintf("Ошибка аналитики: %v", err), nil
+	}
+	if len(hours) == 0 {
+		return fmt.Sprintf("%s → %s: отказов из-за нехватки мест не было.", startDate, endDate), nil
+	}
+
+	var b strings.Builder
+	var requests, seatHours, recovered int
+	fmt.Fprintf(&b, "Упущенный спрос %s → %s (не хватило мест):\n", startDate, endDate)
+	for _, h := range hours {
+		fmt.Fprintf(&b, "- %s: запросов %d (клиентов %d), место-часов %d, в лист ожидания %d, получили бронь %d\n",
+			h.Hour, h.Requests, h.Clients, h.SeatHours, h.Waitlisted, h.Recovered)
+		requests += h.Requests
+		seatHours += h.SeatHours
+		recovered += h.Recovered
+	}
+	fmt.Fprintf(&b, "Итого: запросов %d, место-часов %d, спасено листом ожидания %d.", requests, seatHours, recovered)
+	return b.String(), nil
+}
+
+// -----------------------------------------------------------------------------
+//  RIGS (СТАНЦИИ)
+// -----------------------------------------------------------------------------
+
+func (s *AIService) ListRigsTool(ctx context.Context) (string, error) {
+	repo, ok := s.ContextManager.(RigRepository)
+	if !ok {
+		return "Ошибка: учёт станций недоступен.", nil
+	}
+
+	rigs, err := repo.ListRigs(ctx)
+	if err != nil {
+		return fmt.Sprintf("Ошибка станций: %v", err), nil
+	}
+
+	var b strings.Builder
+	for _, r := range rigs {
+		status := "в работе"
+		if r.Status == models.RigMaintenance {
+			status = "на обслуживании"
+		}
+		b.WriteString(fmt.Sprintf("#%d %s (%s) — %s. Игры: %s\n", r.ID, r.Name, r.Hardware, status, strings.Join(r.Games, ", ")))
+	}
+	return b.String(), nil
+}
+
+func (s *AIService) SetRigStatusTool(ctx context.Context, rigID int, status string) (string, error) {
+	repo, ok := s.ContextManager.(RigRepository)
+	if !ok {
+		return "Ошибка: учёт станций недоступен.", nil
+	}
+
+	if err := repo.SetRigStatus(ctx, rigID, status); err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+
+	msg := fmt.Sprintf("Станция #%d → %s.", rigID, status)
+	if status != models.RigMaintenance {
+		return msg, nil
+	}
+
+	affected, err := repo.GetRigBookings(ctx, rigID, availability.Now())
+	if err != nil {
+		return msg + fmt.Sprintf(" Не удалось получить брони: %v", err), nil
+	}
+	if len(affected) == 0 {
+		return msg + " Будущих броней на станции нет.", nil
+	}
+
+	var b strings.Builder
+	b.WriteString(msg + fmt.Sprintf(" Затронуто броней: %d — нужно пересадить или связаться с клиентом:\n", len(affected)))
+	for _, bk := range affected {
+		b.WriteString(fmt.Sprintf("- %s: %s, %s, %d мест × %d ч\n", bk.BookingID, bk.ClientID, bk.Start.Format("02.01 15:04"), bk.Seats, bk.Hours))
+	}
+	return b.String(), nil
+}
+
+// -----------------------------------------------------------------------------
+//  CLIENT BOOKING DRAFT
+// -----------------------------------------------------------------------------
+
+func (s *AIService) GetClientDraftTool(ctx context.Context, clientID string) (string, error) {
+	if clientID == "" {
+		return "Ошибка: укажите client_id (например WA-7701... или TG-123...).", nil
+	}
+
+	repo, ok := s.ContextManager.(DraftRepository)
+	if !ok {
+		return "Ошибка: черновики броней недоступны.", nil
+	}
+
+	draft, err := repo.GetDraft(ctx, clientID)
+	if err != nil {
+		return fmt.Sprintf("Ошибка черновика: %v", err), nil
+	}
+	if draft.SessionID == 0 || draft.IsEmpty() {
+		return fmt.Sprintf("У клиента %s нет активного черновика брони.", clientID), nil
+	}
+
+	status := "ждём: " + strings.Join(draft.MissingSlots(), ", ")
+	if draft.BookingID != "" {
+		status = "бронь создана"
+	} else if len(draft.MissingSlots()) == 0 {
+		status = "все слоты заполнены, брони ещё нет"
+	}
+
+	return fmt.Sprintf(
+		"Черновик %s (обновлён %s, %s):\n%s",
+		clientID, draft.UpdatedAt.Format("02.01 15:04"), status, formatDraft(draft),
 	), nil
 }
 
+// -----------------------------------------------------------------------------
+//  PROMO CODES
+// -----------------------------------------------------------------------------
+
+func (s *AIService) CreatePromoTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	repo, ok := s.ContextManager.(PromoRepository)
+	if !ok {
+		return "Ошибка: промокоды недоступны.", nil
+	}
+
+	p := &models.PromoCode{}
+	p.Code, _ = strArg(args, "code")
+	p.Kind, _ = strArg(args, "kind")
+	p.Value, _ = floatArg(args, "value")
+	if v, ok := floatArg(args, "max_uses"); ok {
+		p.MaxUses = int(v)
+	}
+	if v, ok := floatArg(args, "per_client_limit"); ok {
+		p.PerClientLimit = int(v)
+	}
+
+	if v, _ := strArg(args, "valid_from"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return "Ошибка: valid_from в формате YYYY-MM-DD.", nil
+		}
+		p.ValidFrom = from
+	}
+	if v, _ := strArg(args, "valid_to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return "Ошибка: valid_to в формате YYYY-MM-DD.", nil
+		}
+		p.ValidTo = to.AddDate(0, 0, 1) // включительно
+	}
+
+	if err := repo.CreatePromo(ctx, p); err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+	return "Промокод создан: " + formatPromo(*p), nil
+}
+
+func (s *AIService) GetPromoStatsTool(ctx context.Context) (string, error) {
+	repo, ok := s.ContextManager.(PromoRepository)
+	if !ok {
+		return "Ошибка: промокоды недоступны.", nil
+	}
+
+	stats, err := repo.ListPromoStats(ctx)
+	if err != nil {
+		return fmt.Sprintf("Ошибка промокодов: %v", err), nil
+	}
+	if len(stats) == 0 {
+		return "Промокодов пока нет.", nil
+	}
+
+	var b strings.Builder
+	for _, st := range stats {
+		b.WriteString(fmt.Sprintf("- %s — погашен %d раз, скидок на %.0f тг\n",
+			formatPromo(st.PromoCode), st.Redemptions, st.TotalDiscount))
+	}
+	return b.String(), nil
+}
+
+// formatPromo — условия промокода с лимитами (для админа).
+func formatPromo(p models.PromoCode) string {
+	out := pricing.PromoSummary(&p, "тг")
+	if !p.ValidFrom.IsZero() {
+		out += ", с " + p.ValidFrom.Format("2006-01-02")
+	}
+	if p.MaxUses > 0 {
+		out += fmt.Sprintf(", лимит %d", p.MaxUses)
+	}
+	if p.PerClientLimit > 0 {
+		out += fmt.Sprintf(", на клиента %d", p.PerClientLimit)
+	}
+	return out
+}
+
+// -----------------------------------------------------------------------------
+//  BOOKING LIFECYCLE
+// -----------------------------------------------------------------------------
+
+func (s *AIService) SetBookingStatusTool(ctx context.Context, bookingID, status, note string) (string, error) {
+	repo, ok := s.ContextManager.(BookingLifecycleRepository)
+	if !ok {
+		return "Ошибка: управление бронями недоступно.", nil
+	}
+
+	switch status {
+	case models.BookingConfirmed, models.BookingNoShow, models.BookingCancelled:
+	default:
+		return "Ошибка: статус должен быть confirmed, no_show или cancelled.", nil
+	}
+	if note == "" {
+		note = "администратор"
+	}
+
+	if err := repo.SetBookingStatus(ctx, bookingID, status, models.ActorAdmin, note); err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+	out := fmt.Sprintf("Бронь %s → %s.", bookingID, models.BookingStatusLabel(status))
+
+	// Неявка сжигает списанное, отмена — возвращает на сертификат/пакет
+	if prepaid, ok := s.ContextManager.(PrepaidRepository); ok && status == models.BookingCancelled {
+		restored, err := prepaid.RestorePrepaid(ctx, bookingID, models.ActorAdmin)
+		if err != nil {
+			return out + fmt.Sprintf(" Предоплата не возвращена: %v", err), nil
+		}
+		if restored > 0 {
+			out += fmt.Sprintf(" %.0f тг вернулись на сертификат/пакет.", restored)
+		}
+	}
+	return out, nil
+}
+
+func (s *AIService) RecordPaymentTool(ctx context.Context, bookingID, kind, method string, amount float64, note string) (string, error) {
+	ledger, ok := s.ContextManager.(LedgerRepository)
+	if !ok {
+		return "Ошибка: журнал оплат недоступен.", nil
+	}
+	bookings, ok := s.ContextManager.(BookingLifecycleRepository)
+	if !ok {
+		return "Ошибка: управление бронями недоступно.", nil
+	}
+	if kind == "" {
+		kind = models.LedgerCharge
+	}
+
+	bal, err := ledger.AddLedgerEntry(ctx, &models.LedgerEntry{
+		BookingID: bookingID,
+		Kind:      kind,
+		Method:    method,
+		Amount:    amount,
+		Actor:     models.ActorAdmin,
+		Note:      note,
+	})
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+
+	out := fmt.Sprintf("Записано: %s %.0f тг (%s). %s",
+		models.LedgerKindLabel(kind), amount, models.PaymentMethodLabel(method), formatBalance(bal))
+
+	bk, err := bookings.GetBooking(ctx, bookingID)
+	if err != nil || bk == nil || !bk.IsActive() {
+		return out, nil
+	}
+	settled, err := SettleBooking(ctx, bookings, bk, bal, models.ActorAdmin, "оплачено на кассе")
+	if err != nil {
+		return out + fmt.Sprintf(" Статус не обновлён: %v", err), nil
+	}
+	if settled && bk.Status != models.BookingPaid {
+		out += " Бронь оплачена полностью."
+	}
+	return out, nil
+}
+
+func formatBalance(bal *models.BookingBalance) string {
+	out := fmt.Sprintf("Бронь %s: сумма %.0f тг, получено %.0f тг", bal.BookingID, bal.Total, bal.Paid)
+	if bal.Refunded > 0 {
+		out += fmt.Sprintf(" (возвращено %.0f)", bal.Refunded)
+	}
+	switch {
+	case bal.Due > 0:
+		out += fmt.Sprintf(", к оплате %.0f тг.", bal.Due)
+	case bal.Due < 0:
+		out += fmt.Sprintf(", переплата %.0f тг.", -bal.Due)
+	default:
+		out += "."
+	}
+	return out
+}
+
+func (s *AIService) RefundPaymentTool(ctx context.Context, bookingID string) (string, error) {
+	if s.Payments == nil {
+		return "Ошибка: онлайн-оплата не подключена.", nil
+	}
+
+	inv, err := s.Payments.Refund(ctx, bookingID)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+	return fmt.Sprintf("Возврат %.0f тг по брони %s оформлен (%s, счёт %s).",
+		inv.Amount, bookingID, inv.Provider, inv.InvoiceID), nil
+}
+
+func (s *AIService) CreateWalkInTool(ctx context.Context, clientID string, seats, hours int) (string, error) {
+	if s.ToolsProvider == nil {
+		return "Ошибка: инструменты броней недоступны.", nil
+	}
+
+	out, err := s.ToolsProvider.CreateWalkIn(ctx, clientID, seats, hours)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+	return "Walk-in оформлен и подтверждён: " + out, nil
+}
+
+func (s *AIService) GetBookingTool(ctx context.Context, bookingID string) (string, error) {
+	repo, ok := s.ContextManager.(BookingLifecycleRepository)
+	if !ok {
+		return "Ошибка: управление бронями недоступно.", nil
+	}
+
+	bk, err := repo.GetBooking(ctx, bookingID)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+	if bk == nil {
+		return fmt.Sprintf("Бронь %s не найдена.", bookingID), nil
+	}
+	events, err := repo.GetBookingEvents(ctx, bookingID)
+	if err != nil {
+		return fmt.Sprintf("Ошибка журнала: %v", err), nil
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%s (%s): %s, %d мест × %d ч, %.0f тг — %s\n",
+		bk.BookingID, bk.ClientID, bk.Start.Format("02.01 15:04"), bk.Seats, bk.Hours, bk.Amount, models.BookingStatusLabel(bk.Status)))
+	for _, e := range events {
+		line := fmt.Sprintf("- %s %s: %s", e.CreatedAt.Format("02.01 15:04"), e.Actor, models.BookingStatusLabel(e.ToStatus))
+		if e.FromStatus != "" && e.FromStatus != e.ToStatus {
+			line = fmt.Sprintf("- %s %s: %s → %s", e.CreatedAt.Format("02.01 15:04"), e.Actor,
+				models.BookingStatusLabel(e.FromStatus), models.BookingStatusLabel(e.ToStatus))
+		}
+		if e.Note != "" {
+			line += " (" + e.Note + ")"
+		}
+		b.WriteString(line + "\n")
+	}
+
+	ledger, ok := s.ContextManager.(LedgerRepository)
+	if !ok {
+		return b.String(), nil
+	}
+	entries, err := ledger.GetLedger(ctx, bookingID)
+	if err != nil {
+		return b.String() + fmt.Sprintf("Ошибка журнала оплат: %v\n", err), nil
+	}
+	for _, e := range entries {
+		line := fmt.Sprintf("- %s %s: %s %.0f тг (%s)", e.CreatedAt.Format("02.01 15:04"), e.Actor,
+			models.LedgerKindLabel(e.Kind), e.Amount, models.PaymentMethodLabel(e.Method))
+		if e.Note != "" {
+			line += " — " + e.Note
+		}
+		b.WriteString(line + "\n")
+	}
+	if bal, err := ledger.GetBookingBalance(ctx, bookingID); err == nil && bal != nil {
+		b.WriteString(formatBalance(bal) + "\n")
+	}
+	if s.TaskManager != nil {
+		if jobs, err := s.TaskManager.List(ctx, models.JobFilter{Ref: bookingID}); err == nil {
+			for _, j := range jobs {
+				b.WriteString(formatJobLine(j) + "\n")
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// -----------------------------------------------------------------------------
+//  SCHEDULED JOBS (напоминания, отзывы, снятие холдов)
+// -----------------------------------------------------------------------------
+
+// ListScheduledJobsTool — задания брони или ближайшие задания клуба
+// (по умолчанию ждущие).
+func (s *AIService) ListScheduledJobsTool(ctx context.Context, bookingID, status string) (string, error) {
+	if s.TaskManager == nil {
+		return "Ошибка: планировщик не подключён.", nil
+	}
+	f := models.JobFilter{Ref: strings.TrimSpace(bookingID), Status: status, Limit: 30}
+	if f.Ref == "" && f.Status == "" {
+		f.Status = models.JobPending
+	}
+	jobs, err := s.TaskManager.List(ctx, f)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+	if len(jobs) == 0 {
+		return "Заданий нет.", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("Задания:\n")
+	for _, j := range jobs {
+		b.WriteString(formatJobLine(j) + "\n")
+	}
+	return b.String(), nil
+}
+
+// CancelScheduledJobTool — отменить одно задание по номеру или все ждущие
+// задания брони.
+func (s *AIService) CancelScheduledJobTool(ctx context.Context, jobID int64, bookingID string) (string, error) {
+	if s.TaskManager == nil {
+		return "Ошибка: планировщик не подключён.", nil
+	}
+	if jobID > 0 {
+		ok, err := s.TaskManager.CancelJob(ctx, jobID)
+		if err != nil {
+			return fmt.Sprintf("Ошибка: %v", err), nil
+		}
+		if !ok {
+			return fmt.Sprintf("Задание #%d не найдено или уже не ждёт выполнения.", jobID), nil
+		}
+		return fmt.Sprintf("Задание #%d отменено.", jobID), nil
+	}
+
+	if bookingID = strings.TrimSpace(bookingID); bookingID == "" {
+		return "Ошибка: укажите job_id или booking_id.", nil
+	}
+	n, err := s.TaskManager.Cancel(ctx, bookingID)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+	return fmt.Sprintf("Отменено заданий по брони %s: %d.", bookingID, n), nil
+}
+
+func formatJobLine(j models.ScheduledJob) string {
+	line := fmt.Sprintf("- #%d %s %s, %s — %s", j.ID, j.RunAt.Format("02.01 15:04"),
+		models.JobKindLabel(j.Kind), j.Ref, models.JobStatusLabel(j.Status))
+	if j.Attempts > 0 && j.Status != models.JobDone {
+		line += fmt.Sprintf(" (попыток %d/%d)", j.Attempts, j.MaxAttempts)
+	}
+	if j.LastError != "" {
+		line += ": " + j.LastError
+	}
+	return line
+}
+
+// -----------------------------------------------------------------------------
+//  WHATSAPP DELIVERY (статусы Wazzup)
+// -----------------------------------------------------------------------------
+
+// GetUndeliveredMessagesTool — исходящие WhatsApp за hours часов, которые не
+// дошли до клиента.
+func (s *AIService) GetUndeliveredMessagesTool(ctx context.Context, hours int) (string, error) {
+	repo, ok := s.ContextManager.(OutboundMessageRepository)
+	if !ok {
+		return "Ошибка: журнал исходящих сообщений не подключён.", nil
+	}
+	if hours <= 0 {
+		hours = 24
+	}
+
+	now := availability.Now()
+	list, err := repo.ListUndeliveredMessages(ctx, now.Add(-time.Duration(hours)*time.Hour), now.Add(-models.OutboundStaleAfter), 30)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+	if len(list) == 0 {
+		return fmt.Sprintf("За %d ч. все ответы в WhatsApp доставлены.", hours), nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Не дошли до клиента (%d):\n", len(list))
+	for _, m := range list {
+		text := m.Text
+		if text == "" {
+			text = m.ContentURI
+		}
+		if r := []rune(text); len(r) > 60 {
+			text = string(r[:60]) + "…"
+		}
+		line := fmt.Sprintf("- %s %s — %s: «%s»", m.CreatedAt.Format("02.01 15:04"), m.ClientID,
+			models.OutboundStatusLabel(m.Status), text)
+		if m.Error != "" {
+			line += " (" + m.Error + ")"
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String(), nil
+}
+
+// -----------------------------------------------------------------------------
+//  EVENTS (турниры)
+// -----------------------------------------------------------------------------
+
+func (s *AIService) CreateEventTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	repo, ok := s.ContextManager.(ClubEventRepository)
+	if !ok {
+		return "Ошибка: мероприятия недоступны.", nil
+	}
+
+	title, _ := strArg(args, "title")
+	date, _ := strArg(args, "date")
+	tm, _ := strArg(args, "time")
+	hours, _ := floatArg(args, "hours")
+	maxParticipants, _ := floatArg(args, "max_participants")
+	fee, _ := floatArg(args, "entry_fee")
+
+	e := &models.ClubEvent{
+		Title:           strings.TrimSpace(title),
+		Hours:           int(hours),
+		MaxParticipants: int(maxParticipants),
+		EntryFee:        fee,
+	}
+	e.Track, _ = strArg(args, "track")
+	e.CarClass, _ = strArg(args, "car_class")
+	e.Game, _ = strArg(args, "game")
+
+	if e.Title == "" {
+		return "Ошибка: нужно название мероприятия.", nil
+	}
+	if fee < 0 {
+		return "Ошибка: взнос не может быть отрицательным.", nil
+	}
+	start, err := time.Parse("2006-01-02 15:04", date+" "+tm)
+	if err != nil {
+		return "Ошибка: дата YYYY-MM-DD и время HH:MM.", nil
+	}
+	if !start.After(availability.Now()) {
+		return "Ошибка: это время уже прошло.", nil
+	}
+	if !availability.WithinOpeningHours(start, e.Hours) {
+		return fmt.Sprintf("Ошибка: %d ч с %s не помещаются в часы работы 12:00–04:00.", e.Hours, tm), nil
+	}
+	e.Start = start
+
+	if err := repo.CreateEvent(ctx, e); err != nil {
+		if errors.Is(err, models.ErrNotEnoughSeats) {
+			return fmt.Sprintf("Ошибка: на %s нет %d свободных станций — выберите другое время или уменьшите число участников.",
+				start.Format("02.01 15:04"), e.MaxParticipants), nil
+		}
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+	return fmt.Sprintf("Мероприятие №%d создано: %s. Станции %s заблокированы бронью %s.",
+		e.ID, formatEventLine(*e), availability.FormatRigIDs(e.RigIDs), e.BookingID), nil
+}
+
+func (s *AIService) GetEventRegistrationsTool(ctx context.Context, eventID int64) (string, error) {
+	repo, ok := s.ContextManager.(ClubEventRepository)
+	if !ok {
+		return "Ошибка: мероприятия недоступны.", nil
+	}
+
+	if eventID <= 0 {
+		events, err := repo.ListUpcomingEvents(ctx, availability.Now())
+		if err != nil {
+			return fmt.Sprintf("Ошибка: %v", err), nil
+		}
+		if len(events) == 0 {
+			return "Предстоящих мероприятий нет.", nil
+		}
+		var b strings.Builder
+		for _, e := range events {
+			fmt.Fprintf(&b, "№%d %s\n", e.ID, formatEventLine(e))
+		}
+		return b.String(), nil
+	}
+
+	e, err := repo.GetEvent(ctx, eventID)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+	if e == nil {
+		return fmt.Sprintf("Мероприятие %d не найдено.", eventID), nil
+	}
+	regs, err := repo.ListEventRegistrations(ctx, eventID)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "№%d %s", e.ID, formatEventLine(*e))
+	if e.Status == models.EventCancelled {
+		b.WriteString(" — ОТМЕНЕНО")
+	}
+	b.WriteString("\n")
+
+	paid, cancelled := 0, 0
+	for _, r := range regs {
+		if r.Status != models.RegistrationActive {
+			cancelled++
+			continue
+		}
+		name := r.Name
+		if name == "" {
+			name = "без имени"
+		}
+		mark := "не оплачен"
+		if r.Paid {
+			mark = "оплачен"
+			paid++
+		}
+		if e.EntryFee == 0 {
+			mark = "бесплатно"
+		}
+		fmt.Fprintf(&b, "- %s (%s), записан %s — %s\n", name, r.ClientID, r.CreatedAt.Format("02.01 15:04"), mark)
+	}
+	if e.Registered == 0 {
+		b.WriteString("Участников пока нет.\n")
+	}
+	if e.EntryFee > 0 {
+		fmt.Fprintf(&b, "Взносы: оплачено %d из %d (%.0f тг).\n", paid, e.Registered, e.EntryFee*float64(paid))
+	}
+	if cancelled > 0 {
+		fmt.Fprintf(&b, "Отменили регистрацию: %d.\n", cancelled)
+	}
+	return b.String(), nil
+}
+
+func (s *AIService) BroadcastEventTool(ctx context.Context, eventID int64, message string) (string, error) {
+	repo, ok := s.ContextManager.(ClubEventRepository)
+	if !ok {
+		return "Ошибка: мероприятия недоступны.", nil
+	}
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return "Ошибка: пустое сообщение.", nil
+	}
+
+	e, err := repo.GetEvent(ctx, eventID)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+	if e == nil {
+		return fmt.Sprintf("Мероприятие %d не найдено.", eventID), nil
+	}
+
+	sent, failed, err := s.broadcastEvent(ctx, repo, e, fmt.Sprintf("«%s», %s: %s", e.Title, e.Start.Format("02.01 15:04"), message))
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+	return formatBroadcast(sent, failed), nil
+}
+
+func (s *AIService) CancelEventTool(ctx context.Context, eventID int64, reason string) (string, error) {
+	repo, ok := s.ContextManager.(ClubEventRepository)
+	if !ok {
+		return "Ошибка: мероприятия недоступны.", nil
+	}
+
+	note := "мероприятие отменено"
+	if reason = strings.TrimSpace(reason); reason != "" {
+		note += ": " + reason
+	}
+	e, err := repo.CancelEvent(ctx, eventID, models.ActorAdmin, note)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+
+	text := fmt.Sprintf("«%s» %s отменяется.", e.Title, e.Start.Format("02.01 15:04"))
+	if reason != "" {
+		text += " Причина: " + reason + "."
+	}
+	sent, failed, err := s.broadcastEvent(ctx, repo, e, text)
+	if err != nil {
+		return fmt.Sprintf("Мероприятие отменено, станции освобождены. Рассылка не удалась: %v", err), nil
+	}
+	out := "Мероприятие отменено, станции освобождены. " + formatBroadcast(sent, failed)
+	if e.EntryFee > 0 {
+		out += " Оплаченные взносы верните через RecordPaymentTool (refund) по брони " + e.BookingID + "."
+	}
+	return out, nil
+}
+
+func (s *AIService) RecordEventPaymentTool(ctx context.Context, eventID int64, clientID, method string) (string, error) {
+	repo, ok := s.ContextManager.(ClubEventRepository)
+	if !ok {
+		return "Ошибка: мероприятия недоступны.", nil
+	}
+	ledger, ok := s.ContextManager.(LedgerRepository)
+	if !ok {
+		return "Ошибка: журнал оплат недоступен.", nil
+	}
+
+	e, err := repo.GetEvent(ctx, eventID)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+	if e == nil {
+		return fmt.Sprintf("Мероприятие %d не найдено.", eventID), nil
+	}
+	if e.EntryFee <= 0 {
+		return "Участие бесплатное — записывать нечего.", nil
+	}
+
+	regs, err := repo.ListEventRegistrations(ctx, eventID)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+	for _, r := range regs {
+		if r.ClientID != clientID || r.Status != models.RegistrationActive {
+			continue
+		}
+		if r.Paid {
+			return fmt.Sprintf("Взнос %s уже оплачен.", clientID), nil
+		}
+
+		// Взнос — движение по блокирующей брони: выручка турнира в общем журнале
+		bal, err := ledger.AddLedgerEntry(ctx, &models.LedgerEntry{
+			BookingID: e.BookingID,
+			Kind:      models.LedgerCharge,
+			Method:    method,
+			Amount:    e.EntryFee,
+			Actor:     models.ActorAdmin,
+			Note:      "взнос " + clientID,
+		})
+		if err != nil {
+			return fmt.Sprintf("Ошибка: %v", err), nil
+		}
+		if err := repo.MarkRegistrationPaid(ctx, eventID, clientID); err != nil {
+			return fmt.Sprintf("Ошибка: %v", err), nil
+		}
+		return fmt.Sprintf("Взнос %.0f тг от %s записан (%s). %s",
+			e.EntryFee, clientID, models.PaymentMethodLabel(method), formatBalance(bal)), nil
+	}
+	return fmt.Sprintf("Ошибка: %s не зарегистрирован на «%s».", clientID, e.Title), nil
+}
+
+// broadcastEvent — сообщение каждому зарегистрированному участнику; оно же
+// сохраняется в истории клиента, чтобы бот видел его при ответе.
+func (s *AIService) broadcastEvent(ctx context.Context, repo ClubEventRepository, e *models.ClubEvent, text string) (sent, failed int, err error) {
+	regs, err := repo.ListEventRegistrations(ctx, e.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if s.Messenger == nil {
+		return 0, 0, fmt.Errorf("отправка клиентам не настроена")
+	}
+
+	for _, r := range regs {
+		if r.Status != models.RegistrationActive {
+			continue
+		}
+		if err := s.ContextManager.SaveMessage(ctx, r.ClientID, "bot", text); err != nil {
+			log.Printf("[Events] save message for %s: %v", r.ClientID, err)
+		}
+		if err := s.Messenger.SendToClient(ctx, r.ClientID, text); err != nil {
+			log.Printf("[Events] send to %s: %v", r.ClientID, err)
+			failed++
+			continue
+		}
+		sent++
+	}
+	return sent, failed, nil
+}
+
+func formatBroadcast(sent, failed int) string {
+	if sent == 0 && failed == 0 {
+		return "Участников нет — рассылать некому."
+	}
+	out := fmt.Sprintf("Отправлено участникам: %d.", sent)
+	if failed > 0 {
+		out += fmt.Sprintf(" Не доставлено: %d (см. логи).", failed)
+	}
+	return out
+}
+
+func formatEventLine(e models.ClubEvent) string {
+	out := fmt.Sprintf("«%s» %s, %d ч", e.Title, e.Start.Format("02.01 15:04"), e.Hours)
+	if e.Track != "" {
+		out += ", " + e.Track
+	}
+	if e.CarClass != "" {
+		out += ", " + e.CarClass
+	}
+	if e.EntryFee > 0 {
+		out += fmt.Sprintf(", взнос %.0f тг", e.EntryFee)
+	}
+	return out + fmt.Sprintf(": участников %d/%d", e.Registered, e.MaxParticipants)
+}
+
+// -----------------------------------------------------------------------------
+//  PREPAID (сертификаты и пакеты часов)
+// -----------------------------------------------------------------------------
+
+// Срок действия по умолчанию, дней.
+const (
+	defaultCertificateDays = 365
+	defaultHourPackageDays = 180
+)
+
+func (s *AIService) IssueCertificateTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	amount, _ := floatArg(args, "amount")
+	price, ok := floatArg(args, "price")
+	if !ok {
+		price = amount
+	}
+	p := &models.PrepaidAccount{Kind: models.PrepaidCertificate, Initial: amount, Price: price}
+	p.Code, _ = strArg(args, "code")
+	p.ClientID, _ = strArg(args, "client_id")
+	return s.issuePrepaid(ctx, p, args, defaultCertificateDays)
+}
+
+func (s *AIService) SellHourPackageTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	hours, _ := floatArg(args, "hours")
+	price, _ := floatArg(args, "price")
+	p := &models.PrepaidAccount{Kind: models.PrepaidHours, Initial: hours, Price: price}
+	p.ClientID, _ = strArg(args, "client_id")
+	if price <= 0 {
+		return "Ошибка: укажите цену пакета.", nil
+	}
+	return s.issuePrepaid(ctx, p, args, defaultHourPackageDays)
+}
+
+// issuePrepaid — общая часть продажи: способ оплаты, срок, запись.
+func (s *AIService) issuePrepaid(ctx context.Context, p *models.PrepaidAccount, args map[string]interface{}, defaultDays int) (string, error) {
+	repo, ok := s.ContextManager.(PrepaidRepository)
+	if !ok {
+		return "Ошибка: сертификаты и пакеты недоступны.", nil
+	}
+
+	p.Method, _ = strArg(args, "method")
+	switch p.Method {
+	case models.MethodKaspiQR, models.MethodCash, models.MethodCard:
+	case "":
+		if p.Price > 0 {
+			return "Ошибка: укажите способ оплаты (kaspi_qr, cash, card).", nil
+		}
+	default:
+		return "Ошибка: способ оплаты kaspi_qr, cash или card.", nil
+	}
+
+	// ExpiresAt — начало дня после последнего дня действия
+	days := defaultDays
+	if d, ok := floatArg(args, "valid_days"); ok && d > 0 {
+		days = int(d)
+	}
+	now := availability.Now()
+	p.ExpiresAt = time.Date(now.Year(), now.Month(), now.Day()+days+1, 0, 0, 0, 0, now.Location())
+	p.CreatedBy = models.ActorAdmin
+
+	if err := repo.IssuePrepaid(ctx, p); err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+	log.Printf("🎟️ Prepaid issued: %s %s initial=%.0f price=%.0f client=%s", p.Kind, p.Code, p.Initial, p.Price, p.ClientID)
+
+	out := fmt.Sprintf("Оформлен %s %s: ", models.PrepaidKindLabel(p.Kind), p.Code)
+	if p.Kind == models.PrepaidHours {
+		out += fmt.Sprintf("%.0f место-часов для %s", p.Initial, p.ClientID)
+	} else {
+		out += fmt.Sprintf("номинал %.0f тг", p.Initial)
+	}
+	if p.Price > 0 {
+		out += fmt.Sprintf(", оплачено %.0f тг (%s)", p.Price, models.PaymentMethodLabel(p.Method))
+	}
+	return out + fmt.Sprintf(", действует до %s.", p.ExpiresAt.AddDate(0, 0, -1).Format("02.01.2006")), nil
+}
+
+func (s *AIService) RedeemPrepaidTool(ctx context.Context, bookingID, code string) (string, error) {
+	repo, ok := s.ContextManager.(PrepaidRepository)
+	if !ok {
+		return "Ошибка: сертификаты и пакеты недоступны.", nil
+	}
+	bookings, ok := s.ContextManager.(BookingLifecycleRepository)
+	if !ok {
+		return "Ошибка: управление бронями недоступно.", nil
+	}
+
+	red, bal, err := repo.RedeemPrepaid(ctx, code, bookingID, models.ActorAdmin)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+	out := fmt.Sprintf("Списано с %s: %.0f тг. %s", red.Code, red.Value, formatBalance(bal))
+
+	bk, err := bookings.GetBooking(ctx, bookingID)
+	if err != nil || bk == nil {
+		return out, nil
+	}
+	if settled, err := SettleBooking(ctx, bookings, bk, bal, models.ActorAdmin, "оплачено предоплатой"); err != nil {
+		out += fmt.Sprintf(" Статус не обновлён: %v", err)
+	} else if settled && bk.Status != models.BookingPaid {
+		out += " Бронь оплачена полностью."
+	}
+	return out, nil
+}
+
+// GetPrepaidReportTool — обязательства по предоплате; с кодом — карточка
+// конкретного сертификата/пакета.
+func (s *AIService) GetPrepaidReportTool(ctx context.Context, code string) (string, error) {
+	repo, ok := s.ContextManager.(PrepaidRepository)
+	if !ok {
+		return "Ошибка: сертификаты и пакеты недоступны.", nil
+	}
+	now := availability.Now()
+
+	if code = strings.TrimSpace(code); code != "" {
+		p, err := repo.GetPrepaid(ctx, code)
+		if err != nil {
+			return fmt.Sprintf("Ошибка: %v", err), nil
+		}
+		if p == nil {
+			return fmt.Sprintf("Код %s не найден.", models.NormalizePromoCode(code)), nil
+		}
+		out := fmt.Sprintf("%s %s: остаток %.2f из %.0f (≈ %.0f тг), продан за %.0f тг %s",
+			models.PrepaidKindLabel(p.Kind), p.Code, p.Balance, p.Initial, p.Balance*p.UnitValue(),
+			p.Price, p.CreatedAt.Format("02.01.2006"))
+		if p.ClientID != "" {
+			out += ", клиент " + p.ClientID
+		}
+		if !p.ExpiresAt.IsZero() {
+			out += ", до " + p.ExpiresAt.AddDate(0, 0, -1).Format("02.01.2006")
+			if p.Expired(now) {
+				out += " (истёк)"
+			}
+		}
+		return out + ".", nil
+	}
+
+	report, err := repo.PrepaidLiability(ctx, now)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+
+	var b strings.Builder
+	var total float64
+	b.WriteString("Непогашенная предоплата (обязательства клуба):\n")
+	for _, l := range report {
+		unit := "тг"
+		if l.Kind == models.PrepaidHours {
+			unit = "место-ч"
+		}
+		fmt.Fprintf(&b, "- %s: действующих %d, остаток %.1f %s ≈ %.0f тг; продано %.0f тг, списано в брони %.0f тг, сгорело %.0f тг\n",
+			models.PrepaidKindLabel(l.Kind), l.Accounts, l.Balance, unit, l.Value, l.Sold, l.RedeemedValue, l.ExpiredValue)
+		total += l.Value
+	}
+	fmt.Fprintf(&b, "Итого обязательств: %.0f тг.", total)
+	return b.String(), nil
+}
+
+// -----------------------------------------------------------------------------
+//  LAP LEADERBOARD
+// -----------------------------------------------------------------------------
+
+func (s *AIService) GetLapLeaderboardTool(ctx context.Context, track string, days, limit int) (string, error) {
+	repo, ok := s.ContextManager.(LapRepository)
+	if !ok {
+		return "Ошибка: лидерборд недоступен.", nil
+	}
+	if days <= 0 {
+		days = 7
+	}
+
+	to := availability.Now()
+	from := to.AddDate(0, 0, -days)
+	top, err := repo.TopLaps(ctx, track, from, to, limit)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+	laps, drivers, clients, err := repo.LapActivity(ctx, from, to)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Лидерборд за %d дн. (%s → %s): кругов %d, пилотов %d, из них клиентов %d.\n",
+		days, from.Format("02.01"), to.Format("02.01"), laps, drivers, clients)
+	if len(top) == 0 {
+		b.WriteString("Кругов за период нет.\n")
+		return b.String(), nil
+	}
+
+	var section string
+	for _, l := range top {
+		if key := l.Game + "|" + l.Track; key != section {
+			section = key
+			fmt.Fprintf(&b, "%s (%s):\n", l.Track, l.Game)
+		}
+		who := l.Driver
+		if l.ClientID != "" {
+			who += " [" + l.ClientID + "]"
+		}
+		fmt.Fprintf(&b, "%d. %s — %s", l.Rank, who, models.FormatLapTime(l.LapMs))
+		if l.Car != "" {
+			b.WriteString(", " + l.Car)
+		}
+		fmt.Fprintf(&b, " (%s, кругов %d)\n", l.SetAt.Format("02.01"), l.Laps)
+	}
+	return b.String(), nil
+}
+
+func (s *AIService) LinkDriverTool(ctx context.Context, driver, clientID string) (string, error) {
+	repo, ok := s.ContextManager.(LapRepository)
+	if !ok {
+		return "Ошибка: лидерборд недоступен.", nil
+	}
+
+	n, err := repo.LinkDriver(ctx, driver, strings.TrimSpace(clientID), true)
+	if err != nil {
+		return fmt.Sprintf("Ошибка: %v", err), nil
+	}
+	return fmt.Sprintf("Ник %s привязан к %s, кругов: %d.", strings.TrimSpace(driver), clientID, n), nil
+}
+
 // -----------------------------------------------------------------------------
 //  SALES RECOMMENDATION TOOL
 // -----------------------------------------------------------------------------
 
 func (s *AIService) GetSalesRecommendationTool(ctx context.Context) (string, error) {
 
-	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	yesterday := availability.Now().AddDate(0, 0, -1).Format("2006-01-02")
 
 	sales, _ := s.GetRevenueByDateRangeTool(ctx, yesterday, yesterday)
 	if sales == "" {
@@ -0,0 +1,232 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// DIALOG LOG (core.AnalyticsRepo)
+// -----------------------------------------------------------------------------
+
+func (r *SQLiteContextRepo) SaveLog(ctx context.Context, entry models.DialogLog) error {
+	_, err := r.DB.ExecContext(ctx, `
+		INSERT INTO dialog_logs (client_id, timestamp, message_text, intent, lead_source, sentiment)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, entry.ClientID, entry.Timestamp, entry.MessageText, entry.Intent, entry.LeadSource, entry.Sentiment)
+	return err
+}
+
+// -----------------------------------------------------------------------------
+// SALES REPORT (диапазон дат YYYY-MM-DD, включительно)
+// -----------------------------------------------------------------------------
+
+// GetSalesReport — выручка кассовым методом: деньги, полученные в период
+// (за вычетом возвратов), с разбивкой по способам. Продажа сертификата или
+// пакета часов — выручка в день продажи (prepaid_accounts.price); списание
+// с него при оплате брони денег не приносит и идёт отдельной строкой
+// prepaid_redeemed. Брони, отмены и долг — по броням, начинающимся в период.
+func (r *SQLiteContextRepo) GetSalesReport(ctx context.Context, startDate, endDate string) (map[string]interface{}, error) {
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return nil, fmt.Errorf("неверная дата начала: %s", startDate)
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return nil, fmt.Errorf("неверная дата окончания: %s", endDate)
+	}
+	end = end.AddDate(0, 0, 1)
+
+	var revenue, refunds, redeemed float64
+	byMethod := map[string]float64{}
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT method,
+		       COALESCE(SUM(amount), 0),
+		       COALESCE(SUM(CASE WHEN kind = ? THEN -amount ELSE 0 END), 0)
+		FROM booking_payments
+		WHERE created_at >= ? AND created_at < ?
+		GROUP BY method
+	`, models.LedgerRefund, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var method string
+		var net, refunded float64
+		if err := rows.Scan(&method, &net, &refunded); err != nil {
+			return nil, err
+		}
+		if method == models.MethodPrepaid {
+			redeemed = net // оплачено раньше, при продаже сертификата
+			continue
+		}
+		byMethod[method] = net
+		revenue += net
+		refunds += refunded
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// Продажи сертификатов и пакетов — по способу оплаты при продаже
+	var prepaidSales float64
+	rows, err = r.DB.QueryContext(ctx, `
+		SELECT method, COALESCE(SUM(price), 0)
+		FROM prepaid_accounts
+		WHERE price > 0 AND created_at >= ? AND created_at < ?
+		GROUP BY method
+	`, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var method string
+		var sold float64
+		if err := rows.Scan(&method, &sold); err != nil {
+			return nil, err
+		}
+		byMethod[method] += sold
+		revenue += sold
+		prepaidSales += sold
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	var total int
+	var booked, due float64
+	err = r.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*),
+		       COALESCE(SUM(b.amount), 0),
+		       COALESCE(SUM(b.amount - COALESCE(
+		           (SELECT SUM(p.amount) FROM booking_payments p WHERE p.booking_id = b.booking_id), 0)), 0)
+		FROM bookings b
+		WHERE b.booking_start >= ? AND b.booking_start < ? AND b.`+activeStatusSQL,
+		start, end).Scan(&total, &booked, &due)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelled, noShow, err := r.lostBookings(ctx, "booking_start >= ? AND booking_start < ?", start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	avg := 0.0
+	if total > 0 {
+		avg = revenue / float64(total)
+	}
+
+	return map[string]interface{}{
+		"total_revenue":      revenue,
+		"revenue_by_method":  byMethod,
+		"refunds":            refunds,
+		"prepaid_sales":      prepaidSales,
+		"prepaid_redeemed":   redeemed,
+		"booked_amount":      booked,
+		"balance_due":        due,
+		"total_bookings":     total,
+		"average_check":      avg,
+		"cancelled_bookings": cancelled,
+		"no_show_bookings":   noShow,
+	}, nil
+}
+
+// -----------------------------------------------------------------------------
+// SALES DETAIL (today | last30 | client_id:XXX | seats=N)
+// -----------------------------------------------------------------------------
+
+func (r *SQLiteContextRepo) GetSalesDetail(ctx context.Context, filter string) (map[string]interface{}, error) {
+	now := availability.Now()
+	where := "booking_start >= ?"
+	args := []interface{}{now.AddDate(0, 0, -30)}
+
+	switch {
+	case filter == "today":
+		day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		where = "booking_start >= ? AND booking_start < ?"
+		args = []interface{}{day, day.AddDate(0, 0, 1)}
+	case strings.HasPrefix(filter, "client_id:"):
+		where += " AND client_id = ?"
+		args = append(args, strings.TrimPrefix(filter, "client_id:"))
+	case strings.HasPrefix(filter, "seats="):
+		seats, err := strconv.Atoi(strings.TrimPrefix(filter, "seats="))
+		if err != nil {
+			return nil, fmt.Errorf("неверный фильтр мест: %s", filter)
+		}
+		where += " AND seats = ?"
+		args = append(args, seats)
+	}
+
+	var total, fourSeat, seatHours int
+	var revenue float64
+	err := r.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*),
+		       COALESCE(SUM(amount), 0),
+		       COALESCE(SUM(CASE WHEN seats = 4 THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(seats * hours), 0)
+		FROM bookings
+		WHERE `+where+` AND `+activeStatusSQL, args...).Scan(&total, &revenue, &fourSeat, &seatHours)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelled, noShow, err := r.lostBookings(ctx, where, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var popularHour sql.NullString
+	err = r.DB.QueryRowContext(ctx, `
+		SELECT strftime('%H:00', booking_start) AS h
+		FROM bookings
+		WHERE `+where+` AND `+activeStatusSQL+`
+		GROUP BY h
+		ORDER BY COUNT(*) DESC
+		LIMIT 1
+	`, args...).Scan(&popularHour)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	avgCheck, avgSeat := 0.0, 0.0
+	if total > 0 {
+		avgCheck = revenue / float64(total)
+	}
+	if seatHours > 0 {
+		avgSeat = revenue / float64(seatHours)
+	}
+
+	return map[string]interface{}{
+		"total_bookings":     total,
+		"total_revenue":      revenue,
+		"avg_check":          avgCheck,
+		"four_seat_bookings": fourSeat,
+		"avg_price_per_seat": avgSeat,
+		"popular_hour":       popularHour.String,
+		"cancelled_bookings": cancelled,
+		"no_show_bookings":   noShow,
+	}, nil
+}
+
+// lostBookings — отмены и неявки за тот же период (в выручку не входят).
+func (r *SQLiteContextRepo) lostBookings(ctx context.Context, where string, args ...interface{}) (cancelled, noShow int, err error) {
+	err = r.DB.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0)
+		FROM bookings
+		WHERE `+where, append([]interface{}{models.BookingCancelled, models.BookingNoShow}, args...)...,
+	).Scan(&cancelled, &noShow)
+	return cancelled, noShow, err
+}
@@ -0,0 +1,285 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/events"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// BOOKING LOOKUP
+// -----------------------------------------------------------------------------
+
+const bookingColumns = `
+	b.booking_id, b.client_id, b.status, b.created_by, b.booking_start, b.seats, b.hours, b.amount, b.quote_json, b.game,
+	COALESCE((SELECT pr.code FROM promo_redemptions pr WHERE pr.booking_id = b.booking_id LIMIT 1), ''),
+	COALESCE((SELECT pr.discount FROM promo_redemptions pr WHERE pr.booking_id = b.booking_id LIMIT 1), 0)`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBooking(row rowScanner) (*models.Booking, error) {
+	var b models.Booking
+	var quoteJSON string
+	if err := row.Scan(
+		&b.BookingID, &b.ClientID, &b.Status, &b.CreatedBy, &b.Start, &b.Seats, &b.Hours, &b.Amount, &quoteJSON, &b.Game,
+		&b.PromoCode, &b.PromoDiscount,
+	); err != nil {
+		return nil, err
+	}
+	if quoteJSON != "" {
+		var q models.Quote
+		if err := json.Unmarshal([]byte(quoteJSON), &q); err == nil {
+			b.Quote = &q
+		}
+	}
+	return &b, nil
+}
+
+// GetBooking — бронь со станциями; nil, если не найдена.
+func (r *SQLiteContextRepo) GetBooking(ctx context.Context, bookingID string) (*models.Booking, error) {
+	return getBooking(ctx, r.DB, bookingID)
+}
+
+type bookingQueryer interface {
+	queryer
+	rowQueryer
+}
+
+func getBooking(ctx context.Context, q bookingQueryer, bookingID string) (*models.Booking, error) {
+	b, err := scanBooking(q.QueryRowContext(ctx,
+		`SELECT `+bookingColumns+` FROM bookings b WHERE b.booking_id = ?`, bookingID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := []models.Booking{*b}
+	if err := loadBookingRigs(ctx, q, list); err != nil {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// ListClientBookings — брони клиента, которые ещё не закончились к from
+// (любой статус), по времени начала.
+func (r *SQLiteContextRepo) ListClientBookings(ctx context.Context, clientID string, from time.Time) ([]models.Booking, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT `+bookingColumns+`
+		FROM bookings b
+		WHERE b.client_id = ? AND b.booking_start >= ?
+		ORDER BY b.booking_start
+	`, clientID, from.Add(-availability.MaxHours*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Booking
+	for rows.Next() {
+		b, err := scanBooking(rows)
+		if err != nil {
+			return nil, err
+		}
+		if availability.End(*b).After(from) {
+			out = append(out, *b)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	return out, loadBookingRigs(ctx, r.DB, out)
+}
+
+// -----------------------------------------------------------------------------
+// STATUS TRANSITIONS
+// -----------------------------------------------------------------------------
+
+// SetBookingStatus — переход статуса с записью в журнал. Недопустимый
+// переход — models.ErrInvalidTransition.
+func (r *SQLiteContextRepo) SetBookingStatus(ctx context.Context, bookingID, status, actor, note string) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	bk, err := getBooking(ctx, tx, bookingID)
+	if err != nil {
+		return err
+	}
+	if bk == nil {
+		return fmt.Errorf("бронь %s не найдена", bookingID)
+	}
+	from := bk.Status
+	if !models.CanTransition(from, status) {
+		return fmt.Errorf("%w: %s → %s", models.ErrInvalidTransition, from, status)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE bookings SET status = ? WHERE booking_id = ?`, status, bookingID,
+	); err != nil {
+		return err
+	}
+	if err := logBookingEvent(ctx, tx, models.BookingEvent{
+		BookingID: bookingID, FromStatus: from, ToStatus: status, Actor: actor, Note: note,
+	}); err != nil {
+		return err
+	}
+
+	var e events.Event = events.BookingStatusChanged{
+		BookingID: bookingID, ClientID: bk.ClientID, From: from, To: status, Actor: actor,
+	}
+	if status == models.BookingCancelled {
+		e = cancelledEvent(bk, actor, note)
+	}
+	if err := insertOutbox(ctx, tx, e); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// cancelledEvent — событие отмены брони b (b.Status — статус до отмены).
+func cancelledEvent(b *models.Booking, actor, note string) events.BookingCancelled {
+	return events.BookingCancelled{
+		BookingID: b.BookingID, ClientID: b.ClientID, Start: b.Start, Seats: b.Seats, Hours: b.Hours,
+		FromStatus: b.Status, Actor: actor, Note: note,
+	}
+}
+
+// -----------------------------------------------------------------------------
+// RESCHEDULE (новые станции подбираются в той же транзакции)
+// -----------------------------------------------------------------------------
+
+// RescheduleBooking переносит активную бронь на b.Start/b.Hours с новой
+// суммой b.Amount/b.Quote. Станции подбираются заново; сама бронь
+// свои старые станции не блокирует.
+func (r *SQLiteContextRepo) RescheduleBooking(ctx context.Context, b *models.Booking, game, actor string) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := getBooking(ctx, tx, b.BookingID)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return fmt.Errorf("бронь %s не найдена", b.BookingID)
+	}
+	if !current.IsActive() {
+		return fmt.Errorf("%w: бронь %s", models.ErrInvalidTransition, models.BookingStatusLabel(current.Status))
+	}
+
+	rigs, err := queryRigs(ctx, tx)
+	if err != nil {
+		return err
+	}
+	end := availability.End(*b)
+	overlapping, err := queryBookingsBetween(ctx, tx, b.Start, end)
+	if err != nil {
+		return err
+	}
+	others := overlapping[:0]
+	for _, o := range overlapping {
+		if o.BookingID != b.BookingID {
+			others = append(others, o)
+		}
+	}
+
+	free := availability.FreeRigs(rigs, others, b.Start, end, game)
+	picked, _ := availability.PickRigs(free, b.Seats)
+	if picked == nil {
+		return models.ErrNotEnoughSeats
+	}
+
+	quoteJSON, err := marshalQuote(b.Quote)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE bookings SET booking_start = ?, hours = ?, amount = ?, quote_json = ?
+		WHERE booking_id = ?
+	`, b.Start, b.Hours, b.Amount, quoteJSON, b.BookingID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM booking_rigs WHERE booking_id = ?`, b.BookingID); err != nil {
+		return err
+	}
+	b.RigIDs = b.RigIDs[:0]
+	for _, rig := range picked {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO booking_rigs (booking_id, rig_id) VALUES (?, ?)`,
+			b.BookingID, rig.ID,
+		); err != nil {
+			return err
+		}
+		b.RigIDs = append(b.RigIDs, rig.ID)
+	}
+
+	b.Status = current.Status
+	note := fmt.Sprintf("перенос %s (%d ч) → %s (%d ч)",
+		current.Start.Format("2006-01-02 15:04"), current.Hours, b.Start.Format("2006-01-02 15:04"), b.Hours)
+	if err := logBookingEvent(ctx, tx, models.BookingEvent{
+		BookingID: b.BookingID, FromStatus: current.Status, ToStatus: current.Status, Actor: actor, Note: note,
+	}); err != nil {
+		return err
+	}
+	if err := insertOutbox(ctx, tx, events.BookingRescheduled{
+		BookingID: b.BookingID, ClientID: current.ClientID, From: current.Start, To: b.Start, Hours: b.Hours, Actor: actor,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// -----------------------------------------------------------------------------
+// BOOKING EVENTS (журнал переходов)
+// -----------------------------------------------------------------------------
+
+func logBookingEvent(ctx context.Context, tx *sql.Tx, e models.BookingEvent) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO booking_events (booking_id, from_status, to_status, actor, note)
+		VALUES (?, ?, ?, ?, ?)
+	`, e.BookingID, e.FromStatus, e.ToStatus, e.Actor, e.Note)
+	return err
+}
+
+// GetBookingEvents — журнал брони по времени.
+func (r *SQLiteContextRepo) GetBookingEvents(ctx context.Context, bookingID string) ([]models.BookingEvent, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT booking_id, from_status, to_status, actor, note, created_at
+		FROM booking_events
+		WHERE booking_id = ?
+		ORDER BY id
+	`, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.BookingEvent
+	for rows.Next() {
+		var e models.BookingEvent
+		if err := rows.Scan(&e.BookingID, &e.FromStatus, &e.ToStatus, &e.Actor, &e.Note, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"time"
 
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/events"
 	"whatsapp-analytics-mvp/internal/models"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -22,7 +24,9 @@ type SQLiteContextRepo struct {
 // -----------------------------------------------------------------------------
 
 func NewSQLiteContextRepo(dbPath string) (*SQLiteContextRepo, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	// immediate-транзакции: две брони из параллельных чатов не проверят
+	// вместимость одновременно; busy_timeout — ждать, а не падать на блокировке
+	db, err := sql.Open("sqlite3", dbPath+"?_txlock=immediate&_busy_timeout=5000")
 	if err != nil {
 		return nil, err
 	}
@@ -53,10 +57,25 @@ func NewSQLiteContextRepo(dbPath string) (*SQLiteContextRepo, error) {
 		seats INTEGER DEFAULT 1,
 		hours INTEGER DEFAULT 1,
 		amount REAL DEFAULT 0,
+		quote_json TEXT DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'created',
+		created_by TEXT DEFAULT 'bot',
+		game TEXT DEFAULT '',
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY(client_id) REFERENCES clients(client_id)
 	);
 
+	CREATE TABLE IF NOT EXISTS booking_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		booking_id TEXT NOT NULL,
+		from_status TEXT DEFAULT '',
+		to_status TEXT NOT NULL,
+		actor TEXT NOT NULL,
+		note TEXT DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(booking_id) REFERENCES bookings(booking_id)
+	);
+
 	CREATE TABLE IF NOT EXISTS sessions (
 		session_id INTEGER PRIMARY KEY AUTOINCREMENT,
 		client_id TEXT NOT NULL,
@@ -67,17 +86,375 @@ func NewSQLiteContextRepo(dbPath string) (*SQLiteContextRepo, error) {
 		FOREIGN KEY(booking_id) REFERENCES bookings(booking_id)
 	);
 
+	CREATE TABLE IF NOT EXISTS booking_drafts (
+		session_id INTEGER PRIMARY KEY,
+		client_id TEXT NOT NULL,
+		seats INTEGER DEFAULT 0,
+		booking_date TEXT DEFAULT '',
+		start_time TEXT DEFAULT '',
+		hours INTEGER DEFAULT 0,
+		price_quote REAL DEFAULT 0,
+		booking_id TEXT DEFAULT '',
+		promo_code TEXT DEFAULT '',
+		game TEXT DEFAULT '',
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(session_id) REFERENCES sessions(session_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS rigs (
+		rig_id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		hardware TEXT DEFAULT '',
+		games TEXT DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'active'
+	);
+
+	CREATE TABLE IF NOT EXISTS booking_rigs (
+		booking_id TEXT NOT NULL,
+		rig_id INTEGER NOT NULL,
+		PRIMARY KEY (booking_id, rig_id),
+		FOREIGN KEY(booking_id) REFERENCES bookings(booking_id),
+		FOREIGN KEY(rig_id) REFERENCES rigs(rig_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS promo_codes (
+		code TEXT PRIMARY KEY,
+		kind TEXT NOT NULL DEFAULT 'percent',
+		value REAL NOT NULL,
+		valid_from TIMESTAMP,
+		valid_to TIMESTAMP,
+		max_uses INTEGER DEFAULT 0,
+		per_client_limit INTEGER DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS promo_redemptions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		code TEXT NOT NULL,
+		client_id TEXT NOT NULL,
+		booking_id TEXT NOT NULL,
+		discount REAL DEFAULT 0,
+		redeemed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY(code) REFERENCES promo_codes(code),
+		FOREIGN KEY(booking_id) REFERENCES bookings(booking_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS invoices (
+		invoice_id TEXT PRIMARY KEY,
+		provider TEXT NOT NULL,
+		booking_id TEXT NOT NULL,
+		amount REAL NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		payment_url TEXT DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		paid_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS booking_payments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		booking_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		method TEXT NOT NULL,
+		amount REAL NOT NULL,
+		actor TEXT NOT NULL,
+		note TEXT DEFAULT '',
+		invoice_id TEXT DEFAULT '',
+		created_at TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS waitlist (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		client_id TEXT NOT NULL,
+		slot_start TIMESTAMP NOT NULL,
+		seats INTEGER NOT NULL,
+		hours INTEGER NOT NULL,
+		game TEXT DEFAULT '',
+		flex_hours INTEGER DEFAULT 0,
+		status TEXT NOT NULL DEFAULT 'waiting',
+		booking_id TEXT DEFAULT '',
+		offer_expires_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS demand_misses (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		client_id TEXT DEFAULT '',
+		slot_start TIMESTAMP NOT NULL,
+		seats INTEGER NOT NULL,
+		hours INTEGER NOT NULL,
+		game TEXT DEFAULT '',
+		created_at TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS club_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		event_start TIMESTAMP NOT NULL,
+		hours INTEGER NOT NULL,
+		track TEXT DEFAULT '',
+		car_class TEXT DEFAULT '',
+		game TEXT DEFAULT '',
+		max_participants INTEGER NOT NULL,
+		entry_fee REAL DEFAULT 0,
+		status TEXT NOT NULL DEFAULT 'open',
+		booking_id TEXT DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS event_registrations (
+		event_id INTEGER NOT NULL,
+		client_id TEXT NOT NULL,
+		name TEXT DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'registered',
+		paid INTEGER DEFAULT 0,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (event_id, client_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS lap_times (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		client_id TEXT DEFAULT '',
+		driver TEXT NOT NULL,
+		driver_key TEXT NOT NULL,
+		game TEXT NOT NULL,
+		track TEXT NOT NULL,
+		car TEXT DEFAULT '',
+		lap_ms INTEGER NOT NULL,
+		set_at TIMESTAMP NOT NULL,
+		source TEXT DEFAULT '',
+		created_at TIMESTAMP NOT NULL,
+		UNIQUE (driver_key, game, track, car, lap_ms, set_at)
+	);
+
+	CREATE TABLE IF NOT EXISTS lap_drivers (
+		driver_key TEXT PRIMARY KEY,
+		driver TEXT NOT NULL,
+		client_id TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS prepaid_accounts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		code TEXT NOT NULL UNIQUE,
+		kind TEXT NOT NULL,
+		client_id TEXT DEFAULT '',
+		initial REAL NOT NULL,
+		balance REAL NOT NULL,
+		price REAL NOT NULL DEFAULT 0,
+		method TEXT DEFAULT '',
+		expires_at TIMESTAMP,
+		status TEXT NOT NULL DEFAULT 'active',
+		created_by TEXT DEFAULT '',
+		created_at TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS prepaid_redemptions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		prepaid_id INTEGER NOT NULL,
+		booking_id TEXT NOT NULL,
+		units REAL NOT NULL,
+		value REAL NOT NULL,
+		reversed INTEGER DEFAULT 0,
+		created_at TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS scheduled_jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		job_key TEXT NOT NULL UNIQUE,
+		kind TEXT NOT NULL,
+		ref TEXT DEFAULT '',
+		payload TEXT DEFAULT '',
+		run_at TIMESTAMP NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		max_attempts INTEGER NOT NULL DEFAULT 5,
+		locked_until TIMESTAMP,
+		last_error TEXT DEFAULT '',
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS outbox_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		retry_at TIMESTAMP,
+		last_error TEXT DEFAULT '',
+		created_at TIMESTAMP NOT NULL,
+		delivered_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		subscriber TEXT NOT NULL,
+		event_id TEXT NOT NULL,
+		event TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		response_code INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT DEFAULT '',
+		next_attempt_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		delivered_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS outbound_messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		crm_message_id TEXT NOT NULL UNIQUE,
+		provider_id TEXT DEFAULT '',
+		client_id TEXT NOT NULL,
+		channel_id TEXT NOT NULL,
+		message_text TEXT DEFAULT '',
+		content_uri TEXT DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'pending',
+		error TEXT DEFAULT '',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS outbound_early_statuses (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		provider_id TEXT NOT NULL,
+		status TEXT NOT NULL,
+		error TEXT DEFAULT '',
+		received_at TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS inbound_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		source TEXT NOT NULL,
+		external_id TEXT NOT NULL,
+		client_id TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT DEFAULT '',
+		next_attempt_at TIMESTAMP NOT NULL,
+		received_at TIMESTAMP NOT NULL,
+		processed_at TIMESTAMP,
+		UNIQUE (source, external_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS handoffs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		client_id TEXT NOT NULL,
+		reason TEXT DEFAULT '',
+		started_at TIMESTAMP NOT NULL,
+		last_operator_at TIMESTAMP NOT NULL,
+		ended_at TIMESTAMP,
+		ended_by TEXT DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS dialog_logs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		client_id TEXT,
+		timestamp TIMESTAMP,
+		message_text TEXT,
+		intent TEXT,
+		lead_source TEXT,
+		sentiment TEXT
+	);
+
 	CREATE INDEX IF NOT EXISTS idx_messages_client_time ON messages(client_id, timestamp DESC);
 	CREATE INDEX IF NOT EXISTS idx_bookings_start ON bookings(booking_start);
+	CREATE INDEX IF NOT EXISTS idx_bookings_client ON bookings(client_id, booking_start);
+	CREATE INDEX IF NOT EXISTS idx_booking_events_booking ON booking_events(booking_id);
+	CREATE INDEX IF NOT EXISTS idx_sessions_client ON sessions(client_id, expires_at);
+	CREATE INDEX IF NOT EXISTS idx_booking_rigs_rig ON booking_rigs(rig_id);
+	CREATE INDEX IF NOT EXISTS idx_promo_redemptions_code ON promo_redemptions(code, client_id);
+	CREATE INDEX IF NOT EXISTS idx_invoices_booking ON invoices(booking_id, status);
+	CREATE INDEX IF NOT EXISTS idx_booking_payments_booking ON booking_payments(booking_id);
+	CREATE INDEX IF NOT EXISTS idx_booking_payments_created ON booking_payments(created_at);
+	CREATE INDEX IF NOT EXISTS idx_waitlist_status ON waitlist(status, slot_start);
+	CREATE INDEX IF NOT EXISTS idx_waitlist_client ON waitlist(client_id);
+	CREATE INDEX IF NOT EXISTS idx_demand_misses_slot ON demand_misses(slot_start);
+	CREATE INDEX IF NOT EXISTS idx_club_events_start ON club_events(event_start);
+	CREATE INDEX IF NOT EXISTS idx_event_registrations_client ON event_registrations(client_id);
+	CREATE INDEX IF NOT EXISTS idx_lap_times_track ON lap_times(game, track, lap_ms);
+	CREATE INDEX IF NOT EXISTS idx_lap_times_client ON lap_times(client_id);
+	CREATE INDEX IF NOT EXISTS idx_lap_times_set_at ON lap_times(set_at);
+	CREATE INDEX IF NOT EXISTS idx_prepaid_client ON prepaid_accounts(client_id);
+	CREATE INDEX IF NOT EXISTS idx_prepaid_redemptions_booking ON prepaid_redemptions(booking_id);
+	CREATE INDEX IF NOT EXISTS idx_scheduled_jobs_due ON scheduled_jobs(status, run_at);
+	CREATE INDEX IF NOT EXISTS idx_scheduled_jobs_ref ON scheduled_jobs(ref);
+	CREATE INDEX IF NOT EXISTS idx_outbox_events_status ON outbox_events(status, id);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscriber ON webhook_deliveries(subscriber, id);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_event ON webhook_deliveries(subscriber, event_id);
+	CREATE INDEX IF NOT EXISTS idx_outbound_messages_provider ON outbound_messages(provider_id);
+	CREATE INDEX IF NOT EXISTS idx_outbound_messages_status ON outbound_messages(status, created_at);
+	CREATE INDEX IF NOT EXISTS idx_outbound_early_statuses_provider ON outbound_early_statuses(provider_id);
+	CREATE INDEX IF NOT EXISTS idx_inbound_events_client ON inbound_events(status, client_id, id);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_handoffs_active ON handoffs(client_id) WHERE ended_at IS NULL;
 	`
 
 	if _, err := db.Exec(schema); err != nil {
 		return nil, err
 	}
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+	if err := seedRigs(db); err != nil {
+		return nil, err
+	}
 
 	return &SQLiteContextRepo{DB: db}, nil
 }
 
+// migrate — колонки, добавленные после первого релиза. CREATE IF NOT EXISTS
+// не трогает существующие таблицы, поэтому старым БД их нужно дописать.
+func migrate(db *sql.DB) error {
+	columns := []struct{ table, column, decl string }{
+		{"bookings", "quote_json", "TEXT DEFAULT ''"},
+		{"bookings", "status", "TEXT NOT NULL DEFAULT 'created'"},
+		{"bookings", "created_by", "TEXT DEFAULT 'bot'"},
+		{"bookings", "game", "TEXT DEFAULT ''"},
+		{"booking_drafts", "promo_code", "TEXT DEFAULT ''"},
+		{"booking_drafts", "game", "TEXT DEFAULT ''"},
+	}
+	for _, c := range columns {
+		if err := ensureColumn(db, c.table, c.column, c.decl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ensureColumn(db *sql.DB, table, column, decl string) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, decl))
+	return err
+}
+
 // -----------------------------------------------------------------------------
 // SAVE MESSAGE
 // -----------------------------------------------------------------------------
@@ -91,54 +468,230 @@ func (r *SQLiteContextRepo) SaveMessage(ctx context.Context, clientID, sender, t
 }
 
 // -----------------------------------------------------------------------------
-// SAVE BOOKING  (новая функция для ToolsService)
+// CHAT HISTORY (последние сообщения, по возрастанию времени; окно режет core)
+// -----------------------------------------------------------------------------
+
+const chatHistoryLimit = 100
+
+func (r *SQLiteContextRepo) GetChatHistory(ctx context.Context, clientID string) ([]models.ChatMessage, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT timestamp, sender, message_text
+		FROM (
+			SELECT id, timestamp, sender, message_text
+			FROM messages
+			WHERE client_id = ?
+			ORDER BY id DESC
+			LIMIT ?
+		)
+		ORDER BY id ASC
+	`, clientID, chatHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.ChatMessage
+	for rows.Next() {
+		var m models.ChatMessage
+		if err := rows.Scan(&m.Timestamp, &m.Sender, &m.Text); err != nil {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// -----------------------------------------------------------------------------
+// SESSIONS (продлеваем активную или открываем новую на 2 часа)
 // -----------------------------------------------------------------------------
 
-func (r *SQLiteContextRepo) SaveBooking(
-	ctx context.Context,
-	bookingID, clientID string,
-	start time.Time,
-	seats, hours int,
-	amountStr string,
-) error {
+const sessionTTL = 2 * time.Hour
+
+func (r *SQLiteContextRepo) CreateOrUpdateSession(ctx context.Context, clientID string, bookingID *string) error {
+	now := time.Now()
+	expires := now.Add(sessionTTL)
 
-	var amount float64
-	fmt.Sscanf(amountStr, "%f", &amount)
+	sessionID, err := r.activeSessionID(ctx, clientID, now)
 
-	_, err := r.DB.ExecContext(ctx, `
-		INSERT INTO bookings (booking_id, client_id, booking_start, seats, hours, amount)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`, bookingID, clientID, start, seats, hours, amount)
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = r.DB.ExecContext(ctx,
+			`INSERT INTO sessions (client_id, expires_at, booking_id) VALUES (?, ?, ?)`,
+			clientID, expires, bookingID,
+		)
+		return err
+	case err != nil:
+		return err
+	}
 
+	if bookingID != nil {
+		_, err = r.DB.ExecContext(ctx,
+			`UPDATE sessions SET expires_at = ?, booking_id = ? WHERE session_id = ?`,
+			expires, *bookingID, sessionID,
+		)
+		return err
+	}
+	_, err = r.DB.ExecContext(ctx,
+		`UPDATE sessions SET expires_at = ? WHERE session_id = ?`,
+		expires, sessionID,
+	)
 	return err
 }
 
+// activeSessionID — последняя неистёкшая сессия клиента (sql.ErrNoRows, если нет).
+func (r *SQLiteContextRepo) activeSessionID(ctx context.Context, clientID string, now time.Time) (int64, error) {
+	var sessionID int64
+	err := r.DB.QueryRowContext(ctx, `
+		SELECT session_id FROM sessions
+		WHERE client_id = ? AND expires_at > ?
+		ORDER BY session_id DESC
+		LIMIT 1
+	`, clientID, now).Scan(&sessionID)
+	return sessionID, err
+}
+
 // -----------------------------------------------------------------------------
-// GET BOOKINGS AT A SPECIFIC TIME (новая функция для ToolsService)
+// BOOKINGS IN RANGE (все брони, пересекающие [from, to))
 // -----------------------------------------------------------------------------
 
-func (r *SQLiteContextRepo) GetBookingsAt(ctx context.Context, t time.Time) ([]models.Booking, error) {
-	rows, err := r.DB.QueryContext(ctx, `
-		SELECT booking_id, client_id, booking_start, seats, hours, amount
+func (r *SQLiteContextRepo) GetBookingsBetween(ctx context.Context, from, to time.Time) ([]models.Booking, error) {
+	return queryBookingsBetween(ctx, r.DB, from, to)
+}
+
+// queryer — общий интерфейс *sql.DB и *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// activeStatusSQL — брони, которые держат станции и входят в выручку.
+const activeStatusSQL = `status IN ('created', 'paid', 'confirmed')`
+
+func queryBookingsBetween(ctx context.Context, q queryer, from, to time.Time) ([]models.Booking, error) {
+	// Конец брони не хранится — берём старты с запасом на максимальный сеанс
+	rows, err := q.QueryContext(ctx, `
+		SELECT booking_id, client_id, status, booking_start, seats, hours, amount
 		FROM bookings
-		WHERE booking_start = ?
-	`, t)
+		WHERE booking_start >= ? AND booking_start < ? AND `+activeStatusSQL,
+		from.Add(-availability.MaxHours*time.Hour), to)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	var out []models.Booking
-
 	for rows.Next() {
 		var b models.Booking
-		err := rows.Scan(&b.BookingID, &b.ClientID, &b.Start, &b.Seats, &b.Hours, &b.Amount)
-		if err != nil {
+		if err := rows.Scan(&b.BookingID, &b.ClientID, &b.Status, &b.Start, &b.Seats, &b.Hours, &b.Amount); err != nil {
 			continue
 		}
-		out = append(out, b)
+		if availability.Overlaps(b, from, to) {
+			out = append(out, b)
+		}
 	}
-	return out, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	return out, loadBookingRigs(ctx, q, out)
+}
+
+// -----------------------------------------------------------------------------
+// CREATE BOOKING (подбор станций и вставка в одной транзакции)
+// -----------------------------------------------------------------------------
+
+// CreateBooking резервирует b.Seats свободных станций с игрой game (пусто —
+// любая), по возможности рядом, и заполняет b.RigIDs.
+func (r *SQLiteContextRepo) CreateBooking(ctx context.Context, b *models.Booking, game string) error {
+	tx, err := r.DB.BeginTx(ctx, nil) // _txlock=immediate: запись блокируется сразу
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertBooking(ctx, tx, b, game); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertBooking — подбор станций и вставка брони внутри чужой транзакции.
+func insertBooking(ctx context.Context, tx *sql.Tx, b *models.Booking, game string) error {
+	rigs, err := queryRigs(ctx, tx)
+	if err != nil {
+		return err
+	}
+	end := availability.End(*b)
+	overlapping, err := queryBookingsBetween(ctx, tx, b.Start, end)
+	if err != nil {
+		return err
+	}
+
+	free := availability.FreeRigs(rigs, overlapping, b.Start, end, game)
+	picked, _ := availability.PickRigs(free, b.Seats)
+	if picked == nil {
+		return models.ErrNotEnoughSeats
+	}
+
+	quoteJSON, err := marshalQuote(b.Quote)
+	if err != nil {
+		return err
+	}
+
+	if b.Status == "" {
+		b.Status = models.BookingCreated
+	}
+	if b.CreatedBy == "" {
+		b.CreatedBy = models.ActorBot
+	}
+
+	b.Game = game
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO bookings (booking_id, client_id, booking_start, seats, hours, amount, quote_json, status, created_by, game)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, b.BookingID, b.ClientID, b.Start, b.Seats, b.Hours, b.Amount, quoteJSON, b.Status, b.CreatedBy, b.Game)
+	if err != nil {
+		return err
+	}
+	if err := logBookingEvent(ctx, tx, models.BookingEvent{
+		BookingID: b.BookingID, ToStatus: b.Status, Actor: b.CreatedBy,
+	}); err != nil {
+		return err
+	}
+	if err := insertOutbox(ctx, tx, events.BookingCreated{
+		BookingID: b.BookingID, ClientID: b.ClientID, Status: b.Status, CreatedBy: b.CreatedBy,
+		Start: b.Start, Seats: b.Seats, Hours: b.Hours, Amount: b.Amount,
+	}); err != nil {
+		return err
+	}
+
+	if b.PromoCode != "" {
+		if err := redeemPromo(ctx, tx, b); err != nil {
+			return err
+		}
+	}
+
+	b.RigIDs = b.RigIDs[:0]
+	for _, rig := range picked {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO booking_rigs (booking_id, rig_id) VALUES (?, ?)`,
+			b.BookingID, rig.ID,
+		); err != nil {
+			return err
+		}
+		b.RigIDs = append(b.RigIDs, rig.ID)
+	}
+	return nil
+}
+
+// marshalQuote — расчёт цены для колонки quote_json (пусто, если расчёта нет).
+func marshalQuote(q *models.Quote) (string, error) {
+	if q == nil {
+		return "", nil
+	}
+	raw, err := json.Marshal(q)
+	return string(raw), err
 }
 
 // -----------------------------------------------------------------------------
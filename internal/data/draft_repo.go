@@ -0,0 +1,69 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// BOOKING DRAFT (один черновик на сессию; новая сессия — чистый лист)
+// -----------------------------------------------------------------------------
+
+func (r *SQLiteContextRepo) GetDraft(ctx context.Context, clientID string) (*models.BookingDraft, error) {
+	d := &models.BookingDraft{ClientID: clientID}
+
+	sessionID, err := r.activeSessionID(ctx, clientID, time.Now())
+	if err == sql.ErrNoRows {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.SessionID = sessionID
+
+	err = r.DB.QueryRowContext(ctx, `
+		SELECT seats, booking_date, start_time, hours, price_quote, booking_id, promo_code, game, updated_at
+		FROM booking_drafts
+		WHERE session_id = ?
+	`, sessionID).Scan(&d.Seats, &d.Date, &d.StartTime, &d.Hours, &d.PriceQuote, &d.BookingID, &d.PromoCode, &d.Game, &d.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (r *SQLiteContextRepo) SaveDraft(ctx context.Context, d *models.BookingDraft) error {
+	if d.SessionID == 0 {
+		if err := r.CreateOrUpdateSession(ctx, d.ClientID, nil); err != nil {
+			return err
+		}
+		sessionID, err := r.activeSessionID(ctx, d.ClientID, time.Now())
+		if err != nil {
+			return err
+		}
+		d.SessionID = sessionID
+	}
+	d.UpdatedAt = time.Now()
+
+	_, err := r.DB.ExecContext(ctx, `
+		INSERT INTO booking_drafts (session_id, client_id, seats, booking_date, start_time, hours, price_quote, booking_id, promo_code, game, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(session_id) DO UPDATE SET
+			seats = excluded.seats,
+			booking_date = excluded.booking_date,
+			start_time = excluded.start_time,
+			hours = excluded.hours,
+			price_quote = excluded.price_quote,
+			booking_id = excluded.booking_id,
+			promo_code = excluded.promo_code,
+			game = excluded.game,
+			updated_at = excluded.updated_at
+	`, d.SessionID, d.ClientID, d.Seats, d.Date, d.StartTime, d.Hours, d.PriceQuote, d.BookingID, d.PromoCode, d.Game, d.UpdatedAt)
+	return err
+}
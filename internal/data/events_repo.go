@@ -0,0 +1,326 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// CLUB EVENTS (турниры: мероприятие + блокирующая бронь станций)
+// -----------------------------------------------------------------------------
+
+const eventColumns = `
+	e.id, e.title, e.event_start, e.hours, e.track, e.car_class, e.game, e.max_participants, e.entry_fee,
+	e.status, e.booking_id, e.created_at,
+	(SELECT COUNT(*) FROM event_registrations er WHERE er.event_id = e.id AND er.status = 'registered')`
+
+func scanEvent(row rowScanner) (*models.ClubEvent, error) {
+	var e models.ClubEvent
+	if err := row.Scan(&e.ID, &e.Title, &e.Start, &e.Hours, &e.Track, &e.CarClass, &e.Game,
+		&e.MaxParticipants, &e.EntryFee, &e.Status, &e.BookingID, &e.CreatedAt, &e.Registered); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func eventBookingID(eventID int64) string {
+	return fmt.Sprintf("ev_%d", eventID)
+}
+
+// CreateEvent — заводит мероприятие и в той же транзакции бронирует под него
+// MaxParticipants станций (бронь ev_<id>, клиент "event"). Если станций на
+// это время не хватает — ErrNotEnoughSeats, мероприятие не создаётся.
+func (r *SQLiteContextRepo) CreateEvent(ctx context.Context, e *models.ClubEvent) error {
+	if e.MaxParticipants <= 0 || e.Hours <= 0 {
+		return fmt.Errorf("нужны число участников и длительность")
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	e.Status = models.EventOpen
+	e.CreatedAt = availability.Now()
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO club_events (title, event_start, hours, track, car_class, game, max_participants, entry_fee, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.Title, e.Start, e.Hours, e.Track, e.CarClass, e.Game, e.MaxParticipants, e.EntryFee, e.Status, e.CreatedAt)
+	if err != nil {
+		return err
+	}
+	e.ID, _ = res.LastInsertId()
+
+	// Сумма блокирующей брони растёт с регистрациями: fee × участники
+	b := &models.Booking{
+		BookingID: eventBookingID(e.ID),
+		ClientID:  models.EventClientID,
+		Status:    models.BookingConfirmed,
+		CreatedBy: models.ActorAdmin,
+		Start:     e.Start,
+		Seats:     e.MaxParticipants,
+		Hours:     e.Hours,
+	}
+	if err := insertBooking(ctx, tx, b, e.Game); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE club_events SET booking_id = ? WHERE id = ?`, b.BookingID, e.ID); err != nil {
+		return err
+	}
+
+	e.BookingID = b.BookingID
+	e.RigIDs = b.RigIDs
+	e.Registered = 0
+	return tx.Commit()
+}
+
+// GetEvent — мероприятие со станциями; nil, если не найдено.
+func (r *SQLiteContextRepo) GetEvent(ctx context.Context, id int64) (*models.ClubEvent, error) {
+	return getEvent(ctx, r.DB, id)
+}
+
+func getEvent(ctx context.Context, q bookingQueryer, id int64) (*models.ClubEvent, error) {
+	e, err := scanEvent(q.QueryRowContext(ctx, `SELECT `+eventColumns+` FROM club_events e WHERE e.id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if e.BookingID != "" {
+		bookings := []models.Booking{{BookingID: e.BookingID}}
+		if err := loadBookingRigs(ctx, q, bookings); err != nil {
+			return nil, err
+		}
+		e.RigIDs = bookings[0].RigIDs
+	}
+	return e, nil
+}
+
+// ListUpcomingEvents — открытые мероприятия, которые ещё не начались.
+func (r *SQLiteContextRepo) ListUpcomingEvents(ctx context.Context, from time.Time) ([]models.ClubEvent, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT `+eventColumns+`
+		FROM club_events e
+		WHERE e.status = ? AND e.event_start > ?
+		ORDER BY e.event_start
+	`, models.EventOpen, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.ClubEvent
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *e)
+	}
+	return out, rows.Err()
+}
+
+// RegisterForEvent — записывает клиента на мероприятие. Мест не больше
+// MaxParticipants; повторная регистрация после отмены восстанавливает запись,
+// а уже зарегистрированному просто обновляет имя.
+func (r *SQLiteContextRepo) RegisterForEvent(ctx context.Context, reg *models.EventRegistration) (*models.ClubEvent, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	e, err := getEvent(ctx, tx, reg.EventID)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, fmt.Errorf("мероприятие %d не найдено", reg.EventID)
+	}
+	if e.Status != models.EventOpen || !e.Start.After(availability.Now()) {
+		return nil, fmt.Errorf("регистрация на «%s» закрыта", e.Title)
+	}
+
+	var status string
+	err = tx.QueryRowContext(ctx, `
+		SELECT status FROM event_registrations WHERE event_id = ? AND client_id = ?
+	`, reg.EventID, reg.ClientID).Scan(&status)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	already := status == models.RegistrationActive
+	if !already && e.Registered >= e.MaxParticipants {
+		return nil, fmt.Errorf("на «%s» мест нет", e.Title)
+	}
+
+	reg.Status = models.RegistrationActive
+	reg.CreatedAt = availability.Now()
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO event_registrations (event_id, client_id, name, status, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(event_id, client_id) DO UPDATE SET
+			name = CASE WHEN excluded.name != '' THEN excluded.name ELSE event_registrations.name END,
+			status = excluded.status,
+			created_at = CASE WHEN event_registrations.status = 'registered'
+				THEN event_registrations.created_at ELSE excluded.created_at END
+	`, reg.EventID, reg.ClientID, reg.Name, reg.Status, reg.CreatedAt); err != nil {
+		return nil, err
+	}
+	if !already {
+		e.Registered++
+	}
+
+	if err := syncEventAmount(ctx, tx, e); err != nil {
+		return nil, err
+	}
+	return e, tx.Commit()
+}
+
+// CancelEventRegistration — клиент снимается с мероприятия.
+func (r *SQLiteContextRepo) CancelEventRegistration(ctx context.Context, eventID int64, clientID string) (*models.ClubEvent, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	e, err := getEvent(ctx, tx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, fmt.Errorf("мероприятие %d не найдено", eventID)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE event_registrations SET status = ? WHERE event_id = ? AND client_id = ? AND status = ?
+	`, models.RegistrationCancelled, eventID, clientID, models.RegistrationActive)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("регистрации на «%s» нет", e.Title)
+	}
+	e.Registered--
+
+	if err := syncEventAmount(ctx, tx, e); err != nil {
+		return nil, err
+	}
+	return e, tx.Commit()
+}
+
+// syncEventAmount — сумма блокирующей брони = взнос × участники, чтобы
+// баланс по ней показывал, сколько взносов ещё не собрано.
+func syncEventAmount(ctx context.Context, tx *sql.Tx, e *models.ClubEvent) error {
+	_, err := tx.ExecContext(ctx, `UPDATE bookings SET amount = ? WHERE booking_id = ?`,
+		e.EntryFee*float64(e.Registered), e.BookingID)
+	return err
+}
+
+// CancelEvent — отмена мероприятия: блокирующая бронь отменяется (станции
+// освобождаются), регистрации остаются для рассылки и возвратов.
+func (r *SQLiteContextRepo) CancelEvent(ctx context.Context, eventID int64, actor, note string) (*models.ClubEvent, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	e, err := getEvent(ctx, tx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, fmt.Errorf("мероприятие %d не найдено", eventID)
+	}
+	if e.Status == models.EventCancelled {
+		return nil, fmt.Errorf("мероприятие «%s» уже отменено", e.Title)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE club_events SET status = ? WHERE id = ?`, models.EventCancelled, eventID); err != nil {
+		return nil, err
+	}
+	e.Status = models.EventCancelled
+
+	b, err := getBooking(ctx, tx, e.BookingID)
+	if err != nil {
+		return nil, err
+	}
+	if b != nil && b.IsActive() {
+		if _, err := tx.ExecContext(ctx, `UPDATE bookings SET status = ? WHERE booking_id = ?`,
+			models.BookingCancelled, b.BookingID); err != nil {
+			return nil, err
+		}
+		if err := logBookingEvent(ctx, tx, models.BookingEvent{
+			BookingID: b.BookingID, FromStatus: b.Status, ToStatus: models.BookingCancelled, Actor: actor, Note: note,
+		}); err != nil {
+			return nil, err
+		}
+		if err := insertOutbox(ctx, tx, cancelledEvent(b, actor, note)); err != nil {
+			return nil, err
+		}
+	}
+	return e, tx.Commit()
+}
+
+// -----------------------------------------------------------------------------
+// REGISTRATIONS
+// -----------------------------------------------------------------------------
+
+// ListEventRegistrations — все регистрации мероприятия (и отменённые), по
+// порядку записи.
+func (r *SQLiteContextRepo) ListEventRegistrations(ctx context.Context, eventID int64) ([]models.EventRegistration, error) {
+	return r.queryRegistrations(ctx, `event_id = ?`, eventID)
+}
+
+// ListClientRegistrations — активные регистрации клиента.
+func (r *SQLiteContextRepo) ListClientRegistrations(ctx context.Context, clientID string) ([]models.EventRegistration, error) {
+	return r.queryRegistrations(ctx, `client_id = ? AND status = ?`, clientID, models.RegistrationActive)
+}
+
+func (r *SQLiteContextRepo) queryRegistrations(ctx context.Context, where string, args ...interface{}) ([]models.EventRegistration, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT event_id, client_id, name, status, paid, created_at
+		FROM event_registrations
+		WHERE `+where+`
+		ORDER BY created_at, client_id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.EventRegistration
+	for rows.Next() {
+		var reg models.EventRegistration
+		if err := rows.Scan(&reg.EventID, &reg.ClientID, &reg.Name, &reg.Status, &reg.Paid, &reg.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, reg)
+	}
+	return out, rows.Err()
+}
+
+// MarkRegistrationPaid — взнос участника получен (сами деньги — в журнале
+// платежей блокирующей брони).
+func (r *SQLiteContextRepo) MarkRegistrationPaid(ctx context.Context, eventID int64, clientID string) error {
+	res, err := r.DB.ExecContext(ctx, `
+		UPDATE event_registrations SET paid = 1 WHERE event_id = ? AND client_id = ? AND status = ?
+	`, eventID, clientID, models.RegistrationActive)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("клиент %s не зарегистрирован на мероприятие %d", clientID, eventID)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package data
+
+import (
+	"context"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// HANDOFFS (диалоги у живого оператора; активный — ended_at IS NULL)
+// -----------------------------------------------------------------------------
+// Заменяет client_profiles.admin_assigned_at из аналитической БД (InitDB):
+// одна метка не хранит ни причину, ни возврат диалога боту.
+
+const handoffColumns = `id, client_id, reason, started_at, last_operator_at, ended_at, ended_by`
+
+func queryHandoffs(ctx context.Context, q queryer, where string, args ...interface{}) ([]models.Handoff, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+handoffColumns+` FROM handoffs WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Handoff
+	for rows.Next() {
+		var h models.Handoff
+		if err := rows.Scan(&h.ID, &h.ClientID, &h.Reason, &h.StartedAt, &h.LastOperatorAt, &h.EndedAt, &h.EndedBy); err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
+
+// StartHandoff — передать диалог оператору. false — уже передан.
+func (r *SQLiteContextRepo) StartHandoff(ctx context.Context, clientID, reason string) (bool, error) {
+	now := availability.Now()
+	res, err := r.DB.ExecContext(ctx, `
+		INSERT OR IGNORE INTO handoffs (client_id, reason, started_at, last_operator_at)
+		VALUES (?, ?, ?, ?)
+	`, clientID, reason, now, now)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// GetActiveHandoff — текущая передача клиента оператору или nil.
+func (r *SQLiteContextRepo) GetActiveHandoff(ctx context.Context, clientID string) (*models.Handoff, error) {
+	list, err := queryHandoffs(ctx, r.DB, `client_id = ? AND ended_at IS NULL`, clientID)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// TouchHandoff — оператор ответил клиенту: отсчёт простоя заново.
+func (r *SQLiteContextRepo) TouchHandoff(ctx context.Context, clientID string) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE handoffs SET last_operator_at = ? WHERE client_id = ? AND ended_at IS NULL
+	`, availability.Now(), clientID)
+	return err
+}
+
+// EndHandoff — вернуть диалог боту. false — диалог и так у бота.
+func (r *SQLiteContextRepo) EndHandoff(ctx context.Context, clientID, endedBy string) (bool, error) {
+	res, err := r.DB.ExecContext(ctx, `
+		UPDATE handoffs SET ended_at = ?, ended_by = ? WHERE client_id = ? AND ended_at IS NULL
+	`, availability.Now(), endedBy, clientID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ListActiveHandoffs — диалоги, которые сейчас ведёт оператор, старые первыми.
+func (r *SQLiteContextRepo) ListActiveHandoffs(ctx context.Context) ([]models.Handoff, error) {
+	return queryHandoffs(ctx, r.DB, `ended_at IS NULL ORDER BY started_at`)
+}
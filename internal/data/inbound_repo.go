@@ -0,0 +1,104 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// INBOUND EVENTS (очередь входящих сообщений; done остаются для дедупликации)
+// -----------------------------------------------------------------------------
+
+const inboundColumns = `id, source, external_id, client_id, payload, status, attempts, last_error, next_attempt_at, received_at, processed_at`
+
+func queryInbound(ctx context.Context, q queryer, where string, args ...interface{}) ([]models.InboundEvent, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+inboundColumns+` FROM inbound_events WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.InboundEvent
+	for rows.Next() {
+		var e models.InboundEvent
+		if err := rows.Scan(&e.ID, &e.Source, &e.ExternalID, &e.ClientID, &e.Payload, &e.Status, &e.Attempts,
+			&e.LastError, &e.NextAttemptAt, &e.ReceivedAt, &e.ProcessedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// EnqueueInbound — сохранить событие до ответа провайдеру. false — событие
+// с таким (source, external_id) уже принято.
+func (r *SQLiteContextRepo) EnqueueInbound(ctx context.Context, e models.InboundEvent) (bool, error) {
+	now := availability.NowPrecise() // окно склейки — секунды
+	res, err := r.DB.ExecContext(ctx, `
+		INSERT OR IGNORE INTO inbound_events (source, external_id, client_id, payload, status, next_attempt_at, received_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, e.Source, e.ExternalID, e.ClientID, e.Payload, models.InboundPending, now, now)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ListInboundHeads — первое ждущее событие каждого клиента, если подошло
+// время его попытки. Следующие события клиента ждут, пока не обработано
+// (или не стало dead) предыдущее.
+func (r *SQLiteContextRepo) ListInboundHeads(ctx context.Context, now time.Time, limit int) ([]models.InboundEvent, error) {
+	return queryInbound(ctx, r.DB, `
+		id IN (SELECT MIN(id) FROM inbound_events WHERE status = ? GROUP BY client_id)
+			AND next_attempt_at <= ?
+		ORDER BY id LIMIT ?
+	`, models.InboundPending, now, limit)
+}
+
+// ListInboundPending — ждущие события клиента по порядку приёма.
+func (r *SQLiteContextRepo) ListInboundPending(ctx context.Context, clientID string, limit int) ([]models.InboundEvent, error) {
+	return queryInbound(ctx, r.DB, `client_id = ? AND status = ? ORDER BY id LIMIT ?`,
+		clientID, models.InboundPending, limit)
+}
+
+func (r *SQLiteContextRepo) MarkInboundProcessing(ctx context.Context, id int64) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE inbound_events SET status = ? WHERE id = ?`, models.InboundProcessing, id)
+	return err
+}
+
+func (r *SQLiteContextRepo) MarkInboundDone(ctx context.Context, id int64) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE inbound_events SET status = ?, attempts = attempts + 1, last_error = '', processed_at = ?
+		WHERE id = ?
+	`, models.InboundDone, availability.Now(), id)
+	return err
+}
+
+// MarkInboundFailed — обработка не удалась: повтор в retryAt или dead.
+func (r *SQLiteContextRepo) MarkInboundFailed(ctx context.Context, id int64, lastError string, retryAt time.Time, dead bool) error {
+	status := models.InboundPending
+	if dead {
+		status = models.InboundDead
+	}
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE inbound_events SET status = ?, attempts = attempts + 1, last_error = ?, next_attempt_at = ?
+		WHERE id = ?
+	`, status, lastError, retryAt, id)
+	return err
+}
+
+// ResetInboundProcessing — при старте: события, чья обработка прервалась
+// падением процесса, снова ждут обработки.
+func (r *SQLiteContextRepo) ResetInboundProcessing(ctx context.Context) (int, error) {
+	res, err := r.DB.ExecContext(ctx, `UPDATE inbound_events SET status = ? WHERE status = ?`,
+		models.InboundPending, models.InboundProcessing)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
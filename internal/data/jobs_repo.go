@@ -0,0 +1,186 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// SCHEDULED JOBS
+// -----------------------------------------------------------------------------
+
+// run_at — плановое время, оно не сдвигается при повторах; locked_until —
+// у running аренда исполнителя, у pending — время следующей попытки.
+const jobColumns = `id, job_key, kind, ref, payload, run_at, status, attempts, max_attempts, last_error, created_at, updated_at`
+
+func scanJob(row rowScanner) (*models.ScheduledJob, error) {
+	var j models.ScheduledJob
+	if err := row.Scan(&j.ID, &j.Key, &j.Kind, &j.Ref, &j.Payload, &j.RunAt, &j.Status,
+		&j.Attempts, &j.MaxAttempts, &j.LastError, &j.CreatedAt, &j.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func queryJobs(ctx context.Context, q queryer, where string, args ...interface{}) ([]models.ScheduledJob, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+jobColumns+` FROM scheduled_jobs WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.ScheduledJob
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *j)
+	}
+	return out, rows.Err()
+}
+
+// ScheduleJob — ставит задание по ключу j.Key. Существующее задание
+// переносится, если время изменилось или оно было отменено; выполненное
+// в то же время заново не планируется. j получает ID и итоговый статус.
+func (r *SQLiteContextRepo) ScheduleJob(ctx context.Context, j *models.ScheduledJob) error {
+	if j.Key == "" {
+		j.Key = models.JobKey(j.Kind, j.Ref)
+	}
+	now := availability.Now()
+
+	if _, err := r.DB.ExecContext(ctx, `
+		INSERT INTO scheduled_jobs (job_key, kind, ref, payload, run_at, status, max_attempts, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(job_key) DO UPDATE SET
+			payload = excluded.payload, run_at = excluded.run_at, max_attempts = excluded.max_attempts,
+			status = excluded.status, attempts = 0, last_error = '', locked_until = NULL,
+			updated_at = excluded.updated_at
+		WHERE scheduled_jobs.status = 'cancelled'
+		   OR (scheduled_jobs.status != 'running' AND scheduled_jobs.run_at != excluded.run_at)
+		   OR (scheduled_jobs.status = 'pending' AND scheduled_jobs.payload != excluded.payload)
+	`, j.Key, j.Kind, j.Ref, j.Payload, j.RunAt, models.JobPending, j.MaxAttempts, now, now); err != nil {
+		return err
+	}
+
+	saved, err := scanJob(r.DB.QueryRowContext(ctx,
+		`SELECT `+jobColumns+` FROM scheduled_jobs WHERE job_key = ?`, j.Key))
+	if err != nil {
+		return err
+	}
+	*j = *saved
+	return nil
+}
+
+// CancelJobs — отменяет ждущие задания по ref (только видов kinds, если
+// заданы). Выполняющееся задание доработает.
+func (r *SQLiteContextRepo) CancelJobs(ctx context.Context, ref string, kinds ...string) (int, error) {
+	where := `ref = ? AND status = ?`
+	args := []interface{}{models.JobCancelled, availability.Now(), ref, models.JobPending}
+	if len(kinds) > 0 {
+		where += ` AND kind IN (?` + strings.Repeat(`, ?`, len(kinds)-1) + `)`
+		for _, k := range kinds {
+			args = append(args, k)
+		}
+	}
+
+	res, err := r.DB.ExecContext(ctx,
+		`UPDATE scheduled_jobs SET status = ?, updated_at = ? WHERE `+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// CancelJob — отменяет одно ждущее задание; false — его нет или оно уже не ждёт.
+func (r *SQLiteContextRepo) CancelJob(ctx context.Context, id int64) (bool, error) {
+	res, err := r.DB.ExecContext(ctx, `
+		UPDATE scheduled_jobs SET status = ?, updated_at = ? WHERE id = ? AND status = ?
+	`, models.JobCancelled, availability.Now(), id, models.JobPending)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ListJobs — задания по фильтру, ближайшие первыми (по умолчанию 50).
+func (r *SQLiteContextRepo) ListJobs(ctx context.Context, f models.JobFilter) ([]models.ScheduledJob, error) {
+	where := []string{"1 = 1"}
+	var args []interface{}
+	if f.Ref != "" {
+		where, args = append(where, "ref = ?"), append(args, f.Ref)
+	}
+	if f.Kind != "" {
+		where, args = append(where, "kind = ?"), append(args, f.Kind)
+	}
+	if f.Status != "" {
+		where, args = append(where, "status = ?"), append(args, f.Status)
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	args = append(args, limit)
+
+	return queryJobs(ctx, r.DB, strings.Join(where, " AND ")+` ORDER BY run_at, id LIMIT ?`, args...)
+}
+
+// ClaimDueJobs — забирает до limit созревших заданий в работу на lease.
+// Задание, чей исполнитель упал (аренда истекла), забирается повторно —
+// так выполнение гарантируется хотя бы один раз. Attempts растёт при захвате.
+func (r *SQLiteContextRepo) ClaimDueJobs(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.ScheduledJob, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Упало на последней попытке — больше не берём
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE scheduled_jobs SET status = ?, last_error = 'исполнитель не завершил задание', updated_at = ?
+		WHERE status = ? AND locked_until <= ? AND attempts >= max_attempts
+	`, models.JobFailed, now, models.JobRunning, now); err != nil {
+		return nil, err
+	}
+
+	jobs, err := queryJobs(ctx, tx, `
+		(status = ? AND run_at <= ? AND (locked_until IS NULL OR locked_until <= ?))
+		OR (status = ? AND locked_until <= ?)
+		ORDER BY run_at, id LIMIT ?
+	`, models.JobPending, now, now, models.JobRunning, now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range jobs {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE scheduled_jobs SET status = ?, attempts = attempts + 1, locked_until = ?, updated_at = ?
+			WHERE id = ?
+		`, models.JobRunning, now.Add(lease), now, jobs[i].ID); err != nil {
+			return nil, err
+		}
+		jobs[i].Status = models.JobRunning
+		jobs[i].Attempts++
+	}
+	return jobs, tx.Commit()
+}
+
+// FinishJob — итог попытки: done, failed или pending с повтором в retryAt.
+func (r *SQLiteContextRepo) FinishJob(ctx context.Context, id int64, status, lastError string, retryAt time.Time) error {
+	var lockedUntil sql.NullTime
+	if status == models.JobPending {
+		lockedUntil = sql.NullTime{Time: retryAt, Valid: true}
+	}
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE scheduled_jobs SET status = ?, last_error = ?, locked_until = ?, updated_at = ?
+		WHERE id = ? AND status = ?
+	`, status, lastError, lockedUntil, availability.Now(), id, models.JobRunning)
+	return err
+}
@@ -0,0 +1,184 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// LAP TIMES (лидерборд)
+// -----------------------------------------------------------------------------
+
+// driverKey — ники сравниваются без регистра и крайних пробелов.
+func driverKey(driver string) string {
+	return strings.ToLower(strings.TrimSpace(driver))
+}
+
+// ImportLaps — сохраняет круги из файла результатов. Повторный импорт того же
+// файла ничего не дублирует; ник, привязанный к клиенту, получает его client_id.
+func (r *SQLiteContextRepo) ImportLaps(ctx context.Context, laps []models.LapRecord) (models.LapImportResult, error) {
+	res := models.LapImportResult{Parsed: len(laps)}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return res, err
+	}
+	defer tx.Rollback()
+
+	links := map[string]string{}
+	now := availability.Now()
+	for _, l := range laps {
+		key := driverKey(l.Driver)
+		clientID := l.ClientID
+		if clientID == "" {
+			linked, ok := links[key]
+			if !ok {
+				err := tx.QueryRowContext(ctx, `SELECT client_id FROM lap_drivers WHERE driver_key = ?`, key).Scan(&linked)
+				if err != nil && !errors.Is(err, sql.ErrNoRows) {
+					return res, err
+				}
+				links[key] = linked
+			}
+			clientID = linked
+		}
+
+		out, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO lap_times (client_id, driver, driver_key, game, track, car, lap_ms, set_at, source, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, clientID, l.Driver, key, l.Game, l.Track, l.Car, l.LapMs, l.SetAt, l.Source, now)
+		if err != nil {
+			return res, err
+		}
+		if n, _ := out.RowsAffected(); n == 0 {
+			continue
+		}
+		res.Inserted++
+		if clientID != "" {
+			res.Linked++
+		} else {
+			res.Unassigned++
+		}
+	}
+	return res, tx.Commit()
+}
+
+// LinkDriver — привязывает игровой ник к клиенту и проставляет client_id
+// уже загруженным кругам. Ник чужого клиента перепривязывается только с
+// force (решение администратора). Возвращает число привязанных кругов.
+func (r *SQLiteContextRepo) LinkDriver(ctx context.Context, driver, clientID string, force bool) (int, error) {
+	key := driverKey(driver)
+	if key == "" || clientID == "" {
+		return 0, fmt.Errorf("нужны ник и клиент")
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var current string
+	err = tx.QueryRowContext(ctx, `SELECT client_id FROM lap_drivers WHERE driver_key = ?`, key).Scan(&current)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	if current != "" && current != clientID && !force {
+		return 0, fmt.Errorf("ник %s уже привязан к другому клиенту — обратитесь к администратору", strings.TrimSpace(driver))
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO lap_drivers (driver_key, driver, client_id) VALUES (?, ?, ?)
+		ON CONFLICT(driver_key) DO UPDATE SET driver = excluded.driver, client_id = excluded.client_id
+	`, key, strings.TrimSpace(driver), clientID); err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE lap_times SET client_id = ? WHERE driver_key = ? AND (client_id = '' OR client_id = ?)
+	`, clientID, key, current)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), tx.Commit()
+}
+
+// lapStandingsSQL — лучший круг каждого пилота на трассе (пилот = клиент,
+// а если ник не привязан — ник) и место в таблице трассы. Голые колонки при
+// MIN() в SQLite берутся из строки с минимумом — это ник, машина и дата
+// именно лучшего круга.
+const lapStandingsSQL = `
+	WITH best AS (
+		SELECT game, track, car, driver, set_at, source, client_id,
+			CASE WHEN client_id != '' THEN client_id ELSE 'd:' || driver_key END AS who,
+			MIN(lap_ms) AS lap_ms, COUNT(*) AS laps
+		FROM lap_times
+		WHERE set_at >= ? AND set_at < ? AND LOWER(track) LIKE ?
+		GROUP BY game, track, who
+	)
+	SELECT client_id, driver, game, track, car, lap_ms, set_at, source, laps,
+		RANK() OVER (PARTITION BY game, track ORDER BY lap_ms) AS rnk,
+		COUNT(*) OVER (PARTITION BY game, track) AS drivers
+	FROM best`
+
+func (r *SQLiteContextRepo) queryStandings(ctx context.Context, track string, from, to time.Time, where string, args ...interface{}) ([]models.LapStanding, error) {
+	query := `SELECT * FROM (` + lapStandingsSQL + `) WHERE ` + where + ` ORDER BY game, track, rnk, set_at`
+	args = append([]interface{}{from, to, trackPattern(track)}, args...)
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.LapStanding
+	for rows.Next() {
+		var s models.LapStanding
+		if err := rows.Scan(&s.ClientID, &s.Driver, &s.Game, &s.Track, &s.Car, &s.LapMs, &s.SetAt, &s.Source,
+			&s.Laps, &s.Rank, &s.Drivers); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// trackPattern — "spa" найдёт "ks_spa", "Spa Francorchamps" и т.п.
+func trackPattern(track string) string {
+	words := strings.Fields(strings.ToLower(track))
+	return "%" + strings.Join(words, "%") + "%"
+}
+
+// ClientBestLaps — лучшие круги клиента по трассам (track — подстрока
+// названия, пусто — все) с местом в общей таблице трассы за всё время.
+func (r *SQLiteContextRepo) ClientBestLaps(ctx context.Context, clientID, track string) ([]models.LapStanding, error) {
+	return r.queryStandings(ctx, track, time.Time{}, farFuture, `client_id = ?`, clientID)
+}
+
+// TopLaps — первые limit мест на каждой трассе по кругам за [from, to).
+func (r *SQLiteContextRepo) TopLaps(ctx context.Context, track string, from, to time.Time, limit int) ([]models.LapStanding, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	return r.queryStandings(ctx, track, from, to, `rnk <= ?`, limit)
+}
+
+// LapActivity — вовлечённость за период: кругов, пилотов, из них клиентов.
+func (r *SQLiteContextRepo) LapActivity(ctx context.Context, from, to time.Time) (laps, drivers, clients int, err error) {
+	err = r.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(DISTINCT driver_key), COUNT(DISTINCT NULLIF(client_id, ''))
+		FROM lap_times
+		WHERE set_at >= ? AND set_at < ?
+	`, from, to).Scan(&laps, &drivers, &clients)
+	return laps, drivers, clients, err
+}
+
+// farFuture — верхняя граница "за всё время".
+var farFuture = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
@@ -0,0 +1,174 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/events"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// PAYMENT LEDGER
+// -----------------------------------------------------------------------------
+
+// AddLedgerEntry — записывает движение денег по брони и возвращает новый
+// баланс. Возврат передаётся положительной суммой и хранится со знаком
+// минус; вернуть больше полученного нельзя.
+func (r *SQLiteContextRepo) AddLedgerEntry(ctx context.Context, e *models.LedgerEntry) (*models.BookingBalance, error) {
+	if err := normalizeLedgerEntry(e); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	bal, err := bookingBalance(ctx, tx, e.BookingID)
+	if err != nil {
+		return nil, err
+	}
+	if bal == nil {
+		return nil, fmt.Errorf("бронь %s не найдена", e.BookingID)
+	}
+	if e.Kind == models.LedgerRefund && -e.Amount > bal.Paid {
+		return nil, fmt.Errorf("возврат %.0f больше полученного по брони (%.0f)", -e.Amount, bal.Paid)
+	}
+
+	if err := insertLedgerEntry(ctx, tx, e); err != nil {
+		return nil, err
+	}
+
+	if bal, err = bookingBalance(ctx, tx, e.BookingID); err != nil {
+		return nil, err
+	}
+	return bal, tx.Commit()
+}
+
+// insertLedgerEntry — запись журнала внутри чужой транзакции (проверки — на
+// вызывающем).
+func insertLedgerEntry(ctx context.Context, tx *sql.Tx, e *models.LedgerEntry) error {
+	// Время — локальное, как у броней: отчёты режут по тем же датам
+	e.CreatedAt = availability.Now()
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO booking_payments (booking_id, kind, method, amount, actor, note, invoice_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.BookingID, e.Kind, e.Method, e.Amount, e.Actor, e.Note, e.InvoiceID, e.CreatedAt)
+	if err != nil {
+		return err
+	}
+	e.ID, _ = res.LastInsertId()
+
+	if e.Kind != models.LedgerCharge && e.Kind != models.LedgerDeposit {
+		return nil
+	}
+	var clientID string
+	if err := tx.QueryRowContext(ctx,
+		`SELECT client_id FROM bookings WHERE booking_id = ?`, e.BookingID,
+	).Scan(&clientID); err != nil {
+		return err
+	}
+	if e.Method == models.MethodPrepaid {
+		// деньги за сертификат получены при продаже — это не поступление
+		return insertOutbox(ctx, tx, events.PrepaidRedeemed{
+			BookingID: e.BookingID, ClientID: clientID, Amount: e.Amount, Actor: e.Actor, Note: e.Note,
+		})
+	}
+	return insertOutbox(ctx, tx, events.PaymentReceived{
+		BookingID: e.BookingID, ClientID: clientID, Kind: e.Kind, Method: e.Method, Amount: e.Amount, Actor: e.Actor,
+	})
+}
+
+func normalizeLedgerEntry(e *models.LedgerEntry) error {
+	switch e.Method {
+	case models.MethodKaspiQR, models.MethodCash, models.MethodCard, models.MethodOnline, models.MethodPrepaid:
+	default:
+		return fmt.Errorf("способ оплаты: kaspi_qr, cash, card или online")
+	}
+
+	switch e.Kind {
+	case models.LedgerCharge, models.LedgerDeposit:
+		if e.Amount <= 0 {
+			return fmt.Errorf("сумма должна быть больше нуля")
+		}
+	case models.LedgerRefund:
+		if e.Amount == 0 {
+			return fmt.Errorf("сумма должна быть больше нуля")
+		}
+		if e.Amount > 0 {
+			e.Amount = -e.Amount
+		}
+	case models.LedgerAdjustment:
+		if e.Amount == 0 {
+			return fmt.Errorf("корректировка на ноль")
+		}
+		if e.Note == "" {
+			return fmt.Errorf("для корректировки нужен комментарий")
+		}
+	default:
+		return fmt.Errorf("вид записи: charge, deposit, refund или adjustment")
+	}
+	return nil
+}
+
+// GetLedger — журнал оплат брони по времени.
+func (r *SQLiteContextRepo) GetLedger(ctx context.Context, bookingID string) ([]models.LedgerEntry, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT id, booking_id, kind, method, amount, actor, note, invoice_id, created_at
+		FROM booking_payments
+		WHERE booking_id = ?
+		ORDER BY id
+	`, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.LedgerEntry
+	for rows.Next() {
+		var e models.LedgerEntry
+		if err := rows.Scan(&e.ID, &e.BookingID, &e.Kind, &e.Method, &e.Amount,
+			&e.Actor, &e.Note, &e.InvoiceID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// GetBookingBalance — баланс брони; nil, если брони нет.
+func (r *SQLiteContextRepo) GetBookingBalance(ctx context.Context, bookingID string) (*models.BookingBalance, error) {
+	return bookingBalance(ctx, r.DB, bookingID)
+}
+
+func bookingBalance(ctx context.Context, q rowQueryer, bookingID string) (*models.BookingBalance, error) {
+	bal := models.BookingBalance{BookingID: bookingID}
+	var status string
+
+	err := q.QueryRowContext(ctx, `
+		SELECT b.amount, b.status,
+		       COALESCE((SELECT SUM(p.amount) FROM booking_payments p WHERE p.booking_id = b.booking_id), 0),
+		       COALESCE((SELECT -SUM(p.amount) FROM booking_payments p
+		                 WHERE p.booking_id = b.booking_id AND p.kind = ?), 0)
+		FROM bookings b
+		WHERE b.booking_id = ?
+	`, models.LedgerRefund, bookingID).Scan(&bal.Total, &status, &bal.Paid, &bal.Refunded)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Отменённая бронь и неявка ничего не должны: удержанный депозит
+	// остаётся в Paid
+	if (&models.Booking{Status: status}).IsActive() {
+		bal.Due = bal.Total - bal.Paid
+	}
+	return &bal, nil
+}
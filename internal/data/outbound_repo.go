@@ -0,0 +1,197 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// OUTBOUND MESSAGES (исходящие WhatsApp и статусы доставки от Wazzup)
+// -----------------------------------------------------------------------------
+
+const outboundColumns = `id, crm_message_id, provider_id, client_id, channel_id, message_text, content_uri, status, error, attempts, created_at, updated_at`
+
+// outboundFrom — из каких статусов допустим переход: статусы Wazzup могут
+// прийти не по порядку, «доставлено» не должно затереть «прочитано».
+var outboundFrom = map[string][]interface{}{
+	models.OutboundSent:      {models.OutboundPending, models.OutboundFailed},
+	models.OutboundDelivered: {models.OutboundPending, models.OutboundFailed, models.OutboundSent, models.OutboundError},
+	models.OutboundRead:      {models.OutboundPending, models.OutboundFailed, models.OutboundSent, models.OutboundError, models.OutboundDelivered},
+	models.OutboundError:     {models.OutboundPending, models.OutboundSent},
+}
+
+func queryOutbound(ctx context.Context, q queryer, where string, args ...interface{}) ([]models.OutboundMessage, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+outboundColumns+` FROM outbound_messages WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.OutboundMessage
+	for rows.Next() {
+		var m models.OutboundMessage
+		if err := rows.Scan(&m.ID, &m.CrmMessageID, &m.ProviderID, &m.ClientID, &m.ChannelID, &m.Text, &m.ContentURI,
+			&m.Status, &m.Error, &m.Attempts, &m.CreatedAt, &m.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// SaveOutboundMessage — сообщение перед первой попыткой отправки (pending).
+func (r *SQLiteContextRepo) SaveOutboundMessage(ctx context.Context, m *models.OutboundMessage) (int64, error) {
+	now := availability.Now()
+	res, err := r.DB.ExecContext(ctx, `
+		INSERT INTO outbound_messages (crm_message_id, client_id, channel_id, message_text, content_uri, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, m.CrmMessageID, m.ClientID, m.ChannelID, m.Text, m.ContentURI, models.OutboundPending, now, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// MarkOutboundSent — Wazzup принял сообщение и вернул свой messageId.
+// Статус не понижается, если вебхук statuses успел раньше ответа: такие
+// статусы отложены (UpdateOutboundStatus) и применяются здесь же.
+func (r *SQLiteContextRepo) MarkOutboundSent(ctx context.Context, id int64, providerID string, attempts int) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE outbound_messages
+		SET provider_id = ?, attempts = ?, error = '', updated_at = ?,
+			status = CASE WHEN status IN (?, ?) THEN ? ELSE status END
+		WHERE id = ?
+	`, providerID, attempts, availability.Now(), models.OutboundPending, models.OutboundFailed, models.OutboundSent, id); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT status, error FROM outbound_early_statuses WHERE provider_id = ? ORDER BY id
+	`, providerID)
+	if err != nil {
+		return err
+	}
+	var early [][2]string
+	for rows.Next() {
+		var st [2]string
+		if err := rows.Scan(&st[0], &st[1]); err != nil {
+			rows.Close()
+			return err
+		}
+		early = append(early, st)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, st := range early {
+		if _, err := applyOutboundStatus(ctx, tx, providerID, st[0], st[1]); err != nil {
+			return err
+		}
+	}
+	if len(early) > 0 {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM outbound_early_statuses WHERE provider_id = ?`, providerID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// MarkOutboundFailed — Wazzup не принял сообщение после всех попыток.
+func (r *SQLiteContextRepo) MarkOutboundFailed(ctx context.Context, id int64, lastError string, attempts int) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE outbound_messages SET status = ?, error = ?, attempts = ?, updated_at = ?
+		WHERE id = ?
+	`, models.OutboundFailed, lastError, attempts, availability.Now(), id)
+	return err
+}
+
+// earlyStatusTTL — сколько хранится статус, пришедший раньше ответа на
+// отправку. Статусы чужих сообщений (менеджер писал из интерфейса Wazzup)
+// тоже попадают в отложенные и удаляются по сроку.
+const earlyStatusTTL = 24 * time.Hour
+
+// UpdateOutboundStatus — статус из вебхука Wazzup по его messageId. false —
+// сообщение с таким messageId ещё не известно или статус устарел.
+// Неизвестный messageId — вебхук мог обогнать ответ Wazzup на отправку:
+// статус откладывается до MarkOutboundSent, а не теряется.
+func (r *SQLiteContextRepo) UpdateOutboundStatus(ctx context.Context, providerID, status, errText string) (bool, error) {
+	if _, ok := outboundFrom[status]; !ok || providerID == "" {
+		return false, nil
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	updated, err := applyOutboundStatus(ctx, tx, providerID, status, errText)
+	if err != nil {
+		return false, err
+	}
+	if !updated {
+		var known bool
+		if err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS(SELECT 1 FROM outbound_messages WHERE provider_id = ?)
+		`, providerID).Scan(&known); err != nil {
+			return false, err
+		}
+		if !known {
+			now := availability.Now()
+			if _, err := tx.ExecContext(ctx, `DELETE FROM outbound_early_statuses WHERE received_at < ?`, now.Add(-earlyStatusTTL)); err != nil {
+				return false, err
+			}
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO outbound_early_statuses (provider_id, status, error, received_at) VALUES (?, ?, ?, ?)
+			`, providerID, status, errText, now); err != nil {
+				return false, err
+			}
+		}
+	}
+	return updated, tx.Commit()
+}
+
+// applyOutboundStatus — переход статуса сообщения по outboundFrom.
+func applyOutboundStatus(ctx context.Context, tx *sql.Tx, providerID, status, errText string) (bool, error) {
+	from, ok := outboundFrom[status]
+	if !ok {
+		return false, nil
+	}
+	args := []interface{}{status, errText, availability.Now(), providerID}
+	args = append(args, from...)
+	res, err := tx.ExecContext(ctx, `
+		UPDATE outbound_messages SET status = ?, error = ?, updated_at = ?
+		WHERE provider_id = ? AND status IN (?`+strings.Repeat(`, ?`, len(from)-1)+`)
+	`, args...)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ListUndeliveredMessages — сообщения с since, которые не дошли до клиента:
+// ошибка или отказ Wazzup, а также «отправлено», но без подтверждения
+// доставки до staleBefore. Новые первыми.
+func (r *SQLiteContextRepo) ListUndeliveredMessages(ctx context.Context, since, staleBefore time.Time, limit int) ([]models.OutboundMessage, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	return queryOutbound(ctx, r.DB, `
+		created_at >= ?
+		AND (status IN (?, ?) OR (status IN (?, ?) AND created_at < ?))
+		ORDER BY id DESC LIMIT ?
+	`, since, models.OutboundError, models.OutboundFailed, models.OutboundPending, models.OutboundSent, staleBefore, limit)
+}
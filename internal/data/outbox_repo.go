@@ -0,0 +1,75 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/events"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// OUTBOX (события пишутся в транзакции изменения, доставляет релей)
+// -----------------------------------------------------------------------------
+
+// insertOutbox — событие в outbox внутри транзакции изменения: упал процесс
+// после коммита — событие всё равно будет доставлено.
+func insertOutbox(ctx context.Context, tx *sql.Tx, e events.Event) error {
+	payload, err := events.Encode(e)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO outbox_events (name, payload, status, created_at) VALUES (?, ?, ?, ?)
+	`, e.Name(), payload, models.OutboxPending, availability.Now())
+	return err
+}
+
+// ListOutbox — недоставленные события по порядку записи, у которых
+// подошло время (следующей) попытки.
+func (r *SQLiteContextRepo) ListOutbox(ctx context.Context, now time.Time, limit int) ([]models.OutboxEvent, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT id, name, payload, status, attempts, last_error, created_at
+		FROM outbox_events
+		WHERE status = ? AND (retry_at IS NULL OR retry_at <= ?)
+		ORDER BY id
+		LIMIT ?
+	`, models.OutboxPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.OutboxEvent
+	for rows.Next() {
+		var e models.OutboxEvent
+		if err := rows.Scan(&e.ID, &e.Name, &e.Payload, &e.Status, &e.Attempts, &e.LastError, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (r *SQLiteContextRepo) MarkOutboxDelivered(ctx context.Context, id int64) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE outbox_events SET status = ?, attempts = attempts + 1, last_error = '', delivered_at = ?
+		WHERE id = ?
+	`, models.OutboxDelivered, availability.Now(), id)
+	return err
+}
+
+// MarkOutboxFailed — попытка не удалась: повтор в retryAt или dead.
+func (r *SQLiteContextRepo) MarkOutboxFailed(ctx context.Context, id int64, lastError string, retryAt time.Time, dead bool) error {
+	status := models.OutboxPending
+	if dead {
+		status = models.OutboxDead
+	}
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE outbox_events SET status = ?, attempts = attempts + 1, last_error = ?, retry_at = ?
+		WHERE id = ?
+	`, status, lastError, retryAt, id)
+	return err
+}
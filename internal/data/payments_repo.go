@@ -0,0 +1,124 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// INVOICES
+// -----------------------------------------------------------------------------
+
+const invoiceColumns = `invoice_id, provider, booking_id, amount, status, payment_url, created_at, paid_at`
+
+func scanInvoice(row rowScanner) (*models.Invoice, error) {
+	var inv models.Invoice
+	var paidAt sql.NullTime
+	err := row.Scan(&inv.InvoiceID, &inv.Provider, &inv.BookingID, &inv.Amount, &inv.Status,
+		&inv.PaymentURL, &inv.CreatedAt, &paidAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	inv.PaidAt = paidAt.Time
+	return &inv, nil
+}
+
+// SaveInvoice — новый счёт провайдера.
+func (r *SQLiteContextRepo) SaveInvoice(ctx context.Context, inv *models.Invoice) error {
+	if inv.CreatedAt.IsZero() {
+		inv.CreatedAt = time.Now()
+	}
+	_, err := r.DB.ExecContext(ctx, `
+		INSERT INTO invoices (invoice_id, provider, booking_id, amount, status, payment_url, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, inv.InvoiceID, inv.Provider, inv.BookingID, inv.Amount, inv.Status, inv.PaymentURL, inv.CreatedAt)
+	return err
+}
+
+// GetInvoice — счёт по ID провайдера; nil, если не найден.
+func (r *SQLiteContextRepo) GetInvoice(ctx context.Context, invoiceID string) (*models.Invoice, error) {
+	return scanInvoice(r.DB.QueryRowContext(ctx,
+		`SELECT `+invoiceColumns+` FROM invoices WHERE invoice_id = ?`, invoiceID))
+}
+
+// GetPendingInvoice — последний неоплаченный счёт брони; nil, если нет.
+func (r *SQLiteContextRepo) GetPendingInvoice(ctx context.Context, bookingID string) (*models.Invoice, error) {
+	return r.lastInvoice(ctx, bookingID, models.InvoicePending)
+}
+
+// GetPaidInvoice — оплаченный счёт брони (для возврата); nil, если нет.
+func (r *SQLiteContextRepo) GetPaidInvoice(ctx context.Context, bookingID string) (*models.Invoice, error) {
+	return r.lastInvoice(ctx, bookingID, models.InvoicePaid)
+}
+
+func (r *SQLiteContextRepo) lastInvoice(ctx context.Context, bookingID, status string) (*models.Invoice, error) {
+	return scanInvoice(r.DB.QueryRowContext(ctx, `
+		SELECT `+invoiceColumns+`
+		FROM invoices
+		WHERE booking_id = ? AND status = ?
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, bookingID, status))
+}
+
+// SetInvoiceStatus — перевести счёт из from в status. false — счёт уже
+// не в from (повтор уведомления или гонка с другим обработчиком).
+func (r *SQLiteContextRepo) SetInvoiceStatus(ctx context.Context, invoiceID, from, status string) (bool, error) {
+	res, err := r.DB.ExecContext(ctx, `
+		UPDATE invoices SET status = ? WHERE invoice_id = ? AND status = ?
+	`, status, invoiceID, from)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// PayInvoice — счёт → paid и деньги в журнал одной транзакцией; возвращает
+// новый баланс брони. false — счёт уже оплачен или возвращён: запись
+// в журнале была сделана тем, кто перевёл его первым.
+func (r *SQLiteContextRepo) PayInvoice(ctx context.Context, invoiceID string, e *models.LedgerEntry) (*models.BookingBalance, bool, error) {
+	if err := normalizeLedgerEntry(e); err != nil {
+		return nil, false, err
+	}
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	// failed → paid: клиент оплатил со второй попытки по той же ссылке
+	res, err := tx.ExecContext(ctx, `
+		UPDATE invoices SET status = ?, paid_at = ? WHERE invoice_id = ? AND status IN (?, ?)
+	`, models.InvoicePaid, availability.Now(), invoiceID, models.InvoicePending, models.InvoiceFailed)
+	if err != nil {
+		return nil, false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, false, nil
+	}
+
+	if bal, err := bookingBalance(ctx, tx, e.BookingID); err != nil {
+		return nil, false, err
+	} else if bal == nil {
+		return nil, false, fmt.Errorf("бронь %s не найдена", e.BookingID)
+	}
+	if err := insertLedgerEntry(ctx, tx, e); err != nil {
+		return nil, false, err
+	}
+	bal, err := bookingBalance(ctx, tx, e.BookingID)
+	if err != nil {
+		return nil, false, err
+	}
+	return bal, true, tx.Commit()
+}
@@ -0,0 +1,352 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// PREPAID (сертификаты и пакеты часов)
+// -----------------------------------------------------------------------------
+
+const prepaidColumns = `id, code, kind, client_id, initial, balance, price, method, expires_at, status, created_by, created_at`
+
+func scanPrepaid(row rowScanner) (*models.PrepaidAccount, error) {
+	var p models.PrepaidAccount
+	var expires sql.NullTime
+	if err := row.Scan(&p.ID, &p.Code, &p.Kind, &p.ClientID, &p.Initial, &p.Balance, &p.Price, &p.Method,
+		&expires, &p.Status, &p.CreatedBy, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	p.ExpiresAt = expires.Time
+	return &p, nil
+}
+
+// Без 0/O и 1/I: код диктуют по телефону и переписывают с открытки.
+const prepaidAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func newPrepaidCode(kind string) (string, error) {
+	prefix := "GC-"
+	if kind == models.PrepaidHours {
+		prefix = "HP-"
+	}
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		buf[i] = prepaidAlphabet[int(b)%len(prepaidAlphabet)]
+	}
+	return prefix + string(buf), nil
+}
+
+// IssuePrepaid — выпуск сертификата или продажа пакета часов. Код
+// генерируется, если не задан; Balance = Initial.
+func (r *SQLiteContextRepo) IssuePrepaid(ctx context.Context, p *models.PrepaidAccount) error {
+	switch p.Kind {
+	case models.PrepaidCertificate:
+	case models.PrepaidHours:
+		if p.ClientID == "" {
+			return fmt.Errorf("пакет часов продаётся конкретному клиенту")
+		}
+	default:
+		return fmt.Errorf("вид: %s или %s", models.PrepaidCertificate, models.PrepaidHours)
+	}
+	if p.Initial <= 0 || p.Price < 0 {
+		return fmt.Errorf("номинал должен быть больше нуля")
+	}
+
+	p.Balance = p.Initial
+	p.Status = models.PrepaidActive
+	p.CreatedAt = availability.Now()
+	custom := p.Code != ""
+	p.Code = models.NormalizePromoCode(p.Code)
+
+	for attempt := 0; ; attempt++ {
+		if !custom {
+			code, err := newPrepaidCode(p.Kind)
+			if err != nil {
+				return err
+			}
+			p.Code = code
+		}
+		res, err := r.DB.ExecContext(ctx, `
+			INSERT INTO prepaid_accounts (code, kind, client_id, initial, balance, price, method, expires_at, status, created_by, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, p.Code, p.Kind, p.ClientID, p.Initial, p.Balance, p.Price, p.Method, nullTime(p.ExpiresAt),
+			p.Status, p.CreatedBy, p.CreatedAt)
+		if err != nil && strings.Contains(err.Error(), "UNIQUE") {
+			if custom || attempt >= 3 {
+				return fmt.Errorf("код %s уже занят", p.Code)
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		p.ID, _ = res.LastInsertId()
+		return nil
+	}
+}
+
+// GetPrepaid — сертификат/пакет по коду; nil, если не найден.
+func (r *SQLiteContextRepo) GetPrepaid(ctx context.Context, code string) (*models.PrepaidAccount, error) {
+	return getPrepaid(ctx, r.DB, code)
+}
+
+func getPrepaid(ctx context.Context, q rowQueryer, code string) (*models.PrepaidAccount, error) {
+	p, err := scanPrepaid(q.QueryRowContext(ctx,
+		`SELECT `+prepaidColumns+` FROM prepaid_accounts WHERE code = ?`, models.NormalizePromoCode(code)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return p, err
+}
+
+// ListClientPrepaid — действующие сертификаты и пакеты клиента с остатком
+// (истёкшие тоже: клиенту стоит сказать, что срок вышел). Первым — тот,
+// что сгорает раньше.
+func (r *SQLiteContextRepo) ListClientPrepaid(ctx context.Context, clientID string) ([]models.PrepaidAccount, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT `+prepaidColumns+`
+		FROM prepaid_accounts
+		WHERE client_id = ? AND status = ? AND balance > 0
+		ORDER BY expires_at IS NULL, expires_at, id
+	`, clientID, models.PrepaidActive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.PrepaidAccount
+	for rows.Next() {
+		p, err := scanPrepaid(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *p)
+	}
+	return out, rows.Err()
+}
+
+// RedeemPrepaid — списывает с сертификата/пакета столько, сколько покрывает
+// остаток по брони (или весь баланс, если его меньше), и пишет поступление
+// в журнал оплат брони. Пакет часов гасится только за брони своего клиента;
+// час пакета = место-час брони по её цене.
+func (r *SQLiteContextRepo) RedeemPrepaid(ctx context.Context, code, bookingID, actor string) (*models.PrepaidRedemption, *models.BookingBalance, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	p, err := getPrepaid(ctx, tx, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := availability.Now()
+	switch {
+	case p == nil || p.Status != models.PrepaidActive:
+		return nil, nil, fmt.Errorf("код %s не найден", models.NormalizePromoCode(code))
+	case p.Expired(now):
+		return nil, nil, fmt.Errorf("срок действия %s истёк %s", p.Code, p.ExpiresAt.Format("02.01.2006"))
+	case p.Balance <= 0:
+		return nil, nil, fmt.Errorf("на %s не осталось средств", p.Code)
+	}
+
+	bk, err := getBooking(ctx, tx, bookingID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bk == nil || !bk.IsActive() {
+		return nil, nil, fmt.Errorf("бронь %s не найдена или не активна", bookingID)
+	}
+	if p.Kind == models.PrepaidHours && p.ClientID != bk.ClientID {
+		return nil, nil, fmt.Errorf("пакет %s оформлен на другого клиента", p.Code)
+	}
+
+	bal, err := bookingBalance(ctx, tx, bookingID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bal.Due <= 0 {
+		return nil, nil, fmt.Errorf("бронь %s уже оплачена", bookingID)
+	}
+
+	red := &models.PrepaidRedemption{Code: p.Code, BookingID: bookingID, CreatedAt: now}
+	switch p.Kind {
+	case models.PrepaidHours:
+		seatHours := float64(bk.Seats * bk.Hours)
+		if seatHours == 0 || bal.Total <= 0 {
+			return nil, nil, fmt.Errorf("бронь %s без суммы — списывать нечего", bookingID)
+		}
+		perHour := bal.Total / seatHours
+		need := bal.Due / perHour
+		if p.Balance+1e-9 >= need {
+			red.Units, red.Value = need, bal.Due
+		} else {
+			red.Units, red.Value = p.Balance, math.Round(p.Balance*perHour)
+		}
+	default:
+		red.Units = math.Min(p.Balance, bal.Due)
+		red.Value = red.Units
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO prepaid_redemptions (prepaid_id, booking_id, units, value, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, p.ID, bookingID, red.Units, red.Value, red.CreatedAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	red.ID, _ = res.LastInsertId()
+
+	// Сертификат без владельца закрепляется за первым, кто им оплатил
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE prepaid_accounts
+		SET balance = MAX(balance - ?, 0), client_id = CASE WHEN client_id = '' THEN ? ELSE client_id END
+		WHERE id = ?
+	`, red.Units, bk.ClientID, p.ID); err != nil {
+		return nil, nil, err
+	}
+
+	if err := insertLedgerEntry(ctx, tx, &models.LedgerEntry{
+		BookingID: bookingID,
+		Kind:      models.LedgerCharge,
+		Method:    models.MethodPrepaid,
+		Amount:    red.Value,
+		Actor:     actor,
+		Note:      fmt.Sprintf("%s %s", models.PrepaidKindLabel(p.Kind), p.Code),
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	if bal, err = bookingBalance(ctx, tx, bookingID); err != nil {
+		return nil, nil, err
+	}
+	return red, bal, tx.Commit()
+}
+
+// RestorePrepaid — отмена брони: списанное с сертификатов/пакетов
+// возвращается на их баланс (возврат в журнале оплат). Возвращает сумму в
+// тенге.
+func (r *SQLiteContextRepo) RestorePrepaid(ctx context.Context, bookingID, actor string) (float64, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT pr.id, pr.prepaid_id, pa.code, pa.kind, pr.units, pr.value
+		FROM prepaid_redemptions pr
+		JOIN prepaid_accounts pa ON pa.id = pr.prepaid_id
+		WHERE pr.booking_id = ? AND pr.reversed = 0
+		ORDER BY pr.id
+	`, bookingID)
+	if err != nil {
+		return 0, err
+	}
+	type redemption struct {
+		id, prepaidID int64
+		code, kind    string
+		units, value  float64
+	}
+	var list []redemption
+	for rows.Next() {
+		var x redemption
+		if err := rows.Scan(&x.id, &x.prepaidID, &x.code, &x.kind, &x.units, &x.value); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		list = append(list, x)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var restored float64
+	for _, x := range list {
+		if _, err := tx.ExecContext(ctx, `UPDATE prepaid_redemptions SET reversed = 1 WHERE id = ?`, x.id); err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE prepaid_accounts SET balance = balance + ? WHERE id = ?`, x.units, x.prepaidID); err != nil {
+			return 0, err
+		}
+		if err := insertLedgerEntry(ctx, tx, &models.LedgerEntry{
+			BookingID: bookingID,
+			Kind:      models.LedgerRefund,
+			Method:    models.MethodPrepaid,
+			Amount:    -x.value,
+			Actor:     actor,
+			Note:      fmt.Sprintf("возврат на %s %s", models.PrepaidKindLabel(x.kind), x.code),
+		}); err != nil {
+			return 0, err
+		}
+		restored += x.value
+	}
+	return restored, tx.Commit()
+}
+
+// PrepaidLiability — обязательства по видам на момент now: остаток
+// действующих счетов в единицах и в тенге (по цене продажи), сгоревшее и
+// списанное.
+func (r *SQLiteContextRepo) PrepaidLiability(ctx context.Context, now time.Time) ([]models.PrepaidLiability, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT `+prepaidColumns+`,
+		       COALESCE((SELECT SUM(pr.value) FROM prepaid_redemptions pr
+		                 WHERE pr.prepaid_id = pa.id AND pr.reversed = 0), 0)
+		FROM prepaid_accounts pa
+		WHERE status = ?
+	`, models.PrepaidActive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byKind := map[string]*models.PrepaidLiability{
+		models.PrepaidCertificate: {Kind: models.PrepaidCertificate},
+		models.PrepaidHours:       {Kind: models.PrepaidHours},
+	}
+	for rows.Next() {
+		var p models.PrepaidAccount
+		var expires sql.NullTime
+		var redeemed float64
+		if err := rows.Scan(&p.ID, &p.Code, &p.Kind, &p.ClientID, &p.Initial, &p.Balance, &p.Price, &p.Method,
+			&expires, &p.Status, &p.CreatedBy, &p.CreatedAt, &redeemed); err != nil {
+			return nil, err
+		}
+		p.ExpiresAt = expires.Time
+
+		l, ok := byKind[p.Kind]
+		if !ok {
+			continue
+		}
+		l.Sold += p.Price
+		l.RedeemedValue += redeemed
+		value := p.Balance * p.UnitValue()
+		switch {
+		case p.Balance <= 0:
+		case p.Expired(now):
+			l.ExpiredValue += value
+		default:
+			l.Accounts++
+			l.Balance += p.Balance
+			l.Value += value
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return []models.PrepaidLiability{*byKind[models.PrepaidCertificate], *byKind[models.PrepaidHours]}, nil
+}
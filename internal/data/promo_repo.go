@@ -0,0 +1,187 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// PROMO CODES
+// -----------------------------------------------------------------------------
+
+// GetPromo — промокод по коду; nil, если такого нет.
+func (r *SQLiteContextRepo) GetPromo(ctx context.Context, code string) (*models.PromoCode, error) {
+	var p models.PromoCode
+	var from, to sql.NullTime
+
+	err := r.DB.QueryRowContext(ctx, `
+		SELECT code, kind, value, valid_from, valid_to, max_uses, per_client_limit, created_at
+		FROM promo_codes
+		WHERE code = ?
+	`, models.NormalizePromoCode(code)).Scan(
+		&p.Code, &p.Kind, &p.Value, &from, &to, &p.MaxUses, &p.PerClientLimit, &p.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.ValidFrom, p.ValidTo = from.Time, to.Time
+	return &p, nil
+}
+
+// PromoUsage — сколько раз код погашен всего и этим клиентом.
+func (r *SQLiteContextRepo) PromoUsage(ctx context.Context, code, clientID string) (int, int, error) {
+	return promoUsage(ctx, r.DB, models.NormalizePromoCode(code), clientID)
+}
+
+type rowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func promoUsage(ctx context.Context, q rowQueryer, code, clientID string) (int, int, error) {
+	var total, byClient int
+	err := q.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN client_id = ? THEN 1 ELSE 0 END), 0)
+		FROM promo_redemptions
+		WHERE code = ?
+	`, clientID, code).Scan(&total, &byClient)
+	return total, byClient, err
+}
+
+// CreatePromo — новый промокод. Код уже занят — ошибка.
+func (r *SQLiteContextRepo) CreatePromo(ctx context.Context, p *models.PromoCode) error {
+	p.Code = models.NormalizePromoCode(p.Code)
+	if p.Code == "" {
+		return fmt.Errorf("пустой промокод")
+	}
+	if p.Kind != models.PromoPercent && p.Kind != models.PromoFixed {
+		return fmt.Errorf("вид промокода: %s или %s", models.PromoPercent, models.PromoFixed)
+	}
+	if p.Value <= 0 || (p.Kind == models.PromoPercent && p.Value >= 100) {
+		return fmt.Errorf("некорректный размер скидки: %.0f", p.Value)
+	}
+	p.CreatedAt = time.Now()
+
+	_, err := r.DB.ExecContext(ctx, `
+		INSERT INTO promo_codes (code, kind, value, valid_from, valid_to, max_uses, per_client_limit, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, p.Code, p.Kind, p.Value, nullTime(p.ValidFrom), nullTime(p.ValidTo), p.MaxUses, p.PerClientLimit, p.CreatedAt)
+	if err != nil && strings.Contains(err.Error(), "UNIQUE") {
+		return fmt.Errorf("промокод %s уже существует", p.Code)
+	}
+	return err
+}
+
+// ListPromoStats — все промокоды с числом погашений, новые сверху.
+func (r *SQLiteContextRepo) ListPromoStats(ctx context.Context) ([]models.PromoStats, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT p.code, p.kind, p.value, p.valid_from, p.valid_to, p.max_uses, p.per_client_limit, p.created_at,
+		       COUNT(pr.id), COALESCE(SUM(pr.discount), 0)
+		FROM promo_codes p
+		LEFT JOIN promo_redemptions pr ON pr.code = p.code
+		GROUP BY p.code
+		ORDER BY p.created_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.PromoStats
+	for rows.Next() {
+		var s models.PromoStats
+		var from, to sql.NullTime
+		if err := rows.Scan(
+			&s.Code, &s.Kind, &s.Value, &from, &to, &s.MaxUses, &s.PerClientLimit, &s.CreatedAt,
+			&s.Redemptions, &s.TotalDiscount,
+		); err != nil {
+			return nil, err
+		}
+		s.ValidFrom, s.ValidTo = from.Time, to.Time
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// redeemPromo — погашение кода внутри транзакции брони: лимиты
+// перепроверяются под той же блокировкой, что и станции.
+func redeemPromo(ctx context.Context, tx *sql.Tx, b *models.Booking) error {
+	var maxUses, perClient int
+	err := tx.QueryRowContext(ctx,
+		`SELECT max_uses, per_client_limit FROM promo_codes WHERE code = ?`, b.PromoCode,
+	).Scan(&maxUses, &perClient)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("промокод %s не найден", b.PromoCode)
+	}
+	if err != nil {
+		return err
+	}
+
+	total, byClient, err := promoUsage(ctx, tx, b.PromoCode, b.ClientID)
+	if err != nil {
+		return err
+	}
+	if (maxUses > 0 && total >= maxUses) || (perClient > 0 && byClient >= perClient) {
+		return models.ErrPromoExhausted
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO promo_redemptions (code, client_id, booking_id, discount)
+		VALUES (?, ?, ?, ?)
+	`, b.PromoCode, b.ClientID, b.BookingID, b.PromoDiscount)
+	return err
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// -----------------------------------------------------------------------------
+// LOYALTY (сумма завершённых броней → clients.total_spent / loyalty_level)
+// -----------------------------------------------------------------------------
+
+// CompletedSpend — сумма броней клиента, закончившихся до now
+// (отмены и неявки не считаются).
+func (r *SQLiteContextRepo) CompletedSpend(ctx context.Context, clientID string, now time.Time) (float64, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT booking_start, hours, amount
+		FROM bookings
+		WHERE client_id = ? AND booking_start < ? AND `+activeStatusSQL,
+		clientID, now)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var spent float64
+	for rows.Next() {
+		var b models.Booking
+		if err := rows.Scan(&b.Start, &b.Hours, &b.Amount); err != nil {
+			return 0, err
+		}
+		if !availability.End(b).After(now) {
+			spent += b.Amount
+		}
+	}
+	return spent, rows.Err()
+}
+
+// UpdateLoyalty — сохраняет сумму и уровень в профиль клиента.
+func (r *SQLiteContextRepo) UpdateLoyalty(ctx context.Context, clientID string, spent float64, level string) error {
+	_, err := r.DB.ExecContext(ctx, `
+		INSERT INTO clients (client_id, name, total_spent, loyalty_level) VALUES (?, 'Client', ?, ?)
+		ON CONFLICT(client_id) DO UPDATE SET
+			total_spent = excluded.total_spent,
+			loyalty_level = excluded.loyalty_level
+	`, clientID, spent, level)
+	return err
+}
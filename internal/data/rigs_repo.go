@@ -0,0 +1,180 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// defaultRigCount / defaultGames — начальная конфигурация зала: 8 станций
+// с рулями Thrustmaster T300 и одинаковым набором игр.
+const (
+	defaultRigCount = 8
+	defaultHardware = "Thrustmaster T300"
+)
+
+var defaultGames = []string{
+	"Assetto Corsa",
+	"Automobilista 2",
+	"Euro Truck Simulator 2",
+	"WreckFest",
+	"City Car Driving",
+}
+
+// seedRigs заполняет таблицу станций, если она пустая.
+func seedRigs(db *sql.DB) error {
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM rigs`).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	for i := 1; i <= defaultRigCount; i++ {
+		_, err := db.Exec(
+			`INSERT INTO rigs (rig_id, name, hardware, games, status) VALUES (?, ?, ?, ?, ?)`,
+			i, fmt.Sprintf("Rig %d", i), defaultHardware, strings.Join(defaultGames, ","), models.RigActive,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// RIGS
+// -----------------------------------------------------------------------------
+
+func (r *SQLiteContextRepo) ListRigs(ctx context.Context) ([]models.Rig, error) {
+	return queryRigs(ctx, r.DB)
+}
+
+func queryRigs(ctx context.Context, q queryer) ([]models.Rig, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT rig_id, name, hardware, games, status
+		FROM rigs
+		ORDER BY rig_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Rig
+	for rows.Next() {
+		var rig models.Rig
+		var games string
+		if err := rows.Scan(&rig.ID, &rig.Name, &rig.Hardware, &games, &rig.Status); err != nil {
+			return nil, err
+		}
+		if games != "" {
+			rig.Games = strings.Split(games, ",")
+		}
+		out = append(out, rig)
+	}
+	return out, rows.Err()
+}
+
+// SetRigStatus — active | maintenance.
+func (r *SQLiteContextRepo) SetRigStatus(ctx context.Context, rigID int, status string) error {
+	if status != models.RigActive && status != models.RigMaintenance {
+		return fmt.Errorf("неизвестный статус станции: %s", status)
+	}
+	res, err := r.DB.ExecContext(ctx, `UPDATE rigs SET status = ? WHERE rig_id = ?`, status, rigID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("станция %d не найдена", rigID)
+	}
+	return nil
+}
+
+// GetFreeRigs — свободные на [from, to) активные станции с игрой game.
+func (r *SQLiteContextRepo) GetFreeRigs(ctx context.Context, from, to time.Time, game string) ([]models.Rig, error) {
+	rigs, err := queryRigs(ctx, r.DB)
+	if err != nil {
+		return nil, err
+	}
+	bookings, err := queryBookingsBetween(ctx, r.DB, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return availability.FreeRigs(rigs, bookings, from, to, game), nil
+}
+
+// GetRigBookings — брони станции, которые ещё не закончились к моменту from.
+func (r *SQLiteContextRepo) GetRigBookings(ctx context.Context, rigID int, from time.Time) ([]models.Booking, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT b.booking_id, b.client_id, b.booking_start, b.seats, b.hours, b.amount
+		FROM bookings b
+		JOIN booking_rigs br ON br.booking_id = b.booking_id
+		WHERE br.rig_id = ? AND b.booking_start >= ? AND b.`+activeStatusSQL+`
+		ORDER BY b.booking_start
+	`, rigID, from.Add(-availability.MaxHours*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Booking
+	for rows.Next() {
+		var b models.Booking
+		if err := rows.Scan(&b.BookingID, &b.ClientID, &b.Start, &b.Seats, &b.Hours, &b.Amount); err != nil {
+			return nil, err
+		}
+		if availability.End(b).After(from) {
+			out = append(out, b)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	return out, loadBookingRigs(ctx, r.DB, out)
+}
+
+// loadBookingRigs заполняет RigIDs у переданных броней.
+func loadBookingRigs(ctx context.Context, q queryer, bookings []models.Booking) error {
+	if len(bookings) == 0 {
+		return nil
+	}
+
+	idx := make(map[string]int, len(bookings))
+	args := make([]interface{}, 0, len(bookings))
+	for i, b := range bookings {
+		idx[b.BookingID] = i
+		args = append(args, b.BookingID)
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT booking_id, rig_id
+		FROM booking_rigs
+		WHERE booking_id IN (?`+strings.Repeat(",?", len(args)-1)+`)
+		ORDER BY rig_id
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bookingID string
+		var rigID int
+		if err := rows.Scan(&bookingID, &rigID); err != nil {
+			return err
+		}
+		if i, ok := idx[bookingID]; ok {
+			bookings[i].RigIDs = append(bookings[i].RigIDs, rigID)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,53 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"whatsapp-analytics-mvp/internal/pricing"
+)
+
+// tariffKey — ключ тарифа в таблице settings.
+const tariffKey = "tariff"
+
+// -----------------------------------------------------------------------------
+// TARIFF (правила цены, сохранённые в БД, перекрывают конфиг)
+// -----------------------------------------------------------------------------
+
+// GetTariff — тариф из БД; nil, если его ещё не сохраняли.
+func (r *SQLiteContextRepo) GetTariff(ctx context.Context) (*pricing.Tariff, error) {
+	var raw string
+	err := r.DB.QueryRowContext(ctx, `SELECT value FROM settings WHERE key = ?`, tariffKey).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var t pricing.Tariff
+	if err := json.Unmarshal([]byte(raw), &t); err != nil {
+		return nil, fmt.Errorf("tariff: %w", err)
+	}
+	return &t, nil
+}
+
+// SaveTariff — сохраняет тариф после проверки правил.
+func (r *SQLiteContextRepo) SaveTariff(ctx context.Context, t pricing.Tariff) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.DB.ExecContext(ctx, `
+		INSERT INTO settings (key, value, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`, tariffKey, string(raw))
+	return err
+}
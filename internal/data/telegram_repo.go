@@ -0,0 +1,36 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+)
+
+// telegramOffsetKey — ключ offset long polling в таблице settings.
+const telegramOffsetKey = "telegram_update_offset"
+
+// -----------------------------------------------------------------------------
+// TELEGRAM (offset getUpdates переживает перезапуск)
+// -----------------------------------------------------------------------------
+
+// GetTelegramOffset — следующий update_id для getUpdates; 0 — ещё не читали.
+func (r *SQLiteContextRepo) GetTelegramOffset(ctx context.Context) (int64, error) {
+	var raw string
+	err := r.DB.QueryRowContext(ctx, `SELECT value FROM settings WHERE key = ?`, telegramOffsetKey).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(raw, 10, 64)
+}
+
+func (r *SQLiteContextRepo) SaveTelegramOffset(ctx context.Context, offset int64) error {
+	_, err := r.DB.ExecContext(ctx, `
+		INSERT INTO settings (key, value, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`, telegramOffsetKey, strconv.FormatInt(offset, 10))
+	return err
+}
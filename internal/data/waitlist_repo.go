@@ -0,0 +1,184 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// WAITLIST
+// -----------------------------------------------------------------------------
+
+const waitlistColumns = `id, client_id, slot_start, seats, hours, game, flex_hours, status, booking_id, offer_expires_at, created_at`
+
+func scanWaitlist(row rowScanner) (*models.WaitlistEntry, error) {
+	var e models.WaitlistEntry
+	var expires sql.NullTime
+	if err := row.Scan(&e.ID, &e.ClientID, &e.Start, &e.Seats, &e.Hours, &e.Game, &e.FlexHours,
+		&e.Status, &e.BookingID, &expires, &e.CreatedAt); err != nil {
+		return nil, err
+	}
+	e.OfferExpiresAt = expires.Time
+	return &e, nil
+}
+
+func (r *SQLiteContextRepo) queryWaitlist(ctx context.Context, where string, args ...interface{}) ([]models.WaitlistEntry, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT `+waitlistColumns+` FROM waitlist WHERE `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.WaitlistEntry
+	for rows.Next() {
+		e, err := scanWaitlist(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *e)
+	}
+	return out, rows.Err()
+}
+
+// AddWaitlist — ставит клиента в очередь. Повторная заявка на тот же слот
+// не дублируется: e получает ID и статус уже существующей.
+func (r *SQLiteContextRepo) AddWaitlist(ctx context.Context, e *models.WaitlistEntry) error {
+	existing, err := scanWaitlist(r.DB.QueryRowContext(ctx, `
+		SELECT `+waitlistColumns+`
+		FROM waitlist
+		WHERE client_id = ? AND slot_start = ? AND status IN (?, ?)
+		LIMIT 1
+	`, e.ClientID, e.Start, models.WaitlistWaiting, models.WaitlistOffered))
+	if err == nil {
+		*e = *existing
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	e.Status = models.WaitlistWaiting
+	e.CreatedAt = availability.Now()
+	res, err := r.DB.ExecContext(ctx, `
+		INSERT INTO waitlist (client_id, slot_start, seats, hours, game, flex_hours, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.ClientID, e.Start, e.Seats, e.Hours, e.Game, e.FlexHours, e.Status, e.CreatedAt)
+	if err != nil {
+		return err
+	}
+	e.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// ListWaiting — ждущие заявки на слоты после from, в порядке постановки.
+func (r *SQLiteContextRepo) ListWaiting(ctx context.Context, from time.Time) ([]models.WaitlistEntry, error) {
+	return r.queryWaitlist(ctx, `status = ? AND slot_start > ?`, models.WaitlistWaiting, from)
+}
+
+// ListClientWaitlist — открытые заявки клиента (ждут или предложены).
+func (r *SQLiteContextRepo) ListClientWaitlist(ctx context.Context, clientID string) ([]models.WaitlistEntry, error) {
+	return r.queryWaitlist(ctx, `client_id = ? AND status IN (?, ?)`,
+		clientID, models.WaitlistWaiting, models.WaitlistOffered)
+}
+
+// ListExpiredOffers — предложения, на которые клиент не ответил до срока.
+func (r *SQLiteContextRepo) ListExpiredOffers(ctx context.Context, now time.Time) ([]models.WaitlistEntry, error) {
+	return r.queryWaitlist(ctx, `status = ? AND offer_expires_at <= ?`, models.WaitlistOffered, now)
+}
+
+// MarkWaitlistOffered — клиенту придержана бронь до expiresAt.
+func (r *SQLiteContextRepo) MarkWaitlistOffered(ctx context.Context, id int64, bookingID string, expiresAt time.Time) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE waitlist SET status = ?, booking_id = ?, offer_expires_at = ? WHERE id = ?
+	`, models.WaitlistOffered, bookingID, expiresAt, id)
+	return err
+}
+
+func (r *SQLiteContextRepo) SetWaitlistStatus(ctx context.Context, id int64, status string) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE waitlist SET status = ? WHERE id = ?`, status, id)
+	return err
+}
+
+// ExpireWaitlist — слот уже начался, ждать больше нечего.
+func (r *SQLiteContextRepo) ExpireWaitlist(ctx context.Context, now time.Time) (int, error) {
+	res, err := r.DB.ExecContext(ctx, `
+		UPDATE waitlist SET status = ? WHERE status = ? AND slot_start <= ?
+	`, models.WaitlistExpired, models.WaitlistWaiting, now)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// -----------------------------------------------------------------------------
+// TURNED-AWAY DEMAND
+// -----------------------------------------------------------------------------
+
+// RecordDemandMiss — запрос, на который не хватило мест.
+func (r *SQLiteContextRepo) RecordDemandMiss(ctx context.Context, m models.DemandMiss) error {
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = availability.Now()
+	}
+	_, err := r.DB.ExecContext(ctx, `
+		INSERT INTO demand_misses (client_id, slot_start, seats, hours, game, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, m.ClientID, m.Start, m.Seats, m.Hours, m.Game, m.CreatedAt)
+	return err
+}
+
+// TurnedAwayByHour — упущенный спрос на слоты [from, to) по часу начала.
+// Повторные проверки одного клиента на тот же слот считаются одним запросом.
+func (r *SQLiteContextRepo) TurnedAwayByHour(ctx context.Context, from, to time.Time) ([]models.TurnedAwayHour, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		WITH m AS (
+			SELECT client_id, slot_start, MAX(seats) AS seats, MAX(hours) AS hours
+			FROM demand_misses
+			WHERE slot_start >= ? AND slot_start < ?
+			GROUP BY client_id, slot_start
+		)
+		SELECT strftime('%H', m.slot_start) AS h,
+		       COUNT(*),
+		       COUNT(DISTINCT m.client_id),
+		       COALESCE(SUM(m.seats * m.hours), 0),
+		       COALESCE(SUM(EXISTS (SELECT 1 FROM waitlist w
+		                    WHERE w.client_id = m.client_id AND w.slot_start = m.slot_start)), 0),
+		       COALESCE(SUM(EXISTS (SELECT 1 FROM waitlist w
+		                    WHERE w.client_id = m.client_id AND w.slot_start = m.slot_start AND w.status = ?)), 0)
+		FROM m
+		GROUP BY h
+	`, from, to, models.WaitlistAccepted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.TurnedAwayHour
+	for rows.Next() {
+		var t models.TurnedAwayHour
+		if err := rows.Scan(&t.Hour, &t.Requests, &t.Clients, &t.SeatHours, &t.Waitlisted, &t.Recovered); err != nil {
+			return nil, err
+		}
+		t.Hour += ":00"
+		out = append(out, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Порядок рабочего дня: 12:00 … 23:00, затем ночь до закрытия
+	sort.SliceStable(out, func(i, j int) bool { return businessHour(out[i].Hour) < businessHour(out[j].Hour) })
+	return out, nil
+}
+
+func businessHour(h string) int {
+	n, _ := strconv.Atoi(h[:2])
+	return (n + 24 - availability.OpenHour) % 24
+}
@@ -0,0 +1,147 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// WEBHOOK DELIVERIES (очередь исходящих вебхуков, dead — отложенные вручную)
+// -----------------------------------------------------------------------------
+
+const webhookColumns = `id, subscriber, event_id, event, payload, status, attempts, response_code, last_error, next_attempt_at, created_at, delivered_at`
+
+func scanWebhook(row rowScanner) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	if err := row.Scan(&d.ID, &d.Subscriber, &d.EventID, &d.Event, &d.Payload, &d.Status, &d.Attempts,
+		&d.ResponseCode, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.DeliveredAt); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func queryWebhooks(ctx context.Context, q queryer, where string, args ...interface{}) ([]models.WebhookDelivery, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhook_deliveries WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *d)
+	}
+	return out, rows.Err()
+}
+
+// EnqueueWebhooks — доставки одного события всем получателям одной
+// транзакцией; первая попытка — сразу. Повтор того же события (тот же
+// event_id у получателя) пропускается.
+func (r *SQLiteContextRepo) EnqueueWebhooks(ctx context.Context, list []models.WebhookDelivery) error {
+	if len(list) == 0 {
+		return nil
+	}
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := availability.Now()
+	for _, d := range list {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO webhook_deliveries (subscriber, event_id, event, payload, status, next_attempt_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, d.Subscriber, d.EventID, d.Event, d.Payload, models.WebhookPending, now, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ListDueWebhooks — ждущие доставки, у которых подошло время попытки.
+func (r *SQLiteContextRepo) ListDueWebhooks(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	return queryWebhooks(ctx, r.DB, `status = ? AND next_attempt_at <= ? ORDER BY id LIMIT ?`,
+		models.WebhookPending, now, limit)
+}
+
+func (r *SQLiteContextRepo) MarkWebhookDelivered(ctx context.Context, id int64, code int) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status = ?, attempts = attempts + 1, response_code = ?, last_error = '', delivered_at = ?
+		WHERE id = ?
+	`, models.WebhookDelivered, code, availability.Now(), id)
+	return err
+}
+
+// MarkWebhookFailed — попытка не удалась: повтор в retryAt или dead.
+func (r *SQLiteContextRepo) MarkWebhookFailed(ctx context.Context, id int64, code int, lastError string, retryAt time.Time, dead bool) error {
+	status := models.WebhookPending
+	if dead {
+		status = models.WebhookDead
+	}
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status = ?, attempts = attempts + 1, response_code = ?, last_error = ?, next_attempt_at = ?
+		WHERE id = ?
+	`, status, code, lastError, retryAt, id)
+	return err
+}
+
+// ListWebhookDeliveries — доставки по фильтру, новые первыми (по умолчанию 50).
+func (r *SQLiteContextRepo) ListWebhookDeliveries(ctx context.Context, f models.WebhookFilter) ([]models.WebhookDelivery, error) {
+	where := []string{"1 = 1"}
+	var args []interface{}
+	if f.Subscriber != "" {
+		where, args = append(where, "subscriber = ?"), append(args, f.Subscriber)
+	}
+	if f.Event != "" {
+		where, args = append(where, "event = ?"), append(args, f.Event)
+	}
+	if f.Status != "" {
+		where, args = append(where, "status = ?"), append(args, f.Status)
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	args = append(args, limit)
+
+	return queryWebhooks(ctx, r.DB, strings.Join(where, " AND ")+` ORDER BY id DESC LIMIT ?`, args...)
+}
+
+func (r *SQLiteContextRepo) GetWebhookDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	list, err := queryWebhooks(ctx, r.DB, `id = ?`, id)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// ReplayWebhooks — вернуть dead-доставки в очередь с новым счётчиком
+// попыток: одну (id > 0) или все получателя subscriber (пусто — всех).
+func (r *SQLiteContextRepo) ReplayWebhooks(ctx context.Context, id int64, subscriber string) (int, error) {
+	where := `status = ?`
+	args := []interface{}{models.WebhookPending, availability.Now(), models.WebhookDead}
+	if id > 0 {
+		where, args = where+` AND id = ?`, append(args, id)
+	}
+	if subscriber != "" {
+		where, args = where+` AND subscriber = ?`, append(args, subscriber)
+	}
+
+	res, err := r.DB.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ?
+		WHERE `+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
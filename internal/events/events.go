@@ -0,0 +1,227 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// DOMAIN EVENTS
+// -----------------------------------------------------------------------------
+
+// Event — доменное событие шины. Name — тип для подписки и outbox.
+type Event interface {
+	Name() string
+}
+
+// Типы событий.
+const (
+	NameMessageReceived      = "message.received"
+	NameBookingCreated       = "booking.created"
+	NameBookingCancelled     = "booking.cancelled"
+	NameBookingRescheduled   = "booking.rescheduled"
+	NameBookingStatusChanged = "booking.status_changed" // кроме отмены
+	NamePaymentReceived      = "payment.received"
+	NamePrepaidRedeemed      = "prepaid.redeemed"
+	NameClientEscalated      = "client.escalated"
+)
+
+// MessageReceived — входящее сообщение клиента (или администратора).
+type MessageReceived struct {
+	ClientID string    `json:"client_id"`
+	Channel  string    `json:"channel"` // telegram | whatsapp
+	Text     string    `json:"text"`
+	IsAdmin  bool      `json:"is_admin,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// BookingCreated — бронь записана (ботом, администратором или под турнир).
+type BookingCreated struct {
+	BookingID string    `json:"booking_id"`
+	ClientID  string    `json:"client_id"`
+	Status    string    `json:"status"`
+	CreatedBy string    `json:"created_by"`
+	Start     time.Time `json:"start"`
+	Seats     int       `json:"seats"`
+	Hours     int       `json:"hours"`
+	Amount    float64   `json:"amount"`
+}
+
+// BookingCancelled — бронь отменена, её станции свободны.
+type BookingCancelled struct {
+	BookingID  string    `json:"booking_id"`
+	ClientID   string    `json:"client_id"`
+	Start      time.Time `json:"start"`
+	Seats      int       `json:"seats"`
+	Hours      int       `json:"hours"`
+	FromStatus string    `json:"from_status"`
+	Actor      string    `json:"actor"`
+	Note       string    `json:"note,omitempty"`
+}
+
+// BookingRescheduled — бронь перенесена на другое время или длительность.
+type BookingRescheduled struct {
+	BookingID string    `json:"booking_id"`
+	ClientID  string    `json:"client_id"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Hours     int       `json:"hours"`
+	Actor     string    `json:"actor"`
+}
+
+// BookingStatusChanged — смена статуса, кроме отмены (оплачена,
+// подтверждена, неявка).
+type BookingStatusChanged struct {
+	BookingID string `json:"booking_id"`
+	ClientID  string `json:"client_id"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Actor     string `json:"actor"`
+}
+
+// PaymentReceived — деньги по брони: оплата или депозит. Списание
+// сертификата/пакета — не поступление, для него PrepaidRedeemed.
+type PaymentReceived struct {
+	BookingID string  `json:"booking_id"`
+	ClientID  string  `json:"client_id"`
+	Kind      string  `json:"kind"`
+	Method    string  `json:"method"`
+	Amount    float64 `json:"amount"`
+	Actor     string  `json:"actor"`
+}
+
+// PrepaidRedeemed — бронь оплачена с сертификата или пакета часов (деньги
+// получены раньше, при продаже).
+type PrepaidRedeemed struct {
+	BookingID string  `json:"booking_id"`
+	ClientID  string  `json:"client_id"`
+	Amount    float64 `json:"amount"`
+	Actor     string  `json:"actor"`
+	Note      string  `json:"note,omitempty"` // вид и код
+}
+
+// ClientEscalated — клиенту нужен живой администратор.
+type ClientEscalated struct {
+	ClientID string `json:"client_id"`
+	Reason   string `json:"reason"`
+}
+
+// Names — все типы событий (подписка «на всё»).
+var Names = []string{
+	NameMessageReceived, NameBookingCreated, NameBookingCancelled, NameBookingRescheduled,
+	NameBookingStatusChanged, NamePaymentReceived, NamePrepaidRedeemed, NameClientEscalated,
+}
+
+func (MessageReceived) Name() string      { return NameMessageReceived }
+func (BookingCreated) Name() string       { return NameBookingCreated }
+func (BookingCancelled) Name() string     { return NameBookingCancelled }
+func (BookingRescheduled) Name() string   { return NameBookingRescheduled }
+func (BookingStatusChanged) Name() string { return NameBookingStatusChanged }
+func (PaymentReceived) Name() string      { return NamePaymentReceived }
+func (PrepaidRedeemed) Name() string      { return NamePrepaidRedeemed }
+func (ClientEscalated) Name() string      { return NameClientEscalated }
+
+// -----------------------------------------------------------------------------
+// ENCODING (outbox)
+// -----------------------------------------------------------------------------
+
+// Encode — JSON события для outbox.
+func Encode(e Event) (string, error) {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("событие %s: %w", e.Name(), err)
+	}
+	return string(raw), nil
+}
+
+// Decode — событие из outbox по имени типа. Возвращает значение (не
+// указатель), как при прямой публикации: подписчик пишет одно утверждение
+// типа для обоих путей.
+func Decode(name, payload string) (Event, error) {
+	var err error
+	decode := func(v interface{}) {
+		err = json.Unmarshal([]byte(payload), v)
+	}
+
+	var e Event
+	switch name {
+	case NameMessageReceived:
+		var v MessageReceived
+		decode(&v)
+		e = v
+	case NameBookingCreated:
+		var v BookingCreated
+		decode(&v)
+		e = v
+	case NameBookingCancelled:
+		var v BookingCancelled
+		decode(&v)
+		e = v
+	case NameBookingRescheduled:
+		var v BookingRescheduled
+		decode(&v)
+		e = v
+	case NameBookingStatusChanged:
+		var v BookingStatusChanged
+		decode(&v)
+		e = v
+	case NamePaymentReceived:
+		var v PaymentReceived
+		decode(&v)
+		e = v
+	case NamePrepaidRedeemed:
+		var v PrepaidRedeemed
+		decode(&v)
+		e = v
+	case NameClientEscalated:
+		var v ClientEscalated
+		decode(&v)
+		e = v
+	default:
+		return nil, fmt.Errorf("неизвестное событие %q", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("событие %s: %w", name, err)
+	}
+	return e, nil
+}
+
+// BookingID — ID брони из события о брони; пусто для прочих.
+func BookingID(e Event) string {
+	switch v := e.(type) {
+	case BookingCreated:
+		return v.BookingID
+	case BookingCancelled:
+		return v.BookingID
+	case BookingRescheduled:
+		return v.BookingID
+	case BookingStatusChanged:
+		return v.BookingID
+	case PaymentReceived:
+		return v.BookingID
+	case PrepaidRedeemed:
+		return v.BookingID
+	}
+	return ""
+}
+
+// -----------------------------------------------------------------------------
+// ID СОБЫТИЯ
+// -----------------------------------------------------------------------------
+
+type idKey struct{}
+
+// WithID — контекст публикации с устойчивым ID события (из outbox): при
+// повторной доставке того же события ID тот же, подписчики отсеивают дубли.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+// ID — ID события из контекста публикации; false — событие опубликовано
+// напрямую, мимо outbox.
+func ID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(idKey{}).(string)
+	return id, ok && id != ""
+}
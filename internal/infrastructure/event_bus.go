@@ -0,0 +1,186 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/core"
+	"whatsapp-analytics-mvp/internal/events"
+)
+
+// ============================================================================
+// EVENT BUS (в процессе)
+// ============================================================================
+
+// MemoryEventBus — core.EventBus в памяти процесса. Долговечность даёт не
+// шина, а outbox: события изменений лежат в БД, пока OutboxRelay не
+// доставит их сюда.
+type MemoryEventBus struct {
+	mu    sync.RWMutex
+	sync  map[string][]core.EventHandler
+	async map[string][]core.EventHandler
+	wg    sync.WaitGroup
+}
+
+func NewMemoryEventBus() *MemoryEventBus {
+	return &MemoryEventBus{
+		sync:  map[string][]core.EventHandler{},
+		async: map[string][]core.EventHandler{},
+	}
+}
+
+func (b *MemoryEventBus) Subscribe(name string, h core.EventHandler) {
+	b.mu.Lock()
+	b.sync[name] = append(b.sync[name], h)
+	b.mu.Unlock()
+}
+
+func (b *MemoryEventBus) SubscribeAsync(name string, h core.EventHandler) {
+	b.mu.Lock()
+	b.async[name] = append(b.async[name], h)
+	b.mu.Unlock()
+}
+
+// Publish — синхронные подписчики по порядку подписки (ошибка одного не
+// останавливает остальных), затем асинхронные в своих горутинах.
+func (b *MemoryEventBus) Publish(ctx context.Context, e events.Event) error {
+	b.mu.RLock()
+	syncHandlers := b.sync[e.Name()]
+	asyncHandlers := b.async[e.Name()]
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, h := range syncHandlers {
+		if err := callHandler(ctx, h, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, h := range asyncHandlers {
+		b.wg.Add(1)
+		go func(h core.EventHandler) {
+			defer b.wg.Done()
+			// Контекст публикации может закончиться раньше подписчика
+			if err := callHandler(context.WithoutCancel(ctx), h, e); err != nil {
+				log.Printf("[EventBus] async %s: %v", e.Name(), err)
+			}
+		}(h)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: %w", e.Name(), errors.Join(errs...))
+	}
+	return nil
+}
+
+// Wait — дождаться асинхронных подписчиков (остановка сервиса).
+func (b *MemoryEventBus) Wait() {
+	b.wg.Wait()
+}
+
+func callHandler(ctx context.Context, h core.EventHandler, e events.Event) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return h(ctx, e)
+}
+
+// ============================================================================
+// OUTBOX RELAY
+// ============================================================================
+
+// OutboxRelay — доставляет события из outbox в шину по порядку записи.
+// Событие помечается доставленным, когда все синхронные подписчики
+// отработали без ошибок; иначе повтор с нарастающей паузой, после
+// MaxAttempts — dead (остаётся в таблице для разбора). При повторе
+// событие получают все подписчики, поэтому они должны быть идемпотентны:
+// ID события (events.ID) выводится из ID записи outbox и между попытками
+// не меняется.
+type OutboxRelay struct {
+	Repo core.OutboxRepository
+	Bus  core.EventBus
+
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Batch       int
+}
+
+// Параметры по умолчанию.
+const (
+	defaultOutboxAttempts = 10
+	defaultOutboxBackoff  = 5 * time.Second
+	defaultOutboxMaxDelay = 10 * time.Minute
+	defaultOutboxBatch    = 50
+)
+
+func NewOutboxRelay(repo core.OutboxRepository, bus core.EventBus) *OutboxRelay {
+	return &OutboxRelay{
+		Repo:        repo,
+		Bus:         bus,
+		MaxAttempts: defaultOutboxAttempts,
+		BaseBackoff: defaultOutboxBackoff,
+		MaxBackoff:  defaultOutboxMaxDelay,
+		Batch:       defaultOutboxBatch,
+	}
+}
+
+// Deliver — один проход по outbox; возвращает число доставленных событий.
+func (r *OutboxRelay) Deliver(ctx context.Context) (int, error) {
+	now := availability.Now()
+	pending, err := r.Repo.ListOutbox(ctx, now, r.Batch)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, rec := range pending {
+		e, err := events.Decode(rec.Name, rec.Payload)
+		if err == nil {
+			err = r.Bus.Publish(events.WithID(ctx, fmt.Sprintf("evt_%d", rec.ID)), e)
+		}
+		if err == nil {
+			if err := r.Repo.MarkOutboxDelivered(ctx, rec.ID); err != nil {
+				return delivered, err
+			}
+			delivered++
+			continue
+		}
+
+		attempt := rec.Attempts + 1
+		dead := attempt >= r.MaxAttempts
+		if dead {
+			log.Printf("[Outbox] ❌ #%d %s dead after %d attempts: %v", rec.ID, rec.Name, attempt, err)
+		} else {
+			log.Printf("[Outbox] #%d %s attempt %d: %v", rec.ID, rec.Name, attempt, err)
+		}
+		if err := r.Repo.MarkOutboxFailed(ctx, rec.ID, err.Error(), now.Add(backoffDelay(r.BaseBackoff, r.MaxBackoff, attempt)), dead); err != nil {
+			return delivered, err
+		}
+	}
+	return delivered, nil
+}
+
+// Run — опрашивает outbox до отмены ctx.
+func (r *OutboxRelay) Run(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Deliver(ctx); err != nil {
+			log.Printf("[Outbox] %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/core"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// ============================================================================
+// INBOUND QUEUE
+// ============================================================================
+
+// InboundHandler — обработка пачки событий одного клиента из одного
+// источника (по порядку приёма; без склейки — одно событие). Ошибка — повтор
+// всей пачки с нарастающей паузой (в ctx — core.WithRetry). final —
+// последняя попытка: повтора не будет, клиенту пора сказать о сбое.
+type InboundHandler func(ctx context.Context, payloads [][]byte, final bool) error
+
+// InboundQueue — входящие сообщения: вебхук сохраняет событие в
+// inbound_events и сразу отвечает, пул из Workers воркеров обрабатывает.
+// Одновременно обрабатывается не больше одного события клиента, события
+// клиента идут по порядку приёма. Принятое событие переживает падение
+// процесса; прерванное на середине обработается ещё раз (не меньше
+// одного раза). Debounce источника склеивает подряд идущие сообщения
+// клиента в одну пачку — один ответ вместо трёх.
+type InboundQueue struct {
+	Repo     core.InboundRepository
+	Handlers map[string]InboundHandler  // по Source
+	Debounce map[string]models.Debounce // по Source; нет записи — без склейки
+
+	Workers      int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration // страховочный опрос; новые события будят сразу
+
+	mu   sync.Mutex
+	busy map[string]bool // клиенты, чьё событие сейчас в обработке
+	wake chan struct{}
+}
+
+// Параметры по умолчанию.
+const (
+	defaultInboundWorkers  = 4
+	defaultInboundAttempts = 3
+	defaultInboundBackoff  = 2 * time.Second
+	defaultInboundMaxDelay = time.Minute
+	defaultInboundPoll     = 2 * time.Second
+	maxInboundBatch        = 20 // больше сообщений подряд — отвечаем, не дожидаясь паузы
+)
+
+// DefaultInboundDebounce — склейка по умолчанию: в WhatsApp чаще пишут
+// несколькими сообщениями подряд.
+func DefaultInboundDebounce() map[string]models.Debounce {
+	return map[string]models.Debounce{
+		models.InboundWazzup:   {Window: 3 * time.Second, MaxWait: 10 * time.Second},
+		models.InboundTelegram: {Window: 2 * time.Second, MaxWait: 8 * time.Second},
+	}
+}
+
+func NewInboundQueue(repo core.InboundRepository) *InboundQueue {
+	return &InboundQueue{
+		Repo:         repo,
+		Handlers:     map[string]InboundHandler{},
+		Debounce:     DefaultInboundDebounce(),
+		Workers:      defaultInboundWorkers,
+		MaxAttempts:  defaultInboundAttempts,
+		BaseBackoff:  defaultInboundBackoff,
+		MaxBackoff:   defaultInboundMaxDelay,
+		PollInterval: defaultInboundPoll,
+		busy:         map[string]bool{},
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Handle — обработчик событий источника source.
+func (q *InboundQueue) Handle(source string, h InboundHandler) {
+	q.Handlers[source] = h
+}
+
+// Enqueue — сохранить событие; false — дубль уже принятого.
+func (q *InboundQueue) Enqueue(ctx context.Context, e models.InboundEvent) (bool, error) {
+	ok, err := q.Repo.EnqueueInbound(ctx, e)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		q.notify()
+	}
+	return ok, nil
+}
+
+func (q *InboundQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run — раздаёт события воркерам до отмены ctx, затем ждёт начатые.
+func (q *InboundQueue) Run(ctx context.Context) {
+	if n, err := q.Repo.ResetInboundProcessing(ctx); err != nil {
+		log.Printf("[Inbound] ⚠️ reset: %v", err)
+	} else if n > 0 {
+		log.Printf("[Inbound] %d interrupted events requeued", n)
+	}
+
+	workers := max(q.Workers, 1)
+	slots := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		wakeAt, err := q.dispatch(ctx, slots, &wg)
+		if err != nil {
+			log.Printf("[Inbound] %v", err)
+		}
+
+		// клиент, который ещё печатает, будится к концу своего окна
+		wait := q.PollInterval
+		if !wakeAt.IsZero() {
+			wait = min(wait, max(wakeAt.Sub(availability.NowPrecise()), 10*time.Millisecond))
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-q.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// dispatch — запустить свободным воркерам очередные пачки клиентов, которые
+// сейчас не обрабатываются. wakeAt — когда истечёт ближайшее окно склейки.
+func (q *InboundQueue) dispatch(ctx context.Context, slots chan struct{}, wg *sync.WaitGroup) (wakeAt time.Time, err error) {
+	now := availability.NowPrecise()
+	heads, err := q.Repo.ListInboundHeads(ctx, now, cap(slots)*4)
+	if err != nil {
+		return wakeAt, err
+	}
+
+	for _, head := range heads {
+		q.mu.Lock()
+		busy := q.busy[head.ClientID]
+		q.mu.Unlock()
+		if busy {
+			continue
+		}
+
+		batch, readyAt, err := q.batch(ctx, head, now)
+		if err != nil {
+			return wakeAt, err
+		}
+		if batch == nil {
+			if wakeAt.IsZero() || readyAt.Before(wakeAt) {
+				wakeAt = readyAt
+			}
+			continue
+		}
+
+		select {
+		case slots <- struct{}{}:
+		default:
+			return wakeAt, nil // все воркеры заняты — освободившийся разбудит
+		}
+		for _, e := range batch {
+			if err := q.Repo.MarkInboundProcessing(ctx, e.ID); err != nil {
+				<-slots
+				return wakeAt, err
+			}
+		}
+
+		q.mu.Lock()
+		q.busy[head.ClientID] = true
+		q.mu.Unlock()
+
+		wg.Add(1)
+		go func(batch []models.InboundEvent) {
+			defer wg.Done()
+			// начатая пачка доводится до конца и при остановке
+			q.process(context.WithoutCancel(ctx), batch)
+
+			q.mu.Lock()
+			delete(q.busy, batch[0].ClientID)
+			q.mu.Unlock()
+			<-slots
+			q.notify()
+		}(batch)
+	}
+	return wakeAt, nil
+}
+
+// batch — события клиента, которые обработать одним ходом: head и
+// следующие за ним из того же источника. nil — клиент, возможно, ещё
+// печатает: ждать до readyAt.
+func (q *InboundQueue) batch(ctx context.Context, head models.InboundEvent, now time.Time) ([]models.InboundEvent, time.Time, error) {
+	d := q.Debounce[head.Source]
+	if d.Window <= 0 {
+		return []models.InboundEvent{head}, time.Time{}, nil
+	}
+
+	pending, err := q.Repo.ListInboundPending(ctx, head.ClientID, maxInboundBatch)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	batch := []models.InboundEvent{head}
+	for _, e := range pending {
+		if e.ID <= head.ID {
+			continue
+		}
+		// повтор — та же пачка; новые сообщения — следующим ходом
+		if e.Source != head.Source || e.Attempts != head.Attempts {
+			break
+		}
+		batch = append(batch, e)
+	}
+
+	readyAt := batch[len(batch)-1].ReceivedAt.Add(d.Window)
+	if d.MaxWait > 0 {
+		readyAt = minTime(readyAt, head.ReceivedAt.Add(d.MaxWait))
+	}
+	if now.Before(readyAt) && len(batch) < maxInboundBatch {
+		return nil, readyAt, nil
+	}
+	return batch, time.Time{}, nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func (q *InboundQueue) process(ctx context.Context, batch []models.InboundEvent) {
+	head := batch[0]
+	if len(batch) > 1 {
+		log.Printf("[Inbound] %s: %d messages merged into one turn", head.ClientID, len(batch))
+	}
+
+	err := q.run(ctx, batch)
+	if err == nil {
+		for _, e := range batch {
+			if err := q.Repo.MarkInboundDone(ctx, e.ID); err != nil {
+				log.Printf("[Inbound] #%d mark done: %v", e.ID, err)
+			}
+		}
+		return
+	}
+
+	attempt := head.Attempts + 1
+	dead := attempt >= q.MaxAttempts
+	if dead {
+		log.Printf("[Inbound] ❌ #%d %s %s dead after %d attempts: %v", head.ID, head.Source, head.ClientID, attempt, err)
+	} else {
+		log.Printf("[Inbound] #%d %s %s attempt %d: %v", head.ID, head.Source, head.ClientID, attempt, err)
+	}
+	retryAt := availability.Now().Add(backoffDelay(q.BaseBackoff, q.MaxBackoff, attempt))
+	for _, e := range batch {
+		if err := q.Repo.MarkInboundFailed(ctx, e.ID, err.Error(), retryAt, dead); err != nil {
+			log.Printf("[Inbound] #%d mark failed: %v", e.ID, err)
+		}
+	}
+}
+
+// run — вызов обработчика; паника считается ошибкой попытки.
+func (q *InboundQueue) run(ctx context.Context, batch []models.InboundEvent) (err error) {
+	h, ok := q.Handlers[batch[0].Source]
+	if !ok {
+		return fmt.Errorf("нет обработчика источника %q", batch[0].Source)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	payloads := make([][]byte, len(batch))
+	for i, e := range batch {
+		payloads[i] = []byte(e.Payload)
+	}
+	if batch[0].Attempts > 0 {
+		ctx = core.WithRetry(ctx)
+	}
+	return h(ctx, payloads, batch[0].Attempts+1 >= q.MaxAttempts)
+}
@@ -0,0 +1,220 @@
+package infrastructure_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/data"
+	"whatsapp-analytics-mvp/internal/infrastructure"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// inboundCall — один вызов обработчика: пачка и когда она пришла.
+type inboundCall struct {
+	payloads []string
+	final    bool
+	at       time.Time
+}
+
+type inboundEnv struct {
+	repo  *data.SQLiteContextRepo
+	queue *infrastructure.InboundQueue
+	calls chan inboundCall
+	seq   int
+}
+
+// newInboundEnv — очередь с короткими окнами склейки Telegram; обработчик
+// пишет пачки в calls и возвращает fail(пачка), если он задан.
+func newInboundEnv(t *testing.T, d models.Debounce, fail func(payloads []string) error) *inboundEnv {
+	t.Helper()
+	repo, err := data.NewSQLiteContextRepo(filepath.Join(t.TempDir(), "inbound.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.DB.Close() })
+
+	e := &inboundEnv{repo: repo, calls: make(chan inboundCall, 16)}
+	q := infrastructure.NewInboundQueue(repo)
+	q.Debounce = map[string]models.Debounce{models.InboundTelegram: d}
+	q.PollInterval = 20 * time.Millisecond
+	q.BaseBackoff = time.Millisecond
+	q.MaxBackoff = time.Millisecond
+	q.Handle(models.InboundTelegram, func(ctx context.Context, payloads [][]byte, final bool) error {
+		call := inboundCall{final: final, at: time.Now()}
+		for _, p := range payloads {
+			call.payloads = append(call.payloads, string(p))
+		}
+		e.calls <- call
+		if fail != nil {
+			return fail(call.payloads)
+		}
+		return nil
+	})
+	e.queue = q
+	return e
+}
+
+func (e *inboundEnv) run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.queue.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// send — сообщение клиента TG-1 с новым update_id.
+func (e *inboundEnv) send(t *testing.T, text string) {
+	t.Helper()
+	e.seq++
+	ok, err := e.queue.Enqueue(context.Background(), models.InboundEvent{
+		Source:     models.InboundTelegram,
+		ExternalID: fmt.Sprint(e.seq),
+		ClientID:   "TG-1",
+		Payload:    text,
+	})
+	if err != nil || !ok {
+		t.Fatalf("Enqueue %q = %v, %v", text, ok, err)
+	}
+}
+
+func (e *inboundEnv) next(t *testing.T) inboundCall {
+	t.Helper()
+	select {
+	case c := <-e.calls:
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler was not called")
+		return inboundCall{}
+	}
+}
+
+func (e *inboundEnv) none(t *testing.T, wait time.Duration) {
+	t.Helper()
+	select {
+	case c := <-e.calls:
+		t.Fatalf("unexpected call with %v", c.payloads)
+	case <-time.After(wait):
+	}
+}
+
+// Три сообщения подряд — один вызов с тремя сообщениями после паузы.
+func TestInboundDebounceMergesMessages(t *testing.T) {
+	e := newInboundEnv(t, models.Debounce{Window: 300 * time.Millisecond, MaxWait: 5 * time.Second}, nil)
+	e.run(t)
+
+	start := time.Now()
+	for _, text := range []string{"привет", "на завтра", "в 19:00"} {
+		e.send(t, text)
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	c := e.next(t)
+	if got := strings.Join(c.payloads, "|"); got != "привет|на завтра|в 19:00" {
+		t.Errorf("payloads = %q, want all three in order", got)
+	}
+	if waited := c.at.Sub(start); waited < 300*time.Millisecond {
+		t.Errorf("handled after %s, before the debounce window", waited)
+	}
+	e.none(t, 400*time.Millisecond)
+}
+
+// Клиент печатает без пауз — ответ не позже MaxWait от первого сообщения,
+// остальное — следующим ходом.
+func TestInboundDebounceMaxWait(t *testing.T) {
+	e := newInboundEnv(t, models.Debounce{Window: 300 * time.Millisecond, MaxWait: 600 * time.Millisecond}, nil)
+	e.run(t)
+
+	start := time.Now()
+	sent := 0
+	for time.Since(start) < 1200*time.Millisecond {
+		e.send(t, fmt.Sprintf("m%d", sent))
+		sent++
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	first := e.next(t)
+	if waited := first.at.Sub(start); waited > 1100*time.Millisecond {
+		t.Errorf("first turn after %s, want about MaxWait (600ms)", waited)
+	}
+	if len(first.payloads) >= sent {
+		t.Fatalf("first turn took all %d messages, want MaxWait to cut it", sent)
+	}
+
+	got := len(first.payloads)
+	for got < sent {
+		got += len(e.next(t).payloads)
+	}
+	if got != sent {
+		t.Errorf("handled %d messages, want %d", got, sent)
+	}
+}
+
+// Повтор вебхука с тем же update_id в очередь не попадает.
+func TestInboundEnqueueDuplicate(t *testing.T) {
+	e := newInboundEnv(t, models.Debounce{}, nil)
+	ev := models.InboundEvent{Source: models.InboundTelegram, ExternalID: "42", ClientID: "TG-1", Payload: "привет"}
+
+	ctx := context.Background()
+	if ok, err := e.queue.Enqueue(ctx, ev); err != nil || !ok {
+		t.Fatalf("first Enqueue = %v, %v; want true", ok, err)
+	}
+	if ok, err := e.queue.Enqueue(ctx, ev); err != nil || ok {
+		t.Fatalf("duplicate Enqueue = %v, %v; want false", ok, err)
+	}
+	// Тот же ID в другом источнике — другое событие.
+	other := ev
+	other.Source = models.InboundWazzup
+	if ok, err := e.queue.Enqueue(ctx, other); err != nil || !ok {
+		t.Fatalf("Enqueue from another source = %v, %v; want true", ok, err)
+	}
+
+	e.run(t)
+	if c := e.next(t); len(c.payloads) != 1 {
+		t.Errorf("payloads = %v, want one", c.payloads)
+	}
+	e.none(t, 300*time.Millisecond)
+}
+
+// Сбой — повтор той же пачки; сообщение, пришедшее после сбоя, к ней не
+// приклеивается, а идёт следующим ходом. Последняя попытка — final.
+func TestInboundRetryKeepsBatch(t *testing.T) {
+	failures := 2
+	e := newInboundEnv(t, models.Debounce{Window: 100 * time.Millisecond, MaxWait: time.Second}, func(payloads []string) error {
+		if payloads[0] == "привет" && failures > 0 {
+			failures--
+			return errors.New("llm down")
+		}
+		return nil
+	})
+	e.queue.MaxAttempts = 3
+	e.run(t)
+
+	e.send(t, "привет")
+	if c := e.next(t); c.final {
+		t.Fatal("first attempt is final")
+	}
+	e.send(t, "ты тут?")
+
+	for i, wantFinal := range []bool{false, true} {
+		c := e.next(t)
+		if got := strings.Join(c.payloads, "|"); got != "привет" {
+			t.Fatalf("retry %d: payloads = %q, want the failed batch only", i+1, got)
+		}
+		if c.final != wantFinal {
+			t.Errorf("retry %d: final = %v, want %v", i+1, c.final, wantFinal)
+		}
+	}
+	if c := e.next(t); strings.Join(c.payloads, "|") != "ты тут?" {
+		t.Errorf("next turn = %v, want the new message", c.payloads)
+	}
+}
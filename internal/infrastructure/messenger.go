@@ -0,0 +1,73 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"whatsapp-analytics-mvp/internal/core"
+)
+
+// ============================================================================
+// CLIENT MESSENGER (ответ в канал клиента по префиксу clientID)
+// ============================================================================
+
+// ChannelRouter — отправляет сообщение клиенту туда, откуда он пришёл:
+// TG-<chatID> → Telegram, WA-<chatID> → Wazzup. Канал Wazzup берётся из
+// последнего входящего сообщения клиента, иначе — DefaultWazzupChannel.
+type ChannelRouter struct {
+	Telegram core.TelegramSender
+	Wazzup   core.WhatsAppSender
+
+	DefaultWazzupChannel string
+
+	mu       sync.RWMutex
+	channels map[string]string // WA chatID → channelId
+}
+
+func NewChannelRouter(telegram core.TelegramSender, wazzup core.WhatsAppSender, defaultWazzupChannel string) *ChannelRouter {
+	return &ChannelRouter{
+		Telegram:             telegram,
+		Wazzup:               wazzup,
+		DefaultWazzupChannel: defaultWazzupChannel,
+		channels:             map[string]string{},
+	}
+}
+
+// RememberWazzupChannel — запомнить канал, через который пишет WA-клиент.
+func (r *ChannelRouter) RememberWazzupChannel(chatID, channelID string) {
+	if channelID == "" {
+		return
+	}
+	r.mu.Lock()
+	r.channels[chatID] = channelID
+	r.mu.Unlock()
+}
+
+func (r *ChannelRouter) SendToClient(ctx context.Context, clientID, text string) error {
+	switch {
+	case strings.HasPrefix(clientID, "TG-"):
+		chatID, err := strconv.ParseInt(strings.TrimPrefix(clientID, "TG-"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("некорректный clientID %q", clientID)
+		}
+		return r.Telegram.Send(chatID, text)
+
+	case strings.HasPrefix(clientID, "WA-"):
+		chatID := strings.TrimPrefix(clientID, "WA-")
+		r.mu.RLock()
+		channelID, ok := r.channels[chatID]
+		r.mu.RUnlock()
+		if !ok {
+			channelID = r.DefaultWazzupChannel
+		}
+		if channelID == "" {
+			return fmt.Errorf("канал Wazzup для %s неизвестен", clientID)
+		}
+		return r.Wazzup.Send(channelID, chatID, text)
+	}
+
+	return fmt.Errorf("неизвестный канал клиента %q", clientID)
+}
@@ -0,0 +1,154 @@
+package infrastructure
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/core"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// ============================================================================
+// FAKE PAYMENT PROVIDER
+// ============================================================================
+
+// FakeSignatureHeader — заголовок с подписью уведомления фейкового провайдера:
+// hex(HMAC-SHA256(secret, body)). Так же подписывает свои уведомления Kaspi.
+const FakeSignatureHeader = "X-Signature"
+
+// FakePaymentProvider — локальный провайдер в стиле Kaspi: счета в памяти,
+// уведомления подписаны общим секретом. Для разработки и ручной проверки
+// webhook-а; оплату имитирует Pay.
+type FakePaymentProvider struct {
+	Secret  string
+	BaseURL string // куда ведёт payment_url
+
+	mu       sync.Mutex
+	seq      int
+	invoices map[string]*models.Invoice
+}
+
+func NewFakePaymentProvider(secret, baseURL string) *FakePaymentProvider {
+	if baseURL == "" {
+		baseURL = "https://pay.example.com"
+	}
+	return &FakePaymentProvider{
+		Secret:   secret,
+		BaseURL:  strings.TrimRight(baseURL, "/"),
+		invoices: map[string]*models.Invoice{},
+	}
+}
+
+func (p *FakePaymentProvider) Name() string { return "fake" }
+
+func (p *FakePaymentProvider) CreateInvoice(ctx context.Context, bookingID string, amount float64, description string) (*models.Invoice, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("некорректная сумма")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.seq++
+	id := fmt.Sprintf("inv_%d_%d", time.Now().Unix(), p.seq)
+	inv := &models.Invoice{
+		InvoiceID:  id,
+		Provider:   p.Name(),
+		BookingID:  bookingID,
+		Amount:     amount,
+		Status:     models.InvoicePending,
+		PaymentURL: fmt.Sprintf("%s/pay/%s?amount=%.0f", p.BaseURL, id, amount),
+		CreatedAt:  time.Now(),
+	}
+	p.invoices[id] = inv
+
+	cp := *inv
+	return &cp, nil
+}
+
+func (p *FakePaymentProvider) GetInvoiceStatus(ctx context.Context, invoiceID string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	inv, ok := p.invoices[invoiceID]
+	if !ok {
+		return "", fmt.Errorf("счёт %s не найден", invoiceID)
+	}
+	return inv.Status, nil
+}
+
+func (p *FakePaymentProvider) Refund(ctx context.Context, invoiceID string, amount float64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	inv, ok := p.invoices[invoiceID]
+	if !ok {
+		return fmt.Errorf("счёт %s не найден", invoiceID)
+	}
+	if inv.Status != models.InvoicePaid {
+		return fmt.Errorf("счёт %s не оплачен", invoiceID)
+	}
+	if amount > inv.Amount {
+		return fmt.Errorf("возврат больше суммы счёта")
+	}
+	inv.Status = models.InvoiceRefunded
+	return nil
+}
+
+// ParseCallback — проверяет X-Signature и разбирает тело models.PaymentCallback.
+func (p *FakePaymentProvider) ParseCallback(header http.Header, body []byte) (*models.PaymentCallback, error) {
+	got, err := hex.DecodeString(header.Get(FakeSignatureHeader))
+	if err != nil || !hmac.Equal(got, p.sign(body)) {
+		return nil, core.ErrBadSignature
+	}
+
+	var cb models.PaymentCallback
+	if err := json.Unmarshal(body, &cb); err != nil {
+		return nil, fmt.Errorf("некорректное уведомление: %w", err)
+	}
+	if cb.InvoiceID == "" {
+		return nil, fmt.Errorf("некорректное уведомление: нет invoice_id")
+	}
+	return &cb, nil
+}
+
+// Pay — имитирует оплату счёта клиентом. Возвращает тело уведомления и
+// подпись, которые провайдер отправил бы на /webhook/payments/fake.
+func (p *FakePaymentProvider) Pay(invoiceID string) ([]byte, string, error) {
+	p.mu.Lock()
+	inv, ok := p.invoices[invoiceID]
+	if ok {
+		inv.Status = models.InvoicePaid
+	}
+	p.mu.Unlock()
+	if !ok {
+		return nil, "", fmt.Errorf("счёт %s не найден", invoiceID)
+	}
+
+	body, err := json.Marshal(models.PaymentCallback{
+		InvoiceID: invoiceID, Status: models.InvoicePaid, Amount: inv.Amount,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return body, p.Sign(body), nil
+}
+
+// Sign — подпись тела уведомления (hex).
+func (p *FakePaymentProvider) Sign(body []byte) string {
+	return hex.EncodeToString(p.sign(body))
+}
+
+func (p *FakePaymentProvider) sign(body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(p.Secret))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,178 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/core"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// ============================================================================
+// TASK MANAGER (задания в SQLite)
+// ============================================================================
+
+// DBTaskManager — core.TaskManager поверх core.JobRepository. Run раз в
+// интервал забирает созревшие задания и выполняет их обработчиками вида.
+// Ошибка → повтор через BaseBackoff·2^(n-1) (не больше MaxBackoff), после
+// MaxAttempts попыток задание помечается failed.
+type DBTaskManager struct {
+	Repo core.JobRepository
+
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Lease       time.Duration // столько задание считается занятым исполнителем
+	Batch       int
+
+	mu       sync.RWMutex
+	handlers map[string]core.JobHandler
+}
+
+// Параметры по умолчанию.
+const (
+	defaultJobAttempts = 5
+	defaultJobBackoff  = 30 * time.Second
+	defaultJobMaxDelay = 30 * time.Minute
+	defaultJobLease    = 5 * time.Minute
+	defaultJobBatch    = 20
+)
+
+func NewDBTaskManager(repo core.JobRepository) *DBTaskManager {
+	return &DBTaskManager{
+		Repo:        repo,
+		MaxAttempts: defaultJobAttempts,
+		BaseBackoff: defaultJobBackoff,
+		MaxBackoff:  defaultJobMaxDelay,
+		Lease:       defaultJobLease,
+		Batch:       defaultJobBatch,
+		handlers:    map[string]core.JobHandler{},
+	}
+}
+
+func (m *DBTaskManager) Handle(kind string, h core.JobHandler) {
+	m.mu.Lock()
+	m.handlers[kind] = h
+	m.mu.Unlock()
+}
+
+func (m *DBTaskManager) Schedule(ctx context.Context, job models.ScheduledJob) (*models.ScheduledJob, error) {
+	if job.Kind == "" {
+		return nil, fmt.Errorf("не указан вид задания")
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = m.MaxAttempts
+	}
+	if err := m.Repo.ScheduleJob(ctx, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (m *DBTaskManager) Cancel(ctx context.Context, ref string, kinds ...string) (int, error) {
+	n, err := m.Repo.CancelJobs(ctx, ref, kinds...)
+	if n > 0 {
+		log.Printf("[TaskManager] cancelled %d job(s) for %s", n, ref)
+	}
+	return n, err
+}
+
+func (m *DBTaskManager) CancelJob(ctx context.Context, id int64) (bool, error) {
+	return m.Repo.CancelJob(ctx, id)
+}
+
+func (m *DBTaskManager) List(ctx context.Context, f models.JobFilter) ([]models.ScheduledJob, error) {
+	return m.Repo.ListJobs(ctx, f)
+}
+
+// -----------------------------------------------------------------------------
+// EXECUTION
+// -----------------------------------------------------------------------------
+
+// RunDue — выполнить всё созревшее к текущему моменту; возвращает, сколько
+// заданий взято в работу.
+func (m *DBTaskManager) RunDue(ctx context.Context) (int, error) {
+	now := availability.Now()
+	jobs, err := m.Repo.ClaimDueJobs(ctx, now, m.Lease, m.Batch)
+	if err != nil {
+		return 0, err
+	}
+	for _, job := range jobs {
+		m.run(ctx, job)
+	}
+	return len(jobs), nil
+}
+
+func (m *DBTaskManager) run(ctx context.Context, job models.ScheduledJob) {
+	m.mu.RLock()
+	h, ok := m.handlers[job.Kind]
+	m.mu.RUnlock()
+
+	var err error
+	if !ok {
+		err = fmt.Errorf("нет обработчика для %q", job.Kind)
+	} else {
+		err = safeRun(ctx, h, job)
+	}
+
+	status, lastErr, retryAt := models.JobDone, "", time.Time{}
+	if err != nil {
+		lastErr = err.Error()
+		if job.Attempts >= job.MaxAttempts {
+			status = models.JobFailed
+			log.Printf("[TaskManager] ❌ %s #%d failed after %d attempts: %v", job.Kind, job.ID, job.Attempts, err)
+		} else {
+			status, retryAt = models.JobPending, availability.Now().Add(backoffDelay(m.BaseBackoff, m.MaxBackoff, job.Attempts))
+			log.Printf("[TaskManager] %s #%d attempt %d: %v (retry at %s)", job.Kind, job.ID, job.Attempts, err, retryAt.Format("15:04:05"))
+		}
+	}
+
+	if err := m.Repo.FinishJob(ctx, job.ID, status, lastErr, retryAt); err != nil {
+		// Аренда истечёт, и задание возьмут повторно
+		log.Printf("[TaskManager] finish #%d: %v", job.ID, err)
+	}
+}
+
+// safeRun — паника обработчика считается ошибкой попытки.
+func safeRun(ctx context.Context, h core.JobHandler, job models.ScheduledJob) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return h(ctx, job)
+}
+
+// backoffDelay — пауза перед повтором после попытки attempt:
+// base·2^(attempt-1), не больше max.
+func backoffDelay(base, max time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// Run — опрашивает очередь до отмены ctx.
+func (m *DBTaskManager) Run(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		if _, err := m.RunDue(ctx); err != nil {
+			log.Printf("[TaskManager] %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
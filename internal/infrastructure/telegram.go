@@ -0,0 +1,442 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// ============================================================================
+// TELEGRAM SENDER (Bot API)
+// ============================================================================
+
+// Лимиты Bot API.
+const (
+	DefaultTelegramBaseURL = "https://api.telegram.org"
+	telegramMaxMessage     = 4096 // символов в одном sendMessage
+)
+
+// Режимы разметки sendMessage.
+const (
+	TelegramPlain      = ""
+	TelegramMarkdownV2 = "MarkdownV2"
+	TelegramHTML       = "HTML"
+)
+
+// TelegramSender — клиент Bot API: sendMessage, sendChatAction, getFile.
+// Текст ответа бота — обычный текст: в режиме ParseMode он экранируется,
+// чтобы символы разметки дошли как есть, и режется на части по 4096.
+type TelegramSender struct {
+	Token     string
+	BaseURL   string // без завершающего «/»; тесты подставляют локальный сервер
+	ParseMode string // TelegramPlain | TelegramMarkdownV2 | TelegramHTML
+	Client    *http.Client
+
+	// 429 Too Many Requests: ждать retry_after и повторить, не больше
+	// MaxRetries раз и не дольше MaxRetryWait за раз.
+	MaxRetries   int
+	MaxRetryWait time.Duration
+}
+
+func NewTelegramSender(token string) *TelegramSender {
+	return &TelegramSender{
+		Token:        token,
+		BaseURL:      DefaultTelegramBaseURL,
+		Client:       &http.Client{Timeout: 10 * time.Second},
+		MaxRetries:   3,
+		MaxRetryWait: 30 * time.Second,
+	}
+}
+
+// TelegramAPIError — ответ Bot API с ok=false.
+type TelegramAPIError struct {
+	Method      string
+	Code        int
+	Description string
+	RetryAfter  time.Duration // только для 429
+}
+
+func (e *TelegramAPIError) Error() string {
+	msg := fmt.Sprintf("telegram %s: %d %s", e.Method, e.Code, e.Description)
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf(" (retry after %s)", e.RetryAfter)
+	}
+	return msg
+}
+
+// telegramResponse — общий конверт ответа Bot API.
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+	Parameters  *struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+func (t *TelegramSender) Send(chatID int64, text string) error {
+	return t.SendKeyboard(chatID, text, nil)
+}
+
+// SendKeyboard — сообщение с inline-кнопками под последней частью текста.
+func (t *TelegramSender) SendKeyboard(chatID int64, text string, kb models.Keyboard) error {
+	if t.Token == "" {
+		log.Println("[TelegramSender] ⚠️ Token not set — message skipped")
+		return nil
+	}
+
+	ctx := context.Background()
+	parts := SplitTelegramText(text, telegramMaxMessage)
+	for i, part := range parts {
+		params := t.textParams(part)
+		params["chat_id"] = chatID
+		if i == len(parts)-1 && len(kb) > 0 {
+			params["reply_markup"] = inlineKeyboard(kb)
+		}
+		if err := t.call(ctx, "sendMessage", params, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EditMessage — заменить текст и кнопки отправленного сообщения (kb = nil
+// убирает кнопки).
+func (t *TelegramSender) EditMessage(chatID, messageID int64, text string, kb models.Keyboard) error {
+	if t.Token == "" {
+		return nil
+	}
+	params := t.textParams(firstPart(text))
+	params["chat_id"] = chatID
+	params["message_id"] = messageID
+	params["reply_markup"] = inlineKeyboard(kb)
+	return t.call(context.Background(), "editMessageText", params, nil)
+}
+
+// AnswerCallback — подтвердить нажатие кнопки (иначе у клиента крутятся
+// часики); text — всплывающая подсказка, можно пусто.
+func (t *TelegramSender) AnswerCallback(callbackID, text string) error {
+	if t.Token == "" {
+		return nil
+	}
+	params := map[string]interface{}{"callback_query_id": callbackID}
+	if text != "" {
+		params["text"] = text
+	}
+	return t.call(context.Background(), "answerCallbackQuery", params, nil)
+}
+
+func (t *TelegramSender) SendTyping(chatID int64) error {
+	if t.Token == "" {
+		return nil
+	}
+	return t.call(context.Background(), "sendChatAction", map[string]interface{}{
+		"chat_id": chatID,
+		"action":  "typing",
+	}, nil)
+}
+
+// GetFileDirectURL — getFile даёт file_path, по нему файл скачивается
+// (ссылка живёт не меньше часа).
+func (t *TelegramSender) GetFileDirectURL(fileID string) (string, error) {
+	if fileID == "" {
+		return "", fmt.Errorf("fileID пустой")
+	}
+	if t.Token == "" {
+		return "", fmt.Errorf("telegram token не задан")
+	}
+
+	var file struct {
+		FilePath string `json:"file_path"`
+	}
+	if err := t.call(context.Background(), "getFile", map[string]interface{}{"file_id": fileID}, &file); err != nil {
+		return "", err
+	}
+	if file.FilePath == "" {
+		return "", fmt.Errorf("telegram getFile: файл %s недоступен для скачивания", fileID)
+	}
+	return fmt.Sprintf("%s/file/bot%s/%s", t.baseURL(), t.Token, file.FilePath), nil
+}
+
+// call — POST метода Bot API с JSON-параметрами; result (если не nil)
+// заполняется полем result ответа.
+func (t *TelegramSender) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	return t.callWith(ctx, t.Client, method, params, result)
+}
+
+func (t *TelegramSender) callWith(ctx context.Context, client *http.Client, method string, params interface{}, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := t.do(ctx, client, method, body, result)
+		apiErr, ok := err.(*TelegramAPIError)
+		if !ok || apiErr.RetryAfter <= 0 || attempt >= t.MaxRetries {
+			return err
+		}
+
+		wait := apiErr.RetryAfter
+		if t.MaxRetryWait > 0 && wait > t.MaxRetryWait {
+			return err // ждать дольше нельзя — пусть повторит вызывающий
+		}
+		log.Printf("[TelegramSender] %s: flood limit, retry in %s", method, wait)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (t *TelegramSender) do(ctx context.Context, client *http.Client, method string, body []byte, result interface{}) error {
+	url := fmt.Sprintf("%s/bot%s/%s", t.baseURL(), t.Token, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// В тексте ошибки net/http есть URL с токеном
+		return fmt.Errorf("telegram %s: %w", method, redactToken(err, t.Token))
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("telegram %s: %w", method, err)
+	}
+
+	var tr telegramResponse
+	if err := json.Unmarshal(raw, &tr); err != nil {
+		return &TelegramAPIError{Method: method, Code: resp.StatusCode, Description: strings.TrimSpace(string(raw))}
+	}
+	if !tr.OK {
+		apiErr := &TelegramAPIError{Method: method, Code: tr.ErrorCode, Description: tr.Description}
+		if apiErr.Code == 0 {
+			apiErr.Code = resp.StatusCode
+		}
+		if tr.Parameters != nil && tr.Parameters.RetryAfter > 0 {
+			apiErr.RetryAfter = time.Duration(tr.Parameters.RetryAfter) * time.Second
+		}
+		return apiErr
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(tr.Result, result); err != nil {
+			return fmt.Errorf("telegram %s: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// UPDATES & WEBHOOK
+// -----------------------------------------------------------------------------
+
+// telegramAllowedUpdates — какие обновления просим у Telegram.
+var telegramAllowedUpdates = []string{"message", "callback_query"}
+
+// TelegramWebhookInfo — ответ getWebhookInfo (нужные поля).
+type TelegramWebhookInfo struct {
+	URL                  string `json:"url"`
+	PendingUpdateCount   int    `json:"pending_update_count"`
+	LastErrorDate        int64  `json:"last_error_date,omitempty"`
+	LastErrorMessage     string `json:"last_error_message,omitempty"`
+	HasCustomCertificate bool   `json:"has_custom_certificate"`
+}
+
+// GetUpdates — long polling: ждёт до timeout новых обновлений начиная
+// с offset (offset подтверждает всё, что до него). Обновления — сырой
+// JSON: разбирает тот же код, что и вебхук.
+func (t *TelegramSender) GetUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]json.RawMessage, error) {
+	// Обычный таймаут клиента короче long polling
+	client := t.Client
+	if client.Timeout > 0 && client.Timeout <= timeout {
+		c := *client
+		c.Timeout = timeout + 10*time.Second
+		client = &c
+	}
+
+	var updates []json.RawMessage
+	err := t.callWith(ctx, client, "getUpdates", map[string]interface{}{
+		"offset":          offset,
+		"timeout":         int(timeout / time.Second),
+		"allowed_updates": telegramAllowedUpdates,
+	}, &updates)
+	return updates, err
+}
+
+// SetWebhook — Telegram шлёт обновления на url; secret приходит
+// в X-Telegram-Bot-Api-Secret-Token.
+func (t *TelegramSender) SetWebhook(ctx context.Context, url, secret string, dropPending bool) error {
+	params := map[string]interface{}{
+		"url":                  url,
+		"drop_pending_updates": dropPending,
+		"allowed_updates":      telegramAllowedUpdates,
+	}
+	if secret != "" {
+		params["secret_token"] = secret
+	}
+	return t.call(ctx, "setWebhook", params, nil)
+}
+
+// DeleteWebhook — снять вебхук (без этого getUpdates отвечает 409).
+func (t *TelegramSender) DeleteWebhook(ctx context.Context, dropPending bool) error {
+	return t.call(ctx, "deleteWebhook", map[string]interface{}{"drop_pending_updates": dropPending}, nil)
+}
+
+func (t *TelegramSender) GetWebhookInfo(ctx context.Context) (TelegramWebhookInfo, error) {
+	var info TelegramWebhookInfo
+	err := t.call(ctx, "getWebhookInfo", map[string]interface{}{}, &info)
+	return info, err
+}
+
+// GetMe — username бота (проверка токена при старте).
+func (t *TelegramSender) GetMe(ctx context.Context) (string, error) {
+	var me struct {
+		Username string `json:"username"`
+	}
+	err := t.call(ctx, "getMe", map[string]interface{}{}, &me)
+	return me.Username, err
+}
+
+func (t *TelegramSender) baseURL() string {
+	if t.BaseURL == "" {
+		return DefaultTelegramBaseURL
+	}
+	return strings.TrimRight(t.BaseURL, "/")
+}
+
+func (t *TelegramSender) textParams(text string) map[string]interface{} {
+	params := map[string]interface{}{"text": t.escape(text)}
+	if t.ParseMode != TelegramPlain {
+		params["parse_mode"] = t.ParseMode
+	}
+	return params
+}
+
+// inlineKeyboard — reply_markup для models.Keyboard; пустая — без кнопок.
+func inlineKeyboard(kb models.Keyboard) map[string]interface{} {
+	type button struct {
+		Text         string `json:"text"`
+		CallbackData string `json:"callback_data"`
+	}
+	rows := [][]button{}
+	for _, row := range kb {
+		var r []button
+		for _, b := range row {
+			r = append(r, button{Text: b.Text, CallbackData: b.Data})
+		}
+		rows = append(rows, r)
+	}
+	return map[string]interface{}{"inline_keyboard": rows}
+}
+
+func firstPart(text string) string {
+	if parts := SplitTelegramText(text, telegramMaxMessage); len(parts) > 0 {
+		return parts[0]
+	}
+	return text
+}
+
+func (t *TelegramSender) escape(text string) string {
+	switch t.ParseMode {
+	case TelegramMarkdownV2:
+		return EscapeMarkdownV2(text)
+	case TelegramHTML:
+		return EscapeTelegramHTML(text)
+	}
+	return text
+}
+
+func redactToken(err error, token string) error {
+	if token == "" {
+		return err
+	}
+	return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), token, "***"))
+}
+
+// -----------------------------------------------------------------------------
+// TEXT HELPERS
+// -----------------------------------------------------------------------------
+
+// EscapeMarkdownV2 — экранирует все спецсимволы MarkdownV2: текст
+// показывается буквально.
+func EscapeMarkdownV2(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if strings.ContainsRune("_*[]()~`>#+-=|{}.!\\", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// EscapeTelegramHTML — экранирует &, < и > для parse_mode=HTML.
+func EscapeTelegramHTML(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// SplitTelegramText — режет текст на части не длиннее limit символов
+// (Telegram считает в UTF-16: эмодзи — два): по возможности по абзацу,
+// затем по строке, затем по пробелу. Пустой текст — ни одной части.
+func SplitTelegramText(text string, limit int) []string {
+	var parts []string
+	for {
+		n := utf16Prefix(text, limit)
+		if n == len(text) {
+			break
+		}
+		if n == 0 {
+			n = len(string([]rune(text)[:1])) // limit меньше одного символа
+		}
+		head := text[:n]
+		cut := len(head)
+		for _, sep := range []string{"\n\n", "\n", " "} {
+			if i := strings.LastIndex(head, sep); i > len(head)/2 {
+				cut = i + len(sep)
+				break
+			}
+		}
+		if part := strings.TrimRight(text[:cut], " \n"); part != "" {
+			parts = append(parts, part)
+		}
+		text = strings.TrimLeft(text[cut:], " \n")
+	}
+	if text = strings.TrimSpace(text); text != "" {
+		parts = append(parts, text)
+	}
+	return parts
+}
+
+// utf16Prefix — длина в байтах самого длинного префикса s не длиннее
+// limit единиц UTF-16.
+func utf16Prefix(s string, limit int) int {
+	units := 0
+	for i, r := range s {
+		w := 1
+		if r > 0xFFFF {
+			w = 2 // суррогатная пара
+		}
+		if units+w > limit {
+			return i
+		}
+		units += w
+	}
+	return len(s)
+}
@@ -0,0 +1,154 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/core"
+)
+
+// ============================================================================
+// TELEGRAM LONG POLLING
+// ============================================================================
+
+// Режимы приёма обновлений Telegram (telegram.mode).
+const (
+	TelegramModeWebhook = "webhook"
+	TelegramModePolling = "polling"
+)
+
+// TelegramPoller — приём обновлений через getUpdates вместо вебхука.
+// Каждое обновление отдаётся Handle (тот же приём, что у вебхука), offset
+// сохраняется после пачки: после перезапуска чтение продолжается с места
+// остановки. Handle вернул ошибку — offset на этом обновлении, оно придёт
+// ещё раз.
+type TelegramPoller struct {
+	Bot     *TelegramSender
+	Offsets core.TelegramOffsetRepository
+	Handle  func(ctx context.Context, update []byte) error
+
+	Timeout    time.Duration // сколько getUpdates ждёт новых обновлений
+	MaxBackoff time.Duration // пауза после ошибок растёт до этого значения
+}
+
+func NewTelegramPoller(bot *TelegramSender, offsets core.TelegramOffsetRepository, handle func(ctx context.Context, update []byte) error) *TelegramPoller {
+	return &TelegramPoller{
+		Bot:        bot,
+		Offsets:    offsets,
+		Handle:     handle,
+		Timeout:    30 * time.Second,
+		MaxBackoff: time.Minute,
+	}
+}
+
+// Run — опрашивает Telegram до отмены ctx. Вебхук должен быть снят
+// (DeleteWebhook), иначе getUpdates отвечает 409.
+func (p *TelegramPoller) Run(ctx context.Context) {
+	offset, err := p.Offsets.GetTelegramOffset(ctx)
+	if err != nil {
+		log.Printf("[TelegramPoller] ⚠️ offset: %v — читаю с начала очереди", err)
+	}
+	log.Printf("[TelegramPoller] started, offset %d", offset)
+
+	failures := 0
+	for ctx.Err() == nil {
+		next, err := p.Poll(ctx, offset)
+		offset = next
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			failures++
+			wait := backoffDelay(time.Second, p.MaxBackoff, failures)
+			log.Printf("[TelegramPoller] %v (retry in %s)", err, wait)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+			continue
+		}
+		failures = 0
+	}
+}
+
+// Poll — один getUpdates: обработать пачку и сохранить offset. Возвращает
+// offset для следующего вызова.
+func (p *TelegramPoller) Poll(ctx context.Context, offset int64) (int64, error) {
+	updates, err := p.Bot.GetUpdates(ctx, offset, p.Timeout)
+	if err != nil {
+		return offset, err
+	}
+	if len(updates) == 0 {
+		return offset, nil
+	}
+
+	next := offset
+	var handleErr error
+	for _, raw := range updates {
+		var head struct {
+			UpdateID int64 `json:"update_id"`
+		}
+		if err := json.Unmarshal(raw, &head); err != nil {
+			log.Printf("[TelegramPoller] skip malformed update: %v", err)
+			continue
+		}
+		if head.UpdateID < next {
+			continue // уже обработано до перезапуска
+		}
+		if err := p.Handle(ctx, raw); err != nil {
+			handleErr = fmt.Errorf("update %d: %w", head.UpdateID, err)
+			break
+		}
+		next = head.UpdateID + 1
+	}
+
+	if next != offset {
+		if err := p.Offsets.SaveTelegramOffset(ctx, next); err != nil {
+			// Telegram отдаст пачку ещё раз только если процесс перезапустится
+			return next, fmt.Errorf("save offset %d: %w", next, err)
+		}
+	}
+	return next, handleErr
+}
+
+// SetupTelegramDelivery — режим приёма при старте. webhook: зарегистрировать
+// url (если задан) и показать состояние вебхука; polling: снять вебхук,
+// чтобы getUpdates заработал.
+func SetupTelegramDelivery(ctx context.Context, bot *TelegramSender, mode, url, secret string, dropPending bool) error {
+	name, err := bot.GetMe(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("[Telegram] bot @%s, mode %s", name, mode)
+
+	switch mode {
+	case TelegramModePolling:
+		return bot.DeleteWebhook(ctx, dropPending)
+
+	case TelegramModeWebhook, "":
+		if url != "" {
+			if err := bot.SetWebhook(ctx, url, secret, dropPending); err != nil {
+				return err
+			}
+		}
+		info, err := bot.GetWebhookInfo(ctx)
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.URL == "":
+			log.Println("[Telegram] ⚠️ webhook не зарегистрирован: задайте telegram.webhook_url или mode: polling")
+		case info.LastErrorMessage != "":
+			log.Printf("[Telegram] webhook %s, pending %d, last error: %s (%s)", info.URL, info.PendingUpdateCount,
+				info.LastErrorMessage, time.Unix(info.LastErrorDate, 0).Format("02.01 15:04"))
+		default:
+			log.Printf("[Telegram] webhook %s, pending %d", info.URL, info.PendingUpdateCount)
+		}
+		return nil
+	}
+	return fmt.Errorf("неизвестный режим telegram.mode: %q", mode)
+}
@@ -0,0 +1,300 @@
+package infrastructure_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"whatsapp-analytics-mvp/internal/data"
+	"whatsapp-analytics-mvp/internal/infrastructure"
+)
+
+const testBotToken = "123456:TEST-token"
+
+// botAPI — поддельный Bot API: ответы по методам по очереди (последний
+// повторяется), запросы запоминаются.
+type botAPI struct {
+	mu        sync.Mutex
+	responses map[string][]string
+	calls     []botCall
+}
+
+type botCall struct {
+	method string
+	params map[string]interface{}
+}
+
+func newBotAPI(t *testing.T) (*botAPI, *httptest.Server, *infrastructure.TelegramSender) {
+	t.Helper()
+	api := &botAPI{responses: map[string][]string{}}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	bot := infrastructure.NewTelegramSender(testBotToken)
+	bot.BaseURL = srv.URL
+	return api, srv, bot
+}
+
+func (a *botAPI) on(method string, responses ...string) {
+	a.mu.Lock()
+	a.responses[method] = append(a.responses[method], responses...)
+	a.mu.Unlock()
+}
+
+func (a *botAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	prefix := "/bot" + testBotToken + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.Error(w, `{"ok":false,"error_code":401,"description":"Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	method := strings.TrimPrefix(r.URL.Path, prefix)
+	var params map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&params)
+
+	a.mu.Lock()
+	a.calls = append(a.calls, botCall{method: method, params: params})
+	resp := `{"ok":true,"result":true}`
+	if queue := a.responses[method]; len(queue) > 0 {
+		resp = queue[0]
+		if len(queue) > 1 {
+			a.responses[method] = queue[1:]
+		}
+	}
+	a.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(resp))
+}
+
+func (a *botAPI) called(method string) []botCall {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var out []botCall
+	for _, c := range a.calls {
+		if c.method == method {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func utf16Len(s string) int { return len(utf16.Encode([]rune(s))) }
+
+func TestSplitTelegramText(t *testing.T) {
+	const limit = 4096
+	tests := []struct {
+		name  string
+		text  string
+		parts []string // nil — проверяются только лимит и состав
+		count int
+	}{
+		{name: "empty", text: "", count: 0},
+		{name: "fits", text: "Привет!", parts: []string{"Привет!"}},
+		{name: "exactly limit", text: strings.Repeat("я", limit), count: 1},
+		{
+			// Эмодзи на 4096-й единице не влезает целиком — уходит в
+			// следующую часть, суррогатная пара не разрывается.
+			name:  "surrogate pair at limit",
+			text:  strings.Repeat("a", limit-1) + "😀b",
+			parts: []string{strings.Repeat("a", limit-1), "😀b"},
+		},
+		{
+			name:  "emoji pair fills limit",
+			text:  strings.Repeat("a", limit-2) + "😀" + "b",
+			parts: []string{strings.Repeat("a", limit-2) + "😀", "b"},
+		},
+		{name: "only emoji", text: strings.Repeat("😀", 3000), count: 2},
+		{
+			name:  "paragraph boundary",
+			text:  strings.Repeat("a", 3000) + "\n\n" + strings.Repeat("b", 3000),
+			parts: []string{strings.Repeat("a", 3000), strings.Repeat("b", 3000)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := infrastructure.SplitTelegramText(tt.text, limit)
+			if tt.parts != nil {
+				if len(got) != len(tt.parts) {
+					t.Fatalf("parts = %d, want %d", len(got), len(tt.parts))
+				}
+				for i := range got {
+					if got[i] != tt.parts[i] {
+						t.Errorf("part %d: %d units, want %d", i, utf16Len(got[i]), utf16Len(tt.parts[i]))
+					}
+				}
+			} else if len(got) != tt.count {
+				t.Fatalf("parts = %d, want %d", len(got), tt.count)
+			}
+			for i, p := range got {
+				if n := utf16Len(p); n > limit {
+					t.Errorf("part %d: %d UTF-16 units > %d", i, n, limit)
+				}
+				if strings.ContainsRune(p, utf8.RuneError) {
+					t.Errorf("part %d: broken character", i)
+				}
+			}
+			if !strings.ContainsAny(tt.text, " \n") && strings.Join(got, "") != tt.text {
+				t.Error("parts do not add up to the text")
+			}
+		})
+	}
+}
+
+func TestTelegramSendRetriesAfterFloodLimit(t *testing.T) {
+	api, _, bot := newBotAPI(t)
+	api.on("sendMessage",
+		`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`,
+		`{"ok":true,"result":{"message_id":1}}`)
+
+	start := time.Now()
+	if err := bot.Send(42, "hello"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if calls := api.called("sendMessage"); len(calls) != 2 {
+		t.Fatalf("sendMessage calls = %d, want 2", len(calls))
+	}
+	if waited := time.Since(start); waited < time.Second {
+		t.Errorf("retried after %s, want at least retry_after (1s)", waited)
+	}
+}
+
+// retry_after длиннее MaxRetryWait — ошибка сразу, повтор за вызывающим.
+func TestTelegramFloodLimitTooLong(t *testing.T) {
+	api, _, bot := newBotAPI(t)
+	bot.MaxRetryWait = time.Second
+	api.on("sendMessage", `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 60","parameters":{"retry_after":60}}`)
+
+	err := bot.Send(42, "hello")
+	var apiErr *infrastructure.TelegramAPIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 429 || apiErr.RetryAfter != time.Minute {
+		t.Fatalf("err = %v, want 429 with retry after 1m", err)
+	}
+	if calls := api.called("sendMessage"); len(calls) != 1 {
+		t.Errorf("sendMessage calls = %d, want 1", len(calls))
+	}
+}
+
+func TestTelegramGetFileDirectURL(t *testing.T) {
+	api, srv, bot := newBotAPI(t)
+	api.on("getFile",
+		`{"ok":true,"result":{"file_id":"F1","file_size":1024,"file_path":"voice/file_7.oga"}}`,
+		`{"ok":true,"result":{"file_id":"F2"}}`)
+
+	url, err := bot.GetFileDirectURL("F1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := srv.URL + "/file/bot" + testBotToken + "/voice/file_7.oga"; url != want {
+		t.Errorf("url = %q, want %q", url, want)
+	}
+	if calls := api.called("getFile"); len(calls) != 1 || calls[0].params["file_id"] != "F1" {
+		t.Errorf("getFile calls = %+v, want file_id F1", calls)
+	}
+
+	// Без file_path (файл больше 20 МБ) скачать нельзя.
+	if _, err := bot.GetFileDirectURL("F2"); err == nil {
+		t.Error("GetFileDirectURL without file_path: want error")
+	}
+}
+
+// Ошибка сети содержит URL запроса — токена в ней быть не должно.
+func TestTelegramErrorsRedactToken(t *testing.T) {
+	_, srv, bot := newBotAPI(t)
+	srv.Close()
+
+	err := bot.Send(42, "hello")
+	if err == nil {
+		t.Fatal("Send to a closed server: want error")
+	}
+	if strings.Contains(err.Error(), testBotToken) {
+		t.Errorf("error leaks token: %v", err)
+	}
+	if !strings.Contains(err.Error(), "***") {
+		t.Errorf("error = %v, want redacted URL", err)
+	}
+}
+
+// Offset сохраняется после пачки; обновление с ошибкой обработки придёт
+// снова, и новый поллер продолжит с него же.
+func TestTelegramPollerSavesAndResumesOffset(t *testing.T) {
+	api, _, bot := newBotAPI(t)
+	repo, err := data.NewSQLiteContextRepo(filepath.Join(t.TempDir(), "poller.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.DB.Close() })
+
+	batch := func(ids ...int64) string {
+		var list []string
+		for _, id := range ids {
+			list = append(list, fmt.Sprintf(`{"update_id":%d,"message":{"text":"u%d"}}`, id, id))
+		}
+		return `{"ok":true,"result":[` + strings.Join(list, ",") + `]}`
+	}
+	api.on("getUpdates", batch(10, 11, 12), batch(12, 13))
+
+	var handled []int64
+	failOn := int64(12)
+	handle := func(ctx context.Context, raw []byte) error {
+		var u struct {
+			UpdateID int64 `json:"update_id"`
+		}
+		_ = json.Unmarshal(raw, &u)
+		if u.UpdateID == failOn {
+			return errors.New("transient")
+		}
+		handled = append(handled, u.UpdateID)
+		return nil
+	}
+
+	ctx := context.Background()
+	p := infrastructure.NewTelegramPoller(bot, repo, handle)
+	next, err := p.Poll(ctx, 0)
+	if err == nil {
+		t.Fatal("Poll: want handler error for update 12")
+	}
+	if next != 12 {
+		t.Fatalf("next offset = %d, want 12", next)
+	}
+	if saved, _ := repo.GetTelegramOffset(ctx); saved != 12 {
+		t.Fatalf("saved offset = %d, want 12", saved)
+	}
+
+	// «Перезапуск»: новый поллер читает offset из базы.
+	failOn = 0
+	p = infrastructure.NewTelegramPoller(bot, repo, handle)
+	offset, err := repo.GetTelegramOffset(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next, err = p.Poll(ctx, offset); err != nil || next != 14 {
+		t.Fatalf("Poll after restart = %d, %v; want 14", next, err)
+	}
+	if saved, _ := repo.GetTelegramOffset(ctx); saved != 14 {
+		t.Errorf("saved offset = %d, want 14", saved)
+	}
+	if fmt.Sprint(handled) != "[10 11 12 13]" {
+		t.Errorf("handled = %v, want [10 11 12 13]", handled)
+	}
+
+	calls := api.called("getUpdates")
+	if len(calls) != 2 {
+		t.Fatalf("getUpdates calls = %d, want 2", len(calls))
+	}
+	for i, want := range []float64{0, 12} {
+		if got := calls[i].params["offset"]; got != want {
+			t.Errorf("getUpdates #%d offset = %v, want %v", i+1, got, want)
+		}
+	}
+}
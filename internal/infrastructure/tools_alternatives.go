@@ -0,0 +1,103 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+)
+
+// Поиск альтернатив: сколько вариантов по умолчанию и сколько соседних
+// дней смотреть.
+const (
+	defaultAlternatives = 5
+	alternativeDays     = 3
+)
+
+// -----------------------------------------------------------------------------
+// Альтернативы занятому слоту (с ценами)
+// -----------------------------------------------------------------------------
+
+func (s *ToolsService) SuggestAlternatives(ctx context.Context, clientID, date, timeStr string, seats, hours int, game, promoCode string, limit int) (string, error) {
+	if err := s.checkSeats(ctx, seats); err != nil {
+		return "", err
+	}
+	if hours <= 0 || hours > availability.MaxHours {
+		return "", fmt.Errorf("часы: 1–%d", availability.MaxHours)
+	}
+	if limit <= 0 {
+		limit = defaultAlternatives
+	}
+
+	start, err := parseStart(date, timeStr, hours)
+	if err != nil {
+		return "", err
+	}
+
+	alts, err := s.alternatives(ctx, start, seats, hours, game, limit)
+	if err != nil {
+		return "", err
+	}
+	if len(alts) == 0 {
+		return fmt.Sprintf("Рядом с %s свободных вариантов на %d мест нет. Можно встать в лист ожидания (JoinWaitlist)",
+			start.Format("02.01 15:04"), seats), nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Вместо %s (мест — %d, часов — %d) свободно:\n", start.Format("02.01 15:04"), seats, hours)
+	for i, alt := range alts {
+		fmt.Fprintf(&b, "%d) %s\n", i+1, s.formatAlternative(ctx, clientID, alt, hours, promoCode))
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// alternatives — станции и брони на соседние дни одним запросом, подбор в памяти.
+func (s *ToolsService) alternatives(ctx context.Context, start time.Time, seats, hours int, game string, limit int) ([]availability.Alternative, error) {
+	rigs, err := s.DB.ListRigs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	day := availability.BusinessDay(start)
+	from := day.AddDate(0, 0, -alternativeDays)
+	to := day.AddDate(0, 0, alternativeDays+1)
+	bookings, err := s.DB.GetBookingsBetween(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return availability.Alternatives(availability.AltRequest{
+		Start: start,
+		Seats: seats,
+		Hours: hours,
+		Game:  game,
+		Days:  alternativeDays,
+	}, rigs, bookings, availability.Now(), limit), nil
+}
+
+// formatAlternative — "19.10 19:00 — 16000 тг" или для деления группы
+// "2 места в 19:00 + 2 места в 20:00 (18.10) — 16500 тг".
+func (s *ToolsService) formatAlternative(ctx context.Context, clientID string, alt availability.Alternative, hours int, promoCode string) string {
+	total := 0.0
+	for _, p := range alt.Parts {
+		q, _, err := s.quote(ctx, clientID, p.Start, p.Seats, hours, promoCode)
+		if err != nil {
+			// Промокод не подошёл — цена без него, как в GetPrice без кода
+			q, _, _ = s.quote(ctx, clientID, p.Start, p.Seats, hours, "")
+		}
+		total += q.Total
+	}
+
+	if alt.Kind != availability.AltSplit {
+		return fmt.Sprintf("%s — %.0f %s", alt.Parts[0].Start.Format("02.01 15:04"), total, s.Tariff.Currency)
+	}
+
+	parts := make([]string, 0, len(alt.Parts))
+	for _, p := range alt.Parts {
+		parts = append(parts, fmt.Sprintf("мест — %d в %s", p.Seats, p.Start.Format("15:04")))
+	}
+	return fmt.Sprintf("разделиться: %s (%s) — %.0f %s",
+		strings.Join(parts, " + "), alt.Parts[0].Start.Format("02.01"), total, s.Tariff.Currency)
+}
@@ -0,0 +1,155 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/core"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// Турниры и мероприятия (клиент)
+// -----------------------------------------------------------------------------
+
+func (s *ToolsService) ListEvents(ctx context.Context, clientID string) (string, error) {
+	repo, err := s.events()
+	if err != nil {
+		return "", err
+	}
+
+	events, err := repo.ListUpcomingEvents(ctx, availability.Now())
+	if err != nil {
+		return "", err
+	}
+	if len(events) == 0 {
+		return "Ближайших турниров и мероприятий нет.", nil
+	}
+
+	mine, err := s.clientEventIDs(ctx, repo, clientID)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("Ближайшие мероприятия:\n")
+	for _, e := range events {
+		line := "- " + formatEvent(e, s.Tariff.Currency)
+		if mine[e.ID] {
+			line += " — вы зарегистрированы"
+		}
+		b.WriteString(line + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+func (s *ToolsService) RegisterForEvent(ctx context.Context, clientID string, eventID int64, name string) (string, error) {
+	repo, err := s.events()
+	if err != nil {
+		return "", err
+	}
+	if eventID <= 0 {
+		return "", fmt.Errorf("не указан номер мероприятия — уточните через ListEvents")
+	}
+
+	e, err := repo.RegisterForEvent(ctx, &models.EventRegistration{
+		EventID:  eventID,
+		ClientID: clientID,
+		Name:     strings.TrimSpace(name),
+	})
+	if err != nil {
+		return "", err
+	}
+	log.Printf("🏁 Event #%d: %s registered (%d/%d)", e.ID, clientID, e.Registered, e.MaxParticipants)
+
+	out := fmt.Sprintf("Вы зарегистрированы на «%s», %s. Участников: %d из %d.",
+		e.Title, e.Start.Format("02.01 15:04"), e.Registered, e.MaxParticipants)
+	if e.EntryFee > 0 {
+		out += fmt.Sprintf(" Взнос %.0f %s — оплата в клубе до старта.", e.EntryFee, s.Tariff.Currency)
+	}
+	return out, nil
+}
+
+func (s *ToolsService) CancelEventRegistration(ctx context.Context, clientID string, eventID int64) (string, error) {
+	repo, err := s.events()
+	if err != nil {
+		return "", err
+	}
+
+	e, err := repo.CancelEventRegistration(ctx, eventID, clientID)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("🏁 Event #%d: %s left (%d/%d)", e.ID, clientID, e.Registered, e.MaxParticipants)
+	return fmt.Sprintf("Регистрация на «%s» отменена.", e.Title), nil
+}
+
+// -----------------------------------------------------------------------------
+// Helpers
+// -----------------------------------------------------------------------------
+
+func (s *ToolsService) events() (core.ClubEventRepository, error) {
+	repo, ok := s.DB.(core.ClubEventRepository)
+	if !ok {
+		return nil, fmt.Errorf("мероприятия недоступны")
+	}
+	return repo, nil
+}
+
+func (s *ToolsService) clientEventIDs(ctx context.Context, repo core.ClubEventRepository, clientID string) (map[int64]bool, error) {
+	regs, err := repo.ListClientRegistrations(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[int64]bool, len(regs))
+	for _, r := range regs {
+		out[r.EventID] = true
+	}
+	return out, nil
+}
+
+// clientEvents — предстоящие мероприятия, на которые записан клиент.
+func (s *ToolsService) clientEvents(ctx context.Context, clientID string) ([]models.ClubEvent, error) {
+	repo, ok := s.DB.(core.ClubEventRepository)
+	if !ok {
+		return nil, nil
+	}
+	mine, err := s.clientEventIDs(ctx, repo, clientID)
+	if err != nil || len(mine) == 0 {
+		return nil, err
+	}
+	events, err := repo.ListUpcomingEvents(ctx, availability.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	out := events[:0]
+	for _, e := range events {
+		if mine[e.ID] {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func formatEvent(e models.ClubEvent, currency string) string {
+	out := fmt.Sprintf("№%d «%s»: %s, %d ч", e.ID, e.Title, e.Start.Format("2006-01-02 15:04"), e.Hours)
+	if e.Track != "" {
+		out += ", трасса " + e.Track
+	}
+	if e.CarClass != "" {
+		out += ", класс " + e.CarClass
+	}
+	if e.Game != "" {
+		out += ", " + e.Game
+	}
+	if e.EntryFee > 0 {
+		out += fmt.Sprintf(", взнос %.0f %s", e.EntryFee, currency)
+	} else {
+		out += ", участие бесплатное"
+	}
+	return out + fmt.Sprintf(", свободно %d из %d", e.SpotsLeft(), e.MaxParticipants)
+}
@@ -0,0 +1,80 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"whatsapp-analytics-mvp/internal/core"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// Лидерборд (клиент)
+// -----------------------------------------------------------------------------
+
+func (s *ToolsService) GetMyBestLaps(ctx context.Context, clientID, track string) (string, error) {
+	repo, err := s.laps()
+	if err != nil {
+		return "", err
+	}
+
+	best, err := repo.ClientBestLaps(ctx, clientID, track)
+	if err != nil {
+		return "", err
+	}
+	if len(best) == 0 {
+		out := "Кругов клиента не найдено"
+		if track != "" {
+			out += " на трассе «" + track + "»"
+		}
+		return out + ". Если круги под игровым ником — узнайте ник и вызовите LinkDriverName.", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("Лучшие круги:\n")
+	for _, l := range best {
+		fmt.Fprintf(&b, "- %s\n", formatStanding(l))
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+func (s *ToolsService) LinkDriverName(ctx context.Context, clientID, driver string) (string, error) {
+	repo, err := s.laps()
+	if err != nil {
+		return "", err
+	}
+	driver = strings.TrimSpace(driver)
+	if driver == "" {
+		return "", fmt.Errorf("не указан ник")
+	}
+
+	n, err := repo.LinkDriver(ctx, driver, clientID, false)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("🏎️ Driver %q → %s (%d laps)", driver, clientID, n)
+	return fmt.Sprintf("Ник %s привязан к клиенту, кругов найдено: %d. Новые результаты под этим ником будут засчитываться клиенту.", driver, n), nil
+}
+
+// -----------------------------------------------------------------------------
+// Helpers
+// -----------------------------------------------------------------------------
+
+func (s *ToolsService) laps() (core.LapRepository, error) {
+	repo, ok := s.DB.(core.LapRepository)
+	if !ok {
+		return nil, fmt.Errorf("лидерборд недоступен")
+	}
+	return repo, nil
+}
+
+func formatStanding(l models.LapStanding) string {
+	out := fmt.Sprintf("%s (%s): %s", l.Track, l.Game, models.FormatLapTime(l.LapMs))
+	if l.Car != "" {
+		out += ", " + l.Car
+	}
+	out += fmt.Sprintf(", %s — %d-е место из %d", l.SetAt.Format("02.01.2006"), l.Rank, l.Drivers)
+	return out
+}
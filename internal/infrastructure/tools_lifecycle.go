@@ -0,0 +1,261 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/core"
+	"whatsapp-analytics-mvp/internal/models"
+	"whatsapp-analytics-mvp/internal/pricing"
+)
+
+// walkInClientID — клиент по умолчанию для брони "с улицы" без контакта.
+const walkInClientID = "walk-in"
+
+// -----------------------------------------------------------------------------
+// Брони клиента
+// -----------------------------------------------------------------------------
+
+func (s *ToolsService) GetMyBookings(ctx context.Context, clientID string) (string, error) {
+	repo, err := s.lifecycle()
+	if err != nil {
+		return "", err
+	}
+
+	bookings, err := repo.ListClientBookings(ctx, clientID, availability.Now())
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, bk := range bookings {
+		if bk.Status == models.BookingCancelled {
+			continue
+		}
+		fmt.Fprintf(&b, "- %s\n", formatBooking(bk, s.Tariff.Currency))
+	}
+
+	var w strings.Builder
+	if repo, ok := s.DB.(core.WaitlistRepository); ok {
+		entries, err := repo.ListClientWaitlist(ctx, clientID)
+		if err != nil {
+			return "", err
+		}
+		for _, e := range entries {
+			fmt.Fprintf(&w, "- %s\n", formatWaitlistEntry(e))
+		}
+	}
+
+	events, err := s.clientEvents(ctx, clientID)
+	if err != nil {
+		return "", err
+	}
+
+	out := "Предстоящих броней нет.\n"
+	if b.Len() > 0 {
+		out = "Предстоящие брони:\n" + b.String()
+	}
+	if w.Len() > 0 {
+		out += "Лист ожидания:\n" + w.String()
+	}
+	if len(events) > 0 {
+		out += "Мероприятия:\n"
+		for _, e := range events {
+			out += "- " + formatEvent(e, s.Tariff.Currency) + "\n"
+		}
+	}
+	return strings.TrimSuffix(out, "\n"), nil
+}
+
+// -----------------------------------------------------------------------------
+// Отмена (клиент — только до дедлайна бесплатной отмены)
+// -----------------------------------------------------------------------------
+
+func (s *ToolsService) CancelBooking(ctx context.Context, clientID, bookingID string) (string, error) {
+	repo, err := s.lifecycle()
+	if err != nil {
+		return "", err
+	}
+
+	bk, err := s.clientBooking(ctx, repo, clientID, bookingID)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkFreeChange(*bk, "Отмена"); err != nil {
+		return "", err
+	}
+
+	if err := repo.SetBookingStatus(ctx, bk.BookingID, models.BookingCancelled, models.ActorClient, "отмена клиентом"); err != nil {
+		return "", err
+	}
+
+	log.Printf("✗ Booking cancelled by client: %s (%s)", bk.BookingID, clientID)
+
+	out := fmt.Sprintf("Бронь %s отменена, станции освобождены.", bk.BookingID)
+	if restored := s.restorePrepaid(ctx, bk.BookingID, models.ActorClient); restored > 0 {
+		out += fmt.Sprintf(" %.0f %s вернулись на сертификат/пакет.", restored, s.Tariff.Currency)
+	}
+
+	// Отмена в срок — оплата возвращается целиком
+	if bk.Status == models.BookingPaid && s.Payments != nil && s.paidBeyondPrepaid(ctx, bk.BookingID) {
+		inv, err := s.Payments.Refund(ctx, bk.BookingID)
+		if err != nil {
+			log.Printf("⚠️ Refund %s failed: %v", bk.BookingID, err)
+			return out + " Возврат оплаты оформит администратор.", nil
+		}
+		return out + fmt.Sprintf(" Возврат %.0f %s оформлен.", inv.Amount, s.Tariff.Currency), nil
+	}
+	return out, nil
+}
+
+// paidBeyondPrepaid — по брони остались деньги, кроме списанных с предоплаты
+// (их уже вернул restorePrepaid).
+func (s *ToolsService) paidBeyondPrepaid(ctx context.Context, bookingID string) bool {
+	ledger, ok := s.DB.(core.LedgerRepository)
+	if !ok {
+		return true
+	}
+	bal, err := ledger.GetBookingBalance(ctx, bookingID)
+	return err != nil || bal == nil || bal.Paid > 0
+}
+
+// -----------------------------------------------------------------------------
+// Перенос (новые станции и пересчёт цены; промокод брони сохраняется)
+// -----------------------------------------------------------------------------
+
+func (s *ToolsService) RescheduleBooking(ctx context.Context, clientID, bookingID, date, timeStr string, hours int) (string, error) {
+	repo, err := s.lifecycle()
+	if err != nil {
+		return "", err
+	}
+
+	bk, err := s.clientBooking(ctx, repo, clientID, bookingID)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkFreeChange(*bk, "Перенос"); err != nil {
+		return "", err
+	}
+
+	if hours <= 0 {
+		hours = bk.Hours
+	}
+	if hours > availability.MaxHours {
+		return "", fmt.Errorf("часы: 1–%d", availability.MaxHours)
+	}
+	start, err := parseStart(date, timeStr, hours)
+	if err != nil {
+		return "", err
+	}
+	if !start.After(availability.Now()) {
+		return "", fmt.Errorf("перенести можно только на будущее время")
+	}
+
+	quote, _, err := s.quote(ctx, clientID, start, bk.Seats, hours, "")
+	if err != nil {
+		return "", err
+	}
+	s.reapplyPromo(ctx, &quote, bk.PromoCode)
+
+	moved := *bk
+	moved.Start, moved.Hours = start, hours
+	moved.Amount, moved.Quote = quote.Total, &quote
+
+	err = repo.RescheduleBooking(ctx, &moved, bk.Game, models.ActorClient)
+	if errors.Is(err, models.ErrNotEnoughSeats) {
+		return "", fmt.Errorf("мест недостаточно на %s %s — выберите другое время", date, timeStr)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("↻ Booking rescheduled: %s → %s %s hours=%d rigs=%v", moved.BookingID, date, timeStr, hours, moved.RigIDs)
+	return fmt.Sprintf("Бронь %s перенесена: %s", moved.BookingID, formatBooking(moved, quote.Currency)), nil
+}
+
+// reapplyPromo — скидка уже погашенного промокода при пересчёте брони
+// (лимиты не проверяются: погашение относится к этой же брони).
+func (s *ToolsService) reapplyPromo(ctx context.Context, q *models.Quote, code string) {
+	if code == "" {
+		return
+	}
+	repo, ok := s.DB.(core.PromoRepository)
+	if !ok {
+		return
+	}
+	promo, err := repo.GetPromo(ctx, code)
+	if err != nil || promo == nil {
+		return
+	}
+	pricing.ApplyPromo(q, promo)
+}
+
+// -----------------------------------------------------------------------------
+// Walk-in (администратор): бронь на сейчас, сразу подтверждена
+// -----------------------------------------------------------------------------
+
+func (s *ToolsService) CreateWalkIn(ctx context.Context, clientID string, seats, hours int) (string, error) {
+	if clientID == "" {
+		clientID = walkInClientID
+	}
+	now := availability.Now()
+	return s.createBooking(ctx, clientID, now.Format("2006-01-02"), now.Format("15:04"), seats, hours, "", "", "", models.ActorAdmin)
+}
+
+// -----------------------------------------------------------------------------
+// Helpers
+// -----------------------------------------------------------------------------
+
+func (s *ToolsService) lifecycle() (core.BookingLifecycleRepository, error) {
+	repo, ok := s.DB.(core.BookingLifecycleRepository)
+	if !ok {
+		return nil, fmt.Errorf("управление бронями недоступно")
+	}
+	return repo, nil
+}
+
+// clientBooking — бронь клиента; чужая бронь для него "не найдена".
+func (s *ToolsService) clientBooking(ctx context.Context, repo core.BookingLifecycleRepository, clientID, bookingID string) (*models.Booking, error) {
+	bookingID = strings.TrimSpace(bookingID)
+	if bookingID == "" {
+		return nil, fmt.Errorf("не указан ID брони — уточните через GetMyBookings")
+	}
+
+	bk, err := repo.GetBooking(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if bk == nil || bk.ClientID != clientID {
+		return nil, fmt.Errorf("бронь %s не найдена", bookingID)
+	}
+	if !bk.IsActive() {
+		return nil, fmt.Errorf("бронь %s уже %s", bookingID, models.BookingStatusLabel(bk.Status))
+	}
+	return bk, nil
+}
+
+// checkFreeChange — политика: клиент сам меняет бронь не позже чем за
+// FreeCancelHours часов до начала.
+func (s *ToolsService) checkFreeChange(bk models.Booking, action string) error {
+	deadline := bk.Start.Add(-time.Duration(s.FreeCancelHours) * time.Hour)
+	if availability.Now().After(deadline) {
+		return fmt.Errorf("%s через бота — не позже чем за %d ч до начала (%s). Свяжитесь с администратором",
+			action, s.FreeCancelHours, bk.Start.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+// formatBooking — бронь одной строкой для клиента.
+func formatBooking(bk models.Booking, currency string) string {
+	out := fmt.Sprintf("%s: %s, мест — %d, часов — %d, %.0f %s",
+		bk.BookingID, bk.Start.Format("2006-01-02 15:04"), bk.Seats, bk.Hours, bk.Amount, currency)
+	if len(bk.RigIDs) > 0 {
+		out += ", станции: " + availability.FormatRigIDs(bk.RigIDs)
+	}
+	return out + " — " + models.BookingStatusLabel(bk.Status)
+}
@@ -0,0 +1,192 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/core"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// prepaidHoursAlias — вместо кода: "спишите с моего пакета часов".
+const prepaidHoursAlias = "hours"
+
+// -----------------------------------------------------------------------------
+// Сертификаты и пакеты часов (клиент)
+// -----------------------------------------------------------------------------
+
+func (s *ToolsService) GetPrepaidBalance(ctx context.Context, clientID, code string) (string, error) {
+	repo, err := s.prepaid()
+	if err != nil {
+		return "", err
+	}
+
+	if code = strings.TrimSpace(code); code != "" {
+		p, err := repo.GetPrepaid(ctx, code)
+		if err != nil {
+			return "", err
+		}
+		// Чужой пакет часов — как несуществующий код
+		if p == nil || p.Status != models.PrepaidActive || (p.Kind == models.PrepaidHours && p.ClientID != clientID) {
+			return "", fmt.Errorf("код %s не найден", models.NormalizePromoCode(code))
+		}
+		return formatPrepaid(*p, s.Tariff.Currency), nil
+	}
+
+	list, err := repo.ListClientPrepaid(ctx, clientID)
+	if err != nil {
+		return "", err
+	}
+	if len(list) == 0 {
+		return "Сертификатов и пакетов часов с остатком нет. Если у клиента подарочный сертификат — спросите код.", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("Предоплата клиента:\n")
+	for _, p := range list {
+		fmt.Fprintf(&b, "- %s\n", formatPrepaid(p, s.Tariff.Currency))
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// PayWithPrepaid — оплатить уже созданную бронь сертификатом или пакетом.
+func (s *ToolsService) PayWithPrepaid(ctx context.Context, clientID, bookingID, code string) (string, error) {
+	lifecycle, err := s.lifecycle()
+	if err != nil {
+		return "", err
+	}
+	bk, err := s.clientBooking(ctx, lifecycle, clientID, bookingID)
+	if err != nil {
+		return "", err
+	}
+	p, err := s.resolvePrepaid(ctx, clientID, code)
+	if err != nil {
+		return "", err
+	}
+	return s.redeemPrepaid(ctx, bk, p, models.ActorClient), nil
+}
+
+// -----------------------------------------------------------------------------
+// Helpers
+// -----------------------------------------------------------------------------
+
+func (s *ToolsService) prepaid() (core.PrepaidRepository, error) {
+	repo, ok := s.DB.(core.PrepaidRepository)
+	if !ok {
+		return nil, fmt.Errorf("сертификаты и пакеты недоступны")
+	}
+	return repo, nil
+}
+
+// resolvePrepaid — действующий сертификат/пакет по коду; "hours" — пакет
+// клиента, который сгорает раньше всех.
+func (s *ToolsService) resolvePrepaid(ctx context.Context, clientID, code string) (*models.PrepaidAccount, error) {
+	repo, err := s.prepaid()
+	if err != nil {
+		return nil, err
+	}
+	now := availability.Now()
+
+	code = strings.TrimSpace(code)
+	if code == "" || strings.EqualFold(code, prepaidHoursAlias) {
+		list, err := repo.ListClientPrepaid(ctx, clientID)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list {
+			if list[i].Kind == models.PrepaidHours && !list[i].Expired(now) {
+				return &list[i], nil
+			}
+		}
+		return nil, fmt.Errorf("действующего пакета часов у клиента нет")
+	}
+
+	p, err := repo.GetPrepaid(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p == nil || p.Status != models.PrepaidActive || (p.Kind == models.PrepaidHours && p.ClientID != clientID):
+		return nil, fmt.Errorf("код %s не найден", models.NormalizePromoCode(code))
+	case p.Expired(now):
+		return nil, fmt.Errorf("срок действия %s истёк %s", p.Code, p.ExpiresAt.Format("02.01.2006"))
+	case p.Balance <= 0:
+		return nil, fmt.Errorf("на %s не осталось средств", p.Code)
+	}
+	return p, nil
+}
+
+// redeemPrepaid — списание в счёт брони; при полном покрытии бронь
+// становится оплаченной. Ошибка не отменяет бронь — она уходит текстом.
+func (s *ToolsService) redeemPrepaid(ctx context.Context, bk *models.Booking, p *models.PrepaidAccount, actor string) string {
+	repo, err := s.prepaid()
+	if err != nil {
+		return err.Error()
+	}
+
+	red, bal, err := repo.RedeemPrepaid(ctx, p.Code, bk.BookingID, actor)
+	if err != nil {
+		log.Printf("⚠️ Prepaid %s for %s: %v", p.Code, bk.BookingID, err)
+		return fmt.Sprintf("%s не списан: %v", models.PrepaidKindLabel(p.Kind), err)
+	}
+	log.Printf("🎟️ Prepaid %s → %s: %.2f units, %.0f", p.Code, bk.BookingID, red.Units, red.Value)
+
+	out := fmt.Sprintf("списано с %s: %.0f %s (%s)", p.Code, red.Value,
+		s.Tariff.Currency, formatPrepaidLeft(p.Kind, p.Balance-red.Units, s.Tariff.Currency))
+
+	if bal.Due > 0 {
+		return out + fmt.Sprintf("; к оплате: %.0f %s", bal.Due, s.Tariff.Currency)
+	}
+	if lifecycle, ok := s.DB.(core.BookingLifecycleRepository); ok {
+		if _, err := core.SettleBooking(ctx, lifecycle, bk, bal, actor, "оплачено предоплатой"); err != nil {
+			log.Printf("⚠️ Settle %s: %v", bk.BookingID, err)
+		}
+	}
+	return out + "; бронь оплачена полностью"
+}
+
+// restorePrepaid — отмена брони возвращает списанное на сертификаты/пакеты.
+func (s *ToolsService) restorePrepaid(ctx context.Context, bookingID, actor string) float64 {
+	repo, ok := s.DB.(core.PrepaidRepository)
+	if !ok {
+		return 0
+	}
+	restored, err := repo.RestorePrepaid(ctx, bookingID, actor)
+	if err != nil {
+		log.Printf("⚠️ Restore prepaid %s: %v", bookingID, err)
+	}
+	return restored
+}
+
+func formatPrepaid(p models.PrepaidAccount, currency string) string {
+	out := fmt.Sprintf("%s %s: %s", models.PrepaidKindLabel(p.Kind), p.Code, formatPrepaidLeft(p.Kind, p.Balance, currency))
+	if p.Kind == models.PrepaidHours {
+		out += fmt.Sprintf(" из %s", formatHours(p.Initial))
+	}
+	switch {
+	case p.ExpiresAt.IsZero():
+	case p.Expired(availability.Now()):
+		out += ", срок истёк " + p.ExpiresAt.Format("02.01.2006")
+	default:
+		out += ", действует до " + p.ExpiresAt.AddDate(0, 0, -1).Format("02.01.2006")
+	}
+	return out
+}
+
+func formatPrepaidLeft(kind string, balance float64, currency string) string {
+	if kind == models.PrepaidHours {
+		return "осталось " + formatHours(balance)
+	}
+	return fmt.Sprintf("остаток %.0f %s", balance, currency)
+}
+
+// formatHours — место-часы пакета: "7 ч" или "6.5 ч".
+func formatHours(h float64) string {
+	if h == float64(int(h)) {
+		return fmt.Sprintf("%d ч", int(h))
+	}
+	return fmt.Sprintf("%.1f ч", h)
+}
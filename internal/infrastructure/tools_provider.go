@@ -2,6 +2,8 @@ package infrastructure
 
 import (
 	"context"
+	"time"
+
 	"whatsapp-analytics-mvp/internal/core"
 )
 
@@ -19,18 +21,94 @@ func NewToolsProviderAdapter(svc *ToolsService) core.ToolsProvider {
 // Реализация методов интерфейса ToolsProvider
 // -----------------------------------------------------------------------------
 
-func (a *ToolsProviderAdapter) CheckAvailability(ctx context.Context, date, time string, seats int) (string, error) {
-	return a.svc.CheckAvailability(ctx, date, time, seats)
+func (a *ToolsProviderAdapter) CheckAvailability(ctx context.Context, clientID, date, time string, seats, hours int, game string) (string, error) {
+	return a.svc.CheckAvailability(ctx, clientID, date, time, seats, hours, game)
+}
+
+func (a *ToolsProviderAdapter) GetFreeSlots(ctx context.Context, date string) (string, error) {
+	return a.svc.GetFreeSlots(ctx, date)
+}
+
+func (a *ToolsProviderAdapter) FreeStarts(ctx context.Context, date string, seats, hours int) ([]time.Time, error) {
+	return a.svc.FreeStarts(ctx, date, seats, hours)
+}
+
+func (a *ToolsProviderAdapter) SeatCapacity(ctx context.Context) (int, error) {
+	return a.svc.SeatCapacity(ctx)
+}
+
+func (a *ToolsProviderAdapter) SuggestAlternatives(ctx context.Context, clientID, date, time string, seats, hours int, game, promoCode string, limit int) (string, error) {
+	return a.svc.SuggestAlternatives(ctx, clientID, date, time, seats, hours, game, promoCode, limit)
+}
+
+func (a *ToolsProviderAdapter) GetPrice(ctx context.Context, clientID string, seats, hours int, date, time, promoCode string) (string, error) {
+	return a.svc.GetPrice(ctx, clientID, seats, hours, date, time, promoCode)
 }
 
-func (a *ToolsProviderAdapter) GetPrice(ctx context.Context, seats, hours int, time string) (string, error) {
-	return a.svc.GetPrice(ctx, seats, hours, time)
+func (a *ToolsProviderAdapter) CreateBooking(ctx context.Context, clientID, date, time string, seats, hours int, game, promoCode, prepaidCode string) (string, error) {
+	return a.svc.CreateBooking(ctx, clientID, date, time, seats, hours, game, promoCode, prepaidCode)
 }
 
-func (a *ToolsProviderAdapter) CreateBooking(ctx context.Context, clientID, date, time string, seats, hours int) (string, error) {
-	return a.svc.CreateBooking(ctx, clientID, date, time, seats, hours)
+func (a *ToolsProviderAdapter) ApplyPromoCode(ctx context.Context, clientID, code string) (string, error) {
+	return a.svc.ApplyPromoCode(ctx, clientID, code)
 }
 
 func (a *ToolsProviderAdapter) GeneratePaymentLink(ctx context.Context, amount float64, bookingID string) (string, error) {
 	return a.svc.GeneratePaymentLink(ctx, amount, bookingID)
 }
+
+func (a *ToolsProviderAdapter) GetMyBookings(ctx context.Context, clientID string) (string, error) {
+	return a.svc.GetMyBookings(ctx, clientID)
+}
+
+func (a *ToolsProviderAdapter) CancelBooking(ctx context.Context, clientID, bookingID string) (string, error) {
+	return a.svc.CancelBooking(ctx, clientID, bookingID)
+}
+
+func (a *ToolsProviderAdapter) RescheduleBooking(ctx context.Context, clientID, bookingID, date, time string, hours int) (string, error) {
+	return a.svc.RescheduleBooking(ctx, clientID, bookingID, date, time, hours)
+}
+
+func (a *ToolsProviderAdapter) JoinWaitlist(ctx context.Context, clientID, date, time string, seats, hours int, game string, flexHours int) (string, error) {
+	return a.svc.JoinWaitlist(ctx, clientID, date, time, seats, hours, game, flexHours)
+}
+
+func (a *ToolsProviderAdapter) AnswerWaitlistOffer(ctx context.Context, clientID string, accept bool) (string, error) {
+	return a.svc.AnswerWaitlistOffer(ctx, clientID, accept)
+}
+
+func (a *ToolsProviderAdapter) LeaveWaitlist(ctx context.Context, clientID string, entryID int64) (string, error) {
+	return a.svc.LeaveWaitlist(ctx, clientID, entryID)
+}
+
+func (a *ToolsProviderAdapter) ListEvents(ctx context.Context, clientID string) (string, error) {
+	return a.svc.ListEvents(ctx, clientID)
+}
+
+func (a *ToolsProviderAdapter) RegisterForEvent(ctx context.Context, clientID string, eventID int64, name string) (string, error) {
+	return a.svc.RegisterForEvent(ctx, clientID, eventID, name)
+}
+
+func (a *ToolsProviderAdapter) CancelEventRegistration(ctx context.Context, clientID string, eventID int64) (string, error) {
+	return a.svc.CancelEventRegistration(ctx, clientID, eventID)
+}
+
+func (a *ToolsProviderAdapter) GetPrepaidBalance(ctx context.Context, clientID, code string) (string, error) {
+	return a.svc.GetPrepaidBalance(ctx, clientID, code)
+}
+
+func (a *ToolsProviderAdapter) PayWithPrepaid(ctx context.Context, clientID, bookingID, code string) (string, error) {
+	return a.svc.PayWithPrepaid(ctx, clientID, bookingID, code)
+}
+
+func (a *ToolsProviderAdapter) GetMyBestLaps(ctx context.Context, clientID, track string) (string, error) {
+	return a.svc.GetMyBestLaps(ctx, clientID, track)
+}
+
+func (a *ToolsProviderAdapter) LinkDriverName(ctx context.Context, clientID, driver string) (string, error) {
+	return a.svc.LinkDriverName(ctx, clientID, driver)
+}
+
+func (a *ToolsProviderAdapter) CreateWalkIn(ctx context.Context, clientID string, seats, hours int) (string, error) {
+	return a.svc.CreateWalkIn(ctx, clientID, seats, hours)
+}
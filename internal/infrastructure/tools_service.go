@@ -2,123 +2,456 @@ package infrastructure
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
+	"whatsapp-analytics-mvp/internal/availability"
 	"whatsapp-analytics-mvp/internal/core"
+	"whatsapp-analytics-mvp/internal/models"
+	"whatsapp-analytics-mvp/internal/pricing"
 )
 
 // ToolsService — основная бизнес-логика для инструментов, вызываемых LLM.
 type ToolsService struct {
-	DB core.ContextManager
+	DB     core.BookingRepository
+	Tariff pricing.Tariff
+
+	// FreeCancelHours — клиент сам отменяет/переносит бронь не позже,
+	// чем за столько часов до начала; дальше — только через администратора.
+	FreeCancelHours int
+
+	// Payments — счета на оплату; nil — онлайн-оплата не подключена.
+	Payments *core.PaymentService
+
+	// Messenger — сообщения клиенту вне диалога (предложение из листа
+	// ожидания); WaitlistOfferTTL — сколько держим места под предложение.
+	Messenger        core.ClientMessenger
+	WaitlistOfferTTL time.Duration
+
+	waitlistMu sync.Mutex // один проход по очереди за раз
 }
 
+// Политика отмены и листа ожидания по умолчанию.
+const (
+	defaultFreeCancelHours  = 3
+	defaultWaitlistOfferTTL = 15 * time.Minute
+)
+
 // NewToolsService — создаёт сервис инструментов.
-func NewToolsService(db core.ContextManager) *ToolsService {
-	return &ToolsService{DB: db}
+func NewToolsService(db core.BookingRepository, tariff pricing.Tariff) *ToolsService {
+	return &ToolsService{
+		DB:               db,
+		Tariff:           tariff,
+		FreeCancelHours:  defaultFreeCancelHours,
+		WaitlistOfferTTL: defaultWaitlistOfferTTL,
+	}
 }
 
 // -----------------------------------------------------------------------------
-// Проверка доступности
+// Проверка доступности (по интервалу start → start+hours, по станциям)
 // -----------------------------------------------------------------------------
 
-func (s *ToolsService) CheckAvailability(ctx context.Context, date, timeStr string, seats int) (string, error) {
-	if seats <= 0 || seats > 6 {
-		return "", fmt.Errorf("количество мест должно быть от 1 до 6")
+func (s *ToolsService) CheckAvailability(ctx context.Context, clientID, date, timeStr string, seats, hours int, game string) (string, error) {
+	if err := s.checkSeats(ctx, seats); err != nil {
+		return "", err
+	}
+	if hours <= 0 {
+		hours = 1
 	}
 
-	parsedTime, err := time.Parse("2006-01-02 15:04", date+" "+timeStr)
+	start, err := parseStart(date, timeStr, hours)
 	if err != nil {
-		return "", fmt.Errorf("неверный формат даты/времени. Используйте YYYY-MM-DD и HH:MM")
+		return "", err
 	}
 
-	// Считаем брони на выбранный час
-	bookings, err := s.DB.GetBookingsAt(ctx, parsedTime)
+	end := start.Add(time.Duration(hours) * time.Hour)
+	free, err := s.DB.GetFreeRigs(ctx, start, end, game)
 	if err != nil {
 		return "", err
 	}
 
-	usedSeats := 0
-	for _, b := range bookings {
-		usedSeats += b.Seats
+	span := fmt.Sprintf("%s–%s", start.Format("15:04"), end.Format("15:04"))
+	if game != "" {
+		span += " с " + game
 	}
 
-	if usedSeats+seats > 6 {
-		return "Мест недостаточно", nil
+	if len(free) < seats {
+		s.recordMiss(ctx, clientID, start, seats, hours, game)
+		out := fmt.Sprintf("Мест недостаточно: на %s свободно %d.", span, len(free))
+
+		// Сразу ближайшие варианты, чтобы клиенту было из чего выбрать
+		alts, err := s.alternatives(ctx, start, seats, hours, game, 3)
+		if err == nil && len(alts) > 0 {
+			out += " Ближайшие варианты:"
+			for i, alt := range alts {
+				out += fmt.Sprintf(" %d) %s;", i+1, s.formatAlternative(ctx, clientID, alt, hours, ""))
+			}
+			out += " Больше — SuggestAlternatives."
+		}
+		return out + " Можно встать в лист ожидания (JoinWaitlist)", nil
 	}
 
-	return "Места доступны", nil
+	_, adjacent := availability.PickRigs(free, seats)
+	if seats > 1 && !adjacent {
+		return fmt.Sprintf("Места доступны: на %s свободно %d, но не рядом", span, len(free)), nil
+	}
+	return fmt.Sprintf("Места доступны: на %s свободно %d", span, len(free)), nil
 }
 
 // -----------------------------------------------------------------------------
-// Расчёт стоимости
+// Свободные места по часам рабочего дня
 // -----------------------------------------------------------------------------
 
-func (s *ToolsService) GetPrice(ctx context.Context, seats, hours int, timeStr string) (string, error) {
-	if seats <= 0 || seats > 6 {
-		return "", fmt.Errorf("места: 1–6")
+func (s *ToolsService) GetFreeSlots(ctx context.Context, date string) (string, error) {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "", fmt.Errorf("неверный формат даты. Используйте YYYY-MM-DD")
 	}
-	if hours <= 0 || hours > 12 {
-		return "", fmt.Errorf("часы: 1–12")
+
+	slots, capacity, err := s.daySlots(ctx, day)
+	if err != nil {
+		return "", err
 	}
 
-	// Простая тарифная логика
-	basePrice := 2000.0
-	nightMultiplier := 1.0
+	var b strings.Builder
+	fmt.Fprintf(&b, "Свободные места %s (из %d):\n", date, capacity)
+	for _, sl := range slots {
+		fmt.Fprintf(&b, "%s — %d\n", sl.Start.Format("15:04"), sl.Free)
+	}
+	return b.String(), nil
+}
 
-	parsedTime, err := time.Parse("15:04", timeStr)
-	if err == nil {
-		// После 22:00 — цена выше
-		if parsedTime.Hour() >= 22 {
-			nightMultiplier = 1.25
+func (s *ToolsService) FreeStarts(ctx context.Context, date string, seats, hours int) ([]time.Time, error) {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil, fmt.Errorf("неверный формат даты. Используйте YYYY-MM-DD")
+	}
+	if hours <= 0 {
+		hours = 1
+	}
+
+	now := availability.Now()
+	open := time.Date(day.Year(), day.Month(), day.Day(), availability.OpenHour, 0, 0, 0, time.UTC)
+	var out []time.Time
+	for i := 0; i < 24-availability.OpenHour+availability.CloseHour; i++ {
+		start := open.Add(time.Duration(i) * time.Hour)
+		if !start.After(now) || !availability.WithinOpeningHours(start, hours) {
+			continue
+		}
+		free, err := s.DB.GetFreeRigs(ctx, start, start.Add(time.Duration(hours)*time.Hour), "")
+		if err != nil {
+			return nil, err
 		}
+		if len(free) >= seats {
+			out = append(out, start)
+		}
+	}
+	return out, nil
+}
+
+func (s *ToolsService) SeatCapacity(ctx context.Context) (int, error) {
+	rigs, err := s.DB.ListRigs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return availability.ActiveCount(rigs), nil
+}
+
+func (s *ToolsService) daySlots(ctx context.Context, day time.Time) ([]availability.Slot, int, error) {
+	rigs, err := s.DB.ListRigs(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	capacity := availability.ActiveCount(rigs)
+
+	open := time.Date(day.Year(), day.Month(), day.Day(), availability.OpenHour, 0, 0, 0, time.UTC)
+	closeAt := open.Add(time.Duration(24-availability.OpenHour+availability.CloseHour) * time.Hour)
+
+	bookings, err := s.DB.GetBookingsBetween(ctx, open, closeAt)
+	if err != nil {
+		return nil, 0, err
 	}
+	return availability.DaySlots(bookings, day, capacity), capacity, nil
+}
 
-	total := basePrice * float64(seats) * float64(hours) * nightMultiplier
-	return fmt.Sprintf("%.0f", total), nil
+// checkSeats — группа не больше числа станций в работе.
+func (s *ToolsService) checkSeats(ctx context.Context, seats int) error {
+	rigs, err := s.DB.ListRigs(ctx)
+	if err != nil {
+		return err
+	}
+	if n := availability.ActiveCount(rigs); seats <= 0 || seats > n {
+		return fmt.Errorf("количество мест должно быть от 1 до %d", n)
+	}
+	return nil
 }
 
 // -----------------------------------------------------------------------------
-// Создание брони
+// Расчёт стоимости
 // -----------------------------------------------------------------------------
 
-func (s *ToolsService) CreateBooking(ctx context.Context, clientID, date, timeStr string, seats, hours int) (string, error) {
-	if seats <= 0 || seats > 6 {
-		return "", fmt.Errorf("места: 1–6")
+// GetPrice — детализированный расчёт по тарифу: база, надбавки, скидки
+// (уровень клиента, промокод), итог. Без даты считаем на сегодня,
+// без времени — с открытия клуба.
+func (s *ToolsService) GetPrice(ctx context.Context, clientID string, seats, hours int, date, timeStr, promoCode string) (string, error) {
+	if seats <= 0 {
+		return "", fmt.Errorf("места: минимум 1")
+	}
+	if hours <= 0 || hours > availability.MaxHours {
+		return "", fmt.Errorf("часы: 1–%d", availability.MaxHours)
+	}
+
+	if date == "" {
+		date = availability.Now().Format("2006-01-02")
+	}
+	if timeStr == "" {
+		timeStr = fmt.Sprintf("%02d:00", availability.OpenHour)
+	}
+
+	start, err := parseStart(date, timeStr, hours)
+	if err != nil {
+		return "", err
+	}
+
+	quote, _, err := s.quote(ctx, clientID, start, seats, hours, promoCode)
+	if err != nil {
+		return "", err
+	}
+	return quote.Text(), nil
+}
+
+// appliedPromo — промокод, вошедший в расчёт, и его скидка.
+type appliedPromo struct {
+	Code     string
+	Discount float64
+}
+
+// quote — тариф, затем скидка уровня лояльности, затем промокод.
+// Возвращает применённый промокод (nil, если кода нет).
+func (s *ToolsService) quote(ctx context.Context, clientID string, start time.Time, seats, hours int, promoCode string) (models.Quote, *appliedPromo, error) {
+	q := s.Tariff.Quote(start, seats, hours)
+
+	if clientID != "" && clientID != walkInClientID {
+		pricing.ApplyLoyalty(&q, s.loyaltyTier(ctx, clientID))
+	}
+
+	if promoCode == "" {
+		return q, nil, nil
+	}
+	promo, err := s.checkPromo(ctx, clientID, promoCode)
+	if err != nil {
+		return q, nil, err
+	}
+	discount := pricing.ApplyPromo(&q, promo)
+	return q, &appliedPromo{Code: promo.Code, Discount: discount}, nil
+}
+
+// loyaltyTier — пересчитывает сумму завершённых броней клиента, обновляет
+// профиль (total_spent, loyalty_level) и возвращает уровень.
+func (s *ToolsService) loyaltyTier(ctx context.Context, clientID string) pricing.LoyaltyTier {
+	repo, ok := s.DB.(core.LoyaltyRepository)
+	if !ok {
+		return pricing.LoyaltyTier{Name: pricing.StandardTier}
+	}
+
+	spent, err := repo.CompletedSpend(ctx, clientID, availability.Now())
+	if err != nil {
+		log.Printf("[Loyalty] spend for %s: %v", clientID, err)
+		return pricing.LoyaltyTier{Name: pricing.StandardTier}
+	}
+
+	tier := s.Tariff.TierFor(spent)
+	if err := repo.UpdateLoyalty(ctx, clientID, spent, tier.Name); err != nil {
+		log.Printf("[Loyalty] update for %s: %v", clientID, err)
+	}
+	return tier
+}
+
+// checkPromo — код существует, действует и лимиты не исчерпаны.
+func (s *ToolsService) checkPromo(ctx context.Context, clientID, code string) (*models.PromoCode, error) {
+	repo, ok := s.DB.(core.PromoRepository)
+	if !ok {
+		return nil, fmt.Errorf("промокоды недоступны")
+	}
+
+	promo, err := repo.GetPromo(ctx, code)
+	if err != nil {
+		return nil, err
 	}
-	if hours <= 0 || hours > 12 {
-		return "", fmt.Errorf("часы: 1–12")
+	if promo == nil {
+		return nil, fmt.Errorf("промокод %s не найден", models.NormalizePromoCode(code))
 	}
 
-	startTime, err := time.Parse("2006-01-02 15:04", date+" "+timeStr)
+	used, usedByClient, err := repo.PromoUsage(ctx, promo.Code, clientID)
 	if err != nil {
-		return "", fmt.Errorf("неверная дата/время")
+		return nil, err
+	}
+	if err := pricing.CheckPromo(promo, availability.Now(), used, usedByClient); err != nil {
+		return nil, err
 	}
+	return promo, nil
+}
 
-	bookingID := fmt.Sprintf("bk_%d", time.Now().UnixNano())
+// -----------------------------------------------------------------------------
+// Промокод (проверка; погашается вместе с бронью)
+// -----------------------------------------------------------------------------
+
+func (s *ToolsService) ApplyPromoCode(ctx context.Context, clientID, code string) (string, error) {
+	if strings.TrimSpace(code) == "" {
+		return "", fmt.Errorf("не указан промокод")
+	}
+
+	promo, err := s.checkPromo(ctx, clientID, code)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Промокод принят (%s). Скидка войдёт в расчёт цены и бронь.",
+		pricing.PromoSummary(promo, s.Tariff.Currency)), nil
+}
+
+// -----------------------------------------------------------------------------
+// Создание брони
+// -----------------------------------------------------------------------------
+
+func (s *ToolsService) CreateBooking(ctx context.Context, clientID, date, timeStr string, seats, hours int, game, promoCode, prepaidCode string) (string, error) {
+	return s.createBooking(ctx, clientID, date, timeStr, seats, hours, game, promoCode, prepaidCode, models.ActorBot)
+}
+
+// createBooking — общая часть брони из чата (actor=bot) и walk-in от
+// администратора (actor=admin, бронь сразу подтверждена).
+func (s *ToolsService) createBooking(ctx context.Context, clientID, date, timeStr string, seats, hours int, game, promoCode, prepaidCode, actor string) (string, error) {
+	if err := s.checkSeats(ctx, seats); err != nil {
+		return "", err
+	}
+	if hours <= 0 || hours > availability.MaxHours {
+		return "", fmt.Errorf("часы: 1–%d", availability.MaxHours)
+	}
+
+	startTime, err := parseStart(date, timeStr, hours)
+	if err != nil {
+		return "", err
+	}
+	// walk-in начинается в текущую минуту — её ещё можно бронировать
+	if startTime.Before(availability.Now().Truncate(time.Minute)) {
+		return "", fmt.Errorf("забронировать можно только на будущее время")
+	}
 
-	priceStr, _ := s.GetPrice(ctx, seats, hours, timeStr)
+	// Сертификат проверяем до брони: с негодным кодом бронь не создаётся
+	var prepaid *models.PrepaidAccount
+	if prepaidCode != "" {
+		if prepaid, err = s.resolvePrepaid(ctx, clientID, prepaidCode); err != nil {
+			return "", err
+		}
+	}
 
-	err = s.DB.SaveBooking(ctx, bookingID, clientID, startTime, seats, hours, priceStr)
+	booking, err := s.book(ctx, clientID, startTime, seats, hours, game, promoCode, actor)
+	if errors.Is(err, models.ErrNotEnoughSeats) {
+		s.recordMiss(ctx, clientID, startTime, seats, hours, game)
+		return "", fmt.Errorf("мест недостаточно на %s %s — выберите другое время или встаньте в лист ожидания", date, timeStr)
+	}
 	if err != nil {
 		return "", err
 	}
 
-	log.Printf("✓ Booking created: %s (%s %s) seats=%d hours=%d", bookingID, date, timeStr, seats, hours)
+	log.Printf("✓ Booking created: %s (%s %s) seats=%d hours=%d rigs=%v", booking.BookingID, date, timeStr, seats, hours, booking.RigIDs)
+
+	out := fmt.Sprintf("%s; станции: %s; сумма: %.0f %s",
+		booking.BookingID, availability.FormatRigIDs(booking.RigIDs), booking.Amount, booking.Quote.Currency)
+	if prepaid != nil {
+		out += "; " + s.redeemPrepaid(ctx, booking, prepaid, actor)
+	}
+	return out, nil
+}
+
+// book — расчёт цены и вставка брони (станции подбираются в транзакции).
+// Нехватка мест — models.ErrNotEnoughSeats.
+func (s *ToolsService) book(ctx context.Context, clientID string, start time.Time, seats, hours int, game, promoCode, actor string) (*models.Booking, error) {
+	quote, promo, err := s.quote(ctx, clientID, start, seats, hours, promoCode)
+	if err != nil {
+		return nil, err
+	}
+
+	booking := models.Booking{
+		BookingID: fmt.Sprintf("bk_%d", time.Now().UnixNano()),
+		ClientID:  clientID,
+		Start:     start,
+		Seats:     seats,
+		Hours:     hours,
+		Amount:    quote.Total,
+		Quote:     &quote,
+		CreatedBy: actor,
+	}
+	if actor == models.ActorAdmin {
+		booking.Status = models.BookingConfirmed
+	}
+	if promo != nil {
+		booking.PromoCode = promo.Code
+		booking.PromoDiscount = promo.Discount
+	}
 
-	return bookingID, nil
+	// Станции подбираются и проверяются внутри транзакции вставки
+	err = s.DB.CreateBooking(ctx, &booking, game)
+	if errors.Is(err, models.ErrPromoExhausted) {
+		return nil, fmt.Errorf("промокод %s: %w — бронь без него пересчитайте через GetPrice", promo.Code, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &booking, nil
 }
 
 // -----------------------------------------------------------------------------
-// Генерация платёжной ссылки (MVP stub)
+// Генерация платёжной ссылки
 // -----------------------------------------------------------------------------
 
+// GeneratePaymentLink — счёт у платёжного провайдера. Сумма берётся из
+// брони, amount от модели только сверяется: за модель не доверяем.
 func (s *ToolsService) GeneratePaymentLink(ctx context.Context, amount float64, bookingID string) (string, error) {
-	if amount <= 0 {
-		return "", fmt.Errorf("некорректная сумма")
+	if s.Payments == nil {
+		return "", fmt.Errorf("онлайн-оплата не подключена, оплата на месте")
+	}
+	if bookingID == "" {
+		return "", fmt.Errorf("не указан номер брони")
 	}
 
-	// В боевом продукте будет интеграция Kaspi / Stripe / WalletPay
-	return fmt.Sprintf("https://pay.example.com/?booking=%s&amount=%.0f", bookingID, amount), nil
+	inv, err := s.Payments.CreatePaymentLink(ctx, bookingID)
+	if err != nil {
+		return "", err
+	}
+	if amount > 0 && amount != inv.Amount {
+		log.Printf("⚠️ Payment link %s: model amount %.0f, booking amount %.0f", bookingID, amount, inv.Amount)
+	}
+
+	hold := ""
+	if s.Payments.HoldTimeout > 0 {
+		hold = fmt.Sprintf("; без оплаты бронь снимется через %s", formatHold(s.Payments.HoldTimeout))
+	}
+	return fmt.Sprintf("%s; сумма: %.0f %s%s", inv.PaymentURL, inv.Amount, s.Tariff.Currency, hold), nil
+}
+
+func formatHold(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%d ч", int(d/time.Hour))
+	}
+	return fmt.Sprintf("%d мин", int(d/time.Minute))
+}
+
+// -----------------------------------------------------------------------------
+// Helpers
+// -----------------------------------------------------------------------------
+
+// parseStart — дата+время начала с проверкой часов работы (12:00–04:00).
+func parseStart(date, timeStr string, hours int) (time.Time, error) {
+	start, err := time.Parse("2006-01-02 15:04", date+" "+timeStr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("неверный формат даты/времени. Используйте YYYY-MM-DD и HH:MM")
+	}
+	if !availability.WithinOpeningHours(start, hours) {
+		return time.Time{}, fmt.Errorf("клуб работает 12:00–04:00: сеанс %s на %d ч не помещается", timeStr, hours)
+	}
+	return start, nil
 }
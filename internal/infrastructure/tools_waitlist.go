@@ -0,0 +1,392 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/core"
+	"whatsapp-analytics-mvp/internal/events"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// maxWaitlistFlex — на сколько часов в обе стороны клиент может разрешить сдвиг.
+const maxWaitlistFlex = 3
+
+// -----------------------------------------------------------------------------
+// Лист ожидания (клиент)
+// -----------------------------------------------------------------------------
+
+func (s *ToolsService) JoinWaitlist(ctx context.Context, clientID, date, timeStr string, seats, hours int, game string, flexHours int) (string, error) {
+	repo, err := s.waitlist()
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkSeats(ctx, seats); err != nil {
+		return "", err
+	}
+	if hours <= 0 || hours > availability.MaxHours {
+		return "", fmt.Errorf("часы: 1–%d", availability.MaxHours)
+	}
+	if flexHours < 0 || flexHours > maxWaitlistFlex {
+		return "", fmt.Errorf("сдвиг по времени: 0–%d ч", maxWaitlistFlex)
+	}
+
+	start, err := parseStart(date, timeStr, hours)
+	if err != nil {
+		return "", err
+	}
+	if !start.After(availability.Now()) {
+		return "", fmt.Errorf("это время уже прошло")
+	}
+
+	entry := &models.WaitlistEntry{
+		ClientID:  clientID,
+		Start:     start,
+		Seats:     seats,
+		Hours:     hours,
+		Game:      game,
+		FlexHours: flexHours,
+	}
+	if err := repo.AddWaitlist(ctx, entry); err != nil {
+		return "", err
+	}
+	if entry.Status == models.WaitlistOffered {
+		return fmt.Sprintf("Заявка №%d: места уже придержаны (бронь %s) — ждём ответа клиента.", entry.ID, entry.BookingID), nil
+	}
+
+	position, err := s.waitlistPosition(ctx, repo, *entry)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("⏳ Waitlist #%d: %s %s seats=%d hours=%d flex=%d", entry.ID, clientID, start.Format("2006-01-02 15:04"), seats, hours, flexHours)
+
+	// Вдруг места уже есть (кто-то отменил между проверкой и заявкой)
+	s.kickWaitlist()
+
+	return fmt.Sprintf("Заявка №%d в листе ожидания: %s, мест — %d, часов — %d%s. В очереди на это время: %d-й. Когда освободится — придержим места на %s и напишем.",
+		entry.ID, start.Format("02.01 15:04"), seats, hours, formatFlex(flexHours), position, formatHold(s.WaitlistOfferTTL)), nil
+}
+
+func (s *ToolsService) AnswerWaitlistOffer(ctx context.Context, clientID string, accept bool) (string, error) {
+	repo, err := s.waitlist()
+	if err != nil {
+		return "", err
+	}
+	lifecycle, err := s.lifecycle()
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := repo.ListClientWaitlist(ctx, clientID)
+	if err != nil {
+		return "", err
+	}
+	var offer *models.WaitlistEntry
+	for i := range entries {
+		if entries[i].Status == models.WaitlistOffered {
+			offer = &entries[i]
+		}
+	}
+	if offer == nil {
+		return "", fmt.Errorf("активных предложений из листа ожидания нет")
+	}
+
+	bk, err := lifecycle.GetBooking(ctx, offer.BookingID)
+	if err != nil {
+		return "", err
+	}
+	if bk == nil || !bk.IsActive() {
+		_ = repo.SetWaitlistStatus(ctx, offer.ID, models.WaitlistExpired)
+		return "", fmt.Errorf("предложение уже истекло")
+	}
+
+	if !accept {
+		if err := lifecycle.SetBookingStatus(ctx, bk.BookingID, models.BookingCancelled, models.ActorClient, "отказ от предложения из листа ожидания"); err != nil {
+			return "", err
+		}
+		if err := repo.SetWaitlistStatus(ctx, offer.ID, models.WaitlistCancelled); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Предложение по заявке №%d снято, места отданы следующему в очереди.", offer.ID), nil
+	}
+
+	if err := repo.SetWaitlistStatus(ctx, offer.ID, models.WaitlistAccepted); err != nil {
+		return "", err
+	}
+	log.Printf("✓ Waitlist #%d accepted: %s", offer.ID, bk.BookingID)
+	return fmt.Sprintf("Бронь за клиентом: %s. Дальше — оплата (GeneratePaymentLink).",
+		formatBooking(*bk, s.Tariff.Currency)), nil
+}
+
+func (s *ToolsService) LeaveWaitlist(ctx context.Context, clientID string, entryID int64) (string, error) {
+	repo, err := s.waitlist()
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := repo.ListClientWaitlist(ctx, clientID)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if e.ID != entryID {
+			continue
+		}
+		if e.Status == models.WaitlistOffered {
+			return s.AnswerWaitlistOffer(ctx, clientID, false)
+		}
+		if err := repo.SetWaitlistStatus(ctx, e.ID, models.WaitlistCancelled); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Заявка №%d снята с листа ожидания.", e.ID), nil
+	}
+	return "", fmt.Errorf("заявка №%d не найдена", entryID)
+}
+
+// -----------------------------------------------------------------------------
+// Обработка очереди: освободились места → предложение первому подходящему
+// -----------------------------------------------------------------------------
+
+// ProcessWaitlist — снимает просроченные предложения и предлагает
+// освободившиеся места по очереди. Клиенту, которому места подошли,
+// создаётся бронь-холд на WaitlistOfferTTL. Возвращает число предложений.
+func (s *ToolsService) ProcessWaitlist(ctx context.Context) (int, error) {
+	repo, err := s.waitlist()
+	if err != nil {
+		return 0, err
+	}
+	lifecycle, err := s.lifecycle()
+	if err != nil {
+		return 0, err
+	}
+
+	s.waitlistMu.Lock()
+	defer s.waitlistMu.Unlock()
+
+	now := availability.Now()
+	s.expireOffers(ctx, repo, lifecycle, now)
+	if _, err := repo.ExpireWaitlist(ctx, now); err != nil {
+		return 0, err
+	}
+
+	waiting, err := repo.ListWaiting(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	offered := 0
+	for _, e := range waiting {
+		bk := s.offerSlot(ctx, e, now)
+		if bk == nil {
+			continue
+		}
+
+		expires := now.Add(s.WaitlistOfferTTL)
+		if err := repo.MarkWaitlistOffered(ctx, e.ID, bk.BookingID, expires); err != nil {
+			log.Printf("[Waitlist] #%d offered %s but not saved: %v", e.ID, bk.BookingID, err)
+			continue
+		}
+		offered++
+
+		log.Printf("⏳ Waitlist #%d → offer %s (%s) until %s", e.ID, bk.BookingID, bk.Start.Format("2006-01-02 15:04"), expires.Format("15:04"))
+		s.notifyClient(ctx, e.ClientID, fmt.Sprintf(
+			"Освободились места! %s, мест — %d, часов — %d, %.0f %s. Держим до %s — ответьте «да», чтобы забрать, или «нет», чтобы отдать следующему.",
+			bk.Start.Format("02.01 15:04"), bk.Seats, bk.Hours, bk.Amount, s.Tariff.Currency, expires.Format("15:04")))
+	}
+	return offered, nil
+}
+
+// offerSlot — пробует забронировать заявку: сначала точное время, потом
+// ближайшие сдвиги в пределах FlexHours. nil — мест всё ещё нет.
+func (s *ToolsService) offerSlot(ctx context.Context, e models.WaitlistEntry, now time.Time) *models.Booking {
+	for _, start := range waitlistCandidates(e) {
+		if !start.After(now) || !availability.WithinOpeningHours(start, e.Hours) {
+			continue
+		}
+
+		free, err := s.DB.GetFreeRigs(ctx, start, start.Add(time.Duration(e.Hours)*time.Hour), e.Game)
+		if err != nil {
+			log.Printf("[Waitlist] #%d free rigs: %v", e.ID, err)
+			return nil
+		}
+		if len(free) < e.Seats {
+			continue
+		}
+
+		bk, err := s.book(ctx, e.ClientID, start, e.Seats, e.Hours, e.Game, "", models.ActorBot)
+		if errors.Is(err, models.ErrNotEnoughSeats) {
+			continue
+		}
+		if err != nil {
+			log.Printf("[Waitlist] #%d booking: %v", e.ID, err)
+			return nil
+		}
+		return bk
+	}
+	return nil
+}
+
+// waitlistCandidates — точное время, затем ±1 ч, ±2 ч … до FlexHours.
+func waitlistCandidates(e models.WaitlistEntry) []time.Time {
+	out := []time.Time{e.Start}
+	for d := 1; d <= e.FlexHours; d++ {
+		shift := time.Duration(d) * time.Hour
+		out = append(out, e.Start.Add(-shift), e.Start.Add(shift))
+	}
+	return out
+}
+
+// expireOffers — клиент не ответил вовремя: холд снимается, если бронь
+// не оплачена; оплаченная бронь считается принятым предложением.
+func (s *ToolsService) expireOffers(ctx context.Context, repo core.WaitlistRepository, lifecycle core.BookingLifecycleRepository, now time.Time) {
+	offers, err := repo.ListExpiredOffers(ctx, now)
+	if err != nil {
+		log.Printf("[Waitlist] expired offers: %v", err)
+		return
+	}
+
+	for _, e := range offers {
+		bk, err := lifecycle.GetBooking(ctx, e.BookingID)
+		if err != nil {
+			log.Printf("[Waitlist] #%d booking %s: %v", e.ID, e.BookingID, err)
+			continue
+		}
+
+		if bk != nil && (bk.Status == models.BookingPaid || bk.Status == models.BookingConfirmed) {
+			_ = repo.SetWaitlistStatus(ctx, e.ID, models.WaitlistAccepted)
+			continue
+		}
+		if bk != nil && bk.Status == models.BookingCreated {
+			if err := lifecycle.SetBookingStatus(ctx, bk.BookingID, models.BookingCancelled, models.ActorBot, "предложение из листа ожидания истекло"); err != nil {
+				log.Printf("[Waitlist] #%d release %s: %v", e.ID, bk.BookingID, err)
+				continue
+			}
+			s.notifyClient(ctx, e.ClientID, fmt.Sprintf(
+				"Время на ответ вышло — места на %s отданы следующему в очереди.", bk.Start.Format("02.01 15:04")))
+		}
+		_ = repo.SetWaitlistStatus(ctx, e.ID, models.WaitlistExpired)
+	}
+}
+
+// RunWaitlist — периодически обрабатывает очередь до отмены ctx.
+func (s *ToolsService) RunWaitlist(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.ProcessWaitlist(ctx); err != nil {
+				log.Printf("[Waitlist] %v", err)
+			}
+		}
+	}
+}
+
+// OnSeatsFreed — подписчик шины на отмену и перенос брони: освободившиеся
+// места сразу предлагаются очереди, не дожидаясь тика RunWaitlist.
+func (s *ToolsService) OnSeatsFreed(ctx context.Context, e events.Event) error {
+	if _, ok := s.DB.(core.WaitlistRepository); !ok {
+		return nil
+	}
+	_, err := s.ProcessWaitlist(ctx)
+	return err
+}
+
+// kickWaitlist — внеочередной проход после новой заявки: места могли
+// освободиться между проверкой и заявкой.
+func (s *ToolsService) kickWaitlist() {
+	if _, ok := s.DB.(core.WaitlistRepository); !ok {
+		return
+	}
+	go func() {
+		if _, err := s.ProcessWaitlist(context.Background()); err != nil {
+			log.Printf("[Waitlist] %v", err)
+		}
+	}()
+}
+
+// -----------------------------------------------------------------------------
+// Helpers
+// -----------------------------------------------------------------------------
+
+func (s *ToolsService) waitlist() (core.WaitlistRepository, error) {
+	repo, ok := s.DB.(core.WaitlistRepository)
+	if !ok {
+		return nil, fmt.Errorf("лист ожидания недоступен")
+	}
+	return repo, nil
+}
+
+// waitlistPosition — место заявки среди ждущих на пересекающееся время
+// (по порядку постановки).
+func (s *ToolsService) waitlistPosition(ctx context.Context, repo core.WaitlistRepository, entry models.WaitlistEntry) (int, error) {
+	waiting, err := repo.ListWaiting(ctx, availability.Now())
+	if err != nil {
+		return 0, err
+	}
+	end := entry.Start.Add(time.Duration(entry.Hours) * time.Hour)
+
+	position := 1
+	for _, e := range waiting {
+		if e.ID >= entry.ID {
+			break
+		}
+		if availability.Overlaps(models.Booking{Start: e.Start, Hours: e.Hours}, entry.Start, end) {
+			position++
+		}
+	}
+	return position, nil
+}
+
+// recordMiss — учёт упущенного спроса для аналитики расширения.
+func (s *ToolsService) recordMiss(ctx context.Context, clientID string, start time.Time, seats, hours int, game string) {
+	repo, ok := s.DB.(core.WaitlistRepository)
+	if !ok {
+		return
+	}
+	if err := repo.RecordDemandMiss(ctx, models.DemandMiss{
+		ClientID: clientID, Start: start, Seats: seats, Hours: hours, Game: game,
+	}); err != nil {
+		log.Printf("[Waitlist] demand miss: %v", err)
+	}
+}
+
+// notifyClient — сообщение вне диалога: уходит в канал клиента и
+// сохраняется в истории, чтобы модель видела его при ответе клиента.
+func (s *ToolsService) notifyClient(ctx context.Context, clientID, text string) {
+	if cm, ok := s.DB.(core.ContextManager); ok {
+		if err := cm.SaveMessage(ctx, clientID, "bot", text); err != nil {
+			log.Printf("[Waitlist] save message for %s: %v", clientID, err)
+		}
+	}
+	if s.Messenger == nil {
+		return
+	}
+	if err := s.Messenger.SendToClient(ctx, clientID, text); err != nil {
+		log.Printf("[Waitlist] message to %s failed: %v", clientID, err)
+	}
+}
+
+func formatFlex(h int) string {
+	if h == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", можно ±%d ч", h)
+}
+
+func formatWaitlistEntry(e models.WaitlistEntry) string {
+	out := fmt.Sprintf("заявка №%d: %s, мест — %d, часов — %d%s", e.ID, e.Start.Format("2006-01-02 15:04"), e.Seats, e.Hours, formatFlex(e.FlexHours))
+	if e.Status == models.WaitlistOffered {
+		out += fmt.Sprintf(" — места придержаны до %s (бронь %s)", e.OfferExpiresAt.Format("15:04"), e.BookingID)
+	} else {
+		out += " — ждёт"
+	}
+	return out
+}
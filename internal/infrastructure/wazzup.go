@@ -0,0 +1,220 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/core"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// ============================================================================
+// WAZZUP (WHATSAPP) SENDER — API v3
+// ============================================================================
+
+const DefaultWazzupBaseURL = "https://api.wazzup24.com"
+
+// WazzupSender — отправка в WhatsApp через Wazzup (POST /v3/message).
+// Сетевые ошибки, 429 и 5xx повторяются с нарастающей паузой; повтор идёт с
+// тем же crmMessageId, и Wazzup не отправит сообщение дважды. Если задан
+// Store, каждое сообщение записывается в outbound_messages, а его статусы
+// обновляет вебхук statuses.
+type WazzupSender struct {
+	APIKey  string
+	BaseURL string // без завершающего «/»; тесты подставляют локальный сервер
+	Client  *http.Client
+	Store   core.OutboundMessageRepository
+
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+func NewWazzupSender(apiKey string) *WazzupSender {
+	return &WazzupSender{
+		APIKey:      apiKey,
+		BaseURL:     DefaultWazzupBaseURL,
+		Client:      &http.Client{Timeout: 15 * time.Second},
+		MaxAttempts: 3,
+		BaseBackoff: time.Second,
+		MaxBackoff:  10 * time.Second,
+	}
+}
+
+// WazzupAPIError — ответ Wazzup не 2xx.
+type WazzupAPIError struct {
+	Code        int
+	Err         string // код ошибки Wazzup, например CHANNEL_BLOCKED
+	Description string
+}
+
+func (e *WazzupAPIError) Error() string {
+	msg := fmt.Sprintf("wazzup: HTTP %d", e.Code)
+	if e.Err != "" {
+		msg += " " + e.Err
+	}
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	return msg
+}
+
+// Temporary — имеет ли смысл повторить запрос.
+func (e *WazzupAPIError) Temporary() bool {
+	return e.Code == http.StatusTooManyRequests || e.Code >= 500
+}
+
+// wazzupRepeatedCrmID — Wazzup уже принял сообщение с этим crmMessageId
+// (ответ на первую попытку потерялся).
+const wazzupRepeatedCrmID = "REPEATED_CRM_MESSAGE_ID"
+
+// wazzupMessage — тело POST /v3/message: text или contentUri.
+type wazzupMessage struct {
+	ChannelID    string `json:"channelId"`
+	ChatType     string `json:"chatType"`
+	ChatID       string `json:"chatId"`
+	Text         string `json:"text,omitempty"`
+	ContentURI   string `json:"contentUri,omitempty"`
+	CrmMessageID string `json:"crmMessageId"`
+}
+
+func (w *WazzupSender) Send(channelID, clientPhone, messageText string) error {
+	if strings.TrimSpace(messageText) == "" {
+		return nil
+	}
+	return w.send(context.Background(), wazzupMessage{ChannelID: channelID, ChatID: clientPhone, Text: messageText})
+}
+
+func (w *WazzupSender) SendMedia(channelID, clientPhone, contentURI string) error {
+	if contentURI == "" {
+		return errors.New("wazzup: пустой contentUri")
+	}
+	return w.send(context.Background(), wazzupMessage{ChannelID: channelID, ChatID: clientPhone, ContentURI: contentURI})
+}
+
+func (w *WazzupSender) send(ctx context.Context, m wazzupMessage) error {
+	if w.APIKey == "" {
+		log.Printf("[WazzupSender] ⚠️ API Key not set — skipping send to %s", m.ChatID)
+		return nil
+	}
+	m.ChatType = "whatsapp"
+	m.CrmMessageID = fmt.Sprintf("out_%d", time.Now().UnixNano())
+
+	var id int64
+	if w.Store != nil {
+		var err error
+		id, err = w.Store.SaveOutboundMessage(ctx, &models.OutboundMessage{
+			CrmMessageID: m.CrmMessageID,
+			ClientID:     "WA-" + m.ChatID,
+			ChannelID:    m.ChannelID,
+			Text:         m.Text,
+			ContentURI:   m.ContentURI,
+		})
+		if err != nil {
+			// журнал статусов не должен мешать ответу клиенту
+			log.Printf("[WazzupSender] ⚠️ outbound log: %v", err)
+		}
+	}
+
+	attempts := max(w.MaxAttempts, 1)
+	attempt := 1
+	var err error
+retry:
+	for ; ; attempt++ {
+		var messageID string
+		if messageID, err = w.post(ctx, m); err == nil {
+			if id > 0 {
+				if err := w.Store.MarkOutboundSent(ctx, id, messageID, attempt); err != nil {
+					log.Printf("[WazzupSender] ⚠️ outbound log: %v", err)
+				}
+			}
+			return nil
+		}
+		if !wazzupRetryable(err) || attempt == attempts {
+			break
+		}
+
+		wait := backoffDelay(w.BaseBackoff, w.MaxBackoff, attempt)
+		log.Printf("[WazzupSender] %s attempt %d: %v (retry in %s)", m.ChatID, attempt, err, wait)
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			break retry
+		case <-time.After(wait):
+		}
+	}
+
+	if id > 0 {
+		if e := w.Store.MarkOutboundFailed(ctx, id, err.Error(), attempt); e != nil {
+			log.Printf("[WazzupSender] ⚠️ outbound log: %v", e)
+		}
+	}
+	return err
+}
+
+// post — одна попытка; возвращает messageId Wazzup.
+func (w *WazzupSender) post(ctx context.Context, m wazzupMessage) (string, error) {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	base := w.BaseURL
+	if base == "" {
+		base = DefaultWazzupBaseURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(base, "/")+"/v3/message", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+w.APIKey)
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("wazzup: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("wazzup: %w", err)
+	}
+
+	var out struct {
+		MessageID   string `json:"messageId"`
+		Error       string `json:"error"`
+		Description string `json:"description"`
+	}
+	_ = json.Unmarshal(raw, &out)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if out.Error == wazzupRepeatedCrmID {
+			return out.MessageID, nil
+		}
+		apiErr := &WazzupAPIError{Code: resp.StatusCode, Err: out.Error, Description: out.Description}
+		if apiErr.Err == "" && apiErr.Description == "" {
+			apiErr.Description = strings.TrimSpace(string(raw))
+		}
+		return "", apiErr
+	}
+	return out.MessageID, nil
+}
+
+// wazzupRetryable — сетевая ошибка, 429 или 5xx.
+func wazzupRetryable(err error) bool {
+	var apiErr *WazzupAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
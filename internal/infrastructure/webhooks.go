@@ -0,0 +1,224 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/core"
+	"whatsapp-analytics-mvp/internal/events"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// ============================================================================
+// OUTBOUND WEBHOOKS
+// ============================================================================
+
+// Заголовки исходящего вебхука. Подпись — hex HMAC-SHA256 тела на секрете
+// получателя с префиксом «sha256=»; проверка — WebhookSignature.
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookIDHeader        = "X-Webhook-Id" // ID события, общий для повторов
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// WebhookDispatcher — исходящие вебхуки на доменные события. Подписка на
+// шину кладёт доставку каждому заинтересованному получателю в очередь
+// (webhook_deliveries), Run отправляет её. Не 2xx или сетевая ошибка →
+// повтор с нарастающей паузой, после MaxAttempts — dead до ручного повтора
+// через служебный API. Доставка не меньше одного раза: получатель
+// отсеивает дубли по X-Webhook-Id.
+type WebhookDispatcher struct {
+	Repo        core.WebhookRepository
+	Subscribers []models.WebhookSubscriber
+	Client      *http.Client
+
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Batch       int
+}
+
+// Параметры по умолчанию.
+const (
+	defaultWebhookAttempts = 8
+	defaultWebhookBackoff  = 30 * time.Second
+	defaultWebhookMaxDelay = time.Hour
+	defaultWebhookBatch    = 20
+	defaultWebhookTimeout  = 10 * time.Second
+)
+
+func NewWebhookDispatcher(repo core.WebhookRepository, subscribers []models.WebhookSubscriber) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		Repo:        repo,
+		Subscribers: subscribers,
+		Client:      &http.Client{Timeout: defaultWebhookTimeout},
+		MaxAttempts: defaultWebhookAttempts,
+		BaseBackoff: defaultWebhookBackoff,
+		MaxBackoff:  defaultWebhookMaxDelay,
+		Batch:       defaultWebhookBatch,
+	}
+}
+
+// webhookBody — тело запроса получателю.
+type webhookBody struct {
+	ID         string          `json:"id"`
+	Event      string          `json:"event"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Subscribe — синхронно на события, нужные хотя бы одному получателю:
+// не удалось поставить в очередь — outbox повторит событие.
+func (d *WebhookDispatcher) Subscribe(bus core.EventBus) {
+	for _, name := range events.Names {
+		if len(d.recipients(name)) > 0 {
+			bus.Subscribe(name, d.enqueue)
+		}
+	}
+}
+
+func (d *WebhookDispatcher) recipients(name string) []models.WebhookSubscriber {
+	var out []models.WebhookSubscriber
+	for _, s := range d.Subscribers {
+		if s.Wants(name) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (d *WebhookDispatcher) enqueue(ctx context.Context, e events.Event) error {
+	data, err := events.Encode(e)
+	if err != nil {
+		return err
+	}
+	// Из outbox — ID записи, общий для повторов релея; напрямую — разовый.
+	id, ok := events.ID(ctx)
+	if !ok {
+		id = fmt.Sprintf("evt_t%d", time.Now().UnixNano())
+	}
+	body, err := json.Marshal(webhookBody{ID: id, Event: e.Name(), OccurredAt: time.Now().UTC(), Data: json.RawMessage(data)})
+	if err != nil {
+		return err
+	}
+
+	var list []models.WebhookDelivery
+	for _, s := range d.recipients(e.Name()) {
+		list = append(list, models.WebhookDelivery{Subscriber: s.Name, EventID: id, Event: e.Name(), Payload: string(body)})
+	}
+	return d.Repo.EnqueueWebhooks(ctx, list)
+}
+
+// -----------------------------------------------------------------------------
+// DELIVERY
+// -----------------------------------------------------------------------------
+
+// Deliver — один проход по очереди; возвращает число доставленных.
+func (d *WebhookDispatcher) Deliver(ctx context.Context) (int, error) {
+	due, err := d.Repo.ListDueWebhooks(ctx, availability.Now(), d.Batch)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, w := range due {
+		code, err := d.send(ctx, w)
+		if err == nil {
+			if err := d.Repo.MarkWebhookDelivered(ctx, w.ID, code); err != nil {
+				return delivered, err
+			}
+			delivered++
+			continue
+		}
+
+		attempt := w.Attempts + 1
+		dead := attempt >= d.MaxAttempts
+		if dead {
+			log.Printf("[Webhooks] ❌ #%d %s → %s dead after %d attempts: %v", w.ID, w.Event, w.Subscriber, attempt, err)
+		} else {
+			log.Printf("[Webhooks] #%d %s → %s attempt %d: %v", w.ID, w.Event, w.Subscriber, attempt, err)
+		}
+		retryAt := availability.Now().Add(backoffDelay(d.BaseBackoff, d.MaxBackoff, attempt))
+		if err := d.Repo.MarkWebhookFailed(ctx, w.ID, code, err.Error(), retryAt, dead); err != nil {
+			return delivered, err
+		}
+	}
+	return delivered, nil
+}
+
+// send — POST тела получателю; код ответа возвращается и при ошибке.
+func (d *WebhookDispatcher) send(ctx context.Context, w models.WebhookDelivery) (int, error) {
+	sub, ok := d.subscriber(w.Subscriber)
+	if !ok {
+		return 0, fmt.Errorf("получатель %q не настроен", w.Subscriber)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader([]byte(w.Payload)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, w.Event)
+	req.Header.Set(WebhookIDHeader, w.EventID)
+	req.Header.Set("X-Webhook-Attempt", strconv.Itoa(w.Attempts+1))
+	req.Header.Set(WebhookSignatureHeader, WebhookSignature(sub.Secret, []byte(w.Payload)))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(snippet))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+// subscriber — текущие настройки получателя: URL и секрет берутся при
+// отправке, смена конфига действует и на очередь.
+func (d *WebhookDispatcher) subscriber(name string) (models.WebhookSubscriber, bool) {
+	for _, s := range d.Subscribers {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return models.WebhookSubscriber{}, false
+}
+
+// Run — опрашивает очередь до отмены ctx.
+func (d *WebhookDispatcher) Run(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.Deliver(ctx); err != nil {
+			log.Printf("[Webhooks] %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// WebhookSignature — значение X-Webhook-Signature для тела body.
+func WebhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,270 @@
+package infrastructure_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/data"
+	"whatsapp-analytics-mvp/internal/events"
+	"whatsapp-analytics-mvp/internal/infrastructure"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// receiver — локальный получатель вебхуков: запоминает запросы и отвечает
+// кодом status.
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []receivedHook
+}
+
+type receivedHook struct {
+	header http.Header
+	body   []byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	r.requests = append(r.requests, receivedHook{header: req.Header.Clone(), body: body})
+	status := r.status
+	r.mu.Unlock()
+	w.WriteHeader(status)
+}
+
+func (r *receiver) setStatus(code int) {
+	r.mu.Lock()
+	r.status = code
+	r.mu.Unlock()
+}
+
+func (r *receiver) received() []receivedHook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedHook(nil), r.requests...)
+}
+
+type webhookEnv struct {
+	repo *data.SQLiteContextRepo
+	bus  *infrastructure.MemoryEventBus
+	disp *infrastructure.WebhookDispatcher
+	recv *receiver
+}
+
+func newWebhookEnv(t *testing.T, filters ...string) *webhookEnv {
+	t.Helper()
+	repo, err := data.NewSQLiteContextRepo(filepath.Join(t.TempDir(), "webhooks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.DB.Close() })
+
+	recv := &receiver{status: http.StatusOK}
+	srv := httptest.NewServer(recv)
+	t.Cleanup(srv.Close)
+
+	disp := infrastructure.NewWebhookDispatcher(repo, []models.WebhookSubscriber{
+		{Name: "crm", URL: srv.URL, Secret: "hook-secret", Events: filters},
+	})
+	bus := infrastructure.NewMemoryEventBus()
+	disp.Subscribe(bus)
+	return &webhookEnv{repo: repo, bus: bus, disp: disp, recv: recv}
+}
+
+func (e *webhookEnv) publish(t *testing.T, ev events.Event) {
+	t.Helper()
+	if err := e.bus.Publish(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// only — единственная доставка в очереди.
+func (e *webhookEnv) only(t *testing.T) *models.WebhookDelivery {
+	t.Helper()
+	list, err := e.repo.ListWebhookDeliveries(context.Background(), models.WebhookFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("deliveries = %d, want 1", len(list))
+	}
+	return &list[0]
+}
+
+// makeDue — переносит следующую попытку в прошлое, не дожидаясь паузы.
+func (e *webhookEnv) makeDue(t *testing.T) {
+	t.Helper()
+	if _, err := e.repo.DB.Exec(`UPDATE webhook_deliveries SET next_attempt_at = ?`, availability.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebhookSubscriberWants(t *testing.T) {
+	tests := []struct {
+		filter string
+		event  string
+		want   bool
+	}{
+		{"*", events.NamePaymentReceived, true},
+		{events.NameBookingCreated, events.NameBookingCreated, true},
+		{events.NameBookingCreated, events.NameBookingCancelled, false},
+		{"booking.*", events.NameBookingCreated, true},
+		{"booking.*", events.NameBookingRescheduled, true},
+		{"booking.*", events.NamePaymentReceived, false},
+		{"book.*", events.NameBookingCreated, false},
+	}
+	for _, tt := range tests {
+		s := models.WebhookSubscriber{Events: []string{tt.filter}}
+		if got := s.Wants(tt.event); got != tt.want {
+			t.Errorf("Wants(%q) with filter %q = %v, want %v", tt.event, tt.filter, got, tt.want)
+		}
+	}
+}
+
+// Подписка «booking.*» ставит в очередь брони, но не оплаты.
+func TestWebhookDispatcherFiltersEvents(t *testing.T) {
+	e := newWebhookEnv(t, "booking.*")
+	e.publish(t, events.BookingCreated{BookingID: "bk_1", ClientID: "TG-1"})
+	e.publish(t, events.PaymentReceived{BookingID: "bk_1", ClientID: "TG-1", Amount: 4000})
+
+	w := e.only(t)
+	if w.Event != events.NameBookingCreated || w.Subscriber != "crm" || w.Status != models.WebhookPending {
+		t.Errorf("delivery = %s → %s (%s), want %s → crm (pending)", w.Event, w.Subscriber, w.Status, events.NameBookingCreated)
+	}
+}
+
+// Релей повторил событие outbox (упал другой подписчик) — ID тот же,
+// доставка в очереди одна; прямые публикации — разные события.
+func TestWebhookEnqueueIdempotent(t *testing.T) {
+	e := newWebhookEnv(t, "*")
+	ctx := events.WithID(context.Background(), "evt_7")
+	for i := 0; i < 2; i++ {
+		if err := e.bus.Publish(ctx, events.BookingCreated{BookingID: "bk_1", ClientID: "TG-1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w := e.only(t); w.EventID != "evt_7" {
+		t.Errorf("event id = %q, want evt_7", w.EventID)
+	}
+
+	e.publish(t, events.ClientEscalated{ClientID: "TG-1"})
+	e.publish(t, events.ClientEscalated{ClientID: "TG-1"})
+	list, err := e.repo.ListWebhookDeliveries(context.Background(), models.WebhookFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 {
+		t.Errorf("deliveries = %d, want 3", len(list))
+	}
+}
+
+func TestWebhookDeliverySignature(t *testing.T) {
+	e := newWebhookEnv(t, "*")
+	e.publish(t, events.BookingCreated{BookingID: "bk_1", ClientID: "TG-1"})
+
+	n, err := e.disp.Deliver(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("Deliver = %d, %v; want 1", n, err)
+	}
+	got := e.recv.received()
+	if len(got) != 1 {
+		t.Fatalf("requests = %d, want 1", len(got))
+	}
+
+	mac := hmac.New(sha256.New, []byte("hook-secret"))
+	mac.Write(got[0].body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if sig := got[0].header.Get(infrastructure.WebhookSignatureHeader); sig != want {
+		t.Errorf("signature = %q, want %q", sig, want)
+	}
+	if sig := infrastructure.WebhookSignature("hook-secret", got[0].body); sig != want {
+		t.Errorf("WebhookSignature = %q, want %q", sig, want)
+	}
+	if ev := got[0].header.Get(infrastructure.WebhookEventHeader); ev != events.NameBookingCreated {
+		t.Errorf("event header = %q, want %q", ev, events.NameBookingCreated)
+	}
+	if w := e.only(t); w.Status != models.WebhookDelivered || w.Attempts != 1 {
+		t.Errorf("delivery = %s after %d attempts, want delivered after 1", w.Status, w.Attempts)
+	}
+}
+
+// Ошибки получателя — повтор с удваивающейся паузой до MaxBackoff, после
+// MaxAttempts — dead; ручной повтор возвращает доставку в очередь.
+func TestWebhookBackoffDeadAndReplay(t *testing.T) {
+	e := newWebhookEnv(t, "*")
+	e.disp.BaseBackoff = time.Minute
+	e.disp.MaxBackoff = 4 * time.Minute
+	e.disp.MaxAttempts = 4
+	e.recv.setStatus(http.StatusInternalServerError)
+	e.publish(t, events.BookingCreated{BookingID: "bk_1", ClientID: "TG-1"})
+
+	ctx := context.Background()
+	for i, delay := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		before := availability.Now()
+		if n, err := e.disp.Deliver(ctx); err != nil || n != 0 {
+			t.Fatalf("attempt %d: Deliver = %d, %v; want 0", i+1, n, err)
+		}
+		w := e.only(t)
+		if w.Status != models.WebhookPending || w.Attempts != i+1 || w.ResponseCode != http.StatusInternalServerError {
+			t.Fatalf("attempt %d: %s, attempts %d, code %d", i+1, w.Status, w.Attempts, w.ResponseCode)
+		}
+		if got := w.NextAttemptAt.Sub(before); got < delay || got > delay+2*time.Second {
+			t.Errorf("attempt %d: retry in %s, want %s", i+1, got, delay)
+		}
+
+		// Пауза не прошла — повторной отправки нет.
+		if _, err := e.disp.Deliver(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(e.recv.received()); got != i+1 {
+			t.Fatalf("attempt %d: requests = %d, want %d", i+1, got, i+1)
+		}
+		e.makeDue(t)
+	}
+
+	if _, err := e.disp.Deliver(ctx); err != nil {
+		t.Fatal(err)
+	}
+	w := e.only(t)
+	if w.Status != models.WebhookDead || w.Attempts != 4 {
+		t.Fatalf("after MaxAttempts: %s, attempts %d; want dead, 4", w.Status, w.Attempts)
+	}
+
+	// dead в очередь не попадает.
+	e.makeDue(t)
+	if _, err := e.disp.Deliver(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(e.recv.received()); got != 4 {
+		t.Fatalf("dead delivery was retried: requests = %d", got)
+	}
+
+	n, err := e.repo.ReplayWebhooks(ctx, w.ID, "")
+	if err != nil || n != 1 {
+		t.Fatalf("ReplayWebhooks = %d, %v; want 1", n, err)
+	}
+	if w := e.only(t); w.Status != models.WebhookPending || w.Attempts != 0 {
+		t.Fatalf("after replay: %s, attempts %d; want pending, 0", w.Status, w.Attempts)
+	}
+
+	e.recv.setStatus(http.StatusNoContent)
+	if n, err := e.disp.Deliver(ctx); err != nil || n != 1 {
+		t.Fatalf("Deliver after replay = %d, %v; want 1", n, err)
+	}
+	if w := e.only(t); w.Status != models.WebhookDelivered {
+		t.Errorf("after replay: %s, want delivered", w.Status)
+	}
+	if n, _ := e.repo.ReplayWebhooks(ctx, w.ID, ""); n != 0 {
+		t.Errorf("ReplayWebhooks replayed a delivered webhook")
+	}
+}
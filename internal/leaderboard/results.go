@@ -0,0 +1,240 @@
+package leaderboard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// Игры, чьи результаты импортируем.
+const (
+	GameAssettoCorsa   = "Assetto Corsa"
+	GameAutomobilista2 = "Automobilista2"
+)
+
+// ErrUnknownFormat — JSON не похож ни на один из поддерживаемых форматов.
+var ErrUnknownFormat = errors.New("неизвестный формат файла результатов")
+
+// Options — что подставить, если в файле этого нет.
+type Options struct {
+	Game   string    // игра для "клубного" формата без поля game
+	Source string    // имя файла: из него же берётся дата сессии AC
+	Date   time.Time // дата сессии по умолчанию
+}
+
+// -----------------------------------------------------------------------------
+// PARSE
+// -----------------------------------------------------------------------------
+
+// Parse разбирает файл результатов в круги. Поддерживаются:
+//   - результаты сервера Assetto Corsa (TrackName + Laps, время в мс,
+//     круги со срезками не зачитываются);
+//   - клубный формат {game, track, date, laps: [{driver, client_id, car,
+//     lap_time, date}]} — в него выгружаются результаты Automobilista 2.
+func Parse(data []byte, opts Options) ([]models.LapRecord, error) {
+	var probe struct {
+		TrackName *string         `json:"TrackName"`
+		Laps      json.RawMessage `json:"Laps"`
+		LapsLower json.RawMessage `json:"laps"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("файл результатов: %w", err)
+	}
+
+	var laps []models.LapRecord
+	var err error
+	switch {
+	case probe.TrackName != nil && probe.Laps != nil:
+		laps, err = parseAssettoCorsa(data, opts)
+	case probe.LapsLower != nil:
+		laps, err = parseClub(data, opts)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range laps {
+		laps[i].Source = filepath.Base(opts.Source)
+		laps[i].Driver = strings.TrimSpace(laps[i].Driver)
+	}
+	return laps, nil
+}
+
+// -----------------------------------------------------------------------------
+// ASSETTO CORSA (acServer results/*.json)
+// -----------------------------------------------------------------------------
+
+// Имя файла сервера AC: 2024_1_20_19_30_QUALIFY.json — другой даты в нём нет.
+var acFileDate = regexp.MustCompile(`(\d{4})_(\d{1,2})_(\d{1,2})_(\d{1,2})_(\d{1,2})`)
+
+func parseAssettoCorsa(data []byte, opts Options) ([]models.LapRecord, error) {
+	var res struct {
+		TrackName   string `json:"TrackName"`
+		TrackConfig string `json:"TrackConfig"`
+		Laps        []struct {
+			DriverName string `json:"DriverName"`
+			CarModel   string `json:"CarModel"`
+			LapTime    int64  `json:"LapTime"`
+			Cuts       int    `json:"Cuts"`
+		} `json:"Laps"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("результаты Assetto Corsa: %w", err)
+	}
+
+	track := res.TrackName
+	if res.TrackConfig != "" {
+		track += " " + res.TrackConfig
+	}
+	setAt := sessionDate(opts)
+
+	out := make([]models.LapRecord, 0, len(res.Laps))
+	for _, l := range res.Laps {
+		if l.Cuts > 0 || l.LapTime <= 0 || l.DriverName == "" {
+			continue
+		}
+		out = append(out, models.LapRecord{
+			Driver: l.DriverName,
+			Game:   GameAssettoCorsa,
+			Track:  track,
+			Car:    l.CarModel,
+			LapMs:  l.LapTime,
+			SetAt:  setAt,
+		})
+	}
+	return out, nil
+}
+
+func sessionDate(opts Options) time.Time {
+	if m := acFileDate.FindStringSubmatch(filepath.Base(opts.Source)); m != nil {
+		n := make([]int, 5)
+		for i := range n {
+			n[i], _ = strconv.Atoi(m[i+1])
+		}
+		return time.Date(n[0], time.Month(n[1]), n[2], n[3], n[4], 0, 0, time.UTC)
+	}
+	return opts.Date
+}
+
+// -----------------------------------------------------------------------------
+// CLUB FORMAT (выгрузка Automobilista 2 и ручной импорт)
+// -----------------------------------------------------------------------------
+
+func parseClub(data []byte, opts Options) ([]models.LapRecord, error) {
+	var res struct {
+		Game  string `json:"game"`
+		Track string `json:"track"`
+		Date  string `json:"date"`
+		Laps  []struct {
+			Driver   string          `json:"driver"`
+			ClientID string          `json:"client_id"`
+			Track    string          `json:"track"`
+			Car      string          `json:"car"`
+			LapTime  json.RawMessage `json:"lap_time"`
+			Date     string          `json:"date"`
+			Valid    *bool           `json:"valid"`
+		} `json:"laps"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("результаты: %w", err)
+	}
+
+	game := res.Game
+	if game == "" {
+		game = opts.Game
+	}
+	if game == "" {
+		game = GameAutomobilista2
+	}
+	setAt := opts.Date
+	if res.Date != "" {
+		t, err := parseDate(res.Date)
+		if err != nil {
+			return nil, err
+		}
+		setAt = t
+	}
+
+	out := make([]models.LapRecord, 0, len(res.Laps))
+	for i, l := range res.Laps {
+		if l.Valid != nil && !*l.Valid {
+			continue
+		}
+		ms, err := parseLapTime(l.LapTime)
+		if err != nil {
+			return nil, fmt.Errorf("круг %d: %w", i+1, err)
+		}
+		rec := models.LapRecord{
+			ClientID: l.ClientID,
+			Driver:   l.Driver,
+			Game:     game,
+			Track:    res.Track,
+			Car:      l.Car,
+			LapMs:    ms,
+			SetAt:    setAt,
+		}
+		if l.Track != "" {
+			rec.Track = l.Track
+		}
+		if l.Date != "" {
+			if rec.SetAt, err = parseDate(l.Date); err != nil {
+				return nil, fmt.Errorf("круг %d: %w", i+1, err)
+			}
+		}
+		if rec.Driver == "" || rec.Track == "" || ms <= 0 {
+			return nil, fmt.Errorf("круг %d: нужны driver, track и lap_time", i+1)
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+// parseLapTime — миллисекунды числом или строка "1:23.456" / "83.456".
+func parseLapTime(raw json.RawMessage) (int64, error) {
+	var ms int64
+	if err := json.Unmarshal(raw, &ms); err == nil {
+		return ms, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return 0, fmt.Errorf("lap_time: ожидаются мс или \"м:сс.ммм\"")
+	}
+	return ParseLapTime(s)
+}
+
+// ParseLapTime — "1:23.456" или "83.456" в миллисекунды.
+func ParseLapTime(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	var minutes int64
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		m, err := strconv.ParseInt(s[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("время круга %q: %w", s, err)
+		}
+		minutes, s = m, s[i+1:]
+	}
+	sec, err := strconv.ParseFloat(s, 64)
+	if err != nil || sec < 0 {
+		return 0, fmt.Errorf("время круга %q: ожидается м:сс.ммм", s)
+	}
+	return minutes*60000 + int64(sec*1000+0.5), nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			// Как у броней: локальное время клуба без зоны
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("дата %q: ожидается YYYY-MM-DD [HH:MM]", s)
+}
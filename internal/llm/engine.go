@@ -2,6 +2,7 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -11,6 +12,9 @@ import (
 	openai "github.com/sashabaranov/go-openai"
 )
 
+// maxToolSteps — сколько раундов "вызов инструмента → ответ" допускаем за один ход.
+const maxToolSteps = 5
+
 // LLMEngine — гибридный движок OpenAI → Gemini (fallback)
 type LLMEngine struct {
 	openaiClient *openai.Client
@@ -47,20 +51,42 @@ func NewLLMEngine(
 // PUBLIC API
 // -------------------------------
 
-// Generate — единая точка входа
+// Generate — короткая форма GenerateChat для одного вопроса без истории.
 func (e *LLMEngine) Generate(
 	ctx context.Context,
 	systemPrompt string,
 	userPrompt string,
-	tools any, // список инструментов (OpenAI tools)
+	tools []Tool,
+	exec ToolExecutor,
+) (string, error, bool) {
+	return e.GenerateChat(ctx, []Message{
+		{Role: RoleSystem, Content: systemPrompt},
+		{Role: RoleUser, Content: userPrompt},
+	}, tools, exec)
+}
+
+// GenerateChat — единая точка входа. Принимает весь диалог (system/user/
+// assistant/tool); если переданы tools, движок сам крутит цикл вызовов
+// инструментов через exec у того провайдера, который обслуживает ход.
+func (e *LLMEngine) GenerateChat(
+	ctx context.Context,
+	messages []Message,
+	tools []Tool,
+	exec ToolExecutor,
 ) (string, error, bool) {
 	// bool → был ли ответ от OpenAI (true) или Gemini fallback (false)
 
+	if len(messages) == 0 || messages[len(messages)-1].Role != RoleUser {
+		return "", errors.New("диалог должен заканчиваться сообщением пользователя"), false
+	}
+
+	exec = memoizeExecutor(exec, tools)
+
 	// ------------------------------------
 	// 1) Сначала пытаемся OpenAI
 	// ------------------------------------
 	if e.openaiClient != nil {
-		reply, err := e.callOpenAI(ctx, systemPrompt, userPrompt, tools)
+		reply, err := e.callOpenAI(ctx, messages, tools, exec)
 		if err == nil {
 			return reply, nil, true // успех OpenAI
 		}
@@ -75,7 +101,11 @@ func (e *LLMEngine) Generate(
 	// ------------------------------------
 	// 2) Fallback → Gemini
 	// ------------------------------------
-	reply, err := e.callGemini(ctx, systemPrompt, userPrompt)
+	if e.geminiClient == nil {
+		return "", errors.New("OpenAI недоступен, Gemini не настроен"), false
+	}
+
+	reply, err := e.callGemini(ctx, messages, tools, exec)
 	if err != nil {
 		return "", fmt.Errorf("ни OpenAI, ни Gemini не смогли ответить: %w", err), false
 	}
@@ -87,53 +117,175 @@ func (e *LLMEngine) Generate(
 // INTERNAL: OpenAI
 // -------------------------------
 
-func (e *LLMEngine) callOpenAI(ctx context.Context, systemPrompt, userPrompt string, tools any) (string, error) {
-	req := openai.ChatCompletionRequest{
-		Model: e.modelOpenAI,
-		Messages: []openai.ChatCompletionMessage{
-			{Role: "system", Content: systemPrompt},
-			{Role: "user", Content: userPrompt},
-		},
-		Tools: tools,
-	}
+func (e *LLMEngine) callOpenAI(ctx context.Context, history []Message, tools []Tool, exec ToolExecutor) (string, error) {
+	messages := toOpenAIMessages(history)
+	oaTools := toOpenAITools(tools)
 
-	resp, err := e.openaiClient.CreateChatCompletion(ctx, req)
-	if err != nil {
-		return "", err
-	}
+	for step := 0; step <= maxToolSteps; step++ {
+		req := openai.ChatCompletionRequest{
+			Model:    e.modelOpenAI,
+			Messages: messages,
+			Tools:    oaTools,
+		}
+
+		resp, err := e.openaiClient.CreateChatCompletion(ctx, req)
+		if err != nil {
+			return "", err
+		}
+
+		if len(resp.Choices) == 0 {
+			return "", errors.New("OpenAI вернул пустой ответ")
+		}
+
+		msg := resp.Choices[0].Message
+		if len(msg.ToolCalls) == 0 {
+			if strings.TrimSpace(msg.Content) == "" {
+				return "", errors.New("OpenAI вернул пустой ответ")
+			}
+			return msg.Content, nil
+		}
 
-	if len(resp.Choices) == 0 {
-		return "", errors.New("OpenAI вернул пустой ответ")
+		// Модель хочет инструменты: выполняем все вызовы и возвращаем результаты
+		messages = append(messages, msg)
+		for _, call := range msg.ToolCalls {
+			args := map[string]any{}
+			if call.Function.Arguments != "" {
+				if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+					log.Printf("[LLM Engine] OpenAI bad tool args for %s: %v", call.Function.Name, err)
+				}
+			}
+
+			log.Printf("[LLM Engine] OpenAI tool call: %s %v", call.Function.Name, args)
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				Content:    runTool(ctx, exec, call.Function.Name, args),
+				Name:       call.Function.Name,
+				ToolCallID: call.ID,
+			})
+		}
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	return "", errors.New("OpenAI: превышен лимит шагов инструментов")
+}
+
+// toOpenAIMessages — история в формат OpenAI. Результаты инструментов из
+// прошлых ходов не имеют tool_call_id, поэтому уходят как заметки ассистента.
+func toOpenAIMessages(history []Message) []openai.ChatCompletionMessage {
+	out := make([]openai.ChatCompletionMessage, 0, len(history))
+	for _, m := range history {
+		switch m.Role {
+		case RoleSystem:
+			out = append(out, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: m.Content})
+		case RoleAssistant:
+			out = append(out, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: m.Content})
+		case RoleTool:
+			out = append(out, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: toolNote(m)})
+		default:
+			out = append(out, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: m.Content})
+		}
+	}
+	return out
 }
 
 // -------------------------------
 // INTERNAL: Gemini fallback
 // -------------------------------
 
-func (e *LLMEngine) callGemini(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+func (e *LLMEngine) callGemini(ctx context.Context, history []Message, tools []Tool, exec ToolExecutor) (string, error) {
 	model := e.geminiClient.GenerativeModel(e.modelGemini)
+	model.Tools = toGeminiTools(tools)
+
+	system, contents := toGeminiContents(history)
+	if system != "" {
+		model.SystemInstruction = &genai.Content{Parts: []genai.Part{genai.Text(system)}}
+	}
+
+	if len(contents) == 0 || contents[len(contents)-1].Role != "user" {
+		return "", errors.New("Gemini: пустое сообщение пользователя")
+	}
+
+	// Последнее сообщение (пользователь) отправляем, остальное — в историю чата
+	chat := model.StartChat()
+	last := contents[len(contents)-1]
+	chat.History = contents[:len(contents)-1]
 
-	resp, err := model.GenerateContent(ctx,
-		genai.Text(systemPrompt+"\n"+userPrompt),
-	)
+	resp, err := chat.SendMessage(ctx, last.Parts...)
 	if err != nil {
 		return "", err
 	}
 
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return "", errors.New("Gemini вернул пустой fallback ответ")
+	for step := 0; step <= maxToolSteps; step++ {
+		if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+			return "", errors.New("Gemini вернул пустой ответ")
+		}
+
+		calls := resp.Candidates[0].FunctionCalls()
+		if len(calls) == 0 {
+			for _, p := range resp.Candidates[0].Content.Parts {
+				if txt, ok := p.(genai.Text); ok && strings.TrimSpace(string(txt)) != "" {
+					return string(txt), nil
+				}
+			}
+			return "", errors.New("Gemini: не удалось извлечь текст")
+		}
+
+		parts := make([]genai.Part, 0, len(calls))
+		for _, call := range calls {
+			log.Printf("[LLM Engine] Gemini tool call: %s %v", call.Name, call.Args)
+			parts = append(parts, genai.FunctionResponse{
+				Name:     call.Name,
+				Response: map[string]any{"result": runTool(ctx, exec, call.Name, call.Args)},
+			})
+		}
+
+		resp, err = chat.SendMessage(ctx, parts...)
+		if err != nil {
+			return "", err
+		}
 	}
 
-	for _, p := range resp.Candidates[0].Content.Pparts {
-		if txt, ok := p.(genai.Text); ok {
-			return string(txt), nil
+	return "", errors.New("Gemini: превышен лимит шагов инструментов")
+}
+
+// toGeminiContents — system-сообщения склеиваются в SystemInstruction,
+// остальное — в роли user/model. Подряд идущие реплики одной роли
+// объединяются: Gemini ожидает чередование.
+func toGeminiContents(history []Message) (string, []*genai.Content) {
+	var system []string
+	var out []*genai.Content
+
+	for _, m := range history {
+		var role, text string
+		switch m.Role {
+		case RoleSystem:
+			system = append(system, m.Content)
+			continue
+		case RoleAssistant:
+			role, text = "model", m.Content
+		case RoleTool:
+			role, text = "model", toolNote(m)
+		default:
+			role, text = "user", m.Content
 		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		if n := len(out); n > 0 && out[n-1].Role == role {
+			out[n-1].Parts = append(out[n-1].Parts, genai.Text(text))
+			continue
+		}
+		out = append(out, &genai.Content{Role: role, Parts: []genai.Part{genai.Text(text)}})
 	}
 
-	return "", errors.New("Gemini fallback: не удалось извлечь текст")
+	return strings.Join(system, "\n\n"), out
+}
+
+func toolNote(m Message) string {
+	if m.Name == "" {
+		return "[результат инструмента] " + m.Content
+	}
+	return fmt.Sprintf("[результат %s] %s", m.Name, m.Content)
 }
 
 // -------------------------------
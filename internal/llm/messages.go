@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// -----------------------------------------------------------------------------
+// MESSAGES
+// -----------------------------------------------------------------------------
+
+// Role — роль сообщения в диалоге (общая для OpenAI и Gemini).
+type Role string
+
+const (
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool"
+)
+
+// Message — одно сообщение диалога. Time нужен только для окна истории.
+type Message struct {
+	Role    Role
+	Content string
+	Name    string // имя инструмента для RoleTool
+	Time    time.Time
+}
+
+// -----------------------------------------------------------------------------
+// HISTORY WINDOW
+// -----------------------------------------------------------------------------
+
+// HistoryWindow — какие сообщения истории отдавать модели.
+// Нулевое значение поля = ограничение выключено.
+type HistoryWindow struct {
+	MaxMessages int           // не больше N последних сообщений
+	MaxTokens   int           // суммарный бюджет токенов (оценка)
+	MaxAge      time.Duration // не старше (как 2-часовой cutoff в профиле)
+}
+
+// DefaultHistoryWindow — окно по умолчанию: 20 сообщений, ~3000 токенов, 2 часа.
+func DefaultHistoryWindow() HistoryWindow {
+	return HistoryWindow{
+		MaxMessages: 20,
+		MaxTokens:   3000,
+		MaxAge:      2 * time.Hour,
+	}
+}
+
+// Apply обрезает историю с начала. Системные сообщения не обрезаются и не
+// учитываются в лимитах; последнее несистемное сообщение (текущая реплика
+// клиента) сохраняется всегда.
+func (w HistoryWindow) Apply(msgs []Message, now time.Time) []Message {
+	var system, dialog []Message
+	for _, m := range msgs {
+		if m.Role == RoleSystem {
+			system = append(system, m)
+		} else {
+			dialog = append(dialog, m)
+		}
+	}
+	if len(dialog) == 0 {
+		return system
+	}
+
+	// Идём с конца и набираем, пока влезает
+	keepFrom := len(dialog) - 1
+	tokens := EstimateTokens(dialog[keepFrom].Content)
+
+	for i := len(dialog) - 2; i >= 0; i-- {
+		m := dialog[i]
+		if w.MaxMessages > 0 && len(dialog)-i > w.MaxMessages {
+			break
+		}
+		if w.MaxAge > 0 && !m.Time.IsZero() && now.Sub(m.Time) > w.MaxAge {
+			break
+		}
+		t := EstimateTokens(m.Content)
+		if w.MaxTokens > 0 && tokens+t > w.MaxTokens {
+			break
+		}
+		tokens += t
+		keepFrom = i
+	}
+
+	// Модели плохо переносят историю, начинающуюся с ответа бота/инструмента
+	for keepFrom < len(dialog)-1 && dialog[keepFrom].Role != RoleUser {
+		keepFrom++
+	}
+
+	return append(system, dialog[keepFrom:]...)
+}
+
+// EstimateTokens — грубая оценка: ~4 символа на токен (кириллица чуть хуже,
+// но для окна истории точность не нужна).
+func EstimateTokens(s string) int {
+	n := utf8.RuneCountInString(s)
+	if n == 0 {
+		return 0
+	}
+	return n/4 + 1
+}
@@ -0,0 +1,198 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/google/generative-ai-go/genai"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// -----------------------------------------------------------------------------
+// PROVIDER-NEUTRAL TOOL DECLARATIONS
+// -----------------------------------------------------------------------------
+
+// Schema — подмножество JSON Schema, достаточное для параметров инструментов.
+// Сериализуется в JSON как есть (OpenAI) и конвертируется в genai.Schema (Gemini).
+type Schema struct {
+	Type        string             `json:"type"`
+	Description string             `json:"description,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+}
+
+// Типы JSON Schema.
+const (
+	TypeObject  = "object"
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeArray   = "array"
+)
+
+// Tool — объявление инструмента: имя, описание и JSON-схема аргументов.
+// Mutates — инструмент меняет данные (бронь, оплата): повторный вызов с
+// теми же аргументами в пределах хода не выполняется второй раз.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  *Schema
+	Mutates     bool
+}
+
+// ToolExecutor выполняет вызов инструмента и возвращает текстовый результат
+// для модели. Ошибка тоже уходит в модель текстом, цикл не прерывается.
+type ToolExecutor func(ctx context.Context, name string, args map[string]any) (string, error)
+
+// -----------------------------------------------------------------------------
+// CONVERTERS
+// -----------------------------------------------------------------------------
+
+func toOpenAITools(tools []Tool) []openai.Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+	out := make([]openai.Tool, 0, len(tools))
+	for _, t := range tools {
+		params := t.Parameters
+		if params == nil {
+			params = &Schema{Type: TypeObject, Properties: map[string]*Schema{}}
+		}
+		out = append(out, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  params,
+			},
+		})
+	}
+	return out
+}
+
+func toGeminiTools(tools []Tool) []*genai.Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+	decls := make([]*genai.FunctionDeclaration, 0, len(tools))
+	for _, t := range tools {
+		decls = append(decls, &genai.FunctionDeclaration{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  toGeminiSchema(t.Parameters),
+		})
+	}
+	return []*genai.Tool{{FunctionDeclarations: decls}}
+}
+
+func toGeminiSchema(s *Schema) *genai.Schema {
+	if s == nil {
+		return nil
+	}
+	// Gemini не принимает объект без свойств — опускаем параметры целиком.
+	if s.Type == TypeObject && len(s.Properties) == 0 {
+		return nil
+	}
+
+	out := &genai.Schema{
+		Type:        geminiType(s.Type),
+		Description: s.Description,
+		Enum:        s.Enum,
+		Items:       toGeminiSchema(s.Items),
+		Required:    s.Required,
+	}
+	if len(s.Properties) > 0 {
+		out.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for k, v := range s.Properties {
+			out.Properties[k] = toGeminiSchema(v)
+		}
+	}
+	return out
+}
+
+func geminiType(t string) genai.Type {
+	switch t {
+	case TypeObject:
+		return genai.TypeObject
+	case TypeString:
+		return genai.TypeString
+	case TypeInteger:
+		return genai.TypeInteger
+	case TypeNumber:
+		return genai.TypeNumber
+	case TypeBoolean:
+		return genai.TypeBoolean
+	case TypeArray:
+		return genai.TypeArray
+	default:
+		return genai.TypeUnspecified
+	}
+}
+
+// -----------------------------------------------------------------------------
+// EXECUTION
+// -----------------------------------------------------------------------------
+
+// memoizeExecutor кэширует результаты изменяющих инструментов (Mutates)
+// в рамках одного Generate. Если OpenAI успел выполнить CreateBooking и упал,
+// Gemini при повторе получит тот же ответ, а не создаст вторую бронь.
+// Чтение не кэшируется: CheckAvailability после CreateBooking видит новую
+// бронь.
+func memoizeExecutor(exec ToolExecutor, tools []Tool) ToolExecutor {
+	if exec == nil {
+		return nil
+	}
+	mutates := map[string]bool{}
+	for _, t := range tools {
+		if t.Mutates {
+			mutates[t.Name] = true
+		}
+	}
+	if len(mutates) == 0 {
+		return exec
+	}
+
+	var mu sync.Mutex
+	cache := map[string]string{}
+
+	return func(ctx context.Context, name string, args map[string]any) (string, error) {
+		if !mutates[name] {
+			return exec(ctx, name, args)
+		}
+		raw, _ := json.Marshal(args) // map → ключи отсортированы, подпись стабильна
+		key := name + ":" + string(raw)
+
+		mu.Lock()
+		if out, ok := cache[key]; ok {
+			mu.Unlock()
+			return out, nil
+		}
+		mu.Unlock()
+
+		out, err := exec(ctx, name, args)
+		if err != nil {
+			return out, err
+		}
+
+		mu.Lock()
+		cache[key] = out
+		mu.Unlock()
+		return out, nil
+	}
+}
+
+// runTool выполняет инструмент и сворачивает ошибку в текст для модели.
+func runTool(ctx context.Context, exec ToolExecutor, name string, args map[string]any) string {
+	if exec == nil {
+		return "Ошибка: инструменты недоступны"
+	}
+	out, err := exec(ctx, name, args)
+	if err != nil {
+		return "Ошибка: " + err.Error()
+	}
+	return out
+}
@@ -1,6 +1,17 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNotEnoughSeats — на запрошенный интервал не хватает свободных мест.
+var ErrNotEnoughSeats = errors.New("мест недостаточно")
+
+// ErrPromoExhausted — лимит использований промокода исчерпан (общий или клиента).
+var ErrPromoExhausted = errors.New("лимит использований промокода исчерпан")
 
 // -----------------------------------------------------------------------------
 // CLIENT PROFILE
@@ -18,17 +29,958 @@ type ClientProfile struct {
 	History string `json:"history"`
 }
 
+// -----------------------------------------------------------------------------
+// CHAT MESSAGE (история диалога из таблицы messages)
+// -----------------------------------------------------------------------------
+
+// ChatMessage — одно сохранённое сообщение. Sender: client | bot | tool.
+type ChatMessage struct {
+	Sender    string    `json:"sender"`
+	Text      string    `json:"text"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
 // -----------------------------------------------------------------------------
 // BOOKING MODEL (используется ToolsService и ContextRepo)
 // -----------------------------------------------------------------------------
 
+// Статусы брони. Места держат created, paid и confirmed.
+const (
+	BookingCreated   = "created" // холд: места держатся до оплаты
+	BookingPaid      = "paid"
+	BookingConfirmed = "confirmed"
+	BookingCancelled = "cancelled"
+	BookingNoShow    = "no_show"
+)
+
+// Кто выполнил переход статуса.
+const (
+	ActorBot    = "bot"
+	ActorAdmin  = "admin"
+	ActorClient = "client"
+)
+
+// ErrInvalidTransition — переход статуса брони не разрешён.
+var ErrInvalidTransition = errors.New("недопустимая смена статуса брони")
+
+// bookingTransitions — разрешённые переходы статусов.
+var bookingTransitions = map[string][]string{
+	BookingCreated:   {BookingPaid, BookingConfirmed, BookingCancelled, BookingNoShow},
+	BookingPaid:      {BookingConfirmed, BookingCancelled, BookingNoShow},
+	BookingConfirmed: {BookingPaid, BookingCancelled, BookingNoShow},
+}
+
+// CanTransition — можно ли перевести бронь из from в to.
+func CanTransition(from, to string) bool {
+	for _, s := range bookingTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// BookingStatusLabel — статус по-русски для ответов бота.
+func BookingStatusLabel(status string) string {
+	switch status {
+	case BookingCreated:
+		return "создана"
+	case BookingPaid:
+		return "оплачена"
+	case BookingConfirmed:
+		return "подтверждена"
+	case BookingCancelled:
+		return "отменена"
+	case BookingNoShow:
+		return "неявка"
+	default:
+		return status
+	}
+}
+
 type Booking struct {
 	BookingID string    `json:"booking_id"`
 	ClientID  string    `json:"client_id"`
+	Status    string    `json:"status"`
+	CreatedBy string    `json:"created_by,omitempty"` // bot | admin (walk-in)
 	Start     time.Time `json:"start"`
 	Seats     int       `json:"seats"`
 	Hours     int       `json:"hours"`
 	Amount    float64   `json:"amount"`
+	RigIDs    []int     `json:"rig_ids,omitempty"` // пусто у броней до учёта станций
+	Quote     *Quote    `json:"quote,omitempty"`   // расчёт цены на момент брони
+	Game      string    `json:"game,omitempty"`    // станции подбирались под игру; пусто — любая
+
+	// Промокод и его скидка — погашение пишется вместе с бронью
+	PromoCode     string  `json:"promo_code,omitempty"`
+	PromoDiscount float64 `json:"promo_discount,omitempty"`
+}
+
+// IsActive — бронь держит станции.
+func (b Booking) IsActive() bool {
+	switch b.Status {
+	case "", BookingCreated, BookingPaid, BookingConfirmed:
+		return true
+	}
+	return false
+}
+
+// BookingEvent — запись журнала броней: смена статуса или перенос.
+type BookingEvent struct {
+	BookingID  string    `json:"booking_id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Actor      string    `json:"actor"` // bot | admin | client
+	Note       string    `json:"note,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// -----------------------------------------------------------------------------
+// PAYMENTS (счета платёжных провайдеров)
+// -----------------------------------------------------------------------------
+
+// Статусы счёта.
+const (
+	InvoicePending  = "pending"
+	InvoicePaid     = "paid"
+	InvoiceFailed   = "failed"
+	InvoiceRefunded = "refunded"
+)
+
+// Invoice — счёт на оплату брони у провайдера.
+type Invoice struct {
+	InvoiceID  string    `json:"invoice_id"` // ID у провайдера
+	Provider   string    `json:"provider"`
+	BookingID  string    `json:"booking_id"`
+	Amount     float64   `json:"amount"`
+	Status     string    `json:"status"`
+	PaymentURL string    `json:"payment_url"`
+	CreatedAt  time.Time `json:"created_at"`
+	PaidAt     time.Time `json:"paid_at,omitempty"`
+}
+
+// PaymentCallback — уведомление провайдера после проверки подписи.
+type PaymentCallback struct {
+	InvoiceID string  `json:"invoice_id"`
+	Status    string  `json:"status"` // InvoicePaid | InvoiceFailed | InvoiceRefunded
+	Amount    float64 `json:"amount"`
+}
+
+// -----------------------------------------------------------------------------
+// PAYMENT LEDGER (деньги по брони)
+// -----------------------------------------------------------------------------
+
+// Виды записей журнала оплат.
+const (
+	LedgerCharge     = "charge"     // оплата брони
+	LedgerDeposit    = "deposit"    // предоплата (депозит) за группу
+	LedgerRefund     = "refund"     // возврат клиенту
+	LedgerAdjustment = "adjustment" // ручная корректировка (±)
+)
+
+// Способы оплаты.
+const (
+	MethodKaspiQR = "kaspi_qr"
+	MethodCash    = "cash"
+	MethodCard    = "card"
+	MethodOnline  = "online"  // счёт платёжного провайдера
+	MethodPrepaid = "prepaid" // списание с сертификата или пакета часов
+)
+
+// LedgerEntry — движение денег по брони. Amount со знаком: поступления
+// положительные, возвраты отрицательные, корректировка — как задана.
+type LedgerEntry struct {
+	ID        int64     `json:"id"`
+	BookingID string    `json:"booking_id"`
+	Kind      string    `json:"kind"`
+	Method    string    `json:"method"`
+	Amount    float64   `json:"amount"`
+	Actor     string    `json:"actor"`
+	Note      string    `json:"note,omitempty"`
+	InvoiceID string    `json:"invoice_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BookingBalance — сколько по брони начислено, получено и осталось.
+type BookingBalance struct {
+	BookingID string  `json:"booking_id"`
+	Total     float64 `json:"total"`    // сумма брони (по смете)
+	Paid      float64 `json:"paid"`     // чистые поступления по журналу
+	Refunded  float64 `json:"refunded"` // возвращено (положительное число)
+	Due       float64 `json:"due"`      // к оплате; отменённая бронь — 0
+}
+
+// LedgerKindLabel — вид записи по-русски.
+func LedgerKindLabel(kind string) string {
+	switch kind {
+	case LedgerCharge:
+		return "оплата"
+	case LedgerDeposit:
+		return "депозит"
+	case LedgerRefund:
+		return "возврат"
+	case LedgerAdjustment:
+		return "корректировка"
+	default:
+		return kind
+	}
+}
+
+// PaymentMethodLabel — способ оплаты по-русски.
+func PaymentMethodLabel(method string) string {
+	switch method {
+	case MethodKaspiQR:
+		return "Kaspi QR"
+	case MethodCash:
+		return "наличные"
+	case MethodCard:
+		return "карта"
+	case MethodOnline:
+		return "онлайн"
+	case MethodPrepaid:
+		return "сертификат/пакет"
+	default:
+		return method
+	}
+}
+
+// -----------------------------------------------------------------------------
+// PREPAID (подарочные сертификаты и пакеты часов)
+// -----------------------------------------------------------------------------
+
+// Виды предоплаты.
+const (
+	PrepaidCertificate = "certificate" // сертификат на сумму, гасится по коду
+	PrepaidHours       = "hours"       // пакет место-часов, привязан к клиенту
+)
+
+// Статусы предоплаты.
+const (
+	PrepaidActive = "active"
+	PrepaidVoid   = "void" // аннулирован администратором
+)
+
+// PrepaidAccount — сертификат или пакет часов. Initial/Balance — в тенге у
+// сертификата и в место-часах у пакета; Price — сколько за него заплатили.
+type PrepaidAccount struct {
+	ID        int64     `json:"id"`
+	Code      string    `json:"code"`
+	Kind      string    `json:"kind"`
+	ClientID  string    `json:"client_id,omitempty"`
+	Initial   float64   `json:"initial"`
+	Balance   float64   `json:"balance"`
+	Price     float64   `json:"price"`
+	Method    string    `json:"method"` // как оплачен при продаже
+	ExpiresAt time.Time `json:"expires_at"`
+	Status    string    `json:"status"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Expired — срок действия истёк.
+func (p PrepaidAccount) Expired(now time.Time) bool {
+	return !p.ExpiresAt.IsZero() && !now.Before(p.ExpiresAt)
+}
+
+// UnitValue — цена единицы остатка для учёта обязательств: тенге у
+// сертификата, цена продажи часа у пакета.
+func (p PrepaidAccount) UnitValue() float64 {
+	if p.Kind == PrepaidHours && p.Initial > 0 {
+		return p.Price / p.Initial
+	}
+	return 1
+}
+
+// PrepaidRedemption — списание с предоплаты в счёт брони. Units — в
+// единицах счёта, Value — сколько брони это покрыло в тенге.
+type PrepaidRedemption struct {
+	ID        int64     `json:"id"`
+	Code      string    `json:"code"`
+	BookingID string    `json:"booking_id"`
+	Units     float64   `json:"units"`
+	Value     float64   `json:"value"`
+	Reversed  bool      `json:"reversed"` // возвращено на счёт при отмене брони
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PrepaidLiability — непогашенная предоплата по виду: что клуб ещё должен
+// оказать в услугах. Сгоревшее (истёкшее) считается отдельно.
+type PrepaidLiability struct {
+	Kind          string  `json:"kind"`
+	Accounts      int     `json:"accounts"`       // действующих с остатком
+	Balance       float64 `json:"balance"`        // остаток в единицах вида
+	Value         float64 `json:"value"`          // он же в тенге
+	Sold          float64 `json:"sold"`           // продано за всё время, тг
+	ExpiredValue  float64 `json:"expired_value"`  // сгорело, тг
+	RedeemedValue float64 `json:"redeemed_value"` // списано в брони, тг
+}
+
+// PrepaidKindLabel — вид предоплаты по-русски.
+func PrepaidKindLabel(kind string) string {
+	switch kind {
+	case PrepaidCertificate:
+		return "сертификат"
+	case PrepaidHours:
+		return "пакет часов"
+	default:
+		return kind
+	}
+}
+
+// -----------------------------------------------------------------------------
+// WAITLIST (лист ожидания на занятые слоты)
+// -----------------------------------------------------------------------------
+
+// Статусы заявки в листе ожидания.
+const (
+	WaitlistWaiting   = "waiting"   // ждёт освобождения мест
+	WaitlistOffered   = "offered"   // места придержаны, ждём ответа клиента
+	WaitlistAccepted  = "accepted"  // клиент забрал бронь
+	WaitlistExpired   = "expired"   // не ответил вовремя или слот прошёл
+	WaitlistCancelled = "cancelled" // клиент отказался
+)
+
+// WaitlistEntry — заявка на слот, где не хватило мест. FlexHours — на сколько
+// часов раньше/позже клиент готов сдвинуться.
+type WaitlistEntry struct {
+	ID             int64     `json:"id"`
+	ClientID       string    `json:"client_id"`
+	Start          time.Time `json:"start"`
+	Seats          int       `json:"seats"`
+	Hours          int       `json:"hours"`
+	Game           string    `json:"game,omitempty"`
+	FlexHours      int       `json:"flex_hours"`
+	Status         string    `json:"status"`
+	BookingID      string    `json:"booking_id,omitempty"` // придержанная бронь
+	OfferExpiresAt time.Time `json:"offer_expires_at,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// DemandMiss — запрос, на который не хватило мест (упущенный спрос).
+type DemandMiss struct {
+	ClientID  string    `json:"client_id"`
+	Start     time.Time `json:"start"`
+	Seats     int       `json:"seats"`
+	Hours     int       `json:"hours"`
+	Game      string    `json:"game,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TurnedAwayHour — упущенный спрос по часу начала.
+type TurnedAwayHour struct {
+	Hour       string `json:"hour"`     // "19:00"
+	Requests   int    `json:"requests"` // уникальные (клиент, слот)
+	Clients    int    `json:"clients"`
+	SeatHours  int    `json:"seat_hours"`
+	Waitlisted int    `json:"waitlisted"` // из них встали в лист ожидания
+	Recovered  int    `json:"recovered"`  // получили бронь из листа
+}
+
+// -----------------------------------------------------------------------------
+// CLUB EVENTS (турниры и чемпионаты)
+// -----------------------------------------------------------------------------
+
+// Статусы мероприятия.
+const (
+	EventOpen      = "open"      // идёт регистрация
+	EventCancelled = "cancelled" // отменено, станции освобождены
+)
+
+// Статусы регистрации участника.
+const (
+	RegistrationActive    = "registered"
+	RegistrationCancelled = "cancelled"
+)
+
+// EventClientID — клиент блокирующей брони мероприятия: станции турнира
+// держит обычная бронь, поэтому движок доступности их учитывает сам.
+const EventClientID = "event"
+
+// ClubEvent — турнир/чемпионат. Станции на MaxParticipants мест блокируются
+// бронью BookingID (ev_<id>) на всё время мероприятия.
+type ClubEvent struct {
+	ID              int64     `json:"id"`
+	Title           string    `json:"title"`
+	Start           time.Time `json:"start"`
+	Hours           int       `json:"hours"`
+	Track           string    `json:"track"`
+	CarClass        string    `json:"car_class"`
+	Game            string    `json:"game,omitempty"`
+	MaxParticipants int       `json:"max_participants"`
+	EntryFee        float64   `json:"entry_fee"`
+	Status          string    `json:"status"`
+	BookingID       string    `json:"booking_id"`
+	RigIDs          []int     `json:"rig_ids,omitempty"`
+	Registered      int       `json:"registered"` // активных регистраций
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// SpotsLeft — сколько мест ещё свободно.
+func (e ClubEvent) SpotsLeft() int {
+	if n := e.MaxParticipants - e.Registered; n > 0 {
+		return n
+	}
+	return 0
+}
+
+// EventRegistration — участник мероприятия.
+type EventRegistration struct {
+	EventID   int64     `json:"event_id"`
+	ClientID  string    `json:"client_id"`
+	Name      string    `json:"name"` // ник/имя для протокола
+	Status    string    `json:"status"`
+	Paid      bool      `json:"paid"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// -----------------------------------------------------------------------------
+// LAP TIMES (лидерборд по кругам из файлов результатов симуляторов)
+// -----------------------------------------------------------------------------
+
+// LapRecord — один зачётный круг. Driver — ник в игре; ClientID пуст, пока
+// ник не привязан к клиенту.
+type LapRecord struct {
+	ID        int64     `json:"id"`
+	ClientID  string    `json:"client_id,omitempty"`
+	Driver    string    `json:"driver"`
+	Game      string    `json:"game"`
+	Track     string    `json:"track"`
+	Car       string    `json:"car"`
+	LapMs     int64     `json:"lap_ms"`
+	SetAt     time.Time `json:"set_at"`
+	Source    string    `json:"source,omitempty"` // имя файла результатов
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LapImportResult — итог импорта файла результатов.
+type LapImportResult struct {
+	Parsed     int `json:"parsed"`
+	Inserted   int `json:"inserted"`   // новые круги (повторы пропускаются)
+	Linked     int `json:"linked"`     // из них привязаны к клиентам
+	Unassigned int `json:"unassigned"` // ники без клиента
+}
+
+// LapStanding — лучший круг пилота и его место в таблице трассы/машины.
+type LapStanding struct {
+	LapRecord
+	Rank    int `json:"rank"`
+	Drivers int `json:"drivers"` // всего пилотов в таблице
+	Laps    int `json:"laps"`    // кругов пилота в выборке
+}
+
+// FormatLapTime — время круга как в симуляторах: 1:23.456.
+func FormatLapTime(ms int64) string {
+	if ms <= 0 {
+		return "—"
+	}
+	return fmt.Sprintf("%d:%02d.%03d", ms/60000, ms/1000%60, ms%1000)
+}
+
+// -----------------------------------------------------------------------------
+// PROMO CODES
+// -----------------------------------------------------------------------------
+
+// Виды промокодов.
+const (
+	PromoPercent = "percent" // Value — процент скидки
+	PromoFixed   = "fixed"   // Value — сумма скидки в валюте тарифа
+)
+
+// PromoCode — промокод. Нулевые ValidFrom/ValidTo и лимиты = без ограничения.
+type PromoCode struct {
+	Code           string    `json:"code"`
+	Kind           string    `json:"kind"`
+	Value          float64   `json:"value"`
+	ValidFrom      time.Time `json:"valid_from,omitempty"`
+	ValidTo        time.Time `json:"valid_to,omitempty"` // не включительно
+	MaxUses        int       `json:"max_uses,omitempty"`
+	PerClientLimit int       `json:"per_client_limit,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// PromoStats — промокод и его погашения (для админа).
+type PromoStats struct {
+	PromoCode
+	Redemptions   int     `json:"redemptions"`
+	TotalDiscount float64 `json:"total_discount"`
+}
+
+// NormalizePromoCode — коды сравниваются без учёта регистра и пробелов.
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// -----------------------------------------------------------------------------
+// PRICE QUOTE (детализированный расчёт стоимости)
+// -----------------------------------------------------------------------------
+
+// QuoteLine — строка расчёта: надбавка или скидка (сумма всегда положительная).
+type QuoteLine struct {
+	Label  string  `json:"label"`
+	Amount float64 `json:"amount"`
+}
+
+// Quote — расчёт стоимости брони: база + надбавки − скидки = итог.
+type Quote struct {
+	Start     time.Time   `json:"start"`
+	Seats     int         `json:"seats"`
+	Hours     int         `json:"hours"`
+	DayType   string      `json:"day_type"` // будний | выходной | праздник
+	Currency  string      `json:"currency"`
+	Base      float64     `json:"base"`
+	Modifiers []QuoteLine `json:"modifiers,omitempty"`
+	Discounts []QuoteLine `json:"discounts,omitempty"`
+	Total     float64     `json:"total"`
+}
+
+// AddModifier / AddDiscount — добавить строку и пересчитать итог.
+func (q *Quote) AddModifier(label string, amount float64) {
+	q.Modifiers = append(q.Modifiers, QuoteLine{Label: label, Amount: amount})
+	q.Recalc()
+}
+
+func (q *Quote) AddDiscount(label string, amount float64) {
+	q.Discounts = append(q.Discounts, QuoteLine{Label: label, Amount: amount})
+	q.Recalc()
+}
+
+// Recalc — итог из базы и строк (не ниже нуля).
+func (q *Quote) Recalc() {
+	total := q.Base
+	for _, m := range q.Modifiers {
+		total += m.Amount
+	}
+	for _, d := range q.Discounts {
+		total -= d.Amount
+	}
+	if total < 0 {
+		total = 0
+	}
+	q.Total = total
+}
+
+// Text — расчёт построчно, чтобы модель могла объяснить цену клиенту.
+// Последняя строка всегда "Итого: N <валюта>".
+func (q *Quote) Text() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Расчёт: мест — %d, часов — %d, %s (%s)\n",
+		q.Seats, q.Hours, q.Start.Format("2006-01-02 15:04"), q.DayType))
+	b.WriteString(fmt.Sprintf("- База: %.0f %s\n", q.Base, q.Currency))
+	for _, m := range q.Modifiers {
+		b.WriteString(fmt.Sprintf("- %s: +%.0f %s\n", m.Label, m.Amount, q.Currency))
+	}
+	for _, d := range q.Discounts {
+		b.WriteString(fmt.Sprintf("- %s: −%.0f %s\n", d.Label, d.Amount, q.Currency))
+	}
+	b.WriteString(fmt.Sprintf("Итого: %.0f %s", q.Total, q.Currency))
+	return b.String()
+}
+
+// -----------------------------------------------------------------------------
+// RIG (конкретный симулятор)
+// -----------------------------------------------------------------------------
+
+// Статусы станции.
+const (
+	RigActive      = "active"
+	RigMaintenance = "maintenance"
+)
+
+// Rig — симулятор. ID задаёт порядок в зале: соседние ID стоят рядом.
+type Rig struct {
+	ID       int      `json:"id"`
+	Name     string   `json:"name"`
+	Hardware string   `json:"hardware"`
+	Games    []string `json:"games"`
+	Status   string   `json:"status"`
+}
+
+// HasGame — установлена ли игра (без учёта регистра и пробелов).
+func (r Rig) HasGame(game string) bool {
+	want := normalizeGame(game)
+	if want == "" {
+		return true
+	}
+	for _, g := range r.Games {
+		if strings.Contains(normalizeGame(g), want) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeGame(s string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), " ", "")
+}
+
+// -----------------------------------------------------------------------------
+// SCHEDULED JOBS (отложенные задания планировщика)
+// -----------------------------------------------------------------------------
+
+// Статусы задания.
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed" // попытки кончились
+	JobCancelled = "cancelled"
+)
+
+// Виды заданий по броням.
+const (
+	JobBookingReminder = "booking_reminder"
+	JobBookingFollowUp = "booking_followup"
+	JobHoldExpiry      = "hold_expiry"
+)
+
+// JobHandoffIdle — вернуть боту диалог, в котором оператор долго молчит
+// (Ref — ID клиента).
+const JobHandoffIdle = "handoff_idle"
+
+// ScheduledJob — задание планировщика. Key уникален: повторное планирование
+// с тем же ключом переносит задание, а не создаёт второе. Ref — к чему
+// относится (ID брони), по нему задания отменяются пачкой. RunAt — в шкале
+// броней (availability.Now).
+type ScheduledJob struct {
+	ID          int64     `json:"id"`
+	Key         string    `json:"key"`
+	Kind        string    `json:"kind"`
+	Ref         string    `json:"ref,omitempty"`
+	Payload     string    `json:"payload,omitempty"` // JSON, по виду задания
+	RunAt       time.Time `json:"run_at"`
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	MaxAttempts int       `json:"max_attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// JobKey — ключ задания вида kind по объекту ref.
+func JobKey(kind, ref string) string {
+	return kind + ":" + ref
+}
+
+// JobFilter — отбор заданий для списка. Пустые поля не фильтруют.
+type JobFilter struct {
+	Ref    string
+	Kind   string
+	Status string
+	Limit  int
+}
+
+// JobKindLabel — вид задания по-русски.
+func JobKindLabel(kind string) string {
+	switch kind {
+	case JobBookingReminder:
+		return "напоминание"
+	case JobBookingFollowUp:
+		return "отзыв после сеанса"
+	case JobHoldExpiry:
+		return "снятие неоплаченной брони"
+	case JobHandoffIdle:
+		return "возврат диалога боту"
+	default:
+		return kind
+	}
+}
+
+// JobStatusLabel — статус задания по-русски.
+func JobStatusLabel(status string) string {
+	switch status {
+	case JobPending:
+		return "ждёт"
+	case JobRunning:
+		return "выполняется"
+	case JobDone:
+		return "выполнено"
+	case JobFailed:
+		return "не удалось"
+	case JobCancelled:
+		return "отменено"
+	default:
+		return status
+	}
+}
+
+// -----------------------------------------------------------------------------
+// OUTBOX (события шины, записанные вместе с изменением)
+// -----------------------------------------------------------------------------
+
+// Статусы записи outbox.
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxDead      = "dead" // попытки кончились
+)
+
+// OutboxEvent — событие, записанное в транзакции изменения и ждущее
+// доставки подписчикам. Payload — JSON события (пакет events).
+type OutboxEvent struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Payload   string    `json:"payload"`
+	Status    string    `json:"status"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ClientChannel — канал клиента по префиксу ID: telegram (TG-), whatsapp
+// (WA-); пусто — написать клиенту первым некуда (walk-in, служебные брони).
+func ClientChannel(clientID string) string {
+	switch {
+	case strings.HasPrefix(clientID, "TG-"):
+		return "telegram"
+	case strings.HasPrefix(clientID, "WA-"):
+		return "whatsapp"
+	}
+	return ""
+}
+
+// -----------------------------------------------------------------------------
+// WEBHOOKS (исходящие уведомления внешним системам)
+// -----------------------------------------------------------------------------
+
+// WebhookSubscriber — получатель исходящих вебхуков (бухгалтерия, CRM).
+// Events — имена событий («booking.cancelled»), маски «booking.*» или «*».
+type WebhookSubscriber struct {
+	Name   string   `yaml:"name"`
+	URL    string   `yaml:"url"`
+	Secret string   `yaml:"secret"` // ключ HMAC-SHA256 подписи тела
+	Events []string `yaml:"events"`
+}
+
+// Wants — подписан ли получатель на событие name.
+func (s WebhookSubscriber) Wants(name string) bool {
+	for _, f := range s.Events {
+		switch {
+		case f == "*", f == name:
+			return true
+		case strings.HasSuffix(f, ".*") && strings.HasPrefix(name, strings.TrimSuffix(f, "*")):
+			return true
+		}
+	}
+	return false
+}
+
+// Статусы доставки вебхука.
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookDead      = "dead" // попытки кончились — ждёт ручного повтора
+)
+
+// WebhookDelivery — доставка одного события одному получателю. EventID
+// общий у всех получателей события: по нему получатель отсеивает повторы.
+type WebhookDelivery struct {
+	ID            int64      `json:"id"`
+	Subscriber    string     `json:"subscriber"`
+	EventID       string     `json:"event_id"`
+	Event         string     `json:"event"`
+	Payload       string     `json:"payload"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	ResponseCode  int        `json:"response_code,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+}
+
+// WebhookFilter — выборка доставок для служебного API.
+type WebhookFilter struct {
+	Subscriber string
+	Event      string
+	Status     string
+	Limit      int
+}
+
+// -----------------------------------------------------------------------------
+// OUTBOUND MESSAGES (ответы в WhatsApp через Wazzup и их статусы доставки)
+// -----------------------------------------------------------------------------
+
+// Статусы исходящего сообщения. sent/delivered/read/error приходят вебхуком
+// statuses от Wazzup; failed — Wazzup так и не принял сообщение.
+const (
+	OutboundPending   = "pending" // отправляется
+	OutboundSent      = "sent"
+	OutboundDelivered = "delivered"
+	OutboundRead      = "read"
+	OutboundError     = "error"
+	OutboundFailed    = "failed"
+)
+
+// OutboundMessage — одно исходящее сообщение клиенту. CrmMessageID — наш
+// ключ идемпотентности при повторе отправки, ProviderID — messageId Wazzup,
+// по нему приходят статусы.
+type OutboundMessage struct {
+	ID           int64     `json:"id"`
+	CrmMessageID string    `json:"crm_message_id"`
+	ProviderID   string    `json:"provider_id,omitempty"`
+	ClientID     string    `json:"client_id"`
+	ChannelID    string    `json:"channel_id"`
+	Text         string    `json:"text,omitempty"`
+	ContentURI   string    `json:"content_uri,omitempty"`
+	Status       string    `json:"status"`
+	Error        string    `json:"error,omitempty"`
+	Attempts     int       `json:"attempts"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// OutboundStaleAfter — «отправлено» без подтверждения доставки дольше этого
+// считается недошедшим (телефон клиента выключен, номер не в WhatsApp).
+const OutboundStaleAfter = time.Hour
+
+// OutboundStatusLabel — статус для ответа администратору.
+func OutboundStatusLabel(status string) string {
+	switch status {
+	case OutboundPending:
+		return "отправляется"
+	case OutboundSent:
+		return "отправлено, не доставлено"
+	case OutboundDelivered:
+		return "доставлено"
+	case OutboundRead:
+		return "прочитано"
+	case OutboundError:
+		return "ошибка доставки"
+	case OutboundFailed:
+		return "не отправлено"
+	}
+	return status
+}
+
+// -----------------------------------------------------------------------------
+// INBOUND EVENTS (очередь входящих сообщений из вебхуков и long polling)
+// -----------------------------------------------------------------------------
+
+// Источники входящих событий.
+const (
+	InboundTelegram = "telegram"
+	InboundWazzup   = "wazzup"
+)
+
+// Статусы входящего события.
+const (
+	InboundPending    = "pending"
+	InboundProcessing = "processing"
+	InboundDone       = "done"
+	InboundDead       = "dead" // попытки кончились — остаётся для разбора
+)
+
+// Debounce — склейка подряд идущих сообщений клиента в один ход: ответ
+// готовится, когда клиент молчит Window, но не позже MaxWait после первого
+// сообщения. Window 0 — без склейки.
+type Debounce struct {
+	Window  time.Duration `yaml:"window"`
+	MaxWait time.Duration `yaml:"max_wait"`
+}
+
+// InboundEvent — сырое входящее событие. ExternalID — update_id Telegram,
+// messageId Wazzup или (старый формат без messageId) хеш чата, времени и
+// текста: повторная доставка того же события отбрасывается.
+// События одного ClientID обрабатываются строго по очереди.
+type InboundEvent struct {
+	ID            int64      `json:"id"`
+	Source        string     `json:"source"`
+	ExternalID    string     `json:"external_id"`
+	ClientID      string     `json:"client_id"`
+	Payload       string     `json:"payload"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	ReceivedAt    time.Time  `json:"received_at"`
+	ProcessedAt   *time.Time `json:"processed_at,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// HANDOFF (диалог передан живому оператору)
+// -----------------------------------------------------------------------------
+
+// SenderOperator — sender сообщений, которые оператор написал клиенту через
+// бота (/reply).
+const SenderOperator = "operator"
+
+// Кто вернул диалог боту.
+const (
+	HandoffEndedByOperator = "operator" // /release
+	HandoffEndedByTimeout  = "timeout"  // оператор долго не отвечал
+)
+
+// Handoff — период, когда клиенту отвечает человек, а бот молчит.
+// LastOperatorAt — последний ответ оператора (или начало): от него
+// считается простой до автоматического возврата боту.
+type Handoff struct {
+	ID             int64      `json:"id"`
+	ClientID       string     `json:"client_id"`
+	Reason         string     `json:"reason"`
+	StartedAt      time.Time  `json:"started_at"`
+	LastOperatorAt time.Time  `json:"last_operator_at"`
+	EndedAt        *time.Time `json:"ended_at,omitempty"`
+	EndedBy        string     `json:"ended_by,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// BOOKING DRAFT (слоты брони в текущей сессии клиента)
+// -----------------------------------------------------------------------------
+
+// BookingDraft — что клиент уже сообщил по брони. Скрипт продаж:
+// Места → Время → Часы → Бронирование. Пустое значение = слот не заполнен.
+type BookingDraft struct {
+	ClientID   string    `json:"client_id"`
+	SessionID  int64     `json:"session_id"`
+	Seats      int       `json:"seats,omitempty"`
+	Date       string    `json:"date,omitempty"`       // YYYY-MM-DD
+	StartTime  string    `json:"start_time,omitempty"` // HH:MM
+	Hours      int       `json:"hours,omitempty"`
+	PriceQuote float64   `json:"price_quote,omitempty"`
+	BookingID  string    `json:"booking_id,omitempty"`
+	PromoCode  string    `json:"promo_code,omitempty"` // принят ApplyPromoCode, ещё не погашен
+	Game       string    `json:"game,omitempty"`       // для подбора ПК
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Button — кнопка под сообщением бота; Data возвращается при нажатии
+// (в Telegram — callback_data, не длиннее 64 байт).
+type Button struct {
+	Text string `json:"text"`
+	Data string `json:"data"`
+}
+
+// Keyboard — ряды кнопок; nil — сообщение без кнопок.
+type Keyboard [][]Button
+
+// BotReply — ответ бота с кнопками выбора.
+type BotReply struct {
+	Text     string
+	Keyboard Keyboard
+}
+
+// MissingSlots — незаполненные слоты в порядке скрипта продаж.
+func (d *BookingDraft) MissingSlots() []string {
+	var out []string
+	if d.Seats == 0 {
+		out = append(out, "seats")
+	}
+	if d.Date == "" {
+		out = append(out, "date")
+	}
+	if d.StartTime == "" {
+		out = append(out, "time")
+	}
+	if d.Hours == 0 {
+		out = append(out, "hours")
+	}
+	return out
+}
+
+// IsEmpty — клиент ещё ничего не сообщил.
+func (d *BookingDraft) IsEmpty() bool {
+	return d.Seats == 0 && d.Date == "" && d.StartTime == "" && d.Hours == 0 &&
+		d.PriceQuote == 0 && d.BookingID == "" && d.PromoCode == ""
 }
 
 // -----------------------------------------------------------------------------
@@ -0,0 +1,104 @@
+package pricing
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// LOYALTY TIERS
+// -----------------------------------------------------------------------------
+
+// LoyaltyTier — уровень клиента по сумме завершённых броней.
+type LoyaltyTier struct {
+	Name            string  `yaml:"name" json:"name"`
+	MinSpent        float64 `yaml:"min_spent" json:"min_spent"`
+	DiscountPercent float64 `yaml:"discount_percent" json:"discount_percent"`
+}
+
+// StandardTier — уровень без скидки (ниже всех порогов или уровни не заданы).
+const StandardTier = "Standard"
+
+// TierFor — наивысший уровень, порог которого достигнут.
+func (t Tariff) TierFor(spent float64) LoyaltyTier {
+	best := LoyaltyTier{Name: StandardTier}
+	for _, tier := range t.Loyalty {
+		if spent >= tier.MinSpent && tier.MinSpent >= best.MinSpent {
+			best = tier
+		}
+	}
+	return best
+}
+
+// ApplyLoyalty — скидка уровня клиента к расчёту.
+func ApplyLoyalty(q *models.Quote, tier LoyaltyTier) {
+	if tier.DiscountPercent <= 0 || q.Total <= 0 {
+		return
+	}
+	q.AddDiscount(
+		fmt.Sprintf("Уровень %s −%.0f%%", tier.Name, tier.DiscountPercent),
+		round(q.Total*tier.DiscountPercent/100),
+	)
+}
+
+// -----------------------------------------------------------------------------
+// PROMO CODES
+// -----------------------------------------------------------------------------
+
+// CheckPromo — можно ли применить код сейчас с учётом погашений
+// (всего и этим клиентом).
+func CheckPromo(p *models.PromoCode, now time.Time, used, usedByClient int) error {
+	switch {
+	case p == nil:
+		return fmt.Errorf("промокод не найден")
+	case !p.ValidFrom.IsZero() && now.Before(p.ValidFrom):
+		return fmt.Errorf("промокод %s действует с %s", p.Code, p.ValidFrom.Format("2006-01-02"))
+	case !p.ValidTo.IsZero() && !now.Before(p.ValidTo):
+		return fmt.Errorf("срок действия промокода %s истёк", p.Code)
+	case p.MaxUses > 0 && used >= p.MaxUses:
+		return fmt.Errorf("промокод %s: %w", p.Code, models.ErrPromoExhausted)
+	case p.PerClientLimit > 0 && usedByClient >= p.PerClientLimit:
+		return fmt.Errorf("промокод %s уже использован", p.Code)
+	}
+	return nil
+}
+
+// ApplyPromo — скидка по промокоду (после уровня лояльности). Фиксированная
+// скидка не больше итога. Возвращает сумму скидки.
+func ApplyPromo(q *models.Quote, p *models.PromoCode) float64 {
+	if p == nil || q.Total <= 0 {
+		return 0
+	}
+
+	var amount float64
+	var label string
+	switch p.Kind {
+	case models.PromoFixed:
+		amount = math.Min(p.Value, q.Total)
+		label = fmt.Sprintf("Промокод %s", p.Code)
+	default:
+		amount = round(q.Total * p.Value / 100)
+		label = fmt.Sprintf("Промокод %s −%.0f%%", p.Code, p.Value)
+	}
+	if amount <= 0 {
+		return 0
+	}
+
+	q.AddDiscount(label, amount)
+	return amount
+}
+
+// PromoSummary — условия кода одной строкой.
+func PromoSummary(p *models.PromoCode, currency string) string {
+	s := fmt.Sprintf("%s: −%.0f%%", p.Code, p.Value)
+	if p.Kind == models.PromoFixed {
+		s = fmt.Sprintf("%s: −%.0f %s", p.Code, p.Value, currency)
+	}
+	if !p.ValidTo.IsZero() {
+		s += ", до " + p.ValidTo.Add(-time.Nanosecond).Format("2006-01-02")
+	}
+	return s
+}
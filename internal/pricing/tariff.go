@@ -0,0 +1,226 @@
+package pricing
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"whatsapp-analytics-mvp/internal/availability"
+	"whatsapp-analytics-mvp/internal/models"
+)
+
+// -----------------------------------------------------------------------------
+// TARIFF RULES
+// -----------------------------------------------------------------------------
+
+// Band — надбавка/скидка по времени суток. Интервал [From, To) может
+// переходить через полночь (22:00–04:00).
+type Band struct {
+	Name       string  `yaml:"name" json:"name"`
+	From       string  `yaml:"from" json:"from"` // HH:MM
+	To         string  `yaml:"to" json:"to"`     // HH:MM
+	Multiplier float64 `yaml:"multiplier" json:"multiplier"`
+}
+
+// Package — скидка за длительность: от MinHours часов −DiscountPercent%.
+type Package struct {
+	Name            string  `yaml:"name" json:"name"`
+	MinHours        int     `yaml:"min_hours" json:"min_hours"`
+	DiscountPercent float64 `yaml:"discount_percent" json:"discount_percent"`
+}
+
+// Tariff — набор правил цены за место-час.
+type Tariff struct {
+	Currency     string    `yaml:"currency" json:"currency"`
+	WeekdayRate  float64   `yaml:"weekday_rate" json:"weekday_rate"`
+	WeekendRate  float64   `yaml:"weekend_rate" json:"weekend_rate"` // 0 → как в будни
+	HolidayRate  float64   `yaml:"holiday_rate" json:"holiday_rate"` // 0 → как в выходной
+	Bands        []Band    `yaml:"bands" json:"bands"`
+	Packages     []Package `yaml:"packages" json:"packages"`
+	Holidays     []string  `yaml:"holidays" json:"holidays"` // YYYY-MM-DD
+	MinimumSpend float64   `yaml:"minimum_spend" json:"minimum_spend"`
+
+	// Уровни лояльности по сумме завершённых броней
+	Loyalty []LoyaltyTier `yaml:"loyalty" json:"loyalty"`
+}
+
+// Default — прежняя тарифная логика: 2000 тг за место-час, ночью ×1.25.
+func Default() Tariff {
+	return Tariff{
+		Currency:    "тг",
+		WeekdayRate: 2000,
+		Bands: []Band{
+			{Name: "Ночной тариф", From: "22:00", To: "04:00", Multiplier: 1.25},
+		},
+		Loyalty: []LoyaltyTier{
+			{Name: "Silver", MinSpent: 50000, DiscountPercent: 5},
+			{Name: "Gold", MinSpent: 150000, DiscountPercent: 10},
+		},
+	}
+}
+
+// Validate — базовая проверка правил из конфига/БД.
+func (t Tariff) Validate() error {
+	if t.WeekdayRate <= 0 {
+		return fmt.Errorf("pricing: weekday_rate должен быть > 0")
+	}
+	for _, b := range t.Bands {
+		if _, err := minuteOfDay(b.From); err != nil {
+			return fmt.Errorf("pricing: band %q: %w", b.Name, err)
+		}
+		if _, err := minuteOfDay(b.To); err != nil {
+			return fmt.Errorf("pricing: band %q: %w", b.Name, err)
+		}
+	}
+	for _, l := range t.Loyalty {
+		if l.DiscountPercent < 0 || l.DiscountPercent >= 100 {
+			return fmt.Errorf("pricing: loyalty %q: скидка должна быть 0–99%%", l.Name)
+		}
+	}
+	for _, d := range t.Holidays {
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			return fmt.Errorf("pricing: неверная дата праздника %q", d)
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// QUOTE
+// -----------------------------------------------------------------------------
+
+// Quote — детализированный расчёт. Считается по часам: каждый час берёт
+// ставку своего рабочего дня (ночь после пятницы — это ещё пятница)
+// и надбавку своей временной полосы.
+func (t Tariff) Quote(start time.Time, seats, hours int) models.Quote {
+	q := models.Quote{
+		Start:    start,
+		Seats:    seats,
+		Hours:    hours,
+		Currency: t.Currency,
+		DayType:  t.dayType(availability.BusinessDay(start)),
+	}
+
+	bandExtra := map[string]float64{}
+	var bandOrder []string
+
+	for h := 0; h < hours; h++ {
+		at := start.Add(time.Duration(h) * time.Hour)
+		rate := t.rate(availability.BusinessDay(at)) * float64(seats)
+		q.Base += rate
+
+		if b, ok := t.band(at); ok && b.Multiplier != 1 {
+			label := fmt.Sprintf("%s (%s–%s) %s", b.Name, b.From, b.To, percent(b.Multiplier-1))
+			if _, seen := bandExtra[label]; !seen {
+				bandOrder = append(bandOrder, label)
+			}
+			bandExtra[label] += rate * (b.Multiplier - 1)
+		}
+	}
+	q.Recalc()
+
+	for _, label := range bandOrder {
+		if amount := round(bandExtra[label]); amount > 0 {
+			q.AddModifier(label, amount)
+		} else if amount < 0 {
+			q.AddDiscount(label, -amount)
+		}
+	}
+
+	if p, ok := t.bestPackage(hours); ok {
+		q.AddDiscount(
+			fmt.Sprintf("%s (от %d ч) −%.0f%%", p.Name, p.MinHours, p.DiscountPercent),
+			round(q.Total*p.DiscountPercent/100),
+		)
+	}
+
+	if t.MinimumSpend > 0 && q.Total < t.MinimumSpend {
+		q.AddModifier("Доплата до минимального чека", round(t.MinimumSpend-q.Total))
+	}
+
+	return q
+}
+
+func (t Tariff) dayType(day time.Time) string {
+	d := day.Format("2006-01-02")
+	for _, h := range t.Holidays {
+		if h == d {
+			return "праздник"
+		}
+	}
+	if wd := day.Weekday(); wd == time.Saturday || wd == time.Sunday {
+		return "выходной"
+	}
+	return "будний"
+}
+
+func (t Tariff) rate(day time.Time) float64 {
+	weekend := t.WeekendRate
+	if weekend <= 0 {
+		weekend = t.WeekdayRate
+	}
+	holiday := t.HolidayRate
+	if holiday <= 0 {
+		holiday = weekend
+	}
+
+	switch t.dayType(day) {
+	case "праздник":
+		return holiday
+	case "выходной":
+		return weekend
+	default:
+		return t.WeekdayRate
+	}
+}
+
+// band — полоса, в которую попадает начало часа (первая подходящая).
+func (t Tariff) band(at time.Time) (Band, bool) {
+	m := at.Hour()*60 + at.Minute()
+	for _, b := range t.Bands {
+		from, err1 := minuteOfDay(b.From)
+		to, err2 := minuteOfDay(b.To)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		if from <= to && m >= from && m < to {
+			return b, true
+		}
+		if from > to && (m >= from || m < to) { // через полночь
+			return b, true
+		}
+	}
+	return Band{}, false
+}
+
+// bestPackage — пакет с наибольшей скидкой из подходящих по длительности.
+func (t Tariff) bestPackage(hours int) (Package, bool) {
+	var best Package
+	found := false
+	for _, p := range t.Packages {
+		if hours >= p.MinHours && p.DiscountPercent > best.DiscountPercent {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+func minuteOfDay(hhmm string) (int, error) {
+	tm, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return 0, fmt.Errorf("неверное время %q (нужно HH:MM)", hhmm)
+	}
+	return tm.Hour()*60 + tm.Minute(), nil
+}
+
+// percent — "+25%" / "−20%".
+func percent(delta float64) string {
+	if delta < 0 {
+		return fmt.Sprintf("−%.0f%%", -delta*100)
+	}
+	return fmt.Sprintf("+%.0f%%", delta*100)
+}
+
+func round(v float64) float64 {
+	return math.Round(v)
+}
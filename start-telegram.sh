@@ -32,10 +32,10 @@ mkdir -p db
 
 echo "🚀 Starting server on port 8080..."
 echo ""
-echo "📝 Next steps:"
-echo "1. In another terminal, run: ngrok http 8080"
-echo "2. Copy the ngrok HTTPS URL"
-echo "3. Set webhook: curl -X POST \"https://api.telegram.org/bot\$TELEGRAM_TOKEN/setWebhook?url=https://YOUR_NGROK_URL/webhook/telegram\""
+echo "📝 Telegram delivery (configs/config.yaml, section telegram):"
+echo "  mode: polling  — no public URL needed, the bot reads getUpdates itself"
+echo "  mode: webhook  — run ngrok http 8080 and set webhook_url: https://YOUR_NGROK_URL/webhook/telegram;"
+echo "                   the webhook is registered and its status logged on startup"
 echo ""
 echo "Press Ctrl+C to stop the server"
 echo "========================================"